@@ -0,0 +1,602 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// tableArguments are the tool arguments naming a table the tool reads.
+var tableArguments = []string{"table", "table_name", "parent_table", "child_table"}
+
+// queryArguments are the tool arguments holding SQL the tool runs; queries
+// is a list.
+var queryArguments = []string{"query", "first", "second", "queries", "where"}
+
+// errUncheckedTables is returned when the tables a query reads cannot be
+// determined, which a role with table restrictions must not risk.
+var errUncheckedTables = errors.New("the tables read by the query cannot be determined")
+
+// tablesKey is a context key for the tables a tool call reads.
+type tablesKey struct{}
+
+// maskAllKey is a context key set when a tool call's queries read a masked
+// column, or might, so that every result column is masked.
+type maskAllKey struct{}
+
+// tablesFromContext returns the lowercased tables the tool call reads, or
+// nil when they are unknown.
+func tablesFromContext(ctx context.Context) []string {
+	tables, _ := ctx.Value(tablesKey{}).([]string)
+	return tables
+}
+
+// accessMiddleware refuses tool calls reading tables or columns outside the
+// caller's role and records the tables read for column masking. Table
+// arguments are checked on every database; queries are compiled with
+// EXPLAIN on SQLite to find the tables and columns they read, and refused
+// elsewhere when the role restricts reads. Tools other than the schema
+// tools cannot be pointed at a table with hidden columns, since they read
+// every column.
+func (ds *DatabaseService) accessMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		profile := profileFromContext(ctx)
+		call := auditCallFromContext(ctx)
+		if profile == nil || !profile.restrictsReads() && !profile.masksColumns() {
+			if call != nil {
+				// Only for the audit log: queries that cannot be analysed are left to fail in the handler
+				if access, _ := ds.tablesAccessed(ctx, request.GetArguments()); access != nil {
+					call.tables, call.columns = access.tables, access.columns
+				}
+			}
+			return next(ctx, request)
+		}
+		access, err := ds.tablesAccessed(ctx, request.GetArguments())
+		if call != nil {
+			call.tables, call.columns = access.tables, access.columns
+		}
+		if err != nil && (profile.restrictsReads() || !errors.Is(err, errUncheckedTables)) {
+			log.Printf("Rejected %s call for role %s: %v", request.Params.Name, profile.Name, err)
+			return accessDeniedResult(profile, err.Error()), nil
+		}
+		deny := func(reason string) (*mcp.CallToolResult, error) {
+			log.Printf("Rejected %s call for role %s: %s", request.Params.Name, profile.Name, reason)
+			return accessDeniedResult(profile, reason), nil
+		}
+		for _, table := range access.tables {
+			if !profile.readsTable(table) {
+				return deny(fmt.Sprintf("the %s role may not read table %s", profile.Name, table))
+			}
+		}
+		if c := profile.Classification; c != nil {
+			for _, table := range access.named {
+				if c.hidesColumns(table) && !schemaTools[request.Params.Name] {
+					return deny(fmt.Sprintf("table %s has columns hidden from the %s role; select the other columns with read_query", table, profile.Name))
+				}
+			}
+			for table, columns := range access.columns {
+				for column := range columns {
+					if c.columnAction(table, column) == actionHide {
+						return deny(fmt.Sprintf("column %s.%s is hidden from the %s role", table, column, profile.Name))
+					}
+				}
+			}
+		}
+		// A condition on a masked column would reveal its values through the
+		// rows it keeps, whatever the result shows
+		for table, columns := range access.condition {
+			for column := range columns {
+				if profile.maskFor([]string{table}, column) != "" {
+					return deny(fmt.Sprintf("the 'where' condition reads column %s.%s, which is masked for the %s role", table, column, profile.Name))
+				}
+			}
+		}
+		if where, _ := request.GetArguments()["where"].(string); errors.Is(err, errUncheckedTables) && strings.TrimSpace(where) != "" {
+			return deny(fmt.Sprintf("the 'where' condition cannot be checked against the columns masked for the %s role on this database", profile.Name))
+		}
+		if len(profile.RowFilters) > 0 {
+			if queries, ok := request.GetArguments()["queries"].([]interface{}); ok {
+				ctx = context.WithValue(ctx, sentQueriesKey{}, slices.Clone(queries))
+			}
+			if reason := ds.filterRows(ctx, profile, request, access); reason != "" {
+				return deny(reason)
+			}
+		}
+		if err == nil {
+			ctx = context.WithValue(ctx, tablesKey{}, access.tables)
+		}
+		if errors.Is(err, errUncheckedTables) || profile.readsMaskedColumn(access) {
+			// Result column names may be aliases or expressions of the masked column
+			ctx = context.WithValue(ctx, maskAllKey{}, true)
+		}
+		return next(ctx, request)
+	}
+}
+
+// accessDeniedResult builds the error returned when a call reads tables
+// outside the caller's role.
+func accessDeniedResult(profile *Profile, reason string) *mcp.CallToolResult {
+	hint := "Use list_tables and describe_table to see the data available to you."
+	if profile.Tables != nil {
+		hint = fmt.Sprintf("The %s role may read: %s.", profile.Name, strings.Join(profile.Tables, ", "))
+	}
+	payload, _ := json.MarshalIndent(map[string]string{
+		"error":   "access_denied",
+		"message": "Access denied: " + reason + ".",
+		"hint":    hint,
+	}, "", "  ")
+	return mcp.NewToolResultError(string(payload))
+}
+
+// queryAccess is what a tool call reads.
+type queryAccess struct {
+	tables    []string                   // Every table read, lowercased and qualified by their schema unless in main
+	named     []string                   // Tables named by table arguments
+	columns   map[string]map[string]bool // Columns read by queries, by table
+	condition map[string]map[string]bool // Columns read by the 'where' condition and not by the source it filters
+}
+
+func (a *queryAccess) addTable(table string) {
+	for _, t := range a.tables {
+		if t == table {
+			return
+		}
+	}
+	a.tables = append(a.tables, table)
+}
+
+func (a *queryAccess) addColumn(table, column string) {
+	if a.columns[table] == nil {
+		a.columns[table] = make(map[string]bool)
+	}
+	a.columns[table][column] = true
+}
+
+// tablesAccessed returns the tables named or queried by the arguments of a
+// tool call and the columns the queries read.
+func (ds *DatabaseService) tablesAccessed(ctx context.Context, args map[string]interface{}) (*queryAccess, error) {
+	access := &queryAccess{tables: []string{}, columns: make(map[string]map[string]bool)}
+	schema, _ := args["schema"].(string)
+	for _, name := range tableArguments {
+		if table, _ := args[name].(string); table != "" {
+			if schema != "" && !strings.Contains(table, ".") {
+				table = schema + "." + table
+			}
+			table = ds.accessName(table)
+			access.addTable(table)
+			access.named = append(access.named, table)
+		}
+	}
+	for _, name := range queryArguments {
+		values, ok := args[name].([]interface{})
+		if !ok {
+			values = []interface{}{args[name]}
+		}
+		for _, v := range values {
+			query, _ := v.(string)
+			if query == "" {
+				continue
+			}
+			if _, ok := ds.dialect.(sqliteDialect); !ok {
+				return access, errUncheckedTables
+			}
+			if name == "where" {
+				if err := ds.conditionAccess(ctx, args, query, access); err != nil {
+					return access, err
+				}
+				continue
+			}
+			if err := ds.sqliteQueryAccess(ctx, query, access); err != nil {
+				return access, err
+			}
+		}
+	}
+	return access, nil
+}
+
+// conditionSource returns the table or query the 'where' condition of a
+// call filters and the alias the condition may refer to it by, or "" when
+// the call has neither.
+func (ds *DatabaseService) conditionSource(args map[string]interface{}) (source, alias string, err error) {
+	if table, _ := args["table"].(string); table != "" {
+		_, name := ds.dialect.SplitTable(table)
+		return ds.quoteTable(table), quoteIdentifier(name), nil
+	}
+	if query, _ := args["query"].(string); query != "" {
+		if err := requireSingleStatement(query); err != nil {
+			return "", "", err
+		}
+		return "(" + strings.TrimRight(strings.TrimSpace(query), "; \t\n") + "\n)", "src", nil
+	}
+	return "", "", nil
+}
+
+// conditionAccess adds what the 'where' condition of a call reads to access.
+// The condition is compiled against the real source, so that its column
+// references resolve to the columns of the tables underneath; the columns
+// it reads beyond those the unfiltered source reads are its own, recorded
+// in access.condition.
+func (ds *DatabaseService) conditionAccess(ctx context.Context, args map[string]interface{}, condition string, access *queryAccess) error {
+	if err := checkCondition(condition); err != nil {
+		return err
+	}
+	source, alias, err := ds.conditionSource(args)
+	if err != nil {
+		return err
+	}
+	unfiltered := &queryAccess{tables: []string{}, columns: make(map[string]map[string]bool)}
+	filtered := &queryAccess{tables: []string{}, columns: make(map[string]map[string]bool)}
+	query := "SELECT 1 WHERE (" + condition + "\n)"
+	if source != "" {
+		if err := ds.sqliteQueryAccess(ctx, fmt.Sprintf("SELECT 1 FROM %s AS %s", source, alias), unfiltered); err != nil {
+			return err
+		}
+		query = fmt.Sprintf("SELECT 1 FROM %s AS %s WHERE (%s\n)", source, alias, condition)
+	}
+	if err := ds.sqliteQueryAccess(ctx, query, filtered); err != nil {
+		return err
+	}
+
+	for _, table := range filtered.tables {
+		access.addTable(table)
+	}
+	for table, columns := range filtered.columns {
+		for column := range columns {
+			access.addColumn(table, column)
+			if !unfiltered.columns[table][column] {
+				if access.condition == nil {
+					access.condition = make(map[string]map[string]bool)
+				}
+				if access.condition[table] == nil {
+					access.condition[table] = make(map[string]bool)
+				}
+				access.condition[table][column] = true
+			}
+		}
+	}
+	return nil
+}
+
+// conditionQuery turns the 'where' condition of a call into a query reading
+// only the tables the condition reads: the table or query the call reads is
+// replaced by a row of NULLs with the same columns, so that the condition's
+// column references still resolve.
+func (ds *DatabaseService) conditionQuery(ctx context.Context, args map[string]interface{}, condition string) (string, error) {
+	if err := checkCondition(condition); err != nil {
+		return "", err
+	}
+	source, alias, err := ds.conditionSource(args)
+	if err != nil {
+		return "", err
+	}
+	if source == "" {
+		return "SELECT 1 WHERE (" + condition + "\n)", nil
+	}
+
+	conn, release, err := ds.sessionConn(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	rows, err := conn.QueryContext(ctx, "SELECT * FROM "+source+" LIMIT 0")
+	if err != nil {
+		return "", err
+	}
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return "", err
+	}
+	nulls := make([]string, len(columns))
+	for i, column := range columns {
+		nulls[i] = "NULL AS " + quoteIdentifier(column)
+	}
+	return fmt.Sprintf("SELECT 1 FROM (SELECT %s) AS %s WHERE (%s\n)", strings.Join(nulls, ", "), alias, condition), nil
+}
+
+// accessName returns the lowercased name of a table argument, qualified by
+// its schema unless it is in main.
+func (ds *DatabaseService) accessName(table string) string {
+	schema, name := ds.dialect.SplitTable(table)
+	name = strings.ToLower(strings.Trim(name, "\"`[]"))
+	if schema == "" || schema == "main" {
+		return name
+	}
+	return strings.ToLower(schema) + "." + name
+}
+
+// sqliteOpcodes are the VDBE opcodes of the bundled SQLite. An EXPLAIN
+// listing with any other opcode is not trusted.
+var sqliteOpcodes = toSet(strings.Fields(`Savepoint AutoCommit Transaction Checkpoint JournalMode Vacuum
+	VFilter VUpdate Init Goto Gosub InitCoroutine Yield MustBeInt Jump Once If IfNot IsType Not IfNullRow
+	SeekLT SeekLE SeekGE SeekGT IfNotOpen IfNoHope NoConflict NotFound Found SeekRowid NotExists Last
+	IfSizeBetween SorterSort Sort Rewind SorterNext Prev Next IdxLE IdxGT IdxLT Or And IdxGE RowSetRead
+	RowSetTest Program FkIfZero IfPos IsNull NotNull Ne Eq Gt Le Lt Ge ElseEq IfNotZero DecrJumpZero
+	IncrVacuum VNext Filter PureFunc Function Return EndCoroutine HaltIfNull Halt Integer Int64 String
+	BeginSubrtn Null SoftNull Blob Variable Move Copy SCopy IntCopy FkCheck ResultRow CollSeq AddImm
+	RealAffinity Cast Permutation Compare IsTrue ZeroOrNull Offset Column TypeCheck Affinity MakeRecord
+	Count ReadCookie SetCookie ReopenIdx OpenRead BitAnd BitOr ShiftLeft ShiftRight Add Subtract Multiply
+	Divide Remainder Concat OpenWrite OpenDup BitNot OpenAutoindex OpenEphemeral String8 SorterOpen
+	SequenceTest OpenPseudo Close ColumnsUsed SeekScan SeekHit Sequence NewRowid Insert RowCell Delete
+	ResetCount SorterCompare SorterData RowData Rowid NullRow SeekEnd IdxInsert SorterInsert IdxDelete
+	DeferredSeek IdxRowid FinishSeek Destroy Clear ResetSorter CreateBtree SqlExec ParseSchema
+	LoadAnalysis DropTable DropIndex DropTrigger Real IntegrityCk RowSetAdd Param FkCounter MemMax
+	OffsetLimit AggInverse AggStep AggStep1 AggValue AggFinal Expire CursorLock CursorUnlock TableLock
+	VBegin VCreate VDestroy VOpen VCheck VInitIn VColumn VRename Pagecount MaxPgcnt ClrSubtype GetSubtype
+	SetSubtype FilterAdd Trace CursorHint ReleaseReg Noop Explain Abortable`))
+
+// sqliteQueryAccess compiles query with EXPLAIN and adds the tables and
+// columns it reads to access. Tables and indexes are opened by OpenRead with
+// the cursor in p1, the root page in p2 and the database in p3; Column reads
+// column p2 of cursor p1 and Rowid its rowid. Virtual tables are opened by
+// VOpen, which does not name them, so queries on them cannot be checked.
+func (ds *DatabaseService) sqliteQueryAccess(ctx context.Context, query string, access *queryAccess) error {
+	// EXPLAIN only compiles the first statement, while the driver runs them all
+	if err := requireSingleStatement(query); err != nil {
+		return err
+	}
+	conn, release, err := ds.sessionConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	rows, err := conn.QueryContext(ctx, "EXPLAIN "+strings.TrimRight(strings.TrimSpace(query), "; \t\n"))
+	if err != nil {
+		return err
+	}
+	// Anything but a program listing means the query was not what it seemed
+	if columns, err := rows.Columns(); err != nil || strings.Join(columns, ",") != "addr,opcode,p1,p2,p3,p4,p5,comment" {
+		rows.Close()
+		return fmt.Errorf("%w: EXPLAIN did not return a program", errUncheckedTables)
+	}
+	listed := false
+	type rootPage struct{ db, page int64 }
+	type columnRead struct{ cursor, column int64 } // column -1 is the rowid
+	cursors := make(map[int64]rootPage)
+	var reads []columnRead
+	for rows.Next() {
+		var addr, p1, p2, p3, p5 int64
+		var opcode string
+		var p4, comment sql.NullString
+		if err := rows.Scan(&addr, &opcode, &p1, &p2, &p3, &p4, &p5, &comment); err != nil {
+			rows.Close()
+			return err
+		}
+		if !sqliteOpcodes[opcode] {
+			rows.Close()
+			return fmt.Errorf("%w: unknown opcode %q", errUncheckedTables, opcode)
+		}
+		listed = true
+		switch opcode {
+		case "OpenRead", "OpenWrite", "ReopenIdx":
+			cursors[p1] = rootPage{db: p3, page: p2}
+		case "Column":
+			reads = append(reads, columnRead{cursor: p1, column: p2})
+		case "Rowid", "IdxRowid":
+			reads = append(reads, columnRead{cursor: p1, column: -1})
+		case "VOpen":
+			rows.Close()
+			return fmt.Errorf("%w: it reads a virtual table", errUncheckedTables)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if !listed {
+		return fmt.Errorf("%w: EXPLAIN did not return a program", errUncheckedTables)
+	}
+
+	databases := make(map[int64]string)
+	dbRows, err := conn.QueryContext(ctx, "PRAGMA database_list")
+	if err != nil {
+		return err
+	}
+	for dbRows.Next() {
+		var seq int64
+		var name string
+		var file sql.NullString
+		if err := dbRows.Scan(&seq, &name, &file); err != nil {
+			dbRows.Close()
+			return err
+		}
+		databases[seq] = name
+	}
+	dbRows.Close()
+
+	// Each root page is a table or an index, with its columns in cursor order
+	type object struct {
+		table   string
+		columns []string
+		rowid   string // Column the rowid is read as: the INTEGER PRIMARY KEY, or rowid
+	}
+	objects := make(map[rootPage]*object)
+	for _, p := range cursors {
+		if objects[p] != nil {
+			continue
+		}
+		schema, ok := databases[p.db]
+		if !ok {
+			return errUncheckedTables
+		}
+		obj := &object{table: "sqlite_schema", rowid: "rowid"}
+		if p.page != 1 {
+			var kind, name, table string
+			query := fmt.Sprintf("SELECT type, name, tbl_name FROM %s.sqlite_schema WHERE rootpage = ?", quoteIdentifier(schema))
+			if err := conn.QueryRowContext(ctx, query, p.page).Scan(&kind, &name, &table); err != nil {
+				return fmt.Errorf("%w: root page %d of %s: %v", errUncheckedTables, p.page, schema, err)
+			}
+			if obj.columns, obj.rowid, err = sqliteObjectColumns(ctx, conn, schema, kind, name, table); err != nil {
+				return err
+			}
+			obj.table = strings.ToLower(table)
+			if schema != "main" {
+				obj.table = strings.ToLower(schema) + "." + obj.table
+			}
+		}
+		objects[p] = obj
+		access.addTable(obj.table)
+	}
+	for _, r := range reads {
+		p, ok := cursors[r.cursor]
+		if !ok {
+			continue // Ephemeral tables and sorters hold values read already
+		}
+		obj := objects[p]
+		switch {
+		case r.column < 0:
+			access.addColumn(obj.table, obj.rowid)
+		case int(r.column) < len(obj.columns):
+			access.addColumn(obj.table, obj.columns[r.column])
+		}
+	}
+	return nil
+}
+
+// sqliteObjectColumns returns the lowercased columns of a table or index in
+// storage order, and the column its rowid is read as.
+func sqliteObjectColumns(ctx context.Context, conn *sql.Conn, schema, kind, name, table string) ([]string, string, error) {
+	var columns []string
+	rowid := "rowid"
+	// The INTEGER PRIMARY KEY of the table is its rowid
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("PRAGMA %s.table_xinfo(%s)", quoteIdentifier(schema), quoteIdentifier(table)))
+	if err != nil {
+		return nil, "", err
+	}
+	var pkColumns []string
+	var pkType string
+	for rows.Next() {
+		var cid, notNull, pk, hidden int64
+		var column, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &column, &colType, &notNull, &dflt, &pk, &hidden); err != nil {
+			rows.Close()
+			return nil, "", err
+		}
+		columns = append(columns, strings.ToLower(column))
+		if pk > 0 {
+			pkColumns = append(pkColumns, strings.ToLower(column))
+			pkType = colType
+		}
+	}
+	rows.Close()
+	if len(pkColumns) == 1 && strings.EqualFold(pkType, "INTEGER") {
+		rowid = pkColumns[0]
+	}
+	if kind != "index" {
+		return columns, rowid, rows.Err()
+	}
+
+	columns = nil
+	rows, err = conn.QueryContext(ctx, fmt.Sprintf("PRAGMA %s.index_xinfo(%s)", quoteIdentifier(schema), quoteIdentifier(name)))
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seqno, cid, desc, key int64
+		var column, collation sql.NullString
+		if err := rows.Scan(&seqno, &cid, &column, &desc, &collation, &key); err != nil {
+			return nil, "", err
+		}
+		// Expressions have no name and the trailing rowid is NULL too
+		switch {
+		case column.Valid:
+			columns = append(columns, strings.ToLower(column.String))
+		case cid == -1:
+			columns = append(columns, rowid)
+		default:
+			columns = append(columns, "")
+		}
+	}
+	return columns, rowid, rows.Err()
+}
+
+// columnMasks masks the values of result columns: columns masked by the
+// caller's role get their masking method, every other column is redacted
+// when the call's queries read a masked column under another name, and the
+// personal data found in other values is redacted when PII_REDACTION is on. A nil *columnMasks
+// leaves values as they are.
+type columnMasks struct {
+	methods []string // Masking method by column index; "" leaves the column to pii
+	pii     *piiRedactor
+}
+
+// masksFor returns the masks applied to columns of the result of the
+// current tool call, or nil if there are none.
+func masksFor(ctx context.Context, columns []string) *columnMasks {
+	m := &columnMasks{pii: piiFromContext(ctx)}
+	if profile := profileFromContext(ctx); profile != nil && profile.masksColumns() {
+		tables := tablesFromContext(ctx)
+		all, _ := ctx.Value(maskAllKey{}).(bool)
+		methods := make([]string, len(columns))
+		for i, column := range columns {
+			if methods[i] = profile.maskFor(tables, column); methods[i] == "" && all {
+				methods[i] = maskRedact
+			}
+			if methods[i] != "" {
+				m.methods = methods
+			}
+		}
+	}
+	if m.methods == nil && m.pii == nil {
+		return nil
+	}
+	return m
+}
+
+// maskedColumnResult refuses a tool computing statistics of a column masked
+// for the caller, as the statistics would reveal its values. It returns nil
+// when no column is masked.
+func maskedColumnResult(ctx context.Context, columns ...string) *mcp.CallToolResult {
+	m := masksFor(ctx, columns)
+	if m == nil || m.methods == nil {
+		return nil
+	}
+	for i, column := range columns {
+		if m.methods[i] != "" {
+			profile := profileFromContext(ctx)
+			return accessDeniedResult(profile, fmt.Sprintf("column %s is masked for the %s role", column, profile.Name))
+		}
+	}
+	return nil
+}
+
+// apply masks the value of column i.
+func (m *columnMasks) apply(i int, v interface{}) interface{} {
+	switch {
+	case m == nil:
+		return v
+	case m.methods != nil && m.methods[i] != "":
+		return maskValue(m.methods[i], v)
+	case m.pii != nil:
+		return m.pii.redact(v)
+	}
+	return v
+}
+
+// maskRowMaps masks, in place, the values of rows keyed by column name.
+func maskRowMaps(ctx context.Context, rows []map[string]interface{}) {
+	for _, row := range rows {
+		columns := make([]string, 0, len(row))
+		for column := range row {
+			columns = append(columns, column)
+		}
+		masks := masksFor(ctx, columns)
+		if masks == nil {
+			return
+		}
+		for i, column := range columns {
+			row[column] = masks.apply(i, row[column])
+		}
+	}
+}
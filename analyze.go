@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// PlanStep is one node of a SQLite query plan.
+type PlanStep struct {
+	ID     int64  `json:"id"`
+	Parent int64  `json:"parent"`
+	Detail string `json:"detail"`
+}
+
+// QueryAnalysis is the payload returned by analyze_query.
+type QueryAnalysis struct {
+	Plan         []PlanStep `json:"plan"`
+	IndexUsed    bool       `json:"index_used"`
+	Indexes      []string   `json:"indexes"`          // Plan steps that search an index or the primary key
+	FullScans    []string   `json:"full_scans"`       // Tables read without an index
+	TempBTrees   []string   `json:"temp_b_trees"`     // Sorts and DISTINCTs that need a temporary index
+	Rows         int        `json:"rows"`             // Rows returned by the query
+	FirstRowMs   float64    `json:"first_row_ms"`     // Time until the first row was available
+	DurationMs   float64    `json:"duration_ms"`      // Time to read every row
+	PlanDuration float64    `json:"plan_duration_ms"` // Time spent planning with EXPLAIN QUERY PLAN
+}
+
+// analyzeQueryHandler explains a SELECT, then runs it and reports the plan
+// with the measured timing and whether any index was used.
+func (ds *DatabaseService) analyzeQueryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if _, ok := ds.dialect.(sqliteDialect); !ok {
+		return mcp.NewToolResultError("analyze_query is only supported for SQLite databases."), nil
+	}
+	query, _ := request.GetArguments()["query"].(string)
+	if query == "" {
+		return mcp.NewToolResultError("Missing or invalid 'query' argument."), nil
+	}
+	if err := ds.validateReadOnly(query); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	limits := ds.limitsFor(ctx)
+
+	analysis := QueryAnalysis{Plan: []PlanStep{}, Indexes: []string{}, FullScans: []string{}, TempBTrees: []string{}}
+	start := time.Now()
+	planRows, err := ds.db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query)
+	if err != nil {
+		log.Printf("Error explaining query: %v, Query: %s", err, logSQL(query))
+		if result := limits.budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error explaining query", err), nil
+	}
+	for planRows.Next() {
+		var step PlanStep
+		var notUsed int64
+		if err := planRows.Scan(&step.ID, &step.Parent, &notUsed, &step.Detail); err != nil {
+			planRows.Close()
+			return mcp.NewToolResultErrorFromErr("Error reading query plan", err), nil
+		}
+		analysis.Plan = append(analysis.Plan, step)
+		classifyPlanStep(&analysis, step.Detail)
+	}
+	planRows.Close()
+	if err := planRows.Err(); err != nil {
+		return mcp.NewToolResultErrorFromErr("Error reading query plan", err), nil
+	}
+	analysis.PlanDuration = millisSince(start)
+	analysis.IndexUsed = len(analysis.Indexes) > 0
+
+	start = time.Now()
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("Error executing query: %v, Query: %s", err, logSQL(query))
+		if result := limits.budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error executing query", err), nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if analysis.Rows == 0 {
+			analysis.FirstRowMs = millisSince(start)
+		}
+		if analysis.Rows++; limits.MaxRows > 0 && analysis.Rows > limits.MaxRows {
+			return limits.budgetError(ctx, errRowBudgetExceeded), nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating rows: %v", err)
+		if result := limits.budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error iterating through results", err), nil
+	}
+	analysis.DurationMs = millisSince(start)
+
+	resultJSON, err := json.MarshalIndent(analysis, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling query analysis to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting query analysis", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// classifyPlanStep records index searches, full table scans and temporary
+// b-trees from the detail text of a plan step, e.g.
+// "SEARCH orders USING INDEX idx_orders_customer (customer_id=?)".
+func classifyPlanStep(analysis *QueryAnalysis, detail string) {
+	switch {
+	case strings.Contains(detail, " USING ") && (strings.HasPrefix(detail, "SEARCH ") || strings.HasPrefix(detail, "SCAN ")):
+		analysis.Indexes = append(analysis.Indexes, detail)
+	case strings.HasPrefix(detail, "SCAN ") && detail != "SCAN CONSTANT ROW":
+		analysis.FullScans = append(analysis.FullScans, strings.TrimPrefix(detail, "SCAN "))
+	case strings.HasPrefix(detail, "USE TEMP B-TREE"):
+		analysis.TempBTrees = append(analysis.TempBTrees, strings.TrimPrefix(detail, "USE TEMP B-TREE FOR "))
+	}
+}
+
+// millisSince returns the time elapsed since start in fractional milliseconds.
+func millisSince(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Anonymizing masking methods, which replace a value with a deterministic
+// stand-in: equal values get equal stand-ins, so results can still be
+// joined and grouped on them.
+const (
+	maskFakeEmail = "fake_email" // A made-up address at example.com
+	maskFakeName  = "fake_name"  // A made-up first name, or first and last name
+)
+
+// maskSalt keys the hash and fake value methods, from ANONYMIZATION_SALT, so
+// stand-ins cannot be reversed by hashing guessed values.
+var maskSalt []byte
+
+var (
+	fakeFirstNames = []string{
+		"Alex", "Blake", "Casey", "Dana", "Eli", "Frankie", "Gray", "Harper",
+		"Indy", "Jordan", "Kai", "Logan", "Morgan", "Noel", "Oakley", "Parker",
+		"Quinn", "Reese", "Sage", "Taylor", "Uma", "Val", "Wren", "Yael",
+		"Ari", "Bo", "Cameron", "Drew", "Emery", "Finley", "Hayden", "Jamie",
+	}
+	fakeLastNames = []string{
+		"Abbott", "Baker", "Carter", "Dalton", "Ellis", "Fisher", "Garner", "Hughes",
+		"Irving", "Jensen", "Keller", "Lawson", "Mercer", "Nolan", "Owens", "Porter",
+		"Quincy", "Reyes", "Sutton", "Turner", "Underwood", "Vance", "Walsh", "Young",
+		"Archer", "Brooks", "Cole", "Dunn", "Easton", "Frost", "Hale", "Marsh",
+	}
+)
+
+// maskMethods are the masking methods roles and ANONYMIZE_COLUMNS accept.
+var maskMethods = []string{maskRedact, maskHash, maskPartial, maskFakeEmail, maskFakeName}
+
+// validMaskMethod reports whether method is one of maskMethods.
+func validMaskMethod(method string) bool {
+	for _, m := range maskMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// loadAnonymizationFromEnv reads ANONYMIZATION_SALT and ANONYMIZE_COLUMNS,
+// comma separated table.column=method or column=method pairs masked for
+// every caller. A role's own mask for a column takes precedence.
+func loadAnonymizationFromEnv(salt string) error {
+	maskSalt = []byte(salt)
+	v := os.Getenv("ANONYMIZE_COLUMNS")
+	if v == "" {
+		return nil
+	}
+	masks := make(map[string]string)
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		column, method, ok := strings.Cut(entry, "=")
+		column, method = strings.ToLower(strings.TrimSpace(column)), strings.TrimSpace(method)
+		if !ok || column == "" || !validMaskMethod(method) {
+			return fmt.Errorf("invalid ANONYMIZE_COLUMNS entry %q, expected column=method with method one of %s", entry, strings.Join(maskMethods, ", "))
+		}
+		masks[column] = method
+	}
+	for _, profile := range profiles {
+		merged := make(map[string]string, len(masks)+len(profile.Masks))
+		for column, method := range masks {
+			merged[column] = method
+		}
+		for column, method := range profile.Masks {
+			merged[column] = method
+		}
+		profile.Masks = merged
+	}
+	return nil
+}
+
+// saltedSum returns the HMAC-SHA256 of s keyed by maskSalt, or its plain
+// SHA-256 without salt.
+func saltedSum(s string) []byte {
+	if len(maskSalt) == 0 {
+		sum := sha256.Sum256([]byte(s))
+		return sum[:]
+	}
+	mac := hmac.New(sha256.New, maskSalt)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+// fakeName returns a made-up name for s, with a last name when s has more
+// than one word.
+func fakeName(s string) string {
+	sum := saltedSum(s)
+	first := fakeFirstNames[binary.BigEndian.Uint32(sum[0:4])%uint32(len(fakeFirstNames))]
+	if len(strings.Fields(s)) < 2 {
+		return first
+	}
+	return first + " " + fakeLastNames[binary.BigEndian.Uint32(sum[4:8])%uint32(len(fakeLastNames))]
+}
+
+// fakeEmail returns a made-up address for s. The hex suffix keeps distinct
+// addresses apart where the names alone would collide.
+func fakeEmail(s string) string {
+	sum := saltedSum(strings.ToLower(strings.TrimSpace(s)))
+	first := fakeFirstNames[binary.BigEndian.Uint32(sum[0:4])%uint32(len(fakeFirstNames))]
+	last := fakeLastNames[binary.BigEndian.Uint32(sum[4:8])%uint32(len(fakeLastNames))]
+	return strings.ToLower(first+"."+last) + "." + hex.EncodeToString(sum[8:11]) + "@example.com"
+}
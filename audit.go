@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/syslog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	defaultAuditLogSize = 10000 // Tool calls kept for audit_log
+	defaultAuditLimit   = 100   // Entries audit_log returns by default
+	maxAuditLimit       = 1000
+)
+
+// AuditEntry records one tool call for the audit log.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id"`
+	SessionID  string    `json:"session_id,omitempty"`
+	Principal  string    `json:"principal,omitempty"`
+	Tool       string    `json:"tool"`
+	SQL        string    `json:"sql,omitempty"`
+	Tables     []string  `json:"tables,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	Outcome    string    `json:"outcome"`
+}
+
+// auditSink ships audit entries outside the server.
+type auditSink interface {
+	send(entry AuditEntry)
+	close()
+}
+
+// AuditLog keeps the most recent tool calls in memory for the audit_log
+// tool and ships every call to the configured sink.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry // Ring buffer, oldest at next once full
+	next    int
+	full    bool
+
+	sink auditSink
+}
+
+// auditLogFromEnv configures the audit log from AUDIT_LOG_SIZE, the number
+// of calls kept in memory (0 keeps none), and AUDIT_SINK: syslog for the
+// local daemon, syslog://host:port or syslog+tcp://host:port for a remote
+// one, or an http(s) URL that receives batches of entries as JSON lines,
+// with AUDIT_SINK_TOKEN as bearer token.
+func auditLogFromEnv(sinkToken string) (*AuditLog, error) {
+	size := defaultAuditLogSize
+	if v := os.Getenv("AUDIT_LOG_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid AUDIT_LOG_SIZE %q", v)
+		}
+		size = n
+	}
+	a := &AuditLog{entries: make([]AuditEntry, size)}
+
+	target := os.Getenv("AUDIT_SINK")
+	switch {
+	case target == "":
+	case target == "syslog":
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, "db-mcp")
+		if err != nil {
+			return nil, fmt.Errorf("connecting to syslog: %w", err)
+		}
+		a.sink = syslogSink{w}
+	case strings.HasPrefix(target, "syslog://"), strings.HasPrefix(target, "syslog+tcp://"):
+		u, err := url.Parse(target)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid AUDIT_SINK %q, expected syslog://host:port", target)
+		}
+		network := "udp"
+		if u.Scheme == "syslog+tcp" {
+			network = "tcp"
+		}
+		w, err := syslog.Dial(network, u.Host, syslog.LOG_INFO|syslog.LOG_AUTH, "db-mcp")
+		if err != nil {
+			return nil, fmt.Errorf("connecting to syslog at %s: %w", u.Host, err)
+		}
+		a.sink = syslogSink{w}
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+		if _, err := url.Parse(target); err != nil {
+			return nil, fmt.Errorf("invalid AUDIT_SINK %q: %w", target, err)
+		}
+		a.sink = newHTTPAuditSink(target, sinkToken)
+	default:
+		return nil, fmt.Errorf("invalid AUDIT_SINK %q (want syslog, syslog://host:port, syslog+tcp://host:port or an http(s) URL)", target)
+	}
+	return a, nil
+}
+
+// enabled reports whether entries are kept or shipped.
+func (a *AuditLog) enabled() bool {
+	return len(a.entries) > 0 || a.sink != nil
+}
+
+// record adds an entry to the log and ships it.
+func (a *AuditLog) record(entry AuditEntry) {
+	if a.sink != nil {
+		a.sink.send(entry)
+	}
+	if len(a.entries) == 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries[a.next] = entry
+	a.next++
+	if a.next == len(a.entries) {
+		a.next, a.full = 0, true
+	}
+}
+
+// close flushes the entries the sink has not shipped yet.
+func (a *AuditLog) close() {
+	if a.sink != nil {
+		a.sink.close()
+	}
+}
+
+// auditCall collects what a tool call touched while it runs.
+type auditCall struct {
+	tables  []string
+	columns map[string]map[string]bool // Columns read by queries, by table
+}
+
+// auditCallKey is a context key for the auditCall of the tool call.
+type auditCallKey struct{}
+
+// auditCallFromContext returns the auditCall of the tool call, or nil
+// when calls are not audited or the audit log is off.
+func auditCallFromContext(ctx context.Context) *auditCall {
+	call, _ := ctx.Value(auditCallKey{}).(*auditCall)
+	return call
+}
+
+// auditFilter selects audit entries; zero fields match every entry.
+type auditFilter struct {
+	Session   string
+	Principal string
+	Tool      string
+	Table     string
+	Since     time.Time
+	Until     time.Time
+}
+
+func (f auditFilter) matches(e AuditEntry) bool {
+	if f.Session != "" && e.SessionID != f.Session ||
+		f.Principal != "" && !strings.EqualFold(e.Principal, f.Principal) ||
+		f.Tool != "" && e.Tool != f.Tool ||
+		!f.Since.IsZero() && e.Time.Before(f.Since) ||
+		!f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	if f.Table == "" {
+		return true
+	}
+	for _, table := range e.Tables {
+		if strings.EqualFold(table, f.Table) {
+			return true
+		}
+	}
+	return false
+}
+
+// search returns up to limit entries matching f, newest first.
+func (a *AuditLog) search(f auditFilter, limit int) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := a.next
+	if a.full {
+		n = len(a.entries)
+	}
+	found := []AuditEntry{}
+	for i := 1; i <= n && len(found) < limit; i++ {
+		e := a.entries[(a.next-i+len(a.entries))%len(a.entries)]
+		if f.matches(e) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// auditLogHandler returns the audited tool calls matching the filters. Only
+// administrators may call it.
+func (a *AuditLog) auditLogHandler(identity *Identity) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !identity.isAdmin(ctx) {
+			return mcp.NewToolResultError("audit_log requires an administrator (see ADMIN_USERS)."), nil
+		}
+		args := request.GetArguments()
+		var f auditFilter
+		f.Session, _ = args["session_id"].(string)
+		f.Principal, _ = args["principal"].(string)
+		f.Tool, _ = args["tool"].(string)
+		if table, _ := args["table"].(string); table != "" {
+			f.Table = strings.ToLower(table)
+		}
+		for name, t := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+			v, _ := args[name].(string)
+			if v == "" {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Invalid '%s' argument %q: expected an RFC 3339 time such as 2024-05-01T12:00:00Z.", name, v)), nil
+			}
+			*t = parsed
+		}
+		limit := defaultAuditLimit
+		if v, ok := args["limit"].(float64); ok && v > 0 {
+			limit = min(int(v), maxAuditLimit)
+		}
+
+		resultJSON, err := json.MarshalIndent(a.search(f, limit), "", "  ")
+		if err != nil {
+			log.Printf("Error marshalling audit log to JSON: %v", err)
+			return mcp.NewToolResultErrorFromErr("Error formatting audit log", err), nil
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	}
+}
+
+// syslogSink writes each entry to syslog as a JSON message.
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func (s syslogSink) send(entry AuditEntry) {
+	line, _ := json.Marshal(entry)
+	if err := s.w.Info(string(line)); err != nil {
+		log.Printf("Error writing audit entry to syslog: %v", err)
+	}
+}
+
+func (s syslogSink) close() { s.w.Close() }
+
+// httpAuditSink posts entries to an HTTP endpoint in batches, in the
+// background so tool calls never wait for the endpoint. Entries arriving
+// while the queue is full are dropped and counted.
+type httpAuditSink struct {
+	url, token string
+	client     *http.Client
+	queue      chan AuditEntry
+	done       chan struct{}
+
+	mu      sync.Mutex
+	dropped int
+}
+
+const (
+	auditQueueSize     = 1000
+	auditBatchSize     = 100
+	auditFlushInterval = time.Second
+)
+
+func newHTTPAuditSink(url, token string) *httpAuditSink {
+	s := &httpAuditSink{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan AuditEntry, auditQueueSize),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *httpAuditSink) send(entry AuditEntry) {
+	select {
+	case s.queue <- entry:
+	default:
+		s.mu.Lock()
+		s.dropped++
+		s.mu.Unlock()
+	}
+}
+
+// close stops accepting entries and waits for the queued ones to be posted.
+func (s *httpAuditSink) close() {
+	close(s.queue)
+	<-s.done
+}
+
+func (s *httpAuditSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+	var batch []AuditEntry
+	for {
+		select {
+		case entry, ok := <-s.queue:
+			if !ok {
+				s.post(batch)
+				return
+			}
+			if batch = append(batch, entry); len(batch) >= auditBatchSize {
+				s.post(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			s.post(batch)
+			batch = nil
+		}
+	}
+}
+
+// post sends a batch as JSON lines.
+func (s *httpAuditSink) post(batch []AuditEntry) {
+	s.mu.Lock()
+	dropped := s.dropped
+	s.dropped = 0
+	s.mu.Unlock()
+	if dropped > 0 {
+		log.Printf("Dropped %d audit entries: the audit sink is not keeping up", dropped)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, entry := range batch {
+		enc.Encode(entry)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, &body)
+	if err != nil {
+		log.Printf("Error shipping %d audit entries: %v", len(batch), err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Printf("Error shipping %d audit entries: %v", len(batch), err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Error shipping %d audit entries: audit sink answered %s", len(batch), resp.Status)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// basePathFromEnv reads BASE_PATH, the URL prefix the HTTP endpoints are
+// served under when a reverse proxy routes several servers on one host. It
+// returns "" or a path such as /mcp/db, without a trailing slash.
+func basePathFromEnv() (string, error) {
+	path := strings.TrimRight(os.Getenv("BASE_PATH"), "/")
+	if path == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, "?#{} ") || strings.Contains(path, "//") {
+		return "", fmt.Errorf("invalid BASE_PATH %q: want a path such as /mcp/db", os.Getenv("BASE_PATH"))
+	}
+	return path, nil
+}
+
+// withBasePath serves next under prefix, stripping it from the request path
+// so routes stay registered at the root. Requests outside the prefix get 404.
+func withBasePath(prefix string, next http.Handler) http.Handler {
+	if prefix == "" {
+		return next
+	}
+	mux := http.NewServeMux()
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, next))
+	return mux
+}
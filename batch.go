@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Limits of batch_read.
+const (
+	maxBatchQueries     = 20
+	defaultBatchRowsMax = 100
+)
+
+// BatchResult is the result of one query of a batch_read call.
+type BatchResult struct {
+	Query     string                   `json:"query"`
+	Columns   []string                 `json:"columns,omitempty"`
+	Rows      []map[string]interface{} `json:"rows,omitempty"`
+	RowCount  int                      `json:"row_count"`
+	Truncated bool                     `json:"truncated,omitempty"` // More rows than the limit exist
+	Error     string                   `json:"error,omitempty"`
+}
+
+// BatchReadResult is the payload returned by batch_read.
+type BatchReadResult struct {
+	Snapshot bool          `json:"snapshot"` // All queries saw the same state of the database
+	Results  []BatchResult `json:"results"`
+}
+
+// queryContexter is implemented by *sql.Tx and *sql.Conn.
+type queryContexter interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// batchReadHandler runs several read-only queries in one read transaction, so
+// they see a single snapshot, and returns all their results at once.
+func (ds *DatabaseService) batchReadHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	list, _ := args["queries"].([]interface{})
+	if len(list) == 0 {
+		return mcp.NewToolResultError("Missing or empty 'queries' argument."), nil
+	}
+	if len(list) > maxBatchQueries {
+		return mcp.NewToolResultError(fmt.Sprintf("At most %d queries can be batched.", maxBatchQueries)), nil
+	}
+	queries := make([]string, len(list))
+	for i, q := range list {
+		query, _ := q.(string)
+		if query == "" {
+			return mcp.NewToolResultError(fmt.Sprintf("Query %d is missing or not a string.", i+1)), nil
+		}
+		if err := ds.validateReadOnly(query); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Query %d: %v", i+1, err)), nil
+		}
+		queries[i] = query
+	}
+	limit := defaultBatchRowsMax
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(math.Min(v, math.MaxInt32))
+	}
+	if maxRows := ds.limitsFor(ctx).MaxRows; maxRows > 0 && limit > maxRows {
+		limit = maxRows
+	}
+
+	conn, err := ds.db.Conn(ctx)
+	if err != nil {
+		log.Printf("Error getting connection: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error getting database connection", err), nil
+	}
+	defer conn.Close()
+	result := BatchReadResult{Results: make([]BatchResult, len(queries))}
+	var q queryContexter = conn
+	if tx, err := conn.BeginTx(ctx, nil); err == nil {
+		defer tx.Rollback()
+		q, result.Snapshot = tx, true
+	} else {
+		// Backends without transactions run the queries one after another
+		log.Printf("Running batch without a transaction: %v", err)
+	}
+
+	for i, query := range queries {
+		result.Results[i] = ds.batchQuery(ctx, q, query, limit)
+		result.Results[i].Query = sentQuery(ctx, i, query)
+		if ctx.Err() != nil {
+			if res := ds.limitsFor(ctx).budgetError(ctx, ctx.Err()); res != nil {
+				return res, nil
+			}
+			return mcp.NewToolResultErrorFromErr("Batch cancelled", ctx.Err()), nil
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling batch results to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting results", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// batchQuery runs one query of a batch, reading at most limit rows. Errors
+// are reported in the result so the other queries still return.
+func (ds *DatabaseService) batchQuery(ctx context.Context, q queryContexter, query string, limit int) BatchResult {
+	result := BatchResult{Query: query, Rows: []map[string]interface{}{}}
+	if limiter, ok := ds.dialect.(rowLimiter); ok {
+		// One extra row tells whether the result was truncated
+		query = limiter.LimitQuery(query, limit+1)
+	}
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("Error executing batch query: %v, Query: %s", err, logSQL(query))
+		result.Error = err.Error()
+		return result
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Columns = columns
+	masks := masksFor(ctx, columns)
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if len(result.Rows) >= limit {
+			result.Truncated = true
+			break
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			row[col] = masks.apply(i, normalizeValue(values[i], columnTypes[i].DatabaseTypeName()))
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		result.Error = err.Error()
+	}
+	result.RowCount = len(result.Rows)
+	addRowsReturned(ctx, result.RowCount)
+	return result
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Limits of the number of runs of benchmark_query.
+const (
+	defaultBenchmarkRuns = 10
+	maxBenchmarkRuns     = 100
+)
+
+// BenchmarkResult is the payload returned by benchmark_query. Latencies are
+// in milliseconds and cover executing the query and reading every row.
+type BenchmarkResult struct {
+	Runs     int     `json:"runs"`
+	Cold     bool    `json:"cold"`
+	Rows     int     `json:"rows"` // Rows returned by each run
+	MinMs    float64 `json:"min_ms"`
+	MedianMs float64 `json:"median_ms"`
+	P95Ms    float64 `json:"p95_ms"`
+	MaxMs    float64 `json:"max_ms"`
+	MeanMs   float64 `json:"mean_ms"`
+}
+
+// benchmarkQueryHandler runs a SELECT several times and reports its latency
+// distribution. Warm runs share one connection after an untimed warm-up run;
+// cold runs each open a new connection, so SQLite starts with an empty page
+// cache (the operating system's file cache stays warm).
+func (ds *DatabaseService) benchmarkQueryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	query, _ := args["query"].(string)
+	if query == "" {
+		return mcp.NewToolResultError("Missing or invalid 'query' argument."), nil
+	}
+	if err := ds.validateReadOnly(query); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	runs := defaultBenchmarkRuns
+	if v, ok := args["runs"].(float64); ok && v >= 1 {
+		runs = int(min(v, maxBenchmarkRuns))
+	}
+	cold, _ := args["cold"].(bool)
+	limits := ds.limitsFor(ctx)
+
+	// A pool that keeps no idle connections opens a new one for every run
+	db := ds.db
+	if cold {
+		db = sql.OpenDB(ds.connector)
+		db.SetMaxIdleConns(0)
+		defer db.Close()
+	} else if _, _, err := timeQuery(ctx, db, query, limits.MaxRows); err != nil {
+		return benchmarkError(ctx, limits, query, err), nil
+	}
+
+	result := BenchmarkResult{Runs: runs, Cold: cold}
+	latencies := make([]float64, runs)
+	for i := range latencies {
+		rows, latency, err := timeQuery(ctx, db, query, limits.MaxRows)
+		if err != nil {
+			return benchmarkError(ctx, limits, query, err), nil
+		}
+		latencies[i], result.Rows = latency, rows
+	}
+
+	sort.Float64s(latencies)
+	sum := 0.0
+	for _, latency := range latencies {
+		sum += latency
+	}
+	result.MinMs, result.MaxMs = latencies[0], latencies[runs-1]
+	result.MedianMs = quantile(latencies, 0.5)
+	result.P95Ms = quantile(latencies, 0.95)
+	result.MeanMs = sum / float64(runs)
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling benchmark result to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting benchmark result", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// timeQuery runs query once, reading and discarding its rows, and returns
+// the row count and the elapsed time in milliseconds.
+func timeQuery(ctx context.Context, db *sql.DB, query string, maxRows int) (int, float64, error) {
+	start := time.Now()
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		if count++; maxRows > 0 && count > maxRows {
+			return count, 0, errRowBudgetExceeded
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return count, 0, err
+	}
+	return count, millisSince(start), nil
+}
+
+// benchmarkError converts a failed run into a tool error.
+func benchmarkError(ctx context.Context, limits QueryLimits, query string, err error) *mcp.CallToolResult {
+	log.Printf("Error benchmarking query: %v, Query: %s", err, logSQL(query))
+	if result := limits.budgetError(ctx, err); result != nil {
+		return result
+	}
+	return mcp.NewToolResultErrorFromErr("Error running query", err)
+}
@@ -0,0 +1,447 @@
+//go:build !no_bigquery
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The bigquery driver runs GoogleSQL queries through the BigQuery REST API
+// (jobs.query and jobs.getQueryResults), authenticating with Application
+// Default Credentials (see gcpauth.go).
+//
+// DSNs name the billing project and an optional default dataset:
+//
+//	bigquery://PROJECT[/DATASET]?location=US&credentials=/path/key.json&max_bytes_billed=10000000000
+//
+// max_bytes_billed is passed to every job as maximumBytesBilled, so BigQuery
+// itself fails queries that would scan more; read_query also checks a dry run
+// against it before running the query.
+
+func init() {
+	sql.Register("bigquery", &bigqueryDriver{tokens: make(map[string]*googleTokenSource)})
+	registerDriver("bigquery", "Google BigQuery over the REST API", newBigQueryDialect)
+}
+
+const (
+	bigqueryAPI = "https://bigquery.googleapis.com/bigquery/v2"
+
+	// bigqueryDryRun is the name of a boolean argument that turns a query into a
+	// dry run; the only result row is the number of bytes the query would scan.
+	bigqueryDryRun = "bigquery_dry_run"
+
+	bigqueryWaitMillis = 10000 // How long each jobs request waits for the job to complete
+)
+
+// bigqueryConfig holds the options of a bigquery:// DSN.
+type bigqueryConfig struct {
+	project        string
+	dataset        string // Default dataset for unqualified table names
+	location       string
+	credentials    string // Credentials file; empty for Application Default Credentials
+	maxBytesBilled int64  // 0 for no limit
+}
+
+func parseBigQueryDSN(dsn string) (*bigqueryConfig, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme != "bigquery" {
+		return nil, fmt.Errorf("invalid BigQuery DSN, expected bigquery://PROJECT[/DATASET]")
+	}
+	cfg := &bigqueryConfig{
+		project:     u.Host,
+		dataset:     strings.Trim(u.Path, "/"),
+		location:    u.Query().Get("location"),
+		credentials: u.Query().Get("credentials"),
+	}
+	if cfg.project == "" {
+		return nil, fmt.Errorf("BigQuery DSN has no project")
+	}
+	if cfg.location == "" {
+		cfg.location = "US"
+	}
+	if limit := u.Query().Get("max_bytes_billed"); limit != "" {
+		if cfg.maxBytesBilled, err = strconv.ParseInt(limit, 10, 64); err != nil || cfg.maxBytesBilled < 0 {
+			return nil, fmt.Errorf("invalid max_bytes_billed %q in BigQuery DSN", limit)
+		}
+	}
+	return cfg, nil
+}
+
+type bigqueryDriver struct {
+	mu     sync.Mutex
+	tokens map[string]*googleTokenSource // By credentials file, shared by all connections
+}
+
+// Open implements driver.Driver.
+func (d *bigqueryDriver) Open(dsn string) (driver.Conn, error) {
+	cfg, err := parseBigQueryDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tokens, ok := d.tokens[cfg.credentials]
+	if !ok {
+		if tokens, err = newGoogleTokenSource(cfg.credentials); err != nil {
+			return nil, err
+		}
+		d.tokens[cfg.credentials] = tokens
+	}
+	return &bigqueryConn{cfg: cfg, tokens: tokens, client: &http.Client{Timeout: 5 * time.Minute}}, nil
+}
+
+// bigqueryConn is stateless: every query is a separate BigQuery job.
+type bigqueryConn struct {
+	cfg    *bigqueryConfig
+	tokens *googleTokenSource
+	client *http.Client
+}
+
+type bigqueryField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode"` // NULLABLE, REQUIRED or REPEATED
+}
+
+type bigqueryRow struct {
+	F []bigqueryCell `json:"f"`
+}
+
+type bigqueryCell struct {
+	V json.RawMessage `json:"v"`
+}
+
+// bigqueryResponse is the response of jobs.query and jobs.getQueryResults.
+type bigqueryResponse struct {
+	JobReference struct {
+		JobID    string `json:"jobId"`
+		Location string `json:"location"`
+	} `json:"jobReference"`
+	JobComplete bool `json:"jobComplete"`
+	Schema      struct {
+		Fields []bigqueryField `json:"fields"`
+	} `json:"schema"`
+	Rows                []bigqueryRow `json:"rows"`
+	PageToken           string        `json:"pageToken"`
+	TotalBytesProcessed string        `json:"totalBytesProcessed"`
+}
+
+type bigqueryParam struct {
+	ParameterType struct {
+		Type string `json:"type"`
+	} `json:"parameterType"`
+	ParameterValue struct {
+		Value *string `json:"value"`
+	} `json:"parameterValue"`
+}
+
+// call sends a request to the BigQuery API and decodes the JSON response into out.
+func (c *bigqueryConn) call(ctx context.Context, method, path string, body, out interface{}) error {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, bigqueryAPI+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(msg, &apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("BigQuery error: %s", apiErr.Error.Message)
+		}
+		return fmt.Errorf("BigQuery returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid BigQuery response: %w", err)
+	}
+	return nil
+}
+
+// QueryContext implements driver.QueryerContext. Arguments are bound as
+// positional (?) query parameters.
+func (c *bigqueryConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	request := map[string]interface{}{
+		"query":         query,
+		"useLegacySql":  false,
+		"location":      c.cfg.location,
+		"timeoutMs":     bigqueryWaitMillis,
+		"formatOptions": map[string]bool{"useInt64Timestamp": true},
+	}
+	if c.cfg.dataset != "" {
+		request["defaultDataset"] = map[string]string{"projectId": c.cfg.project, "datasetId": c.cfg.dataset}
+	}
+	if c.cfg.maxBytesBilled > 0 {
+		request["maximumBytesBilled"] = strconv.FormatInt(c.cfg.maxBytesBilled, 10)
+	}
+	dryRun := false
+	var params []bigqueryParam
+	for _, arg := range args {
+		if arg.Name == bigqueryDryRun {
+			dryRun, _ = arg.Value.(bool)
+			continue
+		}
+		p, err := toBigQueryParam(arg.Value)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, p)
+	}
+	if len(params) > 0 {
+		request["parameterMode"] = "POSITIONAL"
+		request["queryParameters"] = params
+	}
+	if dryRun {
+		request["dryRun"] = true
+	}
+
+	resp := &bigqueryResponse{}
+	if err := c.call(ctx, http.MethodPost, "/projects/"+url.PathEscape(c.cfg.project)+"/queries", request, resp); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		scanned, err := strconv.ParseInt(resp.TotalBytesProcessed, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid totalBytesProcessed in BigQuery dry run: %w", err)
+		}
+		dry := &bigqueryResponse{JobComplete: true}
+		dry.Schema.Fields = []bigqueryField{{Name: "total_bytes_processed", Type: "INTEGER"}}
+		dry.Rows = []bigqueryRow{{F: []bigqueryCell{{V: json.RawMessage(strconv.Quote(strconv.FormatInt(scanned, 10)))}}}}
+		return &bigqueryRows{ctx: ctx, conn: c, resp: dry}, nil
+	}
+
+	rows := &bigqueryRows{ctx: ctx, conn: c, jobID: resp.JobReference.JobID, location: resp.JobReference.Location, resp: resp}
+	for !rows.resp.JobComplete {
+		if err := rows.fetch(""); err != nil {
+			if ctx.Err() != nil {
+				c.cancelJob(rows.jobID, rows.location)
+			}
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// cancelJob asks BigQuery to stop a job whose caller gave up on it.
+func (c *bigqueryConn) cancelJob(jobID, location string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	path := fmt.Sprintf("/projects/%s/jobs/%s/cancel?location=%s", url.PathEscape(c.cfg.project), url.PathEscape(jobID), url.QueryEscape(location))
+	c.call(ctx, http.MethodPost, path, nil, nil)
+}
+
+// ExecContext implements driver.ExecerContext. The server only reads.
+func (c *bigqueryConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, errors.New("the BigQuery driver is read-only")
+}
+
+// Ping implements driver.Pinger by reading the project's first dataset,
+// which checks the credentials without running a job.
+func (c *bigqueryConn) Ping(ctx context.Context) error {
+	return c.call(ctx, http.MethodGet, "/projects/"+url.PathEscape(c.cfg.project)+"/datasets?maxResults=1", nil, nil)
+}
+
+// Prepare implements driver.Conn. Statements are sent as text on execution.
+func (c *bigqueryConn) Prepare(query string) (driver.Stmt, error) {
+	return &bigqueryStmt{conn: c, query: query}, nil
+}
+
+// Close implements driver.Conn.
+func (c *bigqueryConn) Close() error { return nil }
+
+// Begin implements driver.Conn.
+func (c *bigqueryConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("the BigQuery driver does not support transactions")
+}
+
+type bigqueryStmt struct {
+	conn  *bigqueryConn
+	query string
+}
+
+func (s *bigqueryStmt) Close() error  { return nil }
+func (s *bigqueryStmt) NumInput() int { return -1 }
+
+func (s *bigqueryStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, valuesToNamed(args))
+}
+
+func (s *bigqueryStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, valuesToNamed(args))
+}
+
+func (s *bigqueryStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// bigqueryRows reads a job's result one page at a time, so rows the caller
+// never reads are not downloaded.
+type bigqueryRows struct {
+	ctx      context.Context // Context of the query, used for later pages
+	conn     *bigqueryConn
+	jobID    string
+	location string
+	resp     *bigqueryResponse
+	pos      int
+}
+
+// fetch reads the page of results starting at pageToken, waiting for the job
+// to complete if needed.
+func (r *bigqueryRows) fetch(pageToken string) error {
+	query := url.Values{
+		"location":                        {r.location},
+		"timeoutMs":                       {strconv.Itoa(bigqueryWaitMillis)},
+		"formatOptions.useInt64Timestamp": {"true"},
+	}
+	if pageToken != "" {
+		query.Set("pageToken", pageToken)
+	}
+	path := fmt.Sprintf("/projects/%s/queries/%s?%s", url.PathEscape(r.conn.cfg.project), url.PathEscape(r.jobID), query.Encode())
+	resp := &bigqueryResponse{}
+	if err := r.conn.call(r.ctx, http.MethodGet, path, nil, resp); err != nil {
+		return err
+	}
+	r.resp, r.pos = resp, 0
+	return nil
+}
+
+func (r *bigqueryRows) Columns() []string {
+	cols := make([]string, len(r.resp.Schema.Fields))
+	for i, f := range r.resp.Schema.Fields {
+		cols[i] = f.Name
+	}
+	return cols
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName.
+func (r *bigqueryRows) ColumnTypeDatabaseTypeName(index int) string {
+	f := r.resp.Schema.Fields[index]
+	if f.Mode == "REPEATED" {
+		return "ARRAY<" + f.Type + ">"
+	}
+	return f.Type
+}
+
+func (r *bigqueryRows) Close() error { return nil }
+
+func (r *bigqueryRows) Next(dest []driver.Value) error {
+	for r.pos >= len(r.resp.Rows) {
+		if r.resp.PageToken == "" {
+			return io.EOF
+		}
+		if err := r.fetch(r.resp.PageToken); err != nil {
+			return err
+		}
+	}
+	row := r.resp.Rows[r.pos]
+	r.pos++
+	for i := range dest {
+		if i >= len(row.F) {
+			dest[i] = nil
+			continue
+		}
+		v, err := fromBigQueryValue(row.F[i].V, r.resp.Schema.Fields[i])
+		if err != nil {
+			return err
+		}
+		dest[i] = v
+	}
+	return nil
+}
+
+func toBigQueryParam(v driver.Value) (bigqueryParam, error) {
+	var p bigqueryParam
+	var value string
+	switch x := v.(type) {
+	case nil:
+		p.ParameterType.Type = "STRING"
+		return p, nil
+	case int64:
+		p.ParameterType.Type, value = "INT64", strconv.FormatInt(x, 10)
+	case float64:
+		p.ParameterType.Type, value = "FLOAT64", strconv.FormatFloat(x, 'g', -1, 64)
+	case bool:
+		p.ParameterType.Type, value = "BOOL", strconv.FormatBool(x)
+	case string:
+		p.ParameterType.Type, value = "STRING", x
+	case []byte:
+		p.ParameterType.Type, value = "BYTES", base64.StdEncoding.EncodeToString(x)
+	case time.Time:
+		p.ParameterType.Type, value = "TIMESTAMP", x.UTC().Format(time.RFC3339Nano)
+	default:
+		return p, fmt.Errorf("unsupported argument type %T", v)
+	}
+	p.ParameterValue.Value = &value
+	return p, nil
+}
+
+// fromBigQueryValue converts a cell of the REST API's JSON rows. Scalars are
+// strings; arrays and records keep their JSON form.
+func fromBigQueryValue(raw json.RawMessage, field bigqueryField) (driver.Value, error) {
+	var s *string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		// REPEATED or RECORD value
+		return string(raw), nil
+	}
+	if s == nil {
+		return nil, nil
+	}
+	switch field.Type {
+	case "INTEGER", "INT64":
+		return strconv.ParseInt(*s, 10, 64)
+	case "FLOAT", "FLOAT64":
+		return strconv.ParseFloat(*s, 64)
+	case "BOOLEAN", "BOOL":
+		return *s == "true", nil
+	case "TIMESTAMP":
+		micros, err := strconv.ParseInt(*s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TIMESTAMP value %q: %w", *s, err)
+		}
+		return time.UnixMicro(micros).UTC().Format(time.RFC3339Nano), nil
+	default:
+		// NUMERIC and BIGNUMERIC keep their exact digits; BYTES stay base64
+		return *s, nil
+	}
+}
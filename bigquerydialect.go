@@ -0,0 +1,244 @@
+//go:build !no_bigquery
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// bigqueryDialect describes BigQuery datasets through their INFORMATION_SCHEMA
+// views. Schemas are datasets: list_tables lists the default dataset of the
+// DSN unless another one is given, and tables are named dataset.table.
+type bigqueryDialect struct {
+	db             *sql.DB
+	project        string
+	dataset        string // Default dataset; may be empty
+	location       string // Region of the datasets listed by ListSchemas
+	maxBytesBilled int64
+}
+
+func newBigQueryDialect(db *sql.DB, dsn string) Dialect {
+	d := bigqueryDialect{db: db}
+	// The driver has already rejected invalid DSNs
+	if cfg, err := parseBigQueryDSN(dsn); err == nil {
+		d.project, d.dataset, d.location, d.maxBytesBilled = cfg.project, cfg.dataset, cfg.location, cfg.maxBytesBilled
+	}
+	return d
+}
+
+// view names an INFORMATION_SCHEMA view of a dataset, or of the default dataset.
+func (d bigqueryDialect) view(dataset, name string) (string, error) {
+	if dataset == "" {
+		if d.dataset == "" {
+			return "", fmt.Errorf("the BigQuery DSN has no default dataset; pass a dataset as schema (see list_schemas)")
+		}
+		dataset = d.dataset
+	}
+	if strings.Contains(dataset, ".") {
+		// Already project-qualified
+		return d.QuoteIdent(dataset) + ".INFORMATION_SCHEMA." + name, nil
+	}
+	return d.QuoteIdent(d.project) + "." + d.QuoteIdent(dataset) + ".INFORMATION_SCHEMA." + name, nil
+}
+
+// ListSchemas lists the datasets of the project in the DSN's location.
+func (d bigqueryDialect) ListSchemas(ctx context.Context) ([]SchemaInfo, error) {
+	region := d.QuoteIdent(d.project) + "." + d.QuoteIdent("region-"+strings.ToLower(d.location))
+	rows, err := d.db.QueryContext(ctx, fmt.Sprintf(`SELECT s.schema_name, COUNT(t.table_name)
+		FROM %[1]s.INFORMATION_SCHEMA.SCHEMATA AS s
+		LEFT JOIN %[1]s.INFORMATION_SCHEMA.TABLES AS t ON t.table_schema = s.schema_name AND t.table_type = 'BASE TABLE'
+		GROUP BY s.schema_name ORDER BY s.schema_name`, region))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []SchemaInfo
+	for rows.Next() {
+		var s SchemaInfo
+		if err := rows.Scan(&s.Name, &s.Tables); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, s)
+	}
+	return schemas, rows.Err()
+}
+
+func (d bigqueryDialect) ListTables(ctx context.Context, schema string, includeViews bool) ([]string, error) {
+	view, err := d.view(schema, "TABLES")
+	if err != nil {
+		return nil, err
+	}
+	types := "'BASE TABLE', 'EXTERNAL', 'SNAPSHOT', 'CLONE'"
+	if includeViews {
+		types += ", 'VIEW', 'MATERIALIZED VIEW'"
+	}
+	return d.queryStrings(ctx, fmt.Sprintf("SELECT table_name FROM %s WHERE table_type IN (%s) ORDER BY table_name", view, types))
+}
+
+func (d bigqueryDialect) DescribeTable(ctx context.Context, table string) (*TableDescription, error) {
+	schema, name := d.SplitTable(table)
+	view, err := d.view(schema, "COLUMNS")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, fmt.Sprintf(`SELECT column_name, data_type, is_nullable, column_default
+		FROM %s WHERE table_name = ? ORDER BY ordinal_position`, view), name)
+	if err != nil {
+		return nil, err
+	}
+	desc := &TableDescription{
+		Table:             table,
+		Columns:           []map[string]interface{}{},
+		ForeignKeys:       []ForeignKeyInfo{},
+		Indexes:           []IndexInfo{},
+		UniqueConstraints: [][]string{},
+		CheckConstraints:  []string{},
+	}
+	for rows.Next() {
+		var column, dataType, nullable string
+		var dflt sql.NullString
+		if err := rows.Scan(&column, &dataType, &nullable, &dflt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var dfltValue interface{}
+		if dflt.Valid && dflt.String != "NULL" {
+			dfltValue = dflt.String
+		}
+		notNull := 0
+		if nullable == "NO" {
+			notNull = 1
+		}
+		desc.Columns = append(desc.Columns, map[string]interface{}{
+			"cid": len(desc.Columns), "name": column, "type": dataType, "notnull": notNull, "dflt_value": dfltValue, "pk": 0,
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(desc.Columns) == 0 {
+		return desc, err
+	}
+
+	if desc.PrimaryKey, err = d.PrimaryKey(ctx, table); err != nil {
+		return nil, err
+	}
+	for i, key := range desc.PrimaryKey {
+		for _, column := range desc.Columns {
+			if column["name"] == key {
+				column["pk"] = i + 1
+			}
+		}
+	}
+	return desc, nil
+}
+
+// ListIndexes returns no indexes: BigQuery tables are partitioned and
+// clustered rather than indexed.
+func (d bigqueryDialect) ListIndexes(ctx context.Context, table string) ([]IndexInfo, error) {
+	return []IndexInfo{}, nil
+}
+
+// ForeignKeys returns no relationships. BigQuery's unenforced foreign keys do
+// not say which referenced column each referencing column pairs with.
+func (d bigqueryDialect) ForeignKeys(ctx context.Context, table string) ([]Relationship, error) {
+	return nil, nil
+}
+
+// PrimaryKey returns the columns of the table's unenforced primary key.
+func (d bigqueryDialect) PrimaryKey(ctx context.Context, table string) ([]string, error) {
+	schema, name := d.SplitTable(table)
+	constraints, err := d.view(schema, "TABLE_CONSTRAINTS")
+	if err != nil {
+		return nil, err
+	}
+	keys, _ := d.view(schema, "KEY_COLUMN_USAGE")
+	return d.queryStrings(ctx, fmt.Sprintf(`SELECT k.column_name FROM %s AS tc
+		JOIN %s AS k ON k.constraint_name = tc.constraint_name AND k.table_name = tc.table_name
+		WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_name = ? ORDER BY k.ordinal_position`, constraints, keys), name)
+}
+
+// SplitTable treats everything before the last dot as the dataset, which may
+// be project-qualified (project.dataset.table).
+func (d bigqueryDialect) SplitTable(table string) (schema, name string) {
+	if i := strings.LastIndexByte(table, '.'); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+// QuoteIdent quotes with backticks; a backtick path such as `project.dataset`
+// names the same object as its dotted parts.
+func (d bigqueryDialect) QuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(strings.ReplaceAll(name, `\`, `\\`), "`", "\\`") + "`"
+}
+
+// ValidateReadOnly accepts a single SELECT, possibly after WITH. jobs.query
+// runs multi-statement scripts, so anything after a semicolon is refused.
+func (d bigqueryDialect) ValidateReadOnly(query string) error {
+	fields := strings.Fields(strings.ToUpper(query))
+	if len(fields) == 0 || fields[0] != "SELECT" && fields[0] != "WITH" {
+		return fmt.Errorf("only SELECT and WITH queries are allowed for read-only access")
+	}
+	if hasStatementAfterSemicolon(query) {
+		return fmt.Errorf("multi-statement scripts are not allowed for read-only access")
+	}
+	return nil
+}
+
+// EstimateBytes dry-runs a query and returns the bytes it would scan.
+func (d bigqueryDialect) EstimateBytes(ctx context.Context, query string) (int64, error) {
+	var scanned int64
+	err := d.db.QueryRowContext(ctx, query, sql.Named(bigqueryDryRun, true)).Scan(&scanned)
+	return scanned, err
+}
+
+func (d bigqueryDialect) MaxBytesScanned() int64 {
+	return d.maxBytesBilled
+}
+
+func (d bigqueryDialect) queryStrings(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	return infoSchemaDialect{db: d.db}.queryStrings(ctx, query, args...)
+}
+
+// hasStatementAfterSemicolon reports whether anything other than whitespace
+// and comments follows a semicolon outside string literals, quoted
+// identifiers and comments.
+func hasStatementAfterSemicolon(query string) bool {
+	terminated := false
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			if terminated {
+				return true
+			}
+			for i++; i < len(query) && query[i] != c; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+		case c == '#' || c == '-' && strings.HasPrefix(query[i:], "--"):
+			if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(query)
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(query)
+			}
+		case c == ';':
+			terminated = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			if terminated {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// SQLCapabilities is the payload returned by sql_capabilities.
+type SQLCapabilities struct {
+	SQLiteVersion  string              `json:"sqlite_version"`
+	Features       map[string]bool     `json:"features"`
+	Functions      map[string][]string `json:"functions"` // By kind (scalar, aggregate, window); name(arities), * for any
+	Collations     []string            `json:"collations"`
+	Modules        []string            `json:"modules"` // Virtual table modules, e.g. fts5
+	CompileOptions []string            `json:"compile_options"`
+}
+
+// functionKinds names the type column of pragma_function_list.
+var functionKinds = map[string]string{"s": "scalar", "a": "aggregate", "w": "window"}
+
+// sqlFeatures are commonly assumed SQL features and the function or module
+// that tells whether this SQLite build has them.
+var sqlFeatures = map[string]string{
+	"regexp":          "function:regexp",
+	"json":            "function:json_extract",
+	"math_functions":  "function:sqrt",
+	"percentile":      "function:percentile",
+	"fts5":            "module:fts5",
+	"rtree":           "module:rtree",
+	"generate_series": "module:generate_series",
+}
+
+// sqlCapabilitiesHandler lists the functions, collations, virtual table
+// modules and compile options of the SQLite build, so queries only use what
+// is available.
+func (ds *DatabaseService) sqlCapabilitiesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if _, ok := ds.dialect.(sqliteDialect); !ok {
+		return mcp.NewToolResultError("sql_capabilities is only supported for SQLite databases."), nil
+	}
+	caps := SQLCapabilities{Features: make(map[string]bool), Functions: make(map[string][]string)}
+	if err := ds.db.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&caps.SQLiteVersion); err != nil {
+		log.Printf("Error reading SQLite version: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error reading SQLite version", err), nil
+	}
+
+	// Functions are listed once per arity and text encoding
+	rows, err := ds.db.QueryContext(ctx, "SELECT DISTINCT name, type, narg FROM pragma_function_list ORDER BY name, narg")
+	if err != nil {
+		log.Printf("Error listing functions: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error listing functions", err), nil
+	}
+	type function struct{ name, kind string }
+	arities := make(map[function][]string)
+	var order []function
+	available := make(map[string]bool)
+	for rows.Next() {
+		var f function
+		var narg int
+		if err := rows.Scan(&f.name, &f.kind, &narg); err != nil {
+			rows.Close()
+			return mcp.NewToolResultErrorFromErr("Error listing functions", err), nil
+		}
+		f.kind = functionKinds[f.kind]
+		arity := strconv.Itoa(narg)
+		if narg < 0 {
+			arity = "*"
+		}
+		if _, ok := arities[f]; !ok {
+			order = append(order, f)
+		}
+		arities[f] = append(arities[f], arity)
+		available["function:"+f.name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return mcp.NewToolResultErrorFromErr("Error listing functions", err), nil
+	}
+	for _, f := range order {
+		caps.Functions[f.kind] = append(caps.Functions[f.kind], fmt.Sprintf("%s(%s)", f.name, strings.Join(arities[f], "|")))
+	}
+
+	if caps.Collations, err = queryStringList(ctx, ds.db, "SELECT name FROM pragma_collation_list ORDER BY name"); err != nil {
+		log.Printf("Error listing collations: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error listing collations", err), nil
+	}
+	if caps.Modules, err = queryStringList(ctx, ds.db, "SELECT name FROM pragma_module_list ORDER BY name"); err != nil {
+		log.Printf("Error listing modules: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error listing virtual table modules", err), nil
+	}
+	for _, module := range caps.Modules {
+		available["module:"+module] = true
+	}
+	if caps.CompileOptions, err = queryStringList(ctx, ds.db, "SELECT compile_options FROM pragma_compile_options ORDER BY compile_options"); err != nil {
+		log.Printf("Error listing compile options: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error listing compile options", err), nil
+	}
+	for feature, probe := range sqlFeatures {
+		caps.Features[feature] = available[probe]
+	}
+
+	resultJSON, err := json.MarshalIndent(caps, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling capabilities to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting capabilities", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// queryStringList returns the single string column of query, never nil.
+func queryStringList(ctx context.Context, db *sql.DB, query string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// defaultChangePollInterval is how often the database is checked for changes
+// made by other processes.
+const defaultChangePollInterval = 5 * time.Second
+
+// changePollIntervalFromEnv reads CHANGE_POLL_INTERVAL; 0 disables change notifications.
+func changePollIntervalFromEnv() (time.Duration, error) {
+	v := os.Getenv("CHANGE_POLL_INTERVAL")
+	if v == "" {
+		return defaultChangePollInterval, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid CHANGE_POLL_INTERVAL %q", v)
+	}
+	return d, nil
+}
+
+// changeWatcher tells connected clients when another process changes the
+// SQLite database, so they know cached schema or results are stale.
+//
+// PRAGMA data_version only changes when other connections commit, and is
+// only comparable on the same connection, so one pooled connection is kept
+// for polling. Schema changes are told apart by PRAGMA schema_version.
+type changeWatcher struct {
+	db        *sql.DB
+	connector *initConnector
+	server    *server.MCPServer
+	interval  time.Duration
+
+	conn          *sql.Conn
+	generation    int64 // Connector generation conn was opened in
+	dataVersion   int64
+	schemaVersion int64
+}
+
+// Run polls until ctx is done.
+func (w *changeWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	defer w.release()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+func (w *changeWatcher) poll(ctx context.Context) {
+	replaced := false
+	if w.conn != nil && w.generation != w.connector.generation.Load() {
+		// The database file was replaced (Litestream restore); the old
+		// connection still sees the previous file
+		w.release()
+		replaced = true
+	}
+	first := w.conn == nil
+	if first {
+		conn, err := w.db.Conn(ctx)
+		if err != nil {
+			return // The database is unavailable; dbHealth reports it
+		}
+		w.conn, w.generation = conn, w.connector.generation.Load()
+	}
+
+	var dataVersion, schemaVersion int64
+	err := w.conn.QueryRowContext(ctx, `SELECT
+		(SELECT data_version FROM pragma_data_version),
+		(SELECT schema_version FROM pragma_schema_version)`).Scan(&dataVersion, &schemaVersion)
+	if err != nil {
+		log.Printf("Error polling for database changes: %v", err)
+		w.release()
+		return
+	}
+	schemaChanged := !first && schemaVersion != w.schemaVersion || replaced
+	dataChanged := !first && dataVersion != w.dataVersion || replaced
+	w.dataVersion, w.schemaVersion = dataVersion, schemaVersion
+
+	switch {
+	case schemaChanged:
+		log.Printf("Database schema changed (schema_version %d)", schemaVersion)
+		w.server.SendNotificationToAllClients(mcp.MethodNotificationResourcesListChanged, nil)
+		w.notify("schema_changed", "The database schema changed; re-read table definitions before relying on them.", schemaVersion)
+	case dataChanged:
+		w.notify("data_changed", "The database content changed; earlier query results may be stale.", schemaVersion)
+	}
+}
+
+// notify sends an MCP log message describing the change to every client.
+func (w *changeWatcher) notify(event, message string, schemaVersion int64) {
+	w.server.SendNotificationToAllClients("notifications/message", map[string]any{
+		"level":  mcp.LoggingLevelInfo,
+		"logger": "database",
+		"data": map[string]any{
+			"event":          event,
+			"message":        message,
+			"schema_version": schemaVersion,
+		},
+	})
+}
+
+func (w *changeWatcher) release() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
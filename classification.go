@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Sensitivity levels of the classification policy.
+var sensitivityLevels = map[string]bool{"public": true, "internal": true, "confidential": true}
+
+// Actions a classification rule takes on the data of a level.
+const (
+	actionAllow = "allow" // Return the data as is
+	actionMask  = "mask"  // Return the data redacted
+	actionHide  = "hide"  // Refuse reads and leave the data out of listings
+)
+
+// ClassificationPolicy is the document read from CLASSIFICATION_FILE. It
+// tags tables and columns with sensitivity levels and says, per role or
+// profile, what happens to the data of each level.
+type ClassificationPolicy struct {
+	Default string                       `json:"default"` // Level of unlisted tables (default public)
+	Tables  map[string]string            `json:"tables"`  // Level by table
+	Columns map[string]string            `json:"columns"` // Level by table.column, overriding the table's
+	Rules   map[string]map[string]string `json:"rules"`   // Action by level, per role; "*" for the others
+}
+
+// classifiedAccess is the classification policy resolved for one profile:
+// the action on each classified table and column.
+type classifiedAccess struct {
+	tables   map[string]string // Action by table
+	columns  map[string]string // Action by table.column
+	unlisted string            // Action on tables the policy does not list
+}
+
+// loadClassificationFromEnv reads CLASSIFICATION_FILE and applies its rules
+// to every profile, including the roles of ROLES_FILE.
+func loadClassificationFromEnv() error {
+	file := os.Getenv("CLASSIFICATION_FILE")
+	if file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading CLASSIFICATION_FILE: %w", err)
+	}
+	var policy ClassificationPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return fmt.Errorf("parsing CLASSIFICATION_FILE %s: %w", file, err)
+	}
+	if err := policy.validate(); err != nil {
+		return fmt.Errorf("CLASSIFICATION_FILE %s: %w", file, err)
+	}
+	for name, profile := range profiles {
+		profile.Classification = policy.resolve(name)
+	}
+	return nil
+}
+
+// validate checks the levels, actions and role names of the policy.
+func (p *ClassificationPolicy) validate() error {
+	if p.Default == "" {
+		p.Default = "public"
+	}
+	levels := []string{p.Default}
+	for _, level := range p.Tables {
+		levels = append(levels, level)
+	}
+	for _, level := range p.Columns {
+		levels = append(levels, level)
+	}
+	for _, level := range levels {
+		if !sensitivityLevels[level] {
+			return fmt.Errorf("unknown level %q (want public, internal or confidential)", level)
+		}
+	}
+	for role, actions := range p.Rules {
+		if profiles[role] == nil && role != "*" {
+			return fmt.Errorf("rules for unknown role %q", role)
+		}
+		for level, action := range actions {
+			if !sensitivityLevels[level] {
+				return fmt.Errorf("rules for %s: unknown level %q", role, level)
+			}
+			if action != actionAllow && action != actionMask && action != actionHide {
+				return fmt.Errorf("rules for %s: unknown action %q for %s (want allow, mask or hide)", role, action, level)
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the actions the policy takes for a profile, or nil when
+// it allows everything.
+func (p *ClassificationPolicy) resolve(profile string) *classifiedAccess {
+	rules, ok := p.Rules[profile]
+	if !ok {
+		rules = p.Rules["*"]
+	}
+	action := func(level string) string {
+		if a := rules[level]; a != "" {
+			return a
+		}
+		return actionAllow
+	}
+	c := &classifiedAccess{tables: make(map[string]string), columns: make(map[string]string), unlisted: action(p.Default)}
+	restricted := c.unlisted != actionAllow
+	for table, level := range p.Tables {
+		c.tables[strings.ToLower(table)] = action(level)
+		restricted = restricted || action(level) != actionAllow
+	}
+	for column, level := range p.Columns {
+		c.columns[strings.ToLower(column)] = action(level)
+		restricted = restricted || action(level) != actionAllow
+	}
+	if !restricted {
+		return nil
+	}
+	return c
+}
+
+// tableAction returns the action on table.
+func (c *classifiedAccess) tableAction(table string) string {
+	if action, ok := c.tables[table]; ok {
+		return action
+	}
+	return c.unlisted
+}
+
+// columnAction returns the action on a column of table, which is that of
+// the table unless the column is classified itself.
+func (c *classifiedAccess) columnAction(table, column string) string {
+	if action, ok := c.columns[table+"."+column]; ok {
+		return action
+	}
+	return c.tableAction(table)
+}
+
+// hidesColumns reports whether a column of table is hidden.
+func (c *classifiedAccess) hidesColumns(table string) bool {
+	prefix := table + "."
+	for key, action := range c.columns {
+		if action == actionHide && strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// masksAny reports whether some column is masked, wherever it comes from.
+func (c *classifiedAccess) masksAny(column string) bool {
+	for key, action := range c.columns {
+		if _, name, _ := cutLast(key, "."); name == column && action == actionMask {
+			return true
+		}
+	}
+	for _, action := range c.tables {
+		if action == actionMask {
+			return true
+		}
+	}
+	return c.unlisted == actionMask
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// clickhouseDialect introspects ClickHouse through its system tables. Tables
+// have no foreign keys; primary and sorting keys come from the table engine
+// and data skipping indexes are reported as indexes. CHECK constraints are
+// only visible in the DDL and are not reported.
+type clickhouseDialect struct {
+	db *sql.DB
+}
+
+func newClickHouseDialect(db *sql.DB, dsn string) Dialect {
+	return clickhouseDialect{db: db}
+}
+
+// clickhouseSystemDatabases are hidden from listings.
+const clickhouseSystemDatabases = "('system', 'INFORMATION_SCHEMA', 'information_schema')"
+
+func (d clickhouseDialect) ListSchemas(ctx context.Context) ([]SchemaInfo, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT database, count() FROM system.tables WHERE database NOT IN "+clickhouseSystemDatabases+
+		" AND NOT is_temporary GROUP BY database ORDER BY database")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []SchemaInfo
+	for rows.Next() {
+		var s SchemaInfo
+		if err := rows.Scan(&s.Name, &s.Tables); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, s)
+	}
+	return schemas, rows.Err()
+}
+
+// database is the condition on system tables' database column for a schema,
+// the current database when it is empty.
+func (d clickhouseDialect) database(schema string) (string, []interface{}) {
+	if schema == "" {
+		return "database = currentDatabase()", nil
+	}
+	return "database = ?", []interface{}{schema}
+}
+
+func (d clickhouseDialect) ListTables(ctx context.Context, schema string, includeViews bool) ([]string, error) {
+	cond, args := d.database(schema)
+	query := "SELECT name FROM system.tables WHERE " + cond + " AND NOT is_temporary"
+	if !includeViews {
+		query += " AND engine NOT IN ('View', 'MaterializedView', 'LiveView', 'WindowView')"
+	}
+	rows, err := d.db.QueryContext(ctx, query+" ORDER BY name", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func (d clickhouseDialect) DescribeTable(ctx context.Context, table string) (*TableDescription, error) {
+	schema, name := d.SplitTable(table)
+	cond, args := d.database(schema)
+	rows, err := d.db.QueryContext(ctx, "SELECT name, type, default_kind, default_expression, is_in_primary_key, is_in_sorting_key FROM system.columns WHERE "+
+		cond+" AND table = ? ORDER BY position", append(args, name)...)
+	if err != nil {
+		return nil, err
+	}
+	desc := &TableDescription{
+		Table:             table,
+		Columns:           []map[string]interface{}{},
+		ForeignKeys:       []ForeignKeyInfo{},
+		UniqueConstraints: [][]string{},
+		CheckConstraints:  []string{},
+	}
+	for rows.Next() {
+		var column, colType, defaultKind, defaultExpr string
+		var inPrimaryKey, inSortingKey int64
+		if err := rows.Scan(&column, &colType, &defaultKind, &defaultExpr, &inPrimaryKey, &inSortingKey); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var dflt interface{}
+		if defaultKind != "" {
+			dflt = defaultKind + " " + defaultExpr // DEFAULT, MATERIALIZED, ALIAS or EPHEMERAL
+		}
+		notNull := 1
+		if strings.HasPrefix(colType, "Nullable(") {
+			notNull = 0
+		}
+		desc.Columns = append(desc.Columns, map[string]interface{}{
+			"cid": len(desc.Columns), "name": column, "type": colType, "notnull": notNull, "dflt_value": dflt,
+			"pk": inPrimaryKey, "sorting_key": inSortingKey == 1,
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(desc.Columns) == 0 {
+		return desc, nil
+	}
+
+	if desc.PrimaryKey, err = d.PrimaryKey(ctx, table); err != nil {
+		return nil, err
+	}
+	for i, key := range desc.PrimaryKey {
+		for _, column := range desc.Columns {
+			if column["name"] == key {
+				column["pk"] = i + 1
+			}
+		}
+	}
+	if desc.Indexes, err = d.ListIndexes(ctx, table); err != nil {
+		return nil, err
+	}
+	if err := d.db.QueryRowContext(ctx, "SELECT engine FROM system.tables WHERE "+cond+" AND name = ?", append(args, name)...).Scan(&desc.Engine); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+// ListIndexes reports the data skipping indexes of a table with their expressions.
+func (d clickhouseDialect) ListIndexes(ctx context.Context, table string) ([]IndexInfo, error) {
+	schema, name := d.SplitTable(table)
+	cond, args := d.database(schema)
+	rows, err := d.db.QueryContext(ctx, "SELECT name, type, expr FROM system.data_skipping_indices WHERE "+cond+" AND table = ? ORDER BY name", append(args, name)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := []IndexInfo{}
+	for rows.Next() {
+		var idx IndexInfo
+		var indexType, expr string
+		if err := rows.Scan(&idx.Name, &indexType, &expr); err != nil {
+			return nil, err
+		}
+		idx.Origin = "skip_index:" + indexType
+		idx.Columns = []string{expr}
+		indexes = append(indexes, idx)
+	}
+	return indexes, rows.Err()
+}
+
+// ForeignKeys returns nothing: ClickHouse has no foreign keys.
+func (d clickhouseDialect) ForeignKeys(ctx context.Context, table string) ([]Relationship, error) {
+	return nil, nil
+}
+
+// PrimaryKey splits the primary key expression of the table engine. Keys made
+// of expressions rather than columns are returned as written.
+func (d clickhouseDialect) PrimaryKey(ctx context.Context, table string) ([]string, error) {
+	schema, name := d.SplitTable(table)
+	cond, args := d.database(schema)
+	var key string
+	err := d.db.QueryRowContext(ctx, "SELECT primary_key FROM system.tables WHERE "+cond+" AND name = ?", append(args, name)...).Scan(&key)
+	if err == sql.ErrNoRows || key == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := strings.Split(key, ",")
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+	return columns, nil
+}
+
+// SplitTable treats the part before the first dot as the database.
+func (d clickhouseDialect) SplitTable(table string) (schema, name string) {
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		return schema, name
+	}
+	return "", table
+}
+
+func (d clickhouseDialect) QuoteIdent(name string) string {
+	return quoteIdentifier(name)
+}
+
+// ValidateReadOnly accepts the statements ClickHouse can only read with:
+// SELECT (optionally after WITH, which ClickHouse only allows before SELECT),
+// SHOW, DESCRIBE, EXISTS and EXPLAIN.
+func (d clickhouseDialect) ValidateReadOnly(query string) error {
+	fields := strings.Fields(strings.ToUpper(query))
+	if len(fields) > 0 {
+		switch fields[0] {
+		case "SELECT", "WITH", "SHOW", "DESCRIBE", "DESC", "EXISTS", "EXPLAIN":
+			return nil
+		}
+	}
+	return fmt.Errorf("only SELECT, WITH, SHOW, DESCRIBE, EXISTS and EXPLAIN statements are allowed for read-only access")
+}
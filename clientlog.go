@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Client log defaults.
+const (
+	defaultClientLogLevel     = mcp.LoggingLevelNotice
+	defaultSlowQueryThreshold = 5 * time.Second
+)
+
+// logLevelRank orders the MCP logging levels by severity.
+var logLevelRank = map[mcp.LoggingLevel]int{
+	mcp.LoggingLevelDebug: 0, mcp.LoggingLevelInfo: 1, mcp.LoggingLevelNotice: 2, mcp.LoggingLevelWarning: 3,
+	mcp.LoggingLevelError: 4, mcp.LoggingLevelCritical: 5, mcp.LoggingLevelAlert: 6, mcp.LoggingLevelEmergency: 7,
+}
+
+// ClientLog forwards significant server events of a tool call, such as slow
+// queries, truncated results and policy denials, to the client as MCP log
+// notifications, so the client can show why a call behaved as it did. Each
+// session receives the events at or above the level it set with
+// logging/setLevel, or CLIENT_LOG_LEVEL until it sets one.
+type ClientLog struct {
+	server       *server.MCPServer // Set once the server is created
+	defaultLevel mcp.LoggingLevel
+	slowQuery    time.Duration // Calls running longer are reported; 0 disables
+
+	mu     sync.Mutex
+	levels map[string]sessionLogLevel // By session ID
+}
+
+// sessionLogLevel is the level a session set and when, to forget the
+// least recently set first.
+type sessionLogLevel struct {
+	level mcp.LoggingLevel
+	set   time.Time
+}
+
+// clientLogFromEnv reads CLIENT_LOG_LEVEL, the level of sessions that have
+// not set one (default notice, "off" disables forwarding), and
+// SLOW_QUERY_THRESHOLD (default 5s, 0 disables).
+func clientLogFromEnv() (*ClientLog, error) {
+	c := &ClientLog{defaultLevel: defaultClientLogLevel, slowQuery: defaultSlowQueryThreshold, levels: make(map[string]sessionLogLevel)}
+	if v := os.Getenv("CLIENT_LOG_LEVEL"); v == "off" {
+		c.defaultLevel = ""
+	} else if v != "" {
+		if _, ok := logLevelRank[mcp.LoggingLevel(v)]; !ok {
+			return nil, fmt.Errorf("invalid CLIENT_LOG_LEVEL %q (want debug, info, notice, warning, error or off)", v)
+		}
+		c.defaultLevel = mcp.LoggingLevel(v)
+	}
+	if v := os.Getenv("SLOW_QUERY_THRESHOLD"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid SLOW_QUERY_THRESHOLD %q", v)
+		}
+		c.slowQuery = d
+	}
+	return c, nil
+}
+
+// clientLogKey is a context key for the ClientLog of the tool call.
+type clientLogKey struct{}
+
+// notifyClient sends an event of the tool call to its client, if the
+// client's level lets it through.
+func notifyClient(ctx context.Context, level mcp.LoggingLevel, event, message string) {
+	c, _ := ctx.Value(clientLogKey{}).(*ClientLog)
+	if c == nil || c.server == nil {
+		return
+	}
+	threshold := c.levelOf(sessionID(ctx))
+	if threshold == "" || logLevelRank[level] < logLevelRank[threshold] {
+		return
+	}
+	err := c.server.SendNotificationToClient(ctx, "notifications/message", map[string]any{
+		"level":  level,
+		"logger": "db-mcp",
+		"data":   map[string]any{"event": event, "message": message},
+	})
+	if err != nil {
+		log.Printf("Error sending %s log notification: %v", event, err)
+	}
+}
+
+// levelOf returns the level of a session, or "" when nothing is forwarded.
+func (c *ClientLog) levelOf(session string) mcp.LoggingLevel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l, ok := c.levels[session]; ok {
+		return l.level
+	}
+	return c.defaultLevel
+}
+
+// setLevel records the level a session asked for.
+func (c *ClientLog) setLevel(session string, level mcp.LoggingLevel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.levels[session]; !ok && len(c.levels) >= maxTrackedSessions {
+		sessions := make([]string, 0, len(c.levels))
+		for id := range c.levels {
+			sessions = append(sessions, id)
+		}
+		sort.Slice(sessions, func(i, j int) bool { return c.levels[sessions[i]].set.Before(c.levels[sessions[j]].set) })
+		for _, id := range sessions[:len(sessions)/10+1] {
+			delete(c.levels, id)
+		}
+	}
+	c.levels[session] = sessionLogLevel{level: level, set: time.Now()}
+}
+
+// middleware makes the ClientLog available to handlers and reports slow
+// calls and calls refused by policy.
+func (c *ClientLog) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx = context.WithValue(ctx, clientLogKey{}, c)
+		start := time.Now()
+		result, err := next(ctx, request)
+		elapsed := time.Since(start)
+		if c.slowQuery > 0 && elapsed > c.slowQuery {
+			log.Printf("[%s] slow %s call: %s", requestIDFromContext(ctx), request.Params.Name, elapsed.Round(time.Millisecond))
+			notifyClient(ctx, mcp.LoggingLevelWarning, "slow_query", fmt.Sprintf(
+				"%s took %s, over the slow query threshold of %s.", request.Params.Name, elapsed.Round(time.Millisecond), c.slowQuery))
+		}
+		switch errorClass(result, err) {
+		case errorDenied:
+			notifyClient(ctx, mcp.LoggingLevelWarning, "policy_denial", fmt.Sprintf("%s was refused by the access policy: %s", request.Params.Name, resultMessage(result)))
+		case errorTimeout, errorBudget:
+			notifyClient(ctx, mcp.LoggingLevelWarning, "budget_exceeded", fmt.Sprintf("%s was stopped: %s", request.Params.Name, resultMessage(result)))
+		}
+		return result, err
+	}
+}
+
+// resultMessage returns the message of an error result, from the message
+// field of structured errors.
+func resultMessage(result *mcp.CallToolResult) string {
+	text := resultText(result)
+	var structured struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal([]byte(text), &structured) == nil && structured.Message != "" {
+		return structured.Message
+	}
+	return text
+}
+
+// setLevelHandler answers logging/setLevel requests itself: the sessions of
+// the streamable HTTP transport do not keep a level, so the server would
+// otherwise refuse them.
+func (c *ClientLog) setLevelHandler(next http.Handler) http.Handler {
+	setLevel := interceptRPC(next, string(mcp.MethodSetLogLevel), func(r *http.Request, params json.RawMessage) (any, error) {
+		var p struct {
+			Level mcp.LoggingLevel `json:"level"`
+		}
+		json.Unmarshal(params, &p)
+		if _, ok := logLevelRank[p.Level]; !ok {
+			return nil, fmt.Errorf("invalid logging level %q", p.Level)
+		}
+		c.setLevel(r.Header.Get("Mcp-Session-Id"), p.Level)
+		return map[string]any{}, nil
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Mcp-Session-Id") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		setLevel.ServeHTTP(w, r)
+	})
+}
+
+// interceptRPC answers JSON-RPC requests for method itself, before the MCP
+// server sees them, and passes every other request on. handle returns the
+// result, or an error reported as invalid params.
+func interceptRPC(next http.Handler, method string, handle func(r *http.Request, params json.RawMessage) (any, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, ok := peekBody(w, r)
+		if !ok {
+			return
+		}
+		var message struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if !bytes.Contains(body, []byte(`"`+method+`"`)) || json.Unmarshal(body, &message) != nil || message.Method != method {
+			next.ServeHTTP(w, r)
+			return
+		}
+		response := map[string]any{"jsonrpc": "2.0", "id": message.ID}
+		if result, err := handle(r, message.Params); err != nil {
+			response["error"] = map[string]any{"code": mcp.INVALID_PARAMS, "message": err.Error()}
+		} else {
+			response["result"] = result
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+// peekBody reads the request body and puts it back for the next handler.
+// On failure it answers the request and returns false.
+func peekBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return nil, false
+	} else if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
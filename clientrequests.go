@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	streamUpgradeTimeout  = 2 * time.Second // For the response to become an event stream
+	serverRequestIDPrefix = "server-"
+)
+
+var (
+	errRequestNotSent = errors.New("the request could not be sent to the client")
+	errClientTimeout  = errors.New("the client did not answer in time")
+)
+
+// ClientRequests sends requests to the client while a tool call runs, such
+// as elicitation/create and roots/list. The MCP server library only sends
+// notifications, so a request is written to the event stream of the call's
+// response, and the client's answer, which it posts as a request of its
+// own, is passed back to the call waiting for it.
+type ClientRequests struct {
+	server *server.MCPServer // Set once the server is created
+
+	mu       sync.Mutex
+	sessions map[string]clientSession   // Capabilities declared at initialization, by session
+	pending  map[string]*pendingRequest // By request ID
+}
+
+type clientSession struct {
+	capabilities map[string]bool
+	started      time.Time
+}
+
+// pendingRequest is a request waiting for the client's answer.
+type pendingRequest struct {
+	session string
+	answer  chan clientAnswer
+}
+
+type clientAnswer struct {
+	result json.RawMessage
+	err    json.RawMessage
+}
+
+func NewClientRequests() *ClientRequests {
+	return &ClientRequests{sessions: make(map[string]clientSession), pending: make(map[string]*pendingRequest)}
+}
+
+// streamWriter serializes writes to a response, so requests can be written
+// to the event stream the server opened for its notifications, and reports
+// when the response became an event stream.
+type streamWriter struct {
+	http.ResponseWriter
+	mu        sync.Mutex
+	once      sync.Once
+	streaming chan struct{} // Closed once the response is an event stream
+}
+
+// streamWriterKey is a context key for the streamWriter of the request.
+type streamWriterKey struct{}
+
+func (sw *streamWriter) WriteHeader(status int) {
+	if sw.Header().Get("Content-Type") == "text/event-stream" {
+		sw.once.Do(func() { close(sw.streaming) })
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *streamWriter) Flush() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeEvent writes a message to the event stream.
+func (sw *streamWriter) writeEvent(message any) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(sw, "event: message\ndata: %s\n\n", data)
+	sw.Flush()
+	return err
+}
+
+// handler records the capabilities each session's client declares,
+// delivers the answers clients post, and makes the response writable by
+// the calls that send requests.
+func (c *ClientRequests) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, ok := peekBody(w, r)
+		if !ok {
+			return
+		}
+		var message struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Result json.RawMessage `json:"result"`
+			Error  json.RawMessage `json:"error"`
+			Params struct {
+				Capabilities map[string]json.RawMessage `json:"capabilities"`
+			} `json:"params"`
+		}
+		json.Unmarshal(body, &message)
+		var id string
+		if message.Method == "" && json.Unmarshal(message.ID, &id) == nil && strings.HasPrefix(id, serverRequestIDPrefix) {
+			c.deliver(r.Header.Get("Mcp-Session-Id"), id, clientAnswer{result: message.Result, err: message.Error})
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		sw := &streamWriter{ResponseWriter: w, streaming: make(chan struct{})}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), streamWriterKey{}, sw)))
+		if message.Method == string(mcp.MethodInitialize) {
+			if session := w.Header().Get("Mcp-Session-Id"); session != "" {
+				c.addSession(session, message.Params.Capabilities)
+			}
+		}
+	})
+}
+
+// addSession records the capabilities of a session's client, forgetting
+// the oldest tenth of sessions once maxTrackedSessions are tracked.
+func (c *ClientRequests) addSession(session string, capabilities map[string]json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.sessions) >= maxTrackedSessions {
+		var oldest []string
+		for id := range c.sessions {
+			oldest = append(oldest, id)
+		}
+		sort.Slice(oldest, func(i, j int) bool { return c.sessions[oldest[i]].started.Before(c.sessions[oldest[j]].started) })
+		for _, id := range oldest[:len(oldest)/10+1] {
+			delete(c.sessions, id)
+		}
+	}
+	names := make(map[string]bool, len(capabilities))
+	for name := range capabilities {
+		names[name] = true
+	}
+	c.sessions[session] = clientSession{capabilities: names, started: time.Now()}
+}
+
+// supports reports whether a session's client declared a capability.
+func (c *ClientRequests) supports(session, capability string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessions[session].capabilities[capability]
+}
+
+// deliver passes an answer to the call waiting for it, if it came from the
+// session that was asked.
+func (c *ClientRequests) deliver(session, id string, answer clientAnswer) {
+	c.mu.Lock()
+	p, ok := c.pending[id]
+	c.mu.Unlock()
+	if !ok || p.session != session {
+		log.Printf("Ignoring answer to unknown request %s", id)
+		return
+	}
+	select {
+	case p.answer <- answer:
+	default:
+	}
+}
+
+// request sends a request to the client of the call in ctx and waits up to
+// timeout for its result. The server's event stream is opened with a
+// notifications/message carrying event and message, which also tells the
+// user what the call waits for.
+func (c *ClientRequests) request(ctx context.Context, method string, params any, event, message string, timeout time.Duration) (json.RawMessage, error) {
+	sw, _ := ctx.Value(streamWriterKey{}).(*streamWriter)
+	if sw == nil || c.server == nil {
+		return nil, errRequestNotSent
+	}
+	c.server.SendNotificationToClient(ctx, "notifications/message", map[string]any{
+		"level":  mcp.LoggingLevelNotice,
+		"logger": "db-mcp",
+		"data":   map[string]any{"event": event, "message": message},
+	})
+	select {
+	case <-sw.streaming:
+	case <-time.After(streamUpgradeTimeout):
+		return nil, errRequestNotSent
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	id := serverRequestIDPrefix + hex.EncodeToString(b)
+	p := &pendingRequest{session: sessionID(ctx), answer: make(chan clientAnswer, 1)}
+	c.mu.Lock()
+	c.pending[id] = p
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := sw.writeEvent(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+		log.Printf("[%s] Error sending %s request: %v", requestIDFromContext(ctx), method, err)
+		return nil, errRequestNotSent
+	}
+
+	select {
+	case answer := <-p.answer:
+		if len(answer.err) > 0 && string(answer.err) != "null" {
+			return nil, fmt.Errorf("the client answered %s with an error: %s", method, answer.err)
+		}
+		return answer.result, nil
+	case <-time.After(timeout):
+		return nil, errClientTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultStatsSampleSize is the number of rows above which column_stats works
+// on a random sample instead of the whole source. The sample is drawn in one
+// pass over the source, so large tables are not sorted to shuffle them.
+const defaultStatsSampleSize = 100000
+
+// ColumnSummary holds descriptive statistics of a numeric column.
+type ColumnSummary struct {
+	Count      int      `json:"count"`
+	Nulls      int      `json:"nulls"`
+	NonNumeric int      `json:"non_numeric,omitempty"`
+	Mean       *float64 `json:"mean"`
+	StdDev     *float64 `json:"stddev"`
+	Min        *float64 `json:"min"`
+	P25        *float64 `json:"p25"`
+	Median     *float64 `json:"median"`
+	P75        *float64 `json:"p75"`
+	Max        *float64 `json:"max"`
+}
+
+// ColumnStats is the result of column_stats.
+type ColumnStats struct {
+	Rows         int64                          `json:"rows"`
+	SampledRows  int                            `json:"sampled_rows,omitempty"`
+	Columns      map[string]ColumnSummary       `json:"columns"`
+	Correlations map[string]map[string]*float64 `json:"correlations,omitempty"`
+}
+
+// columnStatsHandler computes descriptive statistics and pairwise Pearson
+// correlations for numeric columns, sampling large sources.
+func (ds *DatabaseService) columnStatsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	source, err := ds.sourceRelation(ctx, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	columns := request.GetStringSlice("columns", nil)
+	if len(columns) == 0 || slices.Contains(columns, "") {
+		return mcp.NewToolResultError("Missing or invalid 'columns' argument."), nil
+	}
+	if columns, err = ds.resolveColumns(ctx, source, columns...); err != nil {
+		return columnErrorResult(err), nil
+	}
+	if result := maskedColumnResult(ctx, columns...); result != nil {
+		return result, nil
+	}
+	sampleSize := defaultStatsSampleSize
+	if v, ok := args["sample_size"].(float64); ok && v > 0 {
+		sampleSize = int(v)
+	}
+	if maxRows := ds.limitsFor(ctx).MaxRows; maxRows > 0 && sampleSize > maxRows {
+		sampleSize = maxRows
+	}
+
+	stats := ColumnStats{Columns: make(map[string]ColumnSummary)}
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(column)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), source)
+
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("Error executing column stats query: %v, Query: %s", err, logSQL(query))
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error reading columns", err), nil
+	}
+	defer rows.Close()
+
+	// Reservoir sampling: row n replaces a random kept row with probability
+	// sampleSize/n, which leaves every row equally likely to be kept
+	var sample [][]interface{}
+	valuePtrs := make([]interface{}, len(columns))
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return mcp.NewToolResultErrorFromErr("Error reading result row", err), nil
+		}
+		if stats.Rows++; len(sample) < sampleSize {
+			sample = append(sample, values)
+		} else if j := rand.Int63n(stats.Rows); j < int64(sampleSize) {
+			sample[j] = values
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating column stats rows: %v", err)
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error iterating through results", err), nil
+	}
+	addRowsReturned(ctx, len(sample))
+	if int64(len(sample)) < stats.Rows {
+		stats.SampledRows = len(sample)
+	}
+
+	// Missing and non-numeric values are kept as NaN so rows stay aligned for correlations
+	data := make([][]float64, len(columns))
+	summaries := make([]ColumnSummary, len(columns))
+	for _, values := range sample {
+		for i, v := range values {
+			f := numericValue(v)
+			switch {
+			case v == nil:
+				summaries[i].Nulls++
+			case math.IsNaN(f):
+				summaries[i].NonNumeric++
+			}
+			data[i] = append(data[i], f)
+		}
+	}
+
+	for i, column := range columns {
+		stats.Columns[column] = describeColumn(data[i], summaries[i])
+	}
+	if len(columns) > 1 {
+		stats.Correlations = make(map[string]map[string]*float64)
+		for i, a := range columns {
+			stats.Correlations[a] = make(map[string]*float64)
+			for j, b := range columns {
+				if i != j {
+					stats.Correlations[a][b] = pearson(data[i], data[j])
+				}
+			}
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling column stats to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting column stats", err), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// numericValue converts a scanned value to float64, parsing numeric text. It
+// returns NaN for NULL and non-numeric values.
+func numericValue(v interface{}) float64 {
+	switch n := v.(type) {
+	case []byte:
+		return numericValue(string(n))
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(n), 64); err == nil {
+			return f
+		}
+		return math.NaN()
+	default:
+		return toFloat(v)
+	}
+}
+
+// describeColumn fills the descriptive statistics of the non-NaN values.
+func describeColumn(data []float64, summary ColumnSummary) ColumnSummary {
+	var xs []float64
+	for _, x := range data {
+		if !math.IsNaN(x) {
+			xs = append(xs, x)
+		}
+	}
+	summary.Count = len(xs)
+	if len(xs) == 0 {
+		return summary
+	}
+	sort.Float64s(xs)
+
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+	summary.Mean = floatPtr(mean)
+	if len(xs) > 1 {
+		ss := 0.0
+		for _, x := range xs {
+			ss += (x - mean) * (x - mean)
+		}
+		summary.StdDev = floatPtr(math.Sqrt(ss / float64(len(xs)-1)))
+	}
+	summary.Min = floatPtr(xs[0])
+	summary.P25 = floatPtr(quantile(xs, 0.25))
+	summary.Median = floatPtr(quantile(xs, 0.5))
+	summary.P75 = floatPtr(quantile(xs, 0.75))
+	summary.Max = floatPtr(xs[len(xs)-1])
+	return summary
+}
+
+// quantile returns the q-th quantile of sorted xs by linear interpolation.
+func quantile(xs []float64, q float64) float64 {
+	pos := q * float64(len(xs)-1)
+	lower := int(math.Floor(pos))
+	if lower+1 >= len(xs) {
+		return xs[lower]
+	}
+	return xs[lower] + (pos-float64(lower))*(xs[lower+1]-xs[lower])
+}
+
+// pearson returns the correlation of xs and ys over the rows where both are
+// numeric, or nil when it is undefined.
+func pearson(xs, ys []float64) *float64 {
+	var n, sx, sy, sxx, syy, sxy float64
+	for i := range xs {
+		if math.IsNaN(xs[i]) || math.IsNaN(ys[i]) {
+			continue
+		}
+		n++
+		sx += xs[i]
+		sy += ys[i]
+		sxx += xs[i] * xs[i]
+		syy += ys[i] * ys[i]
+		sxy += xs[i] * ys[i]
+	}
+	den := math.Sqrt(n*sxx-sx*sx) * math.Sqrt(n*syy-sy*sy)
+	if n < 2 || den == 0 || math.IsNaN(den) {
+		return nil
+	}
+	return floatPtr((n*sxy - sx*sy) / den)
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultCompareSample is the number of differing rows compare_queries shows
+// from each side by default.
+const defaultCompareSample = 10
+
+// QueryComparison is the payload returned by compare_queries. Rows are
+// compared as multisets unless ordered is set, so duplicates count.
+type QueryComparison struct {
+	Identical       bool            `json:"identical"`
+	Ordered         bool            `json:"ordered"`
+	Columns         []string        `json:"columns"`                    // Columns of the first query
+	OtherColumns    []string        `json:"other_columns,omitempty"`    // Columns of the second query, when they differ
+	Rows            int             `json:"rows"`                       // Rows returned by the first query
+	OtherRows       int             `json:"other_rows"`                 // Rows returned by the second query
+	OnlyInFirst     int             `json:"only_in_first"`              // Rows missing from the second result
+	OnlyInSecond    int             `json:"only_in_second"`             // Rows missing from the first result
+	FirstDifference int             `json:"first_difference,omitempty"` // 1-based position of the first differing row, when ordered
+	FirstSample     [][]interface{} `json:"first_sample,omitempty"`     // Rows only in the first result
+	SecondSample    [][]interface{} `json:"second_sample,omitempty"`    // Rows only in the second result
+}
+
+// queryResult is a result set with a comparison key per row.
+type queryResult struct {
+	columns []string
+	rows    [][]interface{}
+	keys    []string
+}
+
+// compareQueriesHandler runs two SELECTs on one snapshot and reports whether
+// they return the same rows, with a sample of the rows that differ.
+func (ds *DatabaseService) compareQueriesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	first, _ := args["first"].(string)
+	second, _ := args["second"].(string)
+	if first == "" || second == "" {
+		return mcp.NewToolResultError("Missing or invalid 'first' or 'second' argument."), nil
+	}
+	for _, query := range []string{first, second} {
+		if err := ds.validateReadOnly(query); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+	ordered, _ := args["ordered"].(bool)
+	sample := defaultCompareSample
+	if v, ok := args["sample"].(float64); ok && v >= 0 {
+		sample = int(math.Min(v, math.MaxInt32))
+	}
+	limits := ds.limitsFor(ctx)
+
+	conn, err := ds.db.Conn(ctx)
+	if err != nil {
+		log.Printf("Error getting connection: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error getting database connection", err), nil
+	}
+	defer conn.Close()
+	var q queryContexter = conn
+	if tx, err := conn.BeginTx(ctx, nil); err == nil {
+		defer tx.Rollback()
+		q = tx
+	}
+
+	results := make([]*queryResult, 2)
+	for i, query := range []string{first, second} {
+		if results[i], err = readComparable(ctx, q, query, limits.MaxRows); err != nil {
+			log.Printf("Error executing query: %v, Query: %s", err, logSQL(query))
+			if result := limits.budgetError(ctx, err); result != nil {
+				return result, nil
+			}
+			return mcp.NewToolResultErrorFromErr("Error executing query", err), nil
+		}
+	}
+	comparison := compareResults(results[0], results[1], ordered, sample)
+
+	resultJSON, err := json.MarshalIndent(comparison, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling comparison to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting comparison", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// readComparable reads every row of query, normalized as read_query returns
+// it, failing once more than maxRows rows are read.
+func readComparable(ctx context.Context, q queryContexter, query string, maxRows int) (*queryResult, error) {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	result := &queryResult{columns: make([]string, len(columnTypes))}
+	for i, ct := range columnTypes {
+		result.columns[i] = ct.Name()
+	}
+	masks := masksFor(ctx, result.columns)
+	values := make([]interface{}, len(columnTypes))
+	valuePtrs := make([]interface{}, len(columnTypes))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if maxRows > 0 && len(result.rows) >= maxRows {
+			return nil, errRowBudgetExceeded
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+		row := make([]interface{}, len(values))
+		for i, v := range values {
+			row[i] = masks.apply(i, normalizeValue(v, columnTypes[i].DatabaseTypeName()))
+		}
+		key, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		result.rows = append(result.rows, row)
+		result.keys = append(result.keys, string(key))
+	}
+	return result, rows.Err()
+}
+
+// compareResults diffs two result sets, keeping up to sample differing rows
+// from each side.
+func compareResults(a, b *queryResult, ordered bool, sample int) QueryComparison {
+	c := QueryComparison{Ordered: ordered, Columns: a.columns, Rows: len(a.rows), OtherRows: len(b.rows)}
+	if !slices.Equal(a.columns, b.columns) {
+		c.OtherColumns = b.columns
+	}
+
+	// Count the rows of b, then take away the rows of a that match one
+	remaining := make(map[string]int, len(b.keys))
+	for _, key := range b.keys {
+		remaining[key]++
+	}
+	for i, key := range a.keys {
+		if remaining[key] > 0 {
+			remaining[key]--
+			continue
+		}
+		if c.OnlyInFirst++; len(c.FirstSample) < sample {
+			c.FirstSample = append(c.FirstSample, a.rows[i])
+		}
+	}
+	for i, key := range b.keys {
+		if remaining[key] > 0 {
+			remaining[key]--
+			if c.OnlyInSecond++; len(c.SecondSample) < sample {
+				c.SecondSample = append(c.SecondSample, b.rows[i])
+			}
+		}
+	}
+
+	c.Identical = c.OnlyInFirst == 0 && c.OnlyInSecond == 0 && c.OtherColumns == nil
+	if ordered {
+		for i := 0; i < max(len(a.keys), len(b.keys)); i++ {
+			if i >= len(a.keys) || i >= len(b.keys) || a.keys[i] != b.keys[i] {
+				c.FirstDifference = i + 1
+				c.Identical = false
+				break
+			}
+		}
+	}
+	return c
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	maxCompletionValues = 100 // The most a completion result may hold
+	completionTimeout   = 5 * time.Second
+)
+
+// Completer answers completion/complete requests with the live schema:
+// table names for the table arguments of tools and the name variable of
+// db://tables/{name}, and column names for column arguments, of the table
+// given in the request's context arguments. Only the tables and columns the
+// caller's role may read are offered.
+type Completer struct {
+	ds       *DatabaseService
+	profiles *Profiles
+	identity *Identity
+}
+
+// completionRequest is the params of completion/complete. Tools are
+// referenced as {"type": "ref/tool", "name": …}, next to the prompt and
+// resource references of the protocol.
+type completionRequest struct {
+	Ref struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+		URI  string `json:"uri"`
+	} `json:"ref"`
+	Argument struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"argument"`
+	Context struct {
+		Arguments map[string]string `json:"arguments"`
+	} `json:"context"`
+}
+
+// handler answers completion/complete before the MCP server, which does
+// not implement it.
+func (c *Completer) handler(next http.Handler) http.Handler {
+	return interceptRPC(next, "completion/complete", c.complete)
+}
+
+func (c *Completer) complete(r *http.Request, params json.RawMessage) (any, error) {
+	var request completionRequest
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, fmt.Errorf("invalid completion request: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(c.identity.contextFunc(r.Context(), r), completionTimeout)
+	defer cancel()
+	profile := c.profiles.forContext(ctx)
+
+	var candidates []string
+	var err error
+	name := request.Argument.Name
+	switch {
+	case request.Ref.Type == "ref/resource" && request.Ref.URI == tableURIPrefix+"{name}" && name == "name":
+		candidates, err = c.ds.readableTables(ctx, profile)
+	case request.Ref.Type != "ref/tool" || !profile.allows(request.Ref.Name):
+	case isTableArgument(name):
+		candidates, err = c.ds.readableTables(ctx, profile)
+	case isColumnArgument(name):
+		candidates, err = c.ds.readableColumns(ctx, profile, columnTable(name, request.Context.Arguments))
+	}
+	if err != nil {
+		// Completion is a convenience: offer nothing rather than fail
+		log.Printf("Error completing %s argument %s: %v", request.Ref.Name, name, err)
+	}
+
+	values := matchCompletions(candidates, request.Argument.Value)
+	if values == nil {
+		values = []string{}
+	}
+	var result mcp.CompleteResult
+	result.Completion.Values = values[:min(len(values), maxCompletionValues)]
+	result.Completion.Total = len(values)
+	result.Completion.HasMore = len(values) > maxCompletionValues
+	return result, nil
+}
+
+// isTableArgument reports whether a tool argument names a table.
+func isTableArgument(name string) bool {
+	for _, table := range tableArguments {
+		if name == table {
+			return true
+		}
+	}
+	return false
+}
+
+// isColumnArgument reports whether a tool argument names columns of a table.
+func isColumnArgument(name string) bool {
+	return name == "column" || name == "columns" || strings.HasSuffix(name, "_column") || strings.HasSuffix(name, "_columns")
+}
+
+// columnTable returns the table a column argument refers to among the other
+// arguments: child_columns to child_table, parent_columns to parent_table,
+// and the others to table or table_name.
+func columnTable(column string, args map[string]string) string {
+	for _, prefix := range []string{"child_", "parent_"} {
+		if strings.HasPrefix(column, prefix) {
+			return args[prefix+"table"]
+		}
+	}
+	if table := args["table"]; table != "" {
+		return table
+	}
+	return args["table_name"]
+}
+
+// readableColumns returns the columns of table the caller may read, or none
+// when the table is unknown or outside the caller's role.
+func (ds *DatabaseService) readableColumns(ctx context.Context, profile *Profile, table string) ([]string, error) {
+	if table == "" {
+		return nil, nil
+	}
+	resolved, err := ds.resolveTable(ctx, table)
+	if err != nil {
+		return nil, nil
+	}
+	access := ds.accessName(resolved)
+	if !profile.readsTable(access) {
+		return nil, nil
+	}
+	description, err := ds.dialect.DescribeTable(ctx, resolved)
+	if err != nil {
+		return nil, err
+	}
+	var columns []string
+	for _, column := range description.Columns {
+		name, _ := column["name"].(string)
+		if profile.Classification != nil && profile.Classification.columnAction(access, strings.ToLower(name)) == actionHide {
+			continue
+		}
+		columns = append(columns, name)
+	}
+	return columns, nil
+}
+
+// matchCompletions returns the candidates starting with value, then those
+// containing it elsewhere, ignoring case.
+func matchCompletions(candidates []string, value string) []string {
+	value = strings.ToLower(value)
+	var prefixed, containing []string
+	for _, candidate := range candidates {
+		lower := strings.ToLower(candidate)
+		if strings.HasPrefix(lower, value) {
+			prefixed = append(prefixed, candidate)
+		} else if strings.Contains(lower, value) {
+			containing = append(containing, candidate)
+		}
+	}
+	sort.Strings(prefixed)
+	sort.Strings(containing)
+	return append(prefixed, containing...)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Defaults for the concurrent query limiter.
+const (
+	defaultMaxConcurrentQueries = 4
+	defaultQueueTimeout         = 10 * time.Second
+)
+
+// unqueuedTools never run a query, so they skip the limiter: an
+// administrator must be able to list and kill queries, and health report
+// on the server, while every slot is taken.
+var unqueuedTools = map[string]bool{
+	"running_queries": true, "kill_query": true, "health": true, "session_stats": true,
+	"server_stats": true, "usage_report": true, "fetch_result": true, "format_sql": true,
+}
+
+// queryLimiter allows at most a fixed number of tool calls to run at once.
+// Additional calls wait in line until a slot frees up or the queue timeout expires.
+type queryLimiter struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// newQueryLimiterFromEnv reads MAX_CONCURRENT_QUERIES and QUEUE_TIMEOUT.
+func newQueryLimiterFromEnv() (*queryLimiter, error) {
+	maxConcurrent := defaultMaxConcurrentQueries
+	if v := os.Getenv("MAX_CONCURRENT_QUERIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid MAX_CONCURRENT_QUERIES %q", v)
+		}
+		maxConcurrent = n
+	}
+
+	queueTimeout := defaultQueueTimeout
+	if v := os.Getenv("QUEUE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid QUEUE_TIMEOUT %q", v)
+		}
+		queueTimeout = d
+	}
+
+	return &queryLimiter{
+		slots:        make(chan struct{}, maxConcurrent),
+		queueTimeout: queueTimeout,
+	}, nil
+}
+
+// capacity returns the maximum number of concurrently running calls.
+func (q *queryLimiter) capacity() int {
+	return cap(q.slots)
+}
+
+// acquire waits for a free slot. It returns false if the queue timeout expires
+// or the caller goes away first.
+func (q *queryLimiter) acquire(ctx context.Context) bool {
+	select {
+	case q.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(q.queueTimeout)
+	defer timer.Stop()
+	select {
+	case q.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (q *queryLimiter) release() {
+	<-q.slots
+}
+
+// middleware queues tool calls behind the limiter, except unqueuedTools.
+func (q *queryLimiter) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if unqueuedTools[request.Params.Name] {
+			return next(ctx, request)
+		}
+		if !q.acquire(ctx) {
+			log.Printf("Rejected %s call: no query slot free within %s", request.Params.Name, q.queueTimeout)
+			payload, _ := json.MarshalIndent(map[string]string{
+				"error":   "server_busy",
+				"message": fmt.Sprintf("Server busy: %d queries already running and no slot became free within %s.", q.capacity(), q.queueTimeout),
+				"hint":    "Retry shortly, or issue tool calls sequentially instead of in parallel.",
+			}, "", "  ")
+			return mcp.NewToolResultError(string(payload)), nil
+		}
+		defer q.release()
+		return next(ctx, request)
+	}
+}
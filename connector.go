@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// initConnector opens driver connections and runs a fixed list of setup
+// statements on each one before handing it to the database/sql pool.
+// Connection-scoped state (ATTACHed databases, PRAGMAs) would otherwise only
+// exist on whichever pooled connection happened to run the statement.
+//
+// Connections are tagged with a generation; invalidate() makes every existing
+// connection stale so the pool discards it instead of reusing it. This is used
+// when the database file is replaced underneath the server, and by reopen
+// when use_database switches to another file.
+type initConnector struct {
+	driver driver.Driver
+	mu     sync.Mutex
+	dsn    string // Guarded by mu
+	init   []string
+	keys   []string // SQLCipher keys to try, current first; none for plain files
+
+	generation  atomic.Int64
+	previousKey atomic.Bool // A connection was opened with a previous key
+}
+
+// Connect implements driver.Connector.
+func (c *initConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var conn driver.Conn
+	var err error
+	if len(c.keys) > 0 {
+		conn, _, err = c.unlock(ctx)
+	} else {
+		conn, err = c.driver.Open(c.currentDSN())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stmt := range c.init {
+		if err := execConn(ctx, conn, stmt); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to run connection setup %q: %w", stmt, err)
+		}
+	}
+	return &pooledConn{Conn: conn, connector: c, generation: c.generation.Load()}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *initConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// invalidate marks all currently open connections as stale.
+func (c *initConnector) invalidate() {
+	c.generation.Add(1)
+}
+
+// currentDSN returns the DSN new connections are opened with.
+func (c *initConnector) currentDSN() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dsn
+}
+
+// reopen opens new connections with dsn and marks the open ones as stale.
+func (c *initConnector) reopen(dsn string) {
+	c.mu.Lock()
+	c.dsn = dsn
+	c.mu.Unlock()
+	c.invalidate()
+}
+
+// execConn executes a statement without arguments directly on a driver connection.
+func execConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		return err
+	}
+
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(nil)
+	return err
+}
+
+// pooledConn wraps a driver connection, forwarding the optional database/sql
+// interfaces and reporting itself invalid once its generation is stale.
+type pooledConn struct {
+	driver.Conn
+	connector  *initConnector
+	generation int64
+}
+
+func (pc *pooledConn) stale() bool {
+	return pc.generation != pc.connector.generation.Load()
+}
+
+// IsValid implements driver.Validator.
+func (pc *pooledConn) IsValid() bool {
+	if pc.stale() {
+		return false
+	}
+	if v, ok := pc.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// ResetSession implements driver.SessionResetter.
+func (pc *pooledConn) ResetSession(ctx context.Context) error {
+	if pc.stale() {
+		return driver.ErrBadConn
+	}
+	if r, ok := pc.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// Ping implements driver.Pinger.
+func (pc *pooledConn) Ping(ctx context.Context) error {
+	if p, ok := pc.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// QueryContext implements driver.QueryerContext.
+func (pc *pooledConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := pc.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// ExecContext implements driver.ExecerContext.
+func (pc *pooledConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := pc.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (pc *pooledConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := pc.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return pc.Conn.Prepare(query)
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (pc *pooledConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := pc.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, fmt.Errorf("driver does not support transaction options")
+	}
+	return pc.Conn.Begin()
+}
+
+// CheckNamedValue implements driver.NamedValueChecker.
+func (pc *pooledConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if c, ok := pc.Conn.(driver.NamedValueChecker); ok {
+		return c.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultCORSHeaders are the request headers browser MCP clients send.
+const defaultCORSHeaders = "Content-Type, Accept, Authorization, Mcp-Session-Id, Mcp-Protocol-Version, Last-Event-ID"
+
+// corsExposedHeaders are the response headers scripts may read: the session
+// ID is needed for every call after initialize.
+const corsExposedHeaders = "Mcp-Session-Id, X-Request-ID"
+
+// CORSConfig lets browser-based clients on other origins call the server.
+type CORSConfig struct {
+	Origins     map[string]bool // Allowed origins; "*" allows any
+	Headers     string          // Allowed request headers
+	Credentials bool            // Allow cookies and HTTP authentication
+}
+
+// corsConfigFromEnv reads CORS_ALLOWED_ORIGINS, CORS_ALLOWED_HEADERS and
+// CORS_ALLOW_CREDENTIALS. It returns nil when no origin is allowed.
+func corsConfigFromEnv() (*CORSConfig, error) {
+	origins := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if origins == "" {
+		return nil, nil
+	}
+	cfg := &CORSConfig{Origins: make(map[string]bool), Headers: defaultCORSHeaders}
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			cfg.Origins[origin] = true
+		}
+	}
+	if v := os.Getenv("CORS_ALLOWED_HEADERS"); v != "" {
+		cfg.Headers = v
+	}
+	if v := os.Getenv("CORS_ALLOW_CREDENTIALS"); v != "" {
+		credentials, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CORS_ALLOW_CREDENTIALS %q", v)
+		}
+		cfg.Credentials = credentials
+	}
+	if cfg.Credentials && cfg.Origins["*"] {
+		return nil, fmt.Errorf("CORS_ALLOW_CREDENTIALS requires explicit CORS_ALLOWED_ORIGINS, not *")
+	}
+	return cfg, nil
+}
+
+// handler adds CORS headers for allowed origins and answers preflight
+// requests itself.
+func (c *CORSConfig) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !c.Origins["*"] && !c.Origins[origin] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		if c.Credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", c.Headers)
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DatabaseInfo is the payload returned by the database_info tool.
+type DatabaseInfo struct {
+	File           string `json:"file"`
+	Name           string `json:"name,omitempty"`
+	SQLiteVersion  string `json:"sqlite_version"`
+	UserVersion    int64  `json:"user_version"`
+	ApplicationID  int64  `json:"application_id"`
+	ApplicationHex string `json:"application_id_hex"`
+	PageSize       int64  `json:"page_size"`
+	PageCount      int64  `json:"page_count"`
+	SizeBytes      int64  `json:"size_bytes"`
+	JournalMode    string `json:"journal_mode"`
+	Encoding       string `json:"encoding"`
+	TableCount     int64  `json:"table_count"`
+	ViewCount      int64  `json:"view_count"`
+	IndexCount     int64  `json:"index_count"`
+	TriggerCount   int64  `json:"trigger_count"`
+
+	Driver           string   `json:"driver"`            // Driver used for this database
+	SupportedDrivers []string `json:"supported_drivers"` // Drivers compiled into the server
+}
+
+// parseApplicationNames parses APPLICATION_NAMES, a comma separated list of id=name
+// pairs. Ids may be decimal or 0x-prefixed hex, e.g. "0x0f055112=fossil,42=billing".
+func parseApplicationNames(value string) (map[int64]string, error) {
+	names := make(map[int64]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, name, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid application name mapping %q, expected id=name", pair)
+		}
+		appID, err := strconv.ParseInt(strings.TrimSpace(id), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid application id %q: %w", id, err)
+		}
+		names[appID] = strings.TrimSpace(name)
+	}
+	return names, nil
+}
+
+// databaseInfoHandler reports general information about the database file,
+// including PRAGMA user_version and application_id.
+func (ds *DatabaseService) databaseInfoHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	info := DatabaseInfo{File: ds.databaseFile(), Driver: ds.driverName, SupportedDrivers: driverNames()}
+	if _, ok := ds.dialect.(sqliteDialect); !ok {
+		// The remaining fields come from SQLite pragmas
+		resultJSON, err := json.MarshalIndent(map[string]interface{}{
+			"database":          info.File,
+			"driver":            info.Driver,
+			"supported_drivers": info.SupportedDrivers,
+		}, "", "  ")
+		if err != nil {
+			log.Printf("Error marshalling database info to JSON: %v", err)
+			return mcp.NewToolResultErrorFromErr("Error formatting database info", err), nil
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	}
+
+	err := ds.db.QueryRowContext(ctx, `SELECT
+		sqlite_version(),
+		(SELECT user_version FROM pragma_user_version),
+		(SELECT application_id FROM pragma_application_id),
+		(SELECT page_size FROM pragma_page_size),
+		(SELECT page_count FROM pragma_page_count),
+		(SELECT journal_mode FROM pragma_journal_mode),
+		(SELECT encoding FROM pragma_encoding),
+		(SELECT count(*) FROM sqlite_schema WHERE type='table' AND name NOT LIKE 'sqlite_%'),
+		(SELECT count(*) FROM sqlite_schema WHERE type='view'),
+		(SELECT count(*) FROM sqlite_schema WHERE type='index'),
+		(SELECT count(*) FROM sqlite_schema WHERE type='trigger')`).Scan(
+		&info.SQLiteVersion,
+		&info.UserVersion,
+		&info.ApplicationID,
+		&info.PageSize,
+		&info.PageCount,
+		&info.JournalMode,
+		&info.Encoding,
+		&info.TableCount,
+		&info.ViewCount,
+		&info.IndexCount,
+		&info.TriggerCount,
+	)
+	if err != nil {
+		log.Printf("Error reading database info: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error reading database info", err), nil
+	}
+
+	info.SizeBytes = info.PageSize * info.PageCount
+	info.ApplicationHex = fmt.Sprintf("0x%08x", uint32(info.ApplicationID))
+	info.Name = ds.applicationNames[info.ApplicationID]
+
+	resultJSON, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling database info to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting database info", err), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// The DDL tools build CREATE TABLE, CREATE INDEX and DROP TABLE statements
+// from structured arguments. Without confirm=true they only return the
+// statement, so the agent (or its user) can check it before it is executed on
+// the write pool.
+
+var (
+	// newIdentifierPattern restricts the names of new tables, columns and indexes.
+	newIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// columnTypePattern matches declared types such as INTEGER, VARCHAR(20) or DECIMAL(10, 2).
+	columnTypePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_ ]*(\(\s*\d+\s*(,\s*\d+\s*)?\))?$`)
+)
+
+// validateNewIdentifier checks a name chosen for a new table, column or index.
+func validateNewIdentifier(kind, name string) error {
+	if !newIdentifierPattern.MatchString(name) {
+		return fmt.Errorf("invalid %s name %q: use letters, digits and underscores, not starting with a digit", kind, name)
+	}
+	if strings.HasPrefix(strings.ToLower(name), "sqlite_") {
+		return fmt.Errorf("invalid %s name %q: names starting with sqlite_ are reserved", kind, name)
+	}
+	return nil
+}
+
+// DDLResult reports the statement of a DDL tool and whether it was executed.
+type DDLResult struct {
+	Statement string `json:"statement"`
+	Executed  bool   `json:"executed"`
+	Hint      string `json:"hint,omitempty"`
+}
+
+// sqlLiteral renders a JSON scalar as a SQL literal for a DEFAULT clause.
+func sqlLiteral(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	}
+	return "", fmt.Errorf("default must be a string, number, boolean or null")
+}
+
+// columnDefinition builds the definition of one column from its structured form.
+func columnDefinition(col map[string]interface{}) (def string, primaryKey bool, err error) {
+	name, _ := col["name"].(string)
+	if err := validateNewIdentifier("column", name); err != nil {
+		return "", false, err
+	}
+	def = quoteIdentifier(name)
+	if typ, _ := col["type"].(string); typ != "" {
+		if !columnTypePattern.MatchString(typ) {
+			return "", false, fmt.Errorf("invalid type %q for column %s", typ, name)
+		}
+		def += " " + strings.ToUpper(typ)
+	}
+	primaryKey, _ = col["primary_key"].(bool)
+	if notNull, _ := col["not_null"].(bool); notNull {
+		def += " NOT NULL"
+	}
+	if unique, _ := col["unique"].(bool); unique {
+		def += " UNIQUE"
+	}
+	if dflt, ok := col["default"]; ok {
+		literal, err := sqlLiteral(dflt)
+		if err != nil {
+			return "", false, fmt.Errorf("column %s: %w", name, err)
+		}
+		def += " DEFAULT " + literal
+	}
+	if ref, ok := col["references"].(map[string]interface{}); ok {
+		table, _ := ref["table"].(string)
+		column, _ := ref["column"].(string)
+		if table == "" {
+			return "", false, fmt.Errorf("column %s: references needs a table", name)
+		}
+		def += " REFERENCES " + quoteIdentifier(table)
+		if column != "" {
+			def += "(" + quoteIdentifier(column) + ")"
+		}
+	}
+	return def, primaryKey, nil
+}
+
+// createTableHandler builds and optionally executes a CREATE TABLE statement.
+func (ds *DatabaseService) createTableHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	table, _ := args["table"].(string)
+	if err := validateNewIdentifier("table", table); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	columns, _ := args["columns"].([]interface{})
+	if len(columns) == 0 {
+		return mcp.NewToolResultError("Missing or empty 'columns' argument."), nil
+	}
+
+	var defs, primaryKey []string
+	seen := make(map[string]bool)
+	for _, c := range columns {
+		col, ok := c.(map[string]interface{})
+		if !ok {
+			return mcp.NewToolResultError("Each column must be an object with at least a name."), nil
+		}
+		def, pk, err := columnDefinition(col)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		name := strings.ToLower(col["name"].(string))
+		if seen[name] {
+			return mcp.NewToolResultError(fmt.Sprintf("Duplicate column %q.", col["name"])), nil
+		}
+		seen[name] = true
+		defs = append(defs, def)
+		if pk {
+			primaryKey = append(primaryKey, quoteIdentifier(col["name"].(string)))
+		}
+	}
+	if len(primaryKey) > 0 {
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(primaryKey, ", ")+")")
+	}
+
+	create := "CREATE TABLE "
+	if ifNotExists, _ := args["if_not_exists"].(bool); ifNotExists {
+		create += "IF NOT EXISTS "
+	}
+	stmt := create + quoteIdentifier(table) + " (\n  " + strings.Join(defs, ",\n  ") + "\n)"
+	if strict, _ := args["strict"].(bool); strict {
+		stmt += " STRICT"
+	}
+	return ds.execDDL(ctx, args, stmt)
+}
+
+// createIndexHandler builds and optionally executes a CREATE INDEX statement
+// on existing columns.
+func (ds *DatabaseService) createIndexHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	table, tableColumns, errResult := ds.mutationTable(ctx, args)
+	if errResult != nil {
+		return errResult, nil
+	}
+	columns, _ := args["columns"].([]interface{})
+	if len(columns) == 0 {
+		return mcp.NewToolResultError("Missing or empty 'columns' argument."), nil
+	}
+	quoted := make([]string, len(columns))
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		name, _ := c.(string)
+		if !tableColumns[name] {
+			return mcp.NewToolResultError(fmt.Sprintf("Unknown column '%v'.", c)), nil
+		}
+		names[i], quoted[i] = name, quoteIdentifier(name)
+	}
+
+	tableName, _ := args["table"].(string)
+	_, tableName = ds.dialect.SplitTable(tableName)
+	index, _ := args["name"].(string)
+	if index == "" {
+		index = "idx_" + tableName + "_" + strings.Join(names, "_")
+	}
+	if err := validateNewIdentifier("index", index); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	create := "CREATE INDEX "
+	if unique, _ := args["unique"].(bool); unique {
+		create = "CREATE UNIQUE INDEX "
+	}
+	if ifNotExists, _ := args["if_not_exists"].(bool); ifNotExists {
+		create += "IF NOT EXISTS "
+	}
+	stmt := fmt.Sprintf("%s%s ON %s (%s)", create, quoteIdentifier(index), table, strings.Join(quoted, ", "))
+	return ds.execDDL(ctx, args, stmt)
+}
+
+// dropTableHandler builds and optionally executes a DROP TABLE statement.
+func (ds *DatabaseService) dropTableHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	table, _, errResult := ds.mutationTable(ctx, args)
+	if errResult != nil {
+		return errResult, nil
+	}
+	return ds.execDDL(ctx, args, "DROP TABLE "+table)
+}
+
+// execDDL returns stmt for review, or executes it on the write pool when the
+// confirm argument is true.
+func (ds *DatabaseService) execDDL(ctx context.Context, args map[string]interface{}, stmt string) (*mcp.CallToolResult, error) {
+	result := DDLResult{Statement: stmt}
+	if confirm, _ := args["confirm"].(bool); !confirm {
+		result.Hint = "Nothing was executed. Review the statement and call the tool again with the same arguments and confirm=true to run it."
+	} else {
+		if _, err := ds.writeDB.ExecContext(ctx, stmt); err != nil {
+			log.Printf("Error executing DDL: %v, Statement: %s", err, logSQL(stmt))
+			return mcp.NewToolResultErrorFromErr("Error executing statement", err), nil
+		}
+		log.Printf("Executed DDL: %s", logSQL(stmt))
+		result.Executed = true
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling DDL result to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting result", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"database/sql"
+	"expvar"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+)
+
+// debugAddrFromEnv reads DEBUG_ADDR, the address of the profiling listener.
+// Profiles expose memory contents, so only loopback addresses are accepted.
+func debugAddrFromEnv() (string, error) {
+	addr := os.Getenv("DEBUG_ADDR")
+	if addr == "" {
+		return "", nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid DEBUG_ADDR %q: %v", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", fmt.Errorf("DEBUG_ADDR %q must listen on a loopback address such as 127.0.0.1", addr)
+	}
+	return addr, nil
+}
+
+// startDebugServer serves net/http/pprof under /debug/pprof/ and expvar
+// under /debug/vars on addr, with the goroutine count and the connection
+// pool statistics of db published as variables.
+func startDebugServer(addr string, db *sql.DB) {
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	expvar.Publish("db_pool", expvar.Func(func() any { return db.Stats() }))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	go func() {
+		log.Printf("Debug endpoints on http://%s/debug/pprof/ and /debug/vars", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Debug server error: %v", err)
+		}
+	}()
+}
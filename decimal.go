@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// decimalTypePattern matches declared DECIMAL/NUMERIC column types and captures
+// their optional precision and scale, e.g. DECIMAL(10,2).
+var decimalTypePattern = regexp.MustCompile(`^(?:DECIMAL|NUMERIC|DEC)\s*(?:\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\))?$`)
+
+// decimalScale reports whether colType is a decimal type and returns its scale,
+// or -1 when the declaration does not specify one.
+func decimalScale(colType string) (int, bool) {
+	m := decimalTypePattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(colType)))
+	if m == nil {
+		return 0, false
+	}
+	switch {
+	case m[2] != "":
+		scale, _ := strconv.Atoi(m[2])
+		return scale, true
+	case m[1] != "":
+		return 0, true // DECIMAL(p) has no fractional digits
+	default:
+		return -1, true
+	}
+}
+
+// formatDecimal renders a value read from a decimal column as a string so that
+// JSON clients do not round it through a float. SQLite stores such values as
+// INTEGER, REAL or, when they cannot be converted losslessly, TEXT; text is
+// returned verbatim and numbers are padded to the declared scale.
+func formatDecimal(val interface{}, scale int) string {
+	switch v := val.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		if scale > 0 {
+			s += "." + strings.Repeat("0", scale)
+		}
+		return s
+	case float64:
+		if scale >= 0 {
+			return strconv.FormatFloat(v, 'f', scale, 64)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultDeniedFunctions are refused in queries unless DENIED_FUNCTIONS says
+// otherwise: extension loading, file access from the SQLite shell and fileio
+// extensions, tokenizer pointer tricks, and the functions that allocate
+// blobs of arbitrary size.
+var defaultDeniedFunctions = []string{
+	"load_extension", "fts3_tokenizer", "readfile", "writefile", "edit", "lsdir",
+	"randomblob", "zeroblob",
+}
+
+// functionDenylist holds the lowercased names of SQL functions queries may
+// not call.
+type functionDenylist map[string]bool
+
+// loadDeniedFunctions reads DENIED_FUNCTIONS, a comma separated list that
+// replaces the default denylist; "none" disables it.
+func loadDeniedFunctions() functionDenylist {
+	names := defaultDeniedFunctions
+	if v := strings.TrimSpace(os.Getenv("DENIED_FUNCTIONS")); v == "none" {
+		names = nil
+	} else if v != "" {
+		names = strings.Split(v, ",")
+	}
+	denied := make(functionDenylist, len(names))
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			denied[name] = true
+		}
+	}
+	return denied
+}
+
+// check returns an error naming the first denied function query calls. A
+// call is a name, bare or quoted as an identifier, followed by an opening
+// parenthesis, possibly after comments.
+func (d functionDenylist) check(query string) error {
+	if len(d) == 0 {
+		return nil
+	}
+	tokens := tokenizeSQL(query)
+	for i, tok := range tokens {
+		name := tok.Text
+		switch {
+		case tok.Kind == 'w':
+		case tok.Kind == 'q' && name[0] != '\'' && len(name) >= 2:
+			name = name[1 : len(name)-1]
+		default:
+			continue
+		}
+		if !d[strings.ToLower(name)] {
+			continue
+		}
+		j := i + 1
+		for j < len(tokens) && tokens[j].Kind == 'c' {
+			j++
+		}
+		if j < len(tokens) && tokens[j].Text == "(" {
+			return fmt.Errorf("the %s function is not allowed (denied functions: %s)", strings.ToLower(name), d)
+		}
+	}
+	return nil
+}
+
+// String lists the denied functions in order.
+func (d functionDenylist) String() string {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// validateReadOnly accepts single statements the dialect considers read-only
+// and that call no denied function.
+func (ds *DatabaseService) validateReadOnly(query string) error {
+	if err := requireSingleStatement(query); err != nil {
+		return err
+	}
+	if err := ds.dialect.ValidateReadOnly(query); err != nil {
+		return err
+	}
+	return ds.deniedFunctions.check(query)
+}
+
+// connectionKeywords start statements that reach past the query: another
+// database file, or the settings of the connection.
+var connectionKeywords = map[string]bool{"ATTACH": true, "DETACH": true, "PRAGMA": true}
+
+// checkCondition refuses a 'where' condition that is not a single
+// expression. It is spliced into generated SQL inside parentheses, so its
+// own parentheses must balance without closing that one, and a comment, an
+// unterminated quote or a ';' would hide or end the rest of the statement.
+func checkCondition(where string) error {
+	depth := 0
+	for _, t := range tokenizeSQL(where) {
+		switch {
+		case t.Kind == 'c':
+			return fmt.Errorf("the 'where' condition must not contain comments")
+		case t.Kind == 'q' && !terminatedQuote(t.Text):
+			return fmt.Errorf("the 'where' condition has an unterminated quote")
+		case t.Text == ";":
+			return fmt.Errorf("the 'where' condition must not contain ';'")
+		case t.Text == "(":
+			depth++
+		case t.Text == ")":
+			if depth--; depth < 0 {
+				return fmt.Errorf("the 'where' condition has unbalanced parentheses")
+			}
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("the 'where' condition has unbalanced parentheses")
+	}
+	return nil
+}
+
+// terminatedQuote reports whether a quoted token of tokenizeSQL ends with
+// its closing quote rather than running to the end of the text.
+func terminatedQuote(token string) bool {
+	closing := token[0]
+	if closing == '[' {
+		closing = ']'
+	}
+	body := token[1:]
+	if len(body) == 0 || body[len(body)-1] != closing {
+		return false
+	}
+	body = body[:len(body)-1]
+	if token[0] != '[' {
+		body = strings.ReplaceAll(body, string([]byte{closing, closing}), "")
+	}
+	return strings.IndexByte(body, closing) < 0
+}
+
+// requireSingleStatement rejects text holding more than one statement, as
+// the SQLite driver runs every statement it is given and only the first is
+// checked, and the ATTACH, DETACH and PRAGMA keywords anywhere in it. The
+// pragma_* table-valued functions stay allowed.
+func requireSingleStatement(query string) error {
+	tokens := tokenizeSQL(query)
+	ended := false
+	for i, t := range tokens {
+		switch {
+		case t.Kind == 'c' || ended && t.Text == ";":
+		case ended:
+			return fmt.Errorf("only one statement is allowed per query")
+		case t.Text == ";":
+			ended = true
+		case t.Kind == 'w' && connectionKeywords[strings.ToUpper(t.Text)] && (i == 0 || tokens[i-1].Text != "."):
+			return fmt.Errorf("%s is not allowed in read-only queries", strings.ToUpper(t.Text))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Sample value limits for describe_table.
+const (
+	maxSampleValues     = 10
+	sampleScanRows      = 1000 // Rows read per column when looking for distinct samples
+	maxSampleValueChars = 80
+)
+
+// sampleColumnValues returns up to k distinct non-NULL values of a column, taken
+// from the first rows of the table, so callers can see formats and codes.
+func (ds *DatabaseService) sampleColumnValues(ctx context.Context, table, column, colType string, k int) ([]interface{}, error) {
+	col := quoteIdentifier(column)
+	query := fmt.Sprintf("SELECT DISTINCT v FROM (SELECT %s AS v FROM %s LIMIT %d) WHERE v IS NOT NULL LIMIT %d",
+		col, ds.quoteTable(table), sampleScanRows, k)
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []interface{}{}
+	for rows.Next() {
+		var v interface{}
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		value := normalizeValue(v, colType)
+		if s, ok := value.(string); ok && len(s) > maxSampleValueChars {
+			value = s[:maxSampleValueChars] + "…"
+		}
+		samples = append(samples, value)
+	}
+	return samples, rows.Err()
+}
+
+// ForeignKeyInfo is a foreign key declared on a table.
+type ForeignKeyInfo struct {
+	Columns           []string `json:"columns"`
+	ReferencesTable   string   `json:"references_table"`
+	ReferencesColumns []string `json:"references_columns,omitempty"` // Empty when the parent's primary key is referenced
+	OnUpdate          string   `json:"on_update"`
+	OnDelete          string   `json:"on_delete"`
+}
+
+// IndexInfo is an index on a table.
+type IndexInfo struct {
+	Name    string   `json:"name"`
+	Unique  bool     `json:"unique"`
+	Origin  string   `json:"origin"` // "index" (CREATE INDEX), "unique" or "primary_key" constraint
+	Partial bool     `json:"partial"`
+	Columns []string `json:"columns"`
+}
+
+// TableDescription is the full definition of a table returned by describe_table.
+type TableDescription struct {
+	Table             string                   `json:"table"`
+	Columns           []map[string]interface{} `json:"columns"`
+	PrimaryKey        []string                 `json:"primary_key"`
+	ForeignKeys       []ForeignKeyInfo         `json:"foreign_keys"`
+	Indexes           []IndexInfo              `json:"indexes"`
+	UniqueConstraints [][]string               `json:"unique_constraints"`
+	CheckConstraints  []string                 `json:"check_constraints"`
+	WithoutRowID      bool                     `json:"without_rowid"`
+	Strict            bool                     `json:"strict"`
+	Engine            string                   `json:"engine,omitempty"` // Table engine, for backends that have them
+}
+
+// indexOrigins names the origin codes reported by PRAGMA index_list.
+var indexOrigins = map[string]string{"c": "index", "u": "unique", "pk": "primary_key"}
+
+// checkConstraints extracts the CHECK (...) clauses from a CREATE TABLE
+// statement, skipping string literals, quoted identifiers and comments.
+func checkConstraints(createSQL string) []string {
+	checks := []string{}
+	upper := strings.ToUpper(createSQL)
+	for i := 0; i < len(createSQL); i++ {
+		switch c := createSQL[i]; {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			if end := strings.IndexByte(createSQL[i+1:], closing); end >= 0 {
+				i += end + 1
+			}
+		case c == '-' && strings.HasPrefix(createSQL[i:], "--"):
+			if end := strings.IndexByte(createSQL[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(createSQL)
+			}
+		case strings.HasPrefix(upper[i:], "CHECK") && (i == 0 || !isIdentChar(createSQL[i-1])) &&
+			(i+5 == len(createSQL) || !isIdentChar(createSQL[i+5])):
+			open := strings.IndexByte(createSQL[i:], '(')
+			if open < 0 {
+				return checks
+			}
+			depth, start := 0, i+open
+			for j := start; j < len(createSQL); j++ {
+				if createSQL[j] == '(' {
+					depth++
+				} else if createSQL[j] == ')' {
+					if depth--; depth == 0 {
+						checks = append(checks, strings.TrimSpace(createSQL[start+1:j]))
+						i = j
+						break
+					}
+				}
+			}
+		}
+	}
+	return checks
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// getTableDDLHandler returns the CREATE statements of a table or view and of
+// the indexes and triggers defined on it, exactly as stored in sqlite_schema.
+func (ds *DatabaseService) getTableDDLHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	table, ok := args["table_name"].(string)
+	if !ok || table == "" {
+		return mcp.NewToolResultError("Missing or invalid 'table_name' argument."), nil
+	}
+	resolved, err := ds.resolveTable(ctx, table)
+	if err != nil {
+		return tableErrorResult(table, err), nil
+	}
+	schema, table := splitTableName(resolved)
+
+	query := fmt.Sprintf(`SELECT sql FROM %s.sqlite_schema WHERE tbl_name = ? AND sql IS NOT NULL
+		ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'view' THEN 0 WHEN 'index' THEN 1 ELSE 2 END, name`, quoteIdentifier(schema))
+	rows, err := ds.db.QueryContext(ctx, query, table)
+	if err != nil {
+		log.Printf("Error reading DDL of %s: %v", table, err)
+		return mcp.NewToolResultErrorFromErr(fmt.Sprintf("Error reading DDL of '%s'", table), err), nil
+	}
+	defer rows.Close()
+
+	var statements []string
+	for rows.Next() {
+		var stmt string
+		if err := rows.Scan(&stmt); err != nil {
+			log.Printf("Error scanning DDL: %v", err)
+			return mcp.NewToolResultErrorFromErr("Error reading DDL", err), nil
+		}
+		statements = append(statements, stmt+";")
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating DDL: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error reading DDL", err), nil
+	}
+	if len(statements) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("Table '%s' not found.", table)), nil
+	}
+
+	return mcp.NewToolResultText(strings.Join(statements, "\n\n")), nil
+}
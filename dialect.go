@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Dialect hides how a backend describes its schema and quotes identifiers, so
+// handlers stay the same when another backend is added. Table names may be
+// schema-qualified (mounts.regions); an empty schema is the default one.
+type Dialect interface {
+	ListSchemas(ctx context.Context) ([]SchemaInfo, error)
+	// ListTables returns the tables of a schema, and its views if includeViews is set.
+	ListTables(ctx context.Context, schema string, includeViews bool) ([]string, error)
+	// DescribeTable returns the columns, keys, indexes and constraints of a
+	// table; the description has no columns if the table does not exist.
+	DescribeTable(ctx context.Context, table string) (*TableDescription, error)
+	ListIndexes(ctx context.Context, table string) ([]IndexInfo, error)
+	ForeignKeys(ctx context.Context, table string) ([]Relationship, error)
+	PrimaryKey(ctx context.Context, table string) ([]string, error)
+	// SplitTable separates the schema of a qualified table name; it is empty
+	// for names in the default schema.
+	SplitTable(table string) (schema, name string)
+	QuoteIdent(name string) string
+	// ValidateReadOnly rejects statements other than queries.
+	ValidateReadOnly(query string) error
+}
+
+// rowLimiter is implemented by dialects that cap read_query results in SQL,
+// so the server stops producing rows the row budget would discard anyway.
+type rowLimiter interface {
+	LimitQuery(query string, n int) string
+}
+
+// costEstimator is implemented by dialects of backends that bill by bytes
+// scanned; read_query checks the estimate before running a query.
+type costEstimator interface {
+	// EstimateBytes returns the bytes a query would scan, without running it.
+	EstimateBytes(ctx context.Context, query string) (int64, error)
+	// MaxBytesScanned is the largest estimate a query may have; 0 for no limit.
+	MaxBytesScanned() int64
+}
+
+// sqliteDialect introspects SQLite and libSQL databases through PRAGMA table functions.
+type sqliteDialect struct {
+	db *sql.DB
+}
+
+// ListSchemas lists main and the attached databases. The per-connection temp
+// schema is left out.
+func (d sqliteDialect) ListSchemas(ctx context.Context) ([]SchemaInfo, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT name, file FROM pragma_database_list WHERE name <> 'temp' ORDER BY seq")
+	if err != nil {
+		return nil, err
+	}
+	var schemas []SchemaInfo
+	for rows.Next() {
+		var s SchemaInfo
+		if err := rows.Scan(&s.Name, &s.File); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		schemas = append(schemas, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range schemas {
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s.sqlite_schema WHERE type = 'table' AND name NOT LIKE 'sqlite_%%'", d.QuoteIdent(schemas[i].Name))
+		if err := d.db.QueryRowContext(ctx, query).Scan(&schemas[i].Tables); err != nil {
+			return nil, err
+		}
+	}
+	return schemas, nil
+}
+
+func (d sqliteDialect) ListTables(ctx context.Context, schema string, includeViews bool) ([]string, error) {
+	if schema == "" {
+		schema = "main"
+	}
+	types := "type = 'table'"
+	if includeViews {
+		types = "type IN ('table', 'view')"
+	}
+	query := fmt.Sprintf("SELECT name FROM %s.sqlite_schema WHERE %s AND name NOT LIKE 'sqlite_%%' ORDER BY name", d.QuoteIdent(schema), types)
+	rows, err := d.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func (d sqliteDialect) DescribeTable(ctx context.Context, table string) (*TableDescription, error) {
+	schema, name := splitTableName(table)
+	rows, err := d.db.QueryContext(ctx, "SELECT * FROM pragma_table_info(?, ?) ORDER BY cid", name, schema)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := scanRowMaps(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	desc := &TableDescription{
+		Table:             table,
+		Columns:           columns,
+		ForeignKeys:       []ForeignKeyInfo{},
+		UniqueConstraints: [][]string{},
+		CheckConstraints:  []string{},
+	}
+	if len(columns) == 0 {
+		return desc, nil
+	}
+	if desc.PrimaryKey, err = d.PrimaryKey(ctx, table); err != nil {
+		return nil, err
+	}
+
+	fkRows, err := d.db.QueryContext(ctx, `SELECT id, "table", "from", "to", on_update, on_delete FROM pragma_foreign_key_list(?, ?) ORDER BY id, seq`, name, schema)
+	if err != nil {
+		return nil, err
+	}
+	lastID := int64(-1)
+	for fkRows.Next() {
+		var id int64
+		var parent, from, onUpdate, onDelete string
+		var to *string
+		if err := fkRows.Scan(&id, &parent, &from, &to, &onUpdate, &onDelete); err != nil {
+			fkRows.Close()
+			return nil, err
+		}
+		if id != lastID {
+			desc.ForeignKeys = append(desc.ForeignKeys, ForeignKeyInfo{ReferencesTable: parent, OnUpdate: onUpdate, OnDelete: onDelete})
+			lastID = id
+		}
+		fk := &desc.ForeignKeys[len(desc.ForeignKeys)-1]
+		fk.Columns = append(fk.Columns, from)
+		if to != nil {
+			fk.ReferencesColumns = append(fk.ReferencesColumns, *to)
+		}
+	}
+	fkRows.Close()
+	if err := fkRows.Err(); err != nil {
+		return nil, err
+	}
+
+	if desc.Indexes, err = d.ListIndexes(ctx, table); err != nil {
+		return nil, err
+	}
+	for _, idx := range desc.Indexes {
+		if idx.Origin == "unique" {
+			desc.UniqueConstraints = append(desc.UniqueConstraints, idx.Columns)
+		}
+	}
+
+	// Flags come from pragma_table_list and CHECK constraints from the DDL itself
+	var createSQL *string
+	err = d.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT l.wr, l.strict, s.sql FROM pragma_table_list AS l
+		LEFT JOIN %s.sqlite_schema AS s ON s.name = l.name AND s.type = 'table'
+		WHERE l.name = ? AND l.schema = ?`, d.QuoteIdent(schema)), name, schema).Scan(&desc.WithoutRowID, &desc.Strict, &createSQL)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if createSQL != nil {
+		desc.CheckConstraints = checkConstraints(*createSQL)
+	}
+	return desc, nil
+}
+
+func (d sqliteDialect) ListIndexes(ctx context.Context, table string) ([]IndexInfo, error) {
+	schema, name := splitTableName(table)
+	rows, err := d.db.QueryContext(ctx, `SELECT name, "unique", origin, partial FROM pragma_index_list(?, ?) ORDER BY seq DESC`, name, schema)
+	if err != nil {
+		return nil, err
+	}
+	indexes := []IndexInfo{}
+	for rows.Next() {
+		var idx IndexInfo
+		var origin string
+		if err := rows.Scan(&idx.Name, &idx.Unique, &origin, &idx.Partial); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		idx.Origin = indexOrigins[origin]
+		indexes = append(indexes, idx)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range indexes {
+		if indexes[i].Columns, err = d.indexColumns(ctx, schema, indexes[i].Name); err != nil {
+			return nil, err
+		}
+	}
+	return indexes, nil
+}
+
+// indexColumns returns the key columns of an index; expressions are shown as "<expression>".
+func (d sqliteDialect) indexColumns(ctx context.Context, schema, index string) ([]string, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT name FROM pragma_index_info(?, ?) ORDER BY seqno", index, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []string{}
+	for rows.Next() {
+		var name *string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if name == nil {
+			columns = append(columns, "<expression>")
+		} else {
+			columns = append(columns, *name)
+		}
+	}
+	return columns, rows.Err()
+}
+
+// ForeignKeys returns the relationships declared on a table. Parents outside
+// the main schema are qualified like the child.
+func (d sqliteDialect) ForeignKeys(ctx context.Context, table string) ([]Relationship, error) {
+	schema, name := splitTableName(table)
+	rows, err := d.db.QueryContext(ctx, `SELECT id, "table", "from", "to" FROM pragma_foreign_key_list(?, ?) ORDER BY id, seq`, name, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var relationships []Relationship
+	lastID := int64(-1)
+	for rows.Next() {
+		var id int64
+		var parent, from string
+		var to *string
+		if err := rows.Scan(&id, &parent, &from, &to); err != nil {
+			return nil, err
+		}
+		if schema != "main" {
+			parent = schema + "." + parent
+		}
+		if id != lastID {
+			relationships = append(relationships, Relationship{ChildTable: table, ParentTable: parent, Declared: true})
+			lastID = id
+		}
+		rel := &relationships[len(relationships)-1]
+		rel.ChildColumns = append(rel.ChildColumns, from)
+		if to != nil {
+			rel.ParentColumns = append(rel.ParentColumns, *to)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// A reference without target columns points at the parent's primary key
+	for i := range relationships {
+		if len(relationships[i].ParentColumns) == 0 {
+			pk, err := d.PrimaryKey(ctx, relationships[i].ParentTable)
+			if err != nil {
+				return nil, err
+			}
+			relationships[i].ParentColumns = pk
+		}
+	}
+	return relationships, nil
+}
+
+// PrimaryKey returns the primary key columns of a table in key order.
+func (d sqliteDialect) PrimaryKey(ctx context.Context, table string) ([]string, error) {
+	schema, name := splitTableName(table)
+	rows, err := d.db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?, ?) WHERE pk > 0 ORDER BY pk", name, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// SplitTable recognizes the main, temp and mounts schemas; other dotted names
+// are table names.
+func (d sqliteDialect) SplitTable(table string) (schema, name string) {
+	if schema, name, ok := strings.Cut(table, "."); ok && (schema == "main" || schema == "temp" || schema == mountSchema) {
+		return schema, name
+	}
+	return "", table
+}
+
+func (d sqliteDialect) QuoteIdent(name string) string {
+	return quoteIdentifier(name)
+}
+
+// ValidateReadOnly only accepts SELECT statements. Writes are additionally
+// rejected by SQLite itself for local databases (see readonly.go).
+func (d sqliteDialect) ValidateReadOnly(query string) error {
+	return requireSelect(query)
+}
+
+// requireSelect rejects statements that do not start with SELECT.
+func requireSelect(query string) error {
+	if !strings.HasPrefix(strings.TrimSpace(strings.ToUpper(query)), "SELECT") {
+		return fmt.Errorf("only SELECT queries are allowed for read-only access")
+	}
+	return nil
+}
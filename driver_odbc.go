@@ -0,0 +1,27 @@
+//go:build odbc
+
+package main
+
+import (
+	"database/sql"
+
+	_ "github.com/alexbrainman/odbc" // ODBC driver (cgo, needs unixODBC or the Windows driver manager)
+)
+
+// ODBC reaches databases without a native Go driver (Access, DB2, proprietary
+// warehouses) through the driver manager. Build with "go build -tags odbc ."
+// and select it with DB_DRIVER=odbc and an ODBC connection string in DB_DSN.
+
+func init() {
+	registerDriver("odbc", "ODBC data sources through the system driver manager", newODBCDialect)
+}
+
+// newODBCDialect introspects through INFORMATION_SCHEMA, which most ODBC
+// targets provide; tables of every non-system schema are listed.
+func newODBCDialect(db *sql.DB, dsn string) Dialect {
+	return infoSchemaDialect{
+		db:            db,
+		systemSchemas: []string{"INFORMATION_SCHEMA", "SYS", "SYSIBM", "SYSCAT", "SYSSTAT", "SYSTOOLS", "pg_catalog"},
+		constraints:   true,
+	}
+}
@@ -0,0 +1,13 @@
+//go:build snowflake
+
+package main
+
+import _ "github.com/snowflakedb/gosnowflake" // Snowflake driver
+
+// Build with "go build -tags snowflake ." and connect with
+// DB_DRIVER=snowflake and a DSN such as
+// user:password@myorg-account/ANALYTICS/PUBLIC?warehouse=REPORTING.
+
+func init() {
+	registerDriver("snowflake", "Snowflake", newSnowflakeDialect)
+}
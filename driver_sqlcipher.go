@@ -0,0 +1,19 @@
+//go:build sqlcipher
+
+package main
+
+import (
+	"database/sql"
+
+	sqlcipher "github.com/mutecomm/go-sqlcipher/v4" // SQLite driver with SQLCipher encryption (cgo)
+)
+
+// Build with "go build -tags sqlcipher ." to replace the pure Go SQLite
+// driver with one that also opens files encrypted with SQLCipher, given
+// DB_KEY.
+
+func init() {
+	sql.Register("sqlite", &sqlcipher.SQLiteDriver{})
+	sqlcipherEnabled = true
+	registerDriver("sqlite", "Local SQLite database files, plain or encrypted with SQLCipher (go-sqlcipher)", newSQLiteDialect)
+}
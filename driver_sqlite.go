@@ -0,0 +1,9 @@
+//go:build !no_sqlite && !sqlcipher
+
+package main
+
+import _ "modernc.org/sqlite" // SQLite driver
+
+func init() {
+	registerDriver("sqlite", "Local SQLite database files (modernc.org/sqlite)", newSQLiteDialect)
+}
@@ -0,0 +1,12 @@
+//go:build trino
+
+package main
+
+import _ "github.com/trinodb/trino-go-client/trino" // Trino driver
+
+// Build with "go build -tags trino ." and connect with DB_DRIVER=trino and a
+// DSN such as http://analyst@trino:8080?catalog=hive&schema=sales.
+
+func init() {
+	registerDriver("trino", "Trino and Presto clusters", newTrinoDialect)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Database drivers register themselves from files guarded by build tags, so a
+// binary only carries the drivers (and dependencies) it was built with:
+//
+//	go build -tags no_libsql .    # local SQLite files only
+//	go build -tags no_sqlite .    # remote libSQL/Turso only
+//	go build -tags no_bigquery .  # leave out BigQuery
+//	go build -tags odbc .         # add ODBC data sources (cgo)
+var drivers = make(map[string]driverSpec)
+
+// driverSpec describes a driver compiled into the binary.
+type driverSpec struct {
+	description string
+	dialect     dialectFunc // Introspection for databases opened with the driver
+}
+
+// dialectFunc builds the dialect of a database opened with a driver. The DSN
+// carries options some dialects need, such as the BigQuery project.
+type dialectFunc func(db *sql.DB, dsn string) Dialect
+
+// registerDriver records a database/sql driver compiled into the binary.
+func registerDriver(name, description string, dialect dialectFunc) {
+	drivers[name] = driverSpec{description: description, dialect: dialect}
+}
+
+func newSQLiteDialect(db *sql.DB, dsn string) Dialect {
+	return sqliteDialect{db: db}
+}
+
+// driverNames lists the drivers compiled into the binary.
+func driverNames() []string {
+	return sortedKeys(drivers)
+}
+
+// driverForDSN picks the driver named by the scheme of a URL-style DSN, such
+// as sqlserver:// or clickhouse://.
+func driverForDSN(dsn string) string {
+	scheme, _, ok := strings.Cut(dsn, "://")
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
+
+// checkDriver fails for drivers left out of the build.
+func checkDriver(name string) error {
+	if _, ok := drivers[name]; ok {
+		return nil
+	}
+	return fmt.Errorf("driver %q is not compiled into this binary (available: %s)", name, strings.Join(driverNames(), ", "))
+}
+
+// libsqlDSN builds a driver DSN from a server URL and an optional auth token.
+func libsqlDSN(serverURL, authToken string) (string, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid libSQL URL: %w", err)
+	}
+	if authToken != "" {
+		q := u.Query()
+		q.Set("authToken", authToken)
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
+
+// dsnPassword matches the password of key=value connection strings (ODBC, ADO).
+var dsnPassword = regexp.MustCompile(`(?i)\b(pwd|password)=[^;]*`)
+
+// dsnUserInfo matches the user:password@ prefix of DSNs without a URL scheme,
+// such as Snowflake's user:password@account/database.
+var dsnUserInfo = regexp.MustCompile(`^([^:/@]+):[^@]*@`)
+
+// redactDSN removes credentials from a DSN so it can be logged.
+func redactDSN(dsn string) string {
+	if strings.HasPrefix(dsn, "file:") {
+		return sqliteURIPath(dsn)
+	}
+	if dsnPassword.MatchString(dsn) {
+		return dsnPassword.ReplaceAllString(dsn, "$1=***")
+	}
+	if !strings.Contains(dsn, "://") {
+		base, _, _ := strings.Cut(dsn, "?")
+		return dsnUserInfo.ReplaceAllString(base, "$1:***@")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		return dsn
+	}
+	u.User = nil
+	u.RawQuery = ""
+	return u.String()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Limits of find_duplicates output.
+const (
+	defaultDuplicateGroups   = 20
+	maxDuplicateGroups       = 200
+	defaultDuplicateExamples = 3
+	maxDuplicateExamples     = 10
+)
+
+// DuplicateGroup is a set of rows sharing the same key.
+type DuplicateGroup struct {
+	Key      map[string]interface{}   `json:"key"`
+	Count    int64                    `json:"count"`
+	Examples []map[string]interface{} `json:"examples"`
+}
+
+// DuplicateReport is the result of find_duplicates.
+type DuplicateReport struct {
+	KeyColumns      []string         `json:"key_columns"`
+	DuplicateGroups int64            `json:"duplicate_groups"`
+	DuplicateRows   int64            `json:"duplicate_rows"`
+	Groups          []DuplicateGroup `json:"groups"`
+}
+
+// findDuplicatesHandler reports groups of rows that share the same values in
+// the key columns, largest groups first, with a few example rows each.
+func (ds *DatabaseService) findDuplicatesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	source, err := ds.sourceRelation(ctx, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	keys := request.GetStringSlice("key_columns", nil)
+	if len(keys) == 0 || slices.Contains(keys, "") {
+		return mcp.NewToolResultError("Missing or invalid 'key_columns' argument."), nil
+	}
+	if keys, err = ds.resolveColumns(ctx, source, keys...); err != nil {
+		return columnErrorResult(err), nil
+	}
+	limit := defaultDuplicateGroups
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = min(int(v), maxDuplicateGroups)
+	}
+	examples := defaultDuplicateExamples
+	if v, ok := args["examples"].(float64); ok && v >= 0 {
+		examples = min(int(v), maxDuplicateExamples)
+	}
+
+	quoted := make([]string, len(keys))
+	matches := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = quoteIdentifier(key)
+		matches[i] = quoted[i] + " IS ?"
+	}
+	keyList := strings.Join(quoted, ", ")
+	report := DuplicateReport{KeyColumns: keys, Groups: []DuplicateGroup{}}
+
+	totals := fmt.Sprintf("SELECT COUNT(*), COALESCE(SUM(dup_count__), 0) FROM (SELECT COUNT(*) AS dup_count__ FROM %s GROUP BY %s HAVING dup_count__ > 1)", source, keyList)
+	if err := ds.db.QueryRowContext(ctx, totals).Scan(&report.DuplicateGroups, &report.DuplicateRows); err != nil {
+		log.Printf("Error counting duplicates: %v, Query: %s", err, logSQL(totals))
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error counting duplicates", err), nil
+	}
+
+	query := fmt.Sprintf("SELECT %s, COUNT(*) AS dup_count__ FROM %s GROUP BY %s HAVING dup_count__ > 1 ORDER BY dup_count__ DESC LIMIT %d", keyList, source, keyList, limit)
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("Error finding duplicates: %v, Query: %s", err, logSQL(query))
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error finding duplicates", err), nil
+	}
+	var keyValues [][]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(keys)+1)
+		valuePtrs := make([]interface{}, len(values))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			rows.Close()
+			return mcp.NewToolResultErrorFromErr("Error reading duplicate group", err), nil
+		}
+		group := DuplicateGroup{Key: make(map[string]interface{}), Count: int64(toFloat(values[len(keys)])), Examples: []map[string]interface{}{}}
+		for i, key := range keys {
+			group.Key[key] = normalizeValue(values[i], "")
+		}
+		maskRowMaps(ctx, []map[string]interface{}{group.Key})
+		report.Groups = append(report.Groups, group)
+		keyValues = append(keyValues, values[:len(keys)])
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating duplicate groups: %v", err)
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error iterating duplicate groups", err), nil
+	}
+
+	if examples > 0 {
+		exampleQuery := fmt.Sprintf("SELECT * FROM %s WHERE %s LIMIT %d", source, strings.Join(matches, " AND "), examples)
+		for i := range report.Groups {
+			rows, err := ds.db.QueryContext(ctx, exampleQuery, keyValues[i]...)
+			if err != nil {
+				log.Printf("Error reading duplicate examples: %v, Query: %s", err, logSQL(exampleQuery))
+				return mcp.NewToolResultErrorFromErr("Error reading duplicate examples", err), nil
+			}
+			report.Groups[i].Examples, err = scanRowMaps(rows)
+			rows.Close()
+			if err != nil {
+				return mcp.NewToolResultErrorFromErr("Error reading duplicate examples", err), nil
+			}
+			maskRowMaps(ctx, report.Groups[i].Examples)
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling duplicates to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting duplicates", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// scanRowMaps reads all rows into column name → normalized value maps.
+func scanRowMaps(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			row[col] = normalizeValue(values[i], columnTypes[i].DatabaseTypeName())
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// defaultConfirmTimeout is how long the user has to answer by default.
+const defaultConfirmTimeout = 2 * time.Minute
+
+// Confirmer asks the user to confirm, through MCP elicitation, tool calls
+// that change the database or are estimated to scan many rows, before they
+// run. Calls needing a confirmation the client cannot ask for are refused.
+type Confirmer struct {
+	requests *ClientRequests
+	ds       *DatabaseService
+	writes   bool          // Confirm write and admin tools
+	scanRows int64         // Confirm queries estimated to scan more rows; 0 disables
+	timeout  time.Duration // How long the user has to answer
+}
+
+// confirmerFromEnv reads CONFIRM_WRITES (default false), CONFIRM_SCAN_ROWS
+// (default 0, disabled) and CONFIRM_TIMEOUT (default 2m).
+func confirmerFromEnv(ds *DatabaseService, requests *ClientRequests) (*Confirmer, error) {
+	c := &Confirmer{requests: requests, ds: ds, timeout: defaultConfirmTimeout}
+	if v := os.Getenv("CONFIRM_WRITES"); v != "" {
+		writes, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CONFIRM_WRITES %q", v)
+		}
+		c.writes = writes
+	}
+	if v := os.Getenv("CONFIRM_SCAN_ROWS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid CONFIRM_SCAN_ROWS %q", v)
+		}
+		c.scanRows = n
+	}
+	if v := os.Getenv("CONFIRM_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid CONFIRM_TIMEOUT %q", v)
+		}
+		c.timeout = d
+	}
+	return c, nil
+}
+
+// middleware asks for confirmation before calls that need it.
+func (c *Confirmer) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !c.writes && c.scanRows == 0 {
+			return next(ctx, request)
+		}
+		if reason := c.needsConfirmation(ctx, request); reason != "" {
+			if refused := c.confirm(ctx, request, reason); refused != nil {
+				return refused, nil
+			}
+		}
+		return next(ctx, request)
+	}
+}
+
+// needsConfirmation returns why a call must be confirmed, or "". Schema
+// tools only need it when they execute rather than preview their statement.
+func (c *Confirmer) needsConfirmation(ctx context.Context, request mcp.CallToolRequest) string {
+	name := request.Params.Name
+	args := request.GetArguments()
+	if c.writes && (writeTools[name] || adminTools[name]) {
+		confirm, _ := args["confirm"].(bool)
+		dryRun, _ := args["dry_run"].(bool)
+		switch name {
+		case "create_table", "create_index", "drop_table":
+			if !confirm {
+				return ""
+			}
+		case "apply_migration":
+			if dryRun {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%s changes the database.", name)
+	}
+	if c.scanRows == 0 {
+		return ""
+	}
+	if _, ok := c.ds.dialect.(sqliteDialect); !ok {
+		return ""
+	}
+	for _, arg := range []string{"query", "first", "second"} {
+		query, _ := args[arg].(string)
+		if query == "" {
+			continue
+		}
+		rows, err := c.ds.estimatedScanRows(ctx, query)
+		if err != nil {
+			// Queries that cannot be planned fail in the handler
+			continue
+		}
+		if rows > c.scanRows {
+			return fmt.Sprintf("%s is estimated to scan %d rows, over the confirmation threshold of %d.", name, rows, c.scanRows)
+		}
+	}
+	return ""
+}
+
+// confirm asks the user whether the call may run. It returns nil when they
+// accept, and the error to return otherwise.
+func (c *Confirmer) confirm(ctx context.Context, request mcp.CallToolRequest, reason string) *mcp.CallToolResult {
+	if !c.requests.supports(sessionID(ctx), "elicitation") {
+		return confirmationError("confirmation_unavailable", reason+" It needs the user's confirmation, but the client does not support elicitation.",
+			"Ask an operator to run it, or use a client that supports MCP elicitation.")
+	}
+	answer, err := c.requests.request(ctx, "elicitation/create", map[string]any{
+		"message": reason + " Run it?",
+		"requestedSchema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"confirm": map[string]any{"type": "boolean", "title": "Run " + request.Params.Name, "description": summarizeCall(request)},
+			},
+			"required": []string{"confirm"},
+		},
+	}, "confirmation_requested", reason, c.timeout)
+	switch {
+	case errors.Is(err, errClientTimeout):
+		return confirmationError("confirmation_declined", fmt.Sprintf("%s The user did not confirm it within %s.", reason, c.timeout), "Ask the user before retrying the call.")
+	case ctx.Err() != nil:
+		return confirmationError("confirmation_declined", reason+" The call was cancelled while waiting for the user.", "Ask the user before retrying the call.")
+	case err != nil:
+		return confirmationError("confirmation_unavailable", reason+" It needs the user's confirmation, but the request could not be sent.", "Retry the call.")
+	}
+
+	var result struct {
+		Action  string `json:"action"`
+		Content struct {
+			Confirm bool `json:"confirm"`
+		} `json:"content"`
+	}
+	json.Unmarshal(answer, &result)
+	if result.Action == "accept" && result.Content.Confirm {
+		log.Printf("[%s] %s confirmed by the user", requestIDFromContext(ctx), request.Params.Name)
+		return nil
+	}
+	log.Printf("[%s] %s declined by the user", requestIDFromContext(ctx), request.Params.Name)
+	return confirmationError("confirmation_declined", reason+" The user declined to run it.", "Do not retry the call unless the user asks for it.")
+}
+
+// summarizeCall describes a call's arguments for the user, truncated.
+func summarizeCall(request mcp.CallToolRequest) string {
+	args, _ := json.Marshal(request.GetArguments())
+	summary := string(args)
+	if len(summary) > 500 {
+		summary = summary[:500] + "…"
+	}
+	return summary
+}
+
+func confirmationError(kind, message, hint string) *mcp.CallToolResult {
+	payload, _ := json.MarshalIndent(map[string]string{"error": kind, "message": message, "hint": hint}, "", "  ")
+	return mcp.NewToolResultError(string(payload))
+}
+
+// estimatedScanRows estimates the rows a SQLite query reads in full table
+// scans: the row counts, from ANALYZE statistics or the largest rowid, of
+// the tables its plan scans without an index.
+func (ds *DatabaseService) estimatedScanRows(ctx context.Context, query string) (int64, error) {
+	rows, err := ds.db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query)
+	if err != nil {
+		return 0, err
+	}
+	var scanned []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if strings.HasPrefix(detail, "SCAN ") && !strings.Contains(detail, " USING ") {
+			if fields := strings.Fields(strings.TrimPrefix(detail, "SCAN ")); len(fields) > 0 {
+				scanned = append(scanned, fields[0])
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	estimates := ds.rowEstimates(ctx)
+	for _, table := range scanned {
+		if n, ok := estimates[table]; ok {
+			total += n
+			continue
+		}
+		// Subqueries, CTEs and aliases are not tables and fail here
+		var maxRowid sql.NullInt64
+		if ds.db.QueryRowContext(ctx, "SELECT max(rowid) FROM "+ds.quoteTable(table)).Scan(&maxRowid) == nil {
+			total += maxRowid.Int64
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Error codes of failed tool calls, returned in the code field of every
+// error so clients can branch on the kind of failure.
+const (
+	codeInvalidSQL      = "INVALID_SQL"      // The SQL does not parse, or is not a read-only query
+	codeInvalidArgument = "INVALID_ARGUMENT" // A missing, malformed or oversized argument
+	codeTableNotFound   = "TABLE_NOT_FOUND"
+	codeTimeout         = "TIMEOUT"
+	codeResourceLimit   = "RESOURCE_LIMIT" // A row, memory, cost or row change budget
+	codeResultTruncated = "RESULT_TRUNCATED"
+	codePolicyDenied    = "POLICY_DENIED"
+	codeRateLimited     = "RATE_LIMITED"
+	codeUnavailable     = "UNAVAILABLE"
+	codeCancelled       = "CANCELLED"
+	codeDatabaseError   = "DATABASE_ERROR"
+	codeInternal        = "INTERNAL" // The handler failed outright or panicked
+)
+
+// errorCodeInfo gives the metrics class of a code and the hint added to
+// errors that come without one.
+var errorCodeInfo = map[string]struct{ class, hint string }{
+	codeInvalidSQL:      {errorValidation, "Check the statement with validate_query; only read-only queries are accepted."},
+	codeInvalidArgument: {errorValidation, "Check the arguments against the tool's input schema in tools/list."},
+	codeTableNotFound:   {errorValidation, "Call list_tables to see the available tables, and describe_table for their columns."},
+	codeTimeout:         {errorTimeout, "Narrow the query with WHERE conditions on indexed columns, or add a LIMIT."},
+	codeResourceLimit:   {errorBudget, "Add a LIMIT or aggregate the data."},
+	codePolicyDenied:    {errorDenied, "Use the tools returned by tools/list and the tables returned by list_tables."},
+	codeRateLimited:     {errorUnavailable, "Retry after a short wait."},
+	codeUnavailable:     {errorUnavailable, "Retry later; the health tool reports the database status."},
+	codeCancelled:       {errorTimeout, "Run the call again if its result is still needed."},
+	codeDatabaseError:   {errorDatabase, "Check the query against describe_table and try again."},
+}
+
+// structuredCodes maps the error field of the structured errors built by
+// the middlewares to codes.
+var structuredCodes = map[string]string{
+	"access_denied":            codePolicyDenied,
+	"tool_not_allowed":         codePolicyDenied,
+	"confirmation_declined":    codePolicyDenied,
+	"confirmation_unavailable": codePolicyDenied,
+	"argument_too_long":        codeInvalidArgument,
+	"too_many_rows":            codeResourceLimit,
+	"server_busy":              codeRateLimited,
+	"database_unavailable":     codeUnavailable,
+}
+
+// sqlErrorPattern matches database errors caused by the statement itself.
+var sqlErrorPattern = regexp.MustCompile(`(?i)syntax error|no such column|no such function|near "|ambiguous column|misuse of aggregate|wrong number of arguments|incomplete input|only .* allowed for read-only access`)
+
+// errorCode returns the code of an error result and, for structured
+// errors, their fields. Prose errors are classified by their wording:
+// handlers prefix database errors with "Error …", and everything else is
+// about the arguments.
+func errorCode(result *mcp.CallToolResult) (string, map[string]interface{}) {
+	text := resultText(result)
+	var payload map[string]interface{}
+	if json.Unmarshal([]byte(text), &payload) == nil {
+		if code, _ := payload["code"].(string); code != "" {
+			return code, payload
+		}
+		if kind, _ := payload["error"].(string); kind != "" {
+			if kind == "resource_budget_exceeded" {
+				if payload["limit"] == "timeout" {
+					return codeTimeout, payload
+				}
+				return codeResourceLimit, payload
+			}
+			if code, ok := structuredCodes[kind]; ok {
+				return code, payload
+			}
+			return codeInvalidArgument, payload
+		}
+	}
+	lower := strings.ToLower(text)
+	switch {
+	case strings.Contains(lower, "cancelled") || strings.Contains(lower, "context canceled") || strings.Contains(lower, "interrupted"):
+		return codeCancelled, nil
+	case strings.Contains(lower, "context deadline exceeded"):
+		return codeTimeout, nil
+	case strings.Contains(lower, "no such table") || strings.HasPrefix(text, "Table '") && strings.Contains(text, "' not found"):
+		return codeTableNotFound, nil
+	case strings.Contains(lower, "function is not allowed") || strings.Contains(lower, "requires an administrator"):
+		return codePolicyDenied, nil
+	case sqlErrorPattern.MatchString(text):
+		return codeInvalidSQL, nil
+	case strings.HasPrefix(text, "Error "):
+		return codeDatabaseError, nil
+	}
+	return codeInvalidArgument, nil
+}
+
+// resultText returns the first text content of a result.
+func resultText(result *mcp.CallToolResult) string {
+	if result == nil || len(result.Content) == 0 {
+		return ""
+	}
+	text, _ := result.Content[0].(mcp.TextContent)
+	return text.Text
+}
+
+// errorCodesMiddleware gives every error result a machine-readable code and
+// a hint. Prose errors become structured errors with the prose as message;
+// structured ones keep their fields and gain the code.
+func errorCodesMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err != nil || result == nil || !result.IsError {
+			return result, err
+		}
+		code, payload := errorCode(result)
+		if payload == nil {
+			payload = map[string]interface{}{"error": strings.ToLower(code), "message": resultText(result)}
+		}
+		payload["code"] = code
+		if _, ok := payload["hint"]; !ok {
+			payload["hint"] = errorCodeInfo[code].hint
+		}
+		text, _ := json.MarshalIndent(payload, "", "  ")
+		content := append([]mcp.Content{mcp.NewTextContent(string(text))}, result.Content[min(1, len(result.Content)):]...)
+		result.Content = content
+		return result, nil
+	}
+}
+
+// truncatedMeta marks a result that holds only part of the rows.
+func truncatedMeta(hint string) *mcp.Meta {
+	return mcp.NewMetaFromMap(map[string]any{"code": codeResultTruncated, "hint": hint})
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	errorReportQueueSize = 100 // Reports waiting to be sent; later ones are dropped
+	maxStackFrames       = 64
+)
+
+// errorReport describes a panic or internal error of a tool call. The SQL
+// in its message and arguments has its string literals scrubbed.
+type errorReport struct {
+	Time      time.Time         `json:"time"`
+	Kind      string            `json:"kind"` // panic or error
+	Message   string            `json:"message"`
+	RequestID string            `json:"request_id"`
+	SessionID string            `json:"session_id,omitempty"`
+	Principal string            `json:"principal,omitempty"`
+	Tool      string            `json:"tool"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+	Stack     []stackFrame      `json:"stack,omitempty"` // Innermost first
+}
+
+// stackFrame is one call of a panic's stack.
+type stackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// sentryTarget is where a SENTRY_DSN sends events.
+type sentryTarget struct {
+	endpoint, key string
+}
+
+// ErrorReporter recovers panics of tool handlers and sends them, with the
+// errors handlers return, to Sentry (SENTRY_DSN) or a webhook
+// (ERROR_WEBHOOK_URL), so operators hear about crashes agents trigger.
+// Reports are sent in the background; when the target falls behind they
+// are dropped and counted.
+type ErrorReporter struct {
+	sentry       *sentryTarget
+	webhook      string
+	webhookToken string
+	environment  string
+	client       *http.Client
+	queue        chan errorReport
+	done         chan struct{}
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// errorReporterFromEnv configures reporting from SENTRY_DSN, with
+// SENTRY_ENVIRONMENT as the event environment, or ERROR_WEBHOOK_URL, which
+// receives each report as JSON with ERROR_WEBHOOK_TOKEN as bearer token.
+// With neither set, panics are only recovered and logged.
+func errorReporterFromEnv(sentryDSN, webhookToken string) (*ErrorReporter, error) {
+	r := &ErrorReporter{environment: os.Getenv("SENTRY_ENVIRONMENT"), webhookToken: webhookToken}
+	webhook := os.Getenv("ERROR_WEBHOOK_URL")
+	switch {
+	case sentryDSN != "" && webhook != "":
+		return nil, fmt.Errorf("set SENTRY_DSN or ERROR_WEBHOOK_URL, not both")
+	case sentryDSN != "":
+		target, err := parseSentryDSN(sentryDSN)
+		if err != nil {
+			return nil, err
+		}
+		r.sentry = target
+	case webhook != "":
+		if u, err := url.Parse(webhook); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("invalid ERROR_WEBHOOK_URL, expected an http(s) URL")
+		}
+		r.webhook = webhook
+	default:
+		return r, nil
+	}
+	r.client = &http.Client{Timeout: 10 * time.Second}
+	r.queue = make(chan errorReport, errorReportQueueSize)
+	r.done = make(chan struct{})
+	go r.run()
+	return r, nil
+}
+
+// parseSentryDSN reads a DSN of the form https://key@host/project_id, with
+// an optional path before the project ID for self-hosted installs.
+func parseSentryDSN(dsn string) (*sentryTarget, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" || u.User == nil || u.User.Username() == "" {
+		// The DSN holds the key, so it is not repeated in the error
+		return nil, fmt.Errorf("invalid SENTRY_DSN, expected https://key@host/project_id")
+	}
+	project := path.Base(u.Path)
+	if project == "" || project == "/" || project == "." {
+		return nil, fmt.Errorf("invalid SENTRY_DSN: missing project ID")
+	}
+	prefix := strings.TrimSuffix(path.Dir(u.Path), "/")
+	return &sentryTarget{
+		endpoint: fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, prefix, project),
+		key:      u.User.Username(),
+	}, nil
+}
+
+// recoveryMiddleware turns panics of tool handlers into errors, as the
+// server's own recovery does, and reports them and the errors handlers
+// return.
+func (r *ErrorReporter) recoveryMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (result *mcp.CallToolResult, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic recovered in %s tool handler: %v", request.Params.Name, p)
+				report := r.newReport(ctx, request, "panic", fmt.Sprint(p))
+				report.Stack = panicStack()
+				log.Printf("[%s] panic in %s tool handler: %s\n%s", report.RequestID, request.Params.Name, report.Message, formatStack(report.Stack))
+				r.send(report)
+			}
+		}()
+		result, err = next(ctx, request)
+		if err != nil {
+			r.send(r.newReport(ctx, request, "error", err.Error()))
+		}
+		return result, err
+	}
+}
+
+// newReport describes a failed call, scrubbing the string literals of its
+// message and string arguments: stripped, or hashed when LOG_SQL_LITERALS
+// is hash.
+func (r *ErrorReporter) newReport(ctx context.Context, request mcp.CallToolRequest, kind, message string) errorReport {
+	mode := literalsStrip
+	if logLiterals == literalsHash {
+		mode = literalsHash
+	}
+	report := errorReport{
+		Time:      time.Now().UTC(),
+		Kind:      kind,
+		Message:   scrubSQL(message, mode),
+		RequestID: requestIDFromContext(ctx),
+		SessionID: sessionID(ctx),
+		Principal: principalFromContext(ctx),
+		Tool:      request.Params.Name,
+	}
+	for name, value := range request.GetArguments() {
+		if s, ok := value.(string); ok {
+			if report.Arguments == nil {
+				report.Arguments = make(map[string]string)
+			}
+			report.Arguments[name] = scrubSQL(s, mode)
+		}
+	}
+	return report
+}
+
+// panicStack returns the stack of the panicking goroutine, from the frame
+// that panicked outwards, called from the deferred recover.
+func panicStack() []stackFrame {
+	pcs := make([]uintptr, maxStackFrames)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var stack []stackFrame
+	for {
+		frame, more := frames.Next()
+		// Skip the runtime's panic machinery above the panicking frame
+		if !strings.HasPrefix(frame.Function, "runtime.") || len(stack) > 0 {
+			stack = append(stack, stackFrame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+func formatStack(stack []stackFrame) string {
+	var b strings.Builder
+	for _, f := range stack {
+		fmt.Fprintf(&b, "\t%s\n\t\t%s:%d\n", f.Function, f.File, f.Line)
+	}
+	return b.String()
+}
+
+func (r *ErrorReporter) send(report errorReport) {
+	if r.queue == nil {
+		return
+	}
+	select {
+	case r.queue <- report:
+	default:
+		r.mu.Lock()
+		r.dropped++
+		r.mu.Unlock()
+	}
+}
+
+// close stops accepting reports and waits for the queued ones to be sent.
+func (r *ErrorReporter) close() {
+	if r.queue == nil {
+		return
+	}
+	close(r.queue)
+	<-r.done
+}
+
+func (r *ErrorReporter) run() {
+	defer close(r.done)
+	for report := range r.queue {
+		r.mu.Lock()
+		dropped := r.dropped
+		r.dropped = 0
+		r.mu.Unlock()
+		if dropped > 0 {
+			log.Printf("Dropped %d error reports: the error reporting target is not keeping up", dropped)
+		}
+		if err := r.post(report); err != nil {
+			log.Printf("[%s] Error sending %s report: %v", report.RequestID, report.Kind, err)
+		}
+	}
+}
+
+// post sends a report to the webhook, or as a Sentry event.
+func (r *ErrorReporter) post(report errorReport) error {
+	var req *http.Request
+	if r.sentry != nil {
+		body, err := r.sentryEnvelope(report)
+		if err != nil {
+			return err
+		}
+		req, err = http.NewRequest(http.MethodPost, r.sentry.endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-sentry-envelope")
+		req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_key=%s, sentry_client=db-mcp/1.0.0", r.sentry.key))
+	} else {
+		body, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		req, err = http.NewRequest(http.MethodPost, r.webhook, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if r.webhookToken != "" {
+			req.Header.Set("Authorization", "Bearer "+r.webhookToken)
+		}
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("target answered %s", resp.Status)
+	}
+	return nil
+}
+
+// sentryEnvelope wraps a report as a Sentry event envelope.
+func (r *ErrorReporter) sentryEnvelope(report errorReport) ([]byte, error) {
+	id := make([]byte, 16)
+	rand.Read(id)
+	eventID := hex.EncodeToString(id)
+	level := "error"
+	if report.Kind == "panic" {
+		level = "fatal"
+	}
+	// Sentry lists frames outermost first
+	frames := make([]map[string]any, 0, len(report.Stack))
+	for i := len(report.Stack) - 1; i >= 0; i-- {
+		f := report.Stack[i]
+		frames = append(frames, map[string]any{
+			"function": f.Function,
+			"abs_path": f.File,
+			"filename": path.Base(f.File),
+			"lineno":   f.Line,
+			"in_app":   strings.HasPrefix(f.Function, "main."),
+		})
+	}
+	exception := map[string]any{"type": report.Kind, "value": report.Message}
+	if len(frames) > 0 {
+		exception["stacktrace"] = map[string]any{"frames": frames}
+	}
+	hostname, _ := os.Hostname()
+	event := map[string]any{
+		"event_id":    eventID,
+		"timestamp":   report.Time.Format(time.RFC3339Nano),
+		"platform":    "go",
+		"level":       level,
+		"logger":      "db-mcp",
+		"server_name": hostname,
+		"release":     "db-mcp@1.0.0",
+		"exception":   map[string]any{"values": []any{exception}},
+		"tags":        map[string]string{"tool": report.Tool, "request_id": report.RequestID},
+		"extra":       map[string]any{"session_id": report.SessionID, "arguments": report.Arguments},
+	}
+	if r.environment != "" {
+		event["environment"] = r.environment
+	}
+	if report.Principal != "" {
+		event["user"] = map[string]string{"id": report.Principal}
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, item := range []any{
+		map[string]any{"event_id": eventID, "sent_at": time.Now().UTC().Format(time.RFC3339Nano)},
+		map[string]any{"type": "event"},
+		event,
+	} {
+		if err := enc.Encode(item); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
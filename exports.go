@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultExportTTL is how long exported files are kept when EXPORT_TTL is not set.
+const defaultExportTTL = time.Hour
+
+// exportFilePattern matches the file names written by the export store.
+var exportFilePattern = regexp.MustCompile(`^[0-9a-f]{32}\.[a-z]+$`)
+
+// exportContentTypes maps the supported export formats to their MIME types.
+var exportContentTypes = map[string]string{
+	"csv":     "text/csv; charset=utf-8",
+	"json":    "application/json",
+	"parquet": "application/vnd.apache.parquet",
+	"xlsx":    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// Artifact is an exported query result available for download.
+type Artifact struct {
+	ID      string    `json:"id"`
+	Format  string    `json:"format"`
+	Rows    int       `json:"rows"`
+	Bytes   int64     `json:"bytes"`
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires_at"`
+
+	path  string
+	owner string
+}
+
+// ExportStore writes exported results to a directory and serves them over HTTP
+// at /results/{id} until they expire.
+type ExportStore struct {
+	dir     string
+	ttl     time.Duration
+	baseURL string
+
+	mu        sync.Mutex
+	artifacts map[string]*Artifact
+}
+
+// NewExportStoreFromEnv creates the store configured by EXPORT_DIR, EXPORT_TTL and BASE_URL.
+func NewExportStoreFromEnv() (*ExportStore, error) {
+	store := &ExportStore{
+		dir:       os.Getenv("EXPORT_DIR"),
+		ttl:       defaultExportTTL,
+		baseURL:   strings.TrimRight(os.Getenv("BASE_URL"), "/"),
+		artifacts: make(map[string]*Artifact),
+	}
+	if store.dir == "" {
+		store.dir = filepath.Join(os.TempDir(), "db-mcp-exports")
+	}
+	if v := os.Getenv("EXPORT_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid EXPORT_TTL %q", v)
+		}
+		store.ttl = d
+	}
+	if err := os.MkdirAll(store.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create export directory %s: %w", store.dir, err)
+	}
+
+	// Files from a previous run are no longer indexed and can never be downloaded
+	entries, _ := os.ReadDir(store.dir)
+	for _, entry := range entries {
+		if exportFilePattern.MatchString(entry.Name()) {
+			os.Remove(filepath.Join(store.dir, entry.Name()))
+		}
+	}
+	return store, nil
+}
+
+// Run removes expired artifacts until ctx is cancelled.
+func (e *ExportStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.expire()
+		}
+	}
+}
+
+func (e *ExportStore) expire() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	for id, artifact := range e.artifacts {
+		if now.After(artifact.Expires) {
+			os.Remove(artifact.path)
+			delete(e.artifacts, id)
+		}
+	}
+}
+
+// create reserves a new artifact file and returns it with the open file.
+func (e *ExportStore) create(format, owner string) (*Artifact, *os.File, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, nil, err
+	}
+	id := hex.EncodeToString(idBytes)
+	path := filepath.Join(e.dir, id+"."+format)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Artifact{ID: id, Format: format, path: path, owner: owner}, f, nil
+}
+
+// publish makes a fully written artifact available for download.
+func (e *ExportStore) publish(artifact *Artifact) {
+	artifact.Expires = time.Now().Add(e.ttl)
+	artifact.URL = e.baseURL + "/results/" + artifact.ID
+
+	e.mu.Lock()
+	e.artifacts[artifact.ID] = artifact
+	e.mu.Unlock()
+}
+
+// downloadHandler serves /results/{id}. When the artifact was created by an
+// identified caller, the same identity must be presented to download it.
+func (e *ExportStore) downloadHandler(identity *Identity) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+
+		e.mu.Lock()
+		artifact, ok := e.artifacts[id]
+		e.mu.Unlock()
+		if !ok || time.Now().After(artifact.Expires) {
+			http.NotFound(w, r)
+			return
+		}
+		if artifact.owner != "" && principalFromContext(identity.contextFunc(r.Context(), r)) != artifact.owner {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Content-Type", exportContentTypes[artifact.Format])
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="result-%s.%s"`, artifact.ID, artifact.Format))
+		http.ServeFile(w, r, artifact.path)
+	})
+}
+
+// exportQueryHandler runs a SELECT and writes the full result to a downloadable file.
+func (ds *DatabaseService) exportQueryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	queries := request.GetStringSlice("queries", nil)
+	if query, ok := args["query"].(string); ok && query != "" {
+		queries = append([]string{query}, queries...)
+	}
+	if len(queries) == 0 {
+		return mcp.NewToolResultError("Missing or invalid 'query' argument."), nil
+	}
+	for _, query := range queries {
+		if err := ds.validateReadOnly(query); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+	format, _ := args["format"].(string)
+	if format == "" {
+		format = "csv"
+	}
+	format = strings.ToLower(format)
+	if _, ok := exportContentTypes[format]; !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Unsupported export format '%s' (expected csv, json, parquet or xlsx).", format)), nil
+	}
+	if len(queries) > 1 && format != "xlsx" {
+		return mcp.NewToolResultError("Several queries can only be exported as xlsx, one sheet per query."), nil
+	}
+
+	artifact, f, err := ds.exports.create(format, principalFromContext(ctx))
+	if err != nil {
+		log.Printf("Error creating export file: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error creating export file", err), nil
+	}
+	if format == "xlsx" {
+		artifact.Rows, err = ds.writeWorkbook(ctx, progressWriter(ctx, f), queries)
+	} else {
+		artifact.Rows, err = ds.writeQueryExport(ctx, progressWriter(ctx, f), queries[0], format)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(artifact.path)
+		log.Printf("Error writing export: %v", err)
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error exporting query", err), nil
+	}
+	if info, err := os.Stat(artifact.path); err == nil {
+		artifact.Bytes = info.Size()
+	}
+	ds.exports.publish(artifact)
+	addRowsReturned(ctx, artifact.Rows)
+
+	resultJSON, err := json.MarshalIndent(artifact, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling export info to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting export info", err), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// writeQueryExport runs query and writes its rows to w as CSV, JSON or
+// Parquet.
+func (ds *DatabaseService) writeQueryExport(ctx context.Context, w io.Writer, query, format string) (int, error) {
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	return writeExport(ctx, rows, w, format, ds.limitsFor(ctx).MaxRows)
+}
+
+// writeExport streams rows to w in the given format, masked for the
+// caller's role, and returns the row count. Parquet rows are buffered and
+// written at the end, since the file describes its columns in a footer.
+func writeExport(ctx context.Context, rows *sql.Rows, w io.Writer, format string, maxRows int) (int, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+
+	var csvWriter *csv.Writer
+	switch format {
+	case "csv":
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(columns); err != nil {
+			return 0, err
+		}
+	case "json":
+		if _, err := io.WriteString(w, "["); err != nil {
+			return 0, err
+		}
+	}
+
+	var parquetValues [][]interface{}
+	if format == "parquet" {
+		parquetValues = make([][]interface{}, len(columns))
+	}
+
+	masks := masksFor(ctx, columns)
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	count := 0
+	for rows.Next() {
+		if maxRows > 0 && count >= maxRows {
+			return count, errRowBudgetExceeded
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return count, err
+		}
+
+		switch format {
+		case "csv":
+			record := make([]string, len(columns))
+			for i := range columns {
+				if v := masks.apply(i, normalizeValue(values[i], columnTypes[i].DatabaseTypeName())); v != nil {
+					record[i] = fmt.Sprint(v)
+				}
+			}
+			if err := csvWriter.Write(record); err != nil {
+				return count, err
+			}
+		case "json":
+			rowMap := make(map[string]interface{}, len(columns))
+			for i, colName := range columns {
+				rowMap[colName] = masks.apply(i, normalizeValue(values[i], columnTypes[i].DatabaseTypeName()))
+			}
+			rowJSON, err := json.Marshal(rowMap)
+			if err != nil {
+				return count, err
+			}
+			sep := ",\n"
+			if count == 0 {
+				sep = "\n"
+			}
+			if _, err := io.WriteString(w, sep+string(rowJSON)); err != nil {
+				return count, err
+			}
+		case "parquet":
+			for i := range columns {
+				parquetValues[i] = append(parquetValues[i], masks.apply(i, normalizeValue(values[i], columnTypes[i].DatabaseTypeName())))
+			}
+		}
+		count++
+		addRowProgress(ctx)
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		return count, csvWriter.Error()
+	}
+	if parquetValues != nil {
+		return count, writeParquet(w, columns, parquetValues, count)
+	}
+	_, err = io.WriteString(w, "\n]\n")
+	return count, err
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Output formats accepted by the format argument of read_query.
+const (
+	formatJSON  = "json"  // One indented JSON array (default)
+	formatJSONL = "jsonl" // One compact JSON object per line
+	formatHTML  = "html"  // A minimal HTML table, sortable by clicking a header
+)
+
+// htmlSortScript makes the columns of rendered tables sortable, numerically
+// when both cells parse as numbers.
+const htmlSortScript = `<script>
+document.querySelectorAll("table.db-mcp-result th").forEach(function (th, i) {
+  th.style.cursor = "pointer";
+  th.onclick = function () {
+    var body = th.closest("table").tBodies[0], asc = th.dataset.asc !== "1";
+    th.dataset.asc = asc ? "1" : "";
+    Array.from(body.rows).sort(function (a, b) {
+      var x = a.cells[i].textContent, y = b.cells[i].textContent, n = parseFloat(x) - parseFloat(y);
+      return (isNaN(n) ? x.localeCompare(y) : n) * (asc ? 1 : -1);
+    }).forEach(function (row) { body.appendChild(row); });
+  };
+});
+</script>`
+
+// parseFormat validates a format argument, defaulting to JSON.
+func parseFormat(arg interface{}) (string, error) {
+	format, _ := arg.(string)
+	switch format = strings.ToLower(format); format {
+	case "":
+		return formatJSON, nil
+	case formatJSON, formatJSONL, formatHTML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported format '%s' (expected json, jsonl or html)", format)
+	}
+}
+
+// rowEncoding describes how the rows of a result are encoded and rendered.
+// Rows are encoded one by one so that any page of them can be rendered later.
+type rowEncoding struct {
+	Format  string
+	Columns []string // Column order, used by formats with a header
+}
+
+// encodeRow encodes a single result row.
+func (e rowEncoding) encodeRow(row map[string]interface{}) (string, error) {
+	switch e.Format {
+	case formatJSONL:
+		b, err := json.Marshal(row)
+		return string(b), err
+	case formatHTML:
+		var sb strings.Builder
+		sb.WriteString("<tr>")
+		for _, col := range e.Columns {
+			sb.WriteString("<td>")
+			if v := row[col]; v != nil {
+				sb.WriteString(html.EscapeString(fmt.Sprint(v)))
+			}
+			sb.WriteString("</td>")
+		}
+		sb.WriteString("</tr>")
+		return sb.String(), nil
+	default:
+		b, err := json.MarshalIndent(row, "  ", "  ")
+		return string(b), err
+	}
+}
+
+// render assembles encoded rows into the output of the format.
+func (e rowEncoding) render(rows []string) string {
+	switch e.Format {
+	case formatJSONL:
+		return strings.Join(rows, "\n")
+	case formatHTML:
+		return e.htmlHead() + strings.Join(rows, "\n") + e.htmlTail()
+	default:
+		return joinRows(rows)
+	}
+}
+
+func (e rowEncoding) htmlHead() string {
+	var sb strings.Builder
+	sb.WriteString("<table class=\"db-mcp-result\">\n<thead><tr>")
+	for _, col := range e.Columns {
+		sb.WriteString("<th>" + html.EscapeString(col) + "</th>")
+	}
+	sb.WriteString("</tr></thead>\n<tbody>\n")
+	return sb.String()
+}
+
+func (e rowEncoding) htmlTail() string {
+	return "\n</tbody>\n</table>\n" + htmlSortScript
+}
+
+// truncate shortens rendered output to about maxResultSize bytes. JSON Lines
+// and HTML output is cut at a row boundary so the remainder stays well formed.
+func (e rowEncoding) truncate(s string) string {
+	cut := s[:maxResultSize]
+	switch e.Format {
+	case formatJSONL:
+		if i := strings.LastIndexByte(cut, '\n'); i >= 0 {
+			cut = cut[:i]
+		}
+	case formatHTML:
+		if i := strings.LastIndex(cut, "</tr>"); i >= 0 {
+			return cut[:i+len("</tr>")] + e.htmlTail() + "\n<p>... (results truncated)</p>"
+		}
+	}
+	return cut + "\n... (results truncated)"
+}
+
+// mimeType returns the MIME type of rendered output.
+func (e rowEncoding) mimeType() string {
+	switch e.Format {
+	case formatJSONL:
+		return "application/jsonl"
+	case formatHTML:
+		return "text/html"
+	default:
+		return "application/json"
+	}
+}
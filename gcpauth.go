@@ -0,0 +1,211 @@
+//go:build !no_bigquery
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Google APIs are called with OAuth2 access tokens obtained from Application
+// Default Credentials, looked up in order:
+//
+//  1. an explicit credentials file (the credentials DSN option),
+//  2. GOOGLE_APPLICATION_CREDENTIALS,
+//  3. the gcloud user credentials (gcloud auth application-default login),
+//  4. the metadata server when running on Google Cloud.
+//
+// Credentials files hold either a service account key or gcloud user credentials.
+const (
+	googleTokenURL    = "https://oauth2.googleapis.com/token"
+	googleMetadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	bigqueryScope     = "https://www.googleapis.com/auth/bigquery"
+)
+
+// googleCredentials is a service account key or gcloud user credentials file.
+type googleCredentials struct {
+	Type         string `json:"type"` // service_account or authorized_user
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// googleTokenSource caches an access token until shortly before it expires.
+type googleTokenSource struct {
+	creds  *googleCredentials // nil when tokens come from the metadata server
+	key    *rsa.PrivateKey    // Service account signing key
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newGoogleTokenSource(credentialsFile string) (*googleTokenSource, error) {
+	if credentialsFile == "" {
+		credentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if credentialsFile == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			path := filepath.Join(dir, "gcloud", "application_default_credentials.json")
+			if _, err := os.Stat(path); err == nil {
+				credentialsFile = path
+			}
+		}
+	}
+	ts := &googleTokenSource{client: &http.Client{Timeout: 30 * time.Second}}
+	if credentialsFile == "" {
+		return ts, nil
+	}
+
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading Google credentials: %w", err)
+	}
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("invalid Google credentials file %s: %w", credentialsFile, err)
+	}
+	switch creds.Type {
+	case "service_account":
+		block, _ := pem.Decode([]byte(creds.PrivateKey))
+		if block == nil {
+			return nil, fmt.Errorf("service account key in %s has no PEM private key", credentialsFile)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service account key in %s: %w", credentialsFile, err)
+		}
+		key, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("service account key in %s is not an RSA key", credentialsFile)
+		}
+		if creds.TokenURI == "" {
+			creds.TokenURI = googleTokenURL
+		}
+		ts.key = key
+	case "authorized_user":
+		if creds.RefreshToken == "" {
+			return nil, fmt.Errorf("user credentials in %s have no refresh token", credentialsFile)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Google credentials type %q in %s", creds.Type, credentialsFile)
+	}
+	ts.creds = &creds
+	return ts, nil
+}
+
+// Token returns a valid access token, fetching a new one when needed.
+func (ts *googleTokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token != "" && time.Until(ts.expires) > time.Minute {
+		return ts.token, nil
+	}
+
+	var req *http.Request
+	var err error
+	switch {
+	case ts.creds == nil:
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, googleMetadataURL+"?scopes="+url.QueryEscape(bigqueryScope), nil)
+		if err == nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	case ts.creds.Type == "service_account":
+		var assertion string
+		if assertion, err = ts.assertion(); err == nil {
+			req, err = tokenRequest(ctx, ts.creds.TokenURI, url.Values{
+				"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+				"assertion":  {assertion},
+			})
+		}
+	default:
+		req, err = tokenRequest(ctx, googleTokenURL, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {ts.creds.ClientID},
+			"client_secret": {ts.creds.ClientSecret},
+			"refresh_token": {ts.creds.RefreshToken},
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		if ts.creds == nil {
+			return "", fmt.Errorf("no Google credentials found (set GOOGLE_APPLICATION_CREDENTIALS) and the metadata server is unreachable: %w", err)
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("fetching Google access token: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid Google token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("Google token response has no access token")
+	}
+	ts.token = token.AccessToken
+	ts.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return ts.token, nil
+}
+
+func tokenRequest(ctx context.Context, tokenURL string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// assertion signs the JWT a service account exchanges for an access token.
+func (ts *googleTokenSource) assertion() (string, error) {
+	now := time.Now()
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   ts.creds.ClientEmail,
+		"scope": bigqueryScope,
+		"aud":   ts.creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, ts.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing service account assertion: %w", err)
+	}
+	return signingInput + "." + enc.EncodeToString(signature), nil
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Connection retry settings. Startup keeps retrying for DB_CONNECT_TIMEOUT;
+// a connection lost at runtime is retried until it comes back.
+const (
+	defaultConnectTimeout = 30 * time.Second
+	minRetryDelay         = 500 * time.Millisecond
+	maxRetryDelay         = 30 * time.Second
+	pingTimeout           = 5 * time.Second
+)
+
+// connectOptions control how the server connects at startup.
+type connectOptions struct {
+	Timeout time.Duration // How long to retry; 0 gives up after the first attempt
+	Lazy    bool          // Start anyway when the database is unreachable and connect in the background
+	Keys    []string      // SQLCipher keys of the database file, current first
+}
+
+// connectOptionsFromEnv reads DB_CONNECT_TIMEOUT and DB_LAZY_CONNECT.
+func connectOptionsFromEnv() (connectOptions, error) {
+	opts := connectOptions{Timeout: defaultConnectTimeout}
+	if v := os.Getenv("DB_CONNECT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return opts, fmt.Errorf("invalid DB_CONNECT_TIMEOUT %q", v)
+		}
+		opts.Timeout = d
+	}
+	if v := os.Getenv("DB_LAZY_CONNECT"); v != "" {
+		lazy, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid DB_LAZY_CONNECT %q", v)
+		}
+		opts.Lazy = lazy
+	}
+	return opts, nil
+}
+
+// backoff produces exponentially growing delays with jitter.
+type backoff struct {
+	delay time.Duration
+}
+
+func (b *backoff) next() time.Duration {
+	if b.delay == 0 {
+		b.delay = minRetryDelay
+	} else if b.delay *= 2; b.delay > maxRetryDelay {
+		b.delay = maxRetryDelay
+	}
+	// Up to 20% jitter so restarted replicas do not retry in lockstep
+	return b.delay - time.Duration(rand.Int63n(int64(b.delay)/5+1))
+}
+
+// pingDatabase checks the database with a bounded ping.
+func pingDatabase(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+// connectWithRetry pings the database until it answers or timeout expires.
+func connectWithRetry(db *sql.DB, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var b backoff
+	for {
+		err := pingDatabase(context.Background(), db)
+		if err == nil {
+			return nil
+		}
+		delay := b.next()
+		if time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		log.Printf("Database %s unavailable (%v); retrying in %s", name, err, delay.Round(time.Millisecond))
+		time.Sleep(delay)
+	}
+}
+
+// dbHealth notices when the database stops answering and reconnects in the
+// background. Meanwhile tool calls fail fast with database_unavailable
+// instead of waiting on dead connections or returning driver errors.
+type dbHealth struct {
+	db        *sql.DB
+	connector *initConnector
+
+	mu      sync.Mutex
+	down    bool
+	since   time.Time // When the connection was lost
+	lastErr error
+	retryAt time.Time // Next reconnection attempt
+
+	maintenance  map[string]int // Running maintenance operations, which make the server not ready
+	shuttingDown bool
+}
+
+func newDBHealth(db *sql.DB, connector *initConnector) *dbHealth {
+	return &dbHealth{db: db, connector: connector}
+}
+
+// check pings the database and starts reconnecting if it does not answer.
+// It returns false when the database is unavailable.
+func (h *dbHealth) check() bool {
+	err := pingDatabase(context.Background(), h.db)
+	if err == nil {
+		return true
+	}
+
+	log.Printf("Database connection lost: %v", err)
+	h.markDown(err)
+	return false
+}
+
+// markDown records that the database is unavailable and starts reconnecting.
+func (h *dbHealth) markDown(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastErr = err
+	if !h.down {
+		h.down, h.since = true, time.Now()
+		h.retryAt = h.since.Add(minRetryDelay)
+		// Pooled connections to the old server are useless now
+		h.connector.invalidate()
+		go h.reconnect()
+	}
+}
+
+// reconnect pings with exponential backoff until the database answers.
+func (h *dbHealth) reconnect() {
+	var b backoff
+	for {
+		delay := b.next()
+		h.mu.Lock()
+		h.retryAt = time.Now().Add(delay)
+		h.mu.Unlock()
+		time.Sleep(delay)
+
+		err := pingDatabase(context.Background(), h.db)
+		h.mu.Lock()
+		if err == nil {
+			log.Printf("Database connected after %s", time.Since(h.since).Round(time.Second))
+			h.down, h.lastErr = false, nil
+			h.mu.Unlock()
+			return
+		}
+		h.lastErr = err
+		h.mu.Unlock()
+	}
+}
+
+// beginMaintenance marks the server not ready while op runs, so load
+// balancers route new sessions elsewhere. Call the returned function when
+// op finishes.
+func (h *dbHealth) beginMaintenance(op string) func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.maintenance == nil {
+		h.maintenance = make(map[string]int)
+	}
+	h.maintenance[op]++
+	log.Printf("Not ready: %s started", op)
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.maintenance[op]--; h.maintenance[op] == 0 {
+			delete(h.maintenance, op)
+		}
+		log.Printf("%s finished", op)
+	}
+}
+
+// beginShutdown makes the server not ready for good, so load balancers stop
+// routing to it while it drains.
+func (h *dbHealth) beginShutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shuttingDown = true
+}
+
+// runningMaintenance returns the name of a running maintenance operation, or
+// "" if there is none.
+func (h *dbHealth) runningMaintenance() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for op := range h.maintenance {
+		return op
+	}
+	return ""
+}
+
+// unavailableResult returns the tool error reported while the database is
+// down, or nil if it is up.
+func (h *dbHealth) unavailableResult() *mcp.CallToolResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.down {
+		return nil
+	}
+	retryIn := time.Until(h.retryAt).Round(time.Second)
+	if retryIn < 0 {
+		retryIn = 0
+	}
+	payload, _ := json.MarshalIndent(map[string]string{
+		"error":   "database_unavailable",
+		"message": fmt.Sprintf("Database temporarily unavailable since %s: %v", h.since.UTC().Format(time.RFC3339), h.lastErr),
+		"hint":    fmt.Sprintf("The server is reconnecting (next attempt in %s). Retry the call shortly.", retryIn),
+	}, "", "  ")
+	return mcp.NewToolResultError(string(payload))
+}
+
+// middleware refuses calls while the database is down, except health. When a
+// call fails, the database is pinged to tell a lost connection from an
+// ordinary error.
+func (h *dbHealth) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.Params.Name == "health" {
+			return next(ctx, request)
+		}
+		if result := h.unavailableResult(); result != nil {
+			return result, nil
+		}
+		result, err := next(ctx, request)
+		if (err != nil || result != nil && result.IsError) && ctx.Err() == nil && !h.check() {
+			log.Printf("%s failed while the database was unavailable", request.Params.Name)
+			return h.unavailableResult(), nil
+		}
+		return result, err
+	}
+}
+
+// HealthStatus is the payload returned by the health tool.
+type HealthStatus struct {
+	Status      string  `json:"status"` // "ok" or "unavailable"
+	Ready       bool    `json:"ready"`  // False while unavailable or during maintenance
+	Maintenance string  `json:"maintenance,omitempty"`
+	Database    string  `json:"database"`
+	Driver      string  `json:"driver"`
+	PingMillis  float64 `json:"ping_ms,omitempty"`
+	Since       string  `json:"unavailable_since,omitempty"`
+	LastError   string  `json:"last_error,omitempty"`
+	NextAttempt string  `json:"next_attempt,omitempty"`
+}
+
+// healthHandler reports whether the database is reachable. It answers even
+// while the server is still waiting for the database to come up.
+func (ds *DatabaseService) healthHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	h := ds.health
+	status := HealthStatus{Status: "ok", Database: ds.databaseFile(), Driver: ds.driverName}
+	h.mu.Lock()
+	down := h.down
+	h.mu.Unlock()
+	if !down {
+		start := time.Now()
+		if h.check() {
+			status.PingMillis = float64(time.Since(start).Microseconds()) / 1000
+		}
+	}
+
+	h.mu.Lock()
+	if h.down {
+		status.Status = "unavailable"
+		status.Since = h.since.UTC().Format(time.RFC3339)
+		status.LastError = h.lastErr.Error()
+		status.NextAttempt = h.retryAt.UTC().Format(time.RFC3339)
+	}
+	h.mu.Unlock()
+	status.Maintenance = h.runningMaintenance()
+	status.Ready = status.Status == "ok" && status.Maintenance == ""
+
+	resultJSON, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling health status to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting health status", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// livenessHandler serves /healthz: the process is up and serving HTTP,
+// whatever the state of the database.
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}` + "\n"))
+}
+
+// readiness reports whether the server can serve tool calls: the database
+// answers, its schema can be read and no maintenance operation is running.
+// It returns the reason when it cannot.
+func (ds *DatabaseService) readiness(ctx context.Context) (bool, string) {
+	h := ds.health
+	h.mu.Lock()
+	shuttingDown := h.shuttingDown
+	h.mu.Unlock()
+	if shuttingDown {
+		return false, "shutting down"
+	}
+	if op := h.runningMaintenance(); op != "" {
+		return false, "maintenance in progress: " + op
+	}
+	h.mu.Lock()
+	down := h.down
+	h.mu.Unlock()
+	if down || !h.check() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return false, fmt.Sprintf("database unavailable: %v", h.lastErr)
+	}
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if _, err := ds.dialect.ListSchemas(ctx); err != nil {
+		return false, fmt.Sprintf("schema not loaded: %v", err)
+	}
+	return true, ""
+}
+
+// readinessHandler serves /readyz with 503 Service Unavailable while the
+// server is not ready.
+func (ds *DatabaseService) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	ready, reason := ds.readiness(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "not_ready", "reason": reason})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Histogram bin limits.
+const (
+	defaultHistogramBins = 10
+	maxHistogramBins     = 100
+)
+
+// HistogramBucket is one bin of a histogram. Bounds are numbers for numeric
+// columns and timestamps for date columns.
+type HistogramBucket struct {
+	Lower interface{} `json:"lower"`
+	Upper interface{} `json:"upper"`
+	Count int64       `json:"count"`
+}
+
+// Histogram describes the distribution of a column.
+type Histogram struct {
+	Column  string            `json:"column"`
+	Kind    string            `json:"kind"`   // numeric or date
+	Method  string            `json:"method"` // equal_width or quantile
+	Rows    int64             `json:"rows"`
+	Nulls   int64             `json:"nulls"`
+	Min     interface{}       `json:"min"`
+	Max     interface{}       `json:"max"`
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// histogramHandler buckets a numeric or date column into equal-width or
+// quantile bins and returns the bin boundaries and counts.
+func (ds *DatabaseService) histogramHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	source, err := ds.sourceRelation(ctx, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	column, _ := args["column"].(string)
+	if column == "" {
+		return mcp.NewToolResultError("Missing or invalid 'column' argument."), nil
+	}
+	resolved, err := ds.resolveColumns(ctx, source, column)
+	if err != nil {
+		return columnErrorResult(err), nil
+	}
+	column = resolved[0]
+	if result := maskedColumnResult(ctx, column); result != nil {
+		return result, nil
+	}
+	bins := defaultHistogramBins
+	if v, ok := args["bins"].(float64); ok {
+		bins = int(v)
+	}
+	if bins < 1 || bins > maxHistogramBins {
+		return mcp.NewToolResultError(fmt.Sprintf("'bins' must be between 1 and %d.", maxHistogramBins)), nil
+	}
+	method, _ := args["method"].(string)
+	if method == "" {
+		method = "equal_width"
+	}
+	if method != "equal_width" && method != "quantile" {
+		return mcp.NewToolResultError(fmt.Sprintf("Unsupported method '%s' (expected equal_width or quantile).", method)), nil
+	}
+
+	col := quoteIdentifier(column)
+	hist := Histogram{Column: column, Method: method, Buckets: []HistogramBucket{}}
+
+	// Text columns are treated as dates and bucketed by their Julian day
+	var minVal, maxVal, minDay, maxDay interface{}
+	var nonNull int64
+	stats := fmt.Sprintf("SELECT COUNT(*), COUNT(%s), MIN(%s), MAX(%s), MIN(julianday(%s)), MAX(julianday(%s)) FROM %s", col, col, col, col, col, source)
+	if err := ds.db.QueryRowContext(ctx, stats).Scan(&hist.Rows, &nonNull, &minVal, &maxVal, &minDay, &maxDay); err != nil {
+		log.Printf("Error reading histogram range: %v, Query: %s", err, logSQL(stats))
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error reading column range", err), nil
+	}
+	hist.Nulls = hist.Rows - nonNull
+
+	x := col
+	lo, hi, numeric := toFloat(minVal), toFloat(maxVal), true
+	switch minVal.(type) {
+	case string, []byte:
+		if minDay == nil || maxDay == nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Column '%s' is neither numeric nor a date.", column)), nil
+		}
+		x = "julianday(" + col + ")"
+		lo, hi, numeric = toFloat(minDay), toFloat(maxDay), false
+	}
+	if nonNull > 0 && (math.IsNaN(lo) || math.IsNaN(hi)) {
+		return mcp.NewToolResultError(fmt.Sprintf("Column '%s' mixes numbers with other values.", column)), nil
+	}
+	hist.Kind = "numeric"
+	bound := func(v float64) interface{} { return v }
+	if !numeric {
+		hist.Kind = "date"
+		bound = func(v float64) interface{} { return julianToTime(v) }
+	}
+	if nonNull == 0 {
+		return histogramResult(hist)
+	}
+	hist.Min, hist.Max = bound(lo), bound(hi)
+
+	var query string
+	var params []interface{}
+	if method == "quantile" {
+		query = fmt.Sprintf("SELECT MIN(x), MAX(x), COUNT(*) FROM (SELECT %s AS x, NTILE(?) OVER (ORDER BY %s) AS bucket FROM %s WHERE %s IS NOT NULL) GROUP BY bucket ORDER BY bucket", x, x, source, x)
+		params = []interface{}{bins}
+	} else {
+		width := (hi - lo) / float64(bins)
+		if width == 0 {
+			width, bins = 1, 1
+		}
+		query = fmt.Sprintf("SELECT MIN(CAST((%s - ?) / ? AS INTEGER), ?) AS bucket, COUNT(*) FROM %s WHERE %s IS NOT NULL GROUP BY bucket ORDER BY bucket", x, source, x)
+		params = []interface{}{lo, width, bins - 1}
+
+		hist.Buckets = make([]HistogramBucket, bins)
+		for i := range hist.Buckets {
+			upper := lo + width*float64(i+1)
+			if i == bins-1 {
+				upper = hi
+			}
+			hist.Buckets[i] = HistogramBucket{Lower: bound(lo + width*float64(i)), Upper: bound(upper)}
+		}
+	}
+
+	rows, err := ds.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		log.Printf("Error executing histogram query: %v, Query: %s", err, logSQL(query))
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error executing histogram query", err), nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if method == "quantile" {
+			var lower, upper interface{}
+			var count int64
+			if err := rows.Scan(&lower, &upper, &count); err != nil {
+				return mcp.NewToolResultErrorFromErr("Error reading histogram bucket", err), nil
+			}
+			hist.Buckets = append(hist.Buckets, HistogramBucket{Lower: bound(toFloat(lower)), Upper: bound(toFloat(upper)), Count: count})
+			continue
+		}
+		var bucket int
+		var count int64
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return mcp.NewToolResultErrorFromErr("Error reading histogram bucket", err), nil
+		}
+		if bucket >= 0 && bucket < len(hist.Buckets) {
+			hist.Buckets[bucket].Count = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating histogram buckets: %v", err)
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error iterating histogram buckets", err), nil
+	}
+
+	return histogramResult(hist)
+}
+
+func histogramResult(hist Histogram) (*mcp.CallToolResult, error) {
+	resultJSON, err := json.MarshalIndent(hist, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling histogram to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting histogram", err), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// toFloat converts a scanned numeric value to float64, returning NaN otherwise.
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	default:
+		return math.NaN()
+	}
+}
+
+// julianToTime converts a Julian day number, as returned by SQLite's julianday(),
+// to a UTC timestamp.
+func julianToTime(day float64) string {
+	const unixEpochJulianDay = 2440587.5
+	ms := math.Round((day - unixEpochJulianDay) * 86400 * 1000)
+	return time.UnixMilli(int64(ms)).UTC().Format("2006-01-02 15:04:05")
+}
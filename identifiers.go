@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// quoteIdentifier quotes a table or column name for use in generated SQL.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteTable quotes a table name for the backend. Schema-qualified names such
+// as mounts.regions are quoted part by part.
+func (ds *DatabaseService) quoteTable(table string) string {
+	schema, name := ds.dialect.SplitTable(table)
+	if schema == "" {
+		return ds.dialect.QuoteIdent(name)
+	}
+	return ds.dialect.QuoteIdent(schema) + "." + ds.dialect.QuoteIdent(name)
+}
+
+// splitTableName separates the schema of a possibly qualified SQLite table
+// name, defaulting to main.
+func splitTableName(table string) (schema, name string) {
+	if schema, name = (sqliteDialect{}).SplitTable(table); schema == "" {
+		schema = "main"
+	}
+	return schema, name
+}
+
+// maxTableSuggestions is the number of near matches offered for an unknown table.
+const maxTableSuggestions = 3
+
+// unknownTableError reports a table name that matches no table, with the
+// closest existing names.
+type unknownTableError struct {
+	Name        string
+	Suggestions []string
+}
+
+func (e *unknownTableError) Error() string {
+	msg := fmt.Sprintf("Table '%s' not found.", e.Name)
+	if len(e.Suggestions) > 0 {
+		msg += fmt.Sprintf(" Did you mean '%s'?", strings.Join(e.Suggestions, "', '"))
+	}
+	return msg
+}
+
+// resolveTable maps a table name as given by a client to the stored name:
+// exact matches win, then a unique case-insensitive match, both within the
+// named or default schema. Otherwise an *unknownTableError lists the nearest
+// names, including mounted file tables.
+func (ds *DatabaseService) resolveTable(ctx context.Context, table string) (string, error) {
+	schema, name := ds.dialect.SplitTable(table)
+	names, err := ds.dialect.ListTables(ctx, schema, true)
+	if err != nil {
+		return "", err
+	}
+	if schema != "" {
+		for i := range names {
+			names[i] = schema + "." + names[i]
+		}
+		name = table
+	}
+
+	var folded []string
+	for _, candidate := range names {
+		if candidate == name {
+			return candidate, nil
+		}
+		if strings.EqualFold(candidate, name) {
+			folded = append(folded, candidate)
+		}
+	}
+	if len(folded) == 1 {
+		return folded[0], nil
+	}
+	if len(folded) > 1 {
+		return "", &unknownTableError{Name: table, Suggestions: folded}
+	}
+
+	if schema == "" && ds.mountFile != "" {
+		mounted, err := ds.dialect.ListTables(ctx, mountSchema, true)
+		if err != nil {
+			return "", err
+		}
+		for _, m := range mounted {
+			names = append(names, mountSchema+"."+m)
+		}
+	}
+	return "", &unknownTableError{Name: table, Suggestions: nearestNames(table, names)}
+}
+
+// tableErrorResult converts a resolveTable error into a tool error.
+func tableErrorResult(table string, err error) *mcp.CallToolResult {
+	var unknown *unknownTableError
+	if errors.As(err, &unknown) {
+		return mcp.NewToolResultError(unknown.Error())
+	}
+	log.Printf("Error resolving table %s: %v", table, err)
+	return mcp.NewToolResultErrorFromErr("Error listing tables", err)
+}
+
+// unknownColumnError reports a column name that matches no column of the
+// source, with the closest existing names.
+type unknownColumnError struct {
+	Name        string
+	Suggestions []string
+}
+
+func (e *unknownColumnError) Error() string {
+	msg := fmt.Sprintf("Column '%s' not found.", e.Name)
+	if len(e.Suggestions) > 0 {
+		msg += fmt.Sprintf(" Did you mean '%s'?", strings.Join(e.Suggestions, "', '"))
+	}
+	return msg
+}
+
+// resolveColumns maps column names given by a client to the columns of
+// source, a relation from sourceRelation, the way resolveTable maps table
+// names. Empty names are returned as they are, for optional arguments. An
+// unknown name must not reach the SQL: SQLite reads a double-quoted name
+// that matches no column as a string literal, so a typo would quietly
+// aggregate a constant.
+func (ds *DatabaseService) resolveColumns(ctx context.Context, source string, names ...string) ([]string, error) {
+	columns, err := ds.sourceColumns(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	resolved := make([]string, len(names))
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		if resolved[i], err = resolveColumn(columns, name); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// sourceColumns returns the column names of source.
+func (ds *DatabaseService) sourceColumns(ctx context.Context, source string) ([]string, error) {
+	rows, err := ds.db.QueryContext(ctx, "SELECT * FROM "+source+" LIMIT 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// resolveColumn maps name to one of columns: an exact match, or else a
+// unique case-insensitive one.
+func resolveColumn(columns []string, name string) (string, error) {
+	var folded []string
+	for _, column := range columns {
+		if column == name {
+			return column, nil
+		}
+		if strings.EqualFold(column, name) {
+			folded = append(folded, column)
+		}
+	}
+	switch len(folded) {
+	case 1:
+		return folded[0], nil
+	case 0:
+		return "", &unknownColumnError{Name: name, Suggestions: nearestNames(name, columns)}
+	default:
+		return "", &unknownColumnError{Name: name, Suggestions: folded}
+	}
+}
+
+// columnErrorResult converts a resolveColumns error into a tool error.
+func columnErrorResult(err error) *mcp.CallToolResult {
+	var unknown *unknownColumnError
+	if errors.As(err, &unknown) {
+		return mcp.NewToolResultError(unknown.Error())
+	}
+	log.Printf("Error reading source columns: %v", err)
+	return mcp.NewToolResultErrorFromErr("Error reading source columns", err)
+}
+
+// nearestNames returns the names closest to name by edit distance, ignoring
+// case, among those close enough to be a plausible typo or containing name.
+func nearestNames(name string, names []string) []string {
+	type scored struct {
+		name     string
+		distance int
+	}
+	target := strings.ToLower(name)
+	var matches []scored
+	for _, candidate := range names {
+		lower := strings.ToLower(candidate)
+		d := editDistance(target, lower)
+		if d <= max(2, len(target)/3) || strings.Contains(lower, target) || strings.Contains(target, lower) {
+			matches = append(matches, scored{candidate, d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+
+	suggestions := []string{}
+	for i := 0; i < len(matches) && i < maxTableSuggestions; i++ {
+		suggestions = append(suggestions, matches[i].name)
+	}
+	return suggestions
+}
+
+// editDistance is the Levenshtein distance between a and b in runes.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(t)]
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// defaultIdentityHeader is the header Pomerium uses to pass the authenticated
+// user's email when jwt_claims_headers includes email.
+const defaultIdentityHeader = "X-Pomerium-Claim-Email"
+
+// principalKey is a context key for the authenticated caller.
+type principalKey struct{}
+
+// Identity resolves the caller of a request from a header set by the
+// authenticating proxy in front of the server.
+type Identity struct {
+	Header string
+	Admins map[string]bool
+}
+
+// NewIdentity creates an Identity reading the given header (or the Pomerium
+// default) and treating the comma separated adminList as administrators.
+func NewIdentity(header, adminList string) *Identity {
+	if header == "" {
+		header = defaultIdentityHeader
+	}
+	admins := make(map[string]bool)
+	for _, admin := range strings.Split(adminList, ",") {
+		if admin = strings.ToLower(strings.TrimSpace(admin)); admin != "" {
+			admins[admin] = true
+		}
+	}
+	return &Identity{Header: header, Admins: admins}
+}
+
+// contextFunc stores the caller taken from the HTTP request in the context.
+// A request authenticated by bearer token keeps the token's name: the header
+// is only trusted without one, as the token holder could set it too.
+func (id *Identity) contextFunc(ctx context.Context, r *http.Request) context.Context {
+	if scopeFromContext(ctx) != "" {
+		return ctx
+	}
+	if principal := strings.TrimSpace(r.Header.Get(id.Header)); principal != "" {
+		ctx = context.WithValue(ctx, principalKey{}, strings.ToLower(principal))
+	}
+	return ctx
+}
+
+// principalFromContext returns the authenticated caller, or "" if unknown.
+func principalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// isAdmin reports whether the caller is listed in ADMIN_USERS or uses the
+// admin profile.
+func (id *Identity) isAdmin(ctx context.Context) bool {
+	if profile := profileFromContext(ctx); profile != nil && profile.Admin {
+		return true
+	}
+	principal := principalFromContext(ctx)
+	return principal != "" && id.Admins[principal]
+}
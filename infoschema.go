@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// infoSchemaDialect introspects backends through the standard
+// INFORMATION_SCHEMA views. Backends differ in bind parameters, the default
+// schema and which constraint views exist, which the fields describe.
+type infoSchemaDialect struct {
+	db            *sql.DB
+	param         func(n int) string  // Bind parameter n (1-based); nil for "?"
+	quote         func(string) string // Identifier quoting; nil for standard double quotes
+	currentSchema string              // SQL expression naming the default schema; empty to span all non-system schemas
+	systemSchemas []string            // Schemas hidden from listings
+	constraints   bool                // TABLE_CONSTRAINTS, KEY_COLUMN_USAGE and friends are available
+}
+
+// bindings collects query arguments and renders their placeholders.
+type bindings struct {
+	d    infoSchemaDialect
+	args []interface{}
+}
+
+func (b *bindings) add(v interface{}) string {
+	b.args = append(b.args, v)
+	if b.d.param == nil {
+		return "?"
+	}
+	return b.d.param(len(b.args))
+}
+
+// schemaCondition restricts column to the given schema, or to the default one.
+func (d infoSchemaDialect) schemaCondition(column, schema string, b *bindings) string {
+	switch {
+	case schema != "":
+		return column + " = " + b.add(schema)
+	case d.currentSchema != "":
+		return column + " = " + d.currentSchema
+	case len(d.systemSchemas) > 0:
+		return "UPPER(" + column + ") NOT IN ('" + strings.ToUpper(strings.Join(d.systemSchemas, "', '")) + "')"
+	default:
+		return "1 = 1"
+	}
+}
+
+func (d infoSchemaDialect) ListSchemas(ctx context.Context) ([]SchemaInfo, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT TABLE_SCHEMA, COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE = 'BASE TABLE' GROUP BY TABLE_SCHEMA ORDER BY TABLE_SCHEMA")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []SchemaInfo
+	for rows.Next() {
+		var s SchemaInfo
+		if err := rows.Scan(&s.Name, &s.Tables); err != nil {
+			return nil, err
+		}
+		if !d.isSystemSchema(s.Name) {
+			schemas = append(schemas, s)
+		}
+	}
+	return schemas, rows.Err()
+}
+
+func (d infoSchemaDialect) isSystemSchema(schema string) bool {
+	for _, s := range d.systemSchemas {
+		if strings.EqualFold(s, schema) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d infoSchemaDialect) ListTables(ctx context.Context, schema string, includeViews bool) ([]string, error) {
+	types := "table_type = 'BASE TABLE'"
+	if includeViews {
+		types = "table_type IN ('BASE TABLE', 'VIEW')"
+	}
+	b := &bindings{d: d}
+	query := fmt.Sprintf("SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE %s AND %s ORDER BY TABLE_NAME",
+		d.schemaCondition("TABLE_SCHEMA", schema, b), types)
+	return d.queryStrings(ctx, query, b.args...)
+}
+
+func (d infoSchemaDialect) DescribeTable(ctx context.Context, table string) (*TableDescription, error) {
+	schema, name := d.SplitTable(table)
+	b := &bindings{d: d}
+	query := fmt.Sprintf(`SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT FROM INFORMATION_SCHEMA.COLUMNS
+		WHERE %s AND TABLE_NAME = %s ORDER BY ORDINAL_POSITION`, d.schemaCondition("TABLE_SCHEMA", schema, b), b.add(name))
+	rows, err := d.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	desc := &TableDescription{
+		Table:             table,
+		Columns:           []map[string]interface{}{},
+		ForeignKeys:       []ForeignKeyInfo{},
+		Indexes:           []IndexInfo{},
+		UniqueConstraints: [][]string{},
+		CheckConstraints:  []string{},
+	}
+	for rows.Next() {
+		var column, dataType, nullable string
+		var dflt sql.NullString
+		if err := rows.Scan(&column, &dataType, &nullable, &dflt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var dfltValue interface{}
+		if dflt.Valid {
+			dfltValue = dflt.String
+		}
+		notNull := 0
+		if strings.EqualFold(nullable, "NO") {
+			notNull = 1
+		}
+		desc.Columns = append(desc.Columns, map[string]interface{}{
+			"cid": len(desc.Columns), "name": column, "type": dataType, "notnull": notNull, "dflt_value": dfltValue, "pk": 0,
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(desc.Columns) == 0 || !d.constraints {
+		return desc, nil
+	}
+
+	if desc.PrimaryKey, err = d.PrimaryKey(ctx, table); err != nil {
+		return nil, err
+	}
+	for i, key := range desc.PrimaryKey {
+		for _, column := range desc.Columns {
+			if column["name"] == key {
+				column["pk"] = i + 1
+			}
+		}
+	}
+	if desc.UniqueConstraints, err = d.constraintColumns(ctx, schema, name, "UNIQUE"); err != nil {
+		return nil, err
+	}
+	if desc.Indexes, err = d.ListIndexes(ctx, table); err != nil {
+		return nil, err
+	}
+
+	if desc.ForeignKeys, _, err = d.foreignKeys(ctx, schema, name); err != nil {
+		return nil, err
+	}
+
+	b = &bindings{d: d}
+	query = fmt.Sprintf(`SELECT cc.CHECK_CLAUSE FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS AS tc
+		JOIN INFORMATION_SCHEMA.CHECK_CONSTRAINTS AS cc ON cc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND cc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+		WHERE tc.CONSTRAINT_TYPE = 'CHECK' AND %s AND tc.TABLE_NAME = %s ORDER BY tc.CONSTRAINT_NAME`,
+		d.schemaCondition("tc.TABLE_SCHEMA", schema, b), b.add(name))
+	if desc.CheckConstraints, err = d.queryStrings(ctx, query, b.args...); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+// ListIndexes returns no indexes: INFORMATION_SCHEMA does not describe them.
+func (d infoSchemaDialect) ListIndexes(ctx context.Context, table string) ([]IndexInfo, error) {
+	return []IndexInfo{}, nil
+}
+
+// constraintColumns returns the columns of each constraint of the given type on a table.
+func (d infoSchemaDialect) constraintColumns(ctx context.Context, schema, table, constraintType string) ([][]string, error) {
+	b := &bindings{d: d}
+	query := fmt.Sprintf(`SELECT tc.CONSTRAINT_NAME, k.COLUMN_NAME FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS AS tc
+		JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS k ON k.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA
+			AND k.CONSTRAINT_NAME = tc.CONSTRAINT_NAME AND k.TABLE_NAME = tc.TABLE_NAME
+		WHERE tc.CONSTRAINT_TYPE = %s AND %s AND tc.TABLE_NAME = %s ORDER BY tc.CONSTRAINT_NAME, k.ORDINAL_POSITION`,
+		b.add(constraintType), d.schemaCondition("tc.TABLE_SCHEMA", schema, b), b.add(table))
+	rows, err := d.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	constraints := [][]string{}
+	lastName := ""
+	for rows.Next() {
+		var name, column string
+		if err := rows.Scan(&name, &column); err != nil {
+			return nil, err
+		}
+		if name != lastName || len(constraints) == 0 {
+			constraints = append(constraints, nil)
+			lastName = name
+		}
+		constraints[len(constraints)-1] = append(constraints[len(constraints)-1], column)
+	}
+	return constraints, rows.Err()
+}
+
+func (d infoSchemaDialect) PrimaryKey(ctx context.Context, table string) ([]string, error) {
+	if !d.constraints {
+		return nil, nil
+	}
+	schema, name := d.SplitTable(table)
+	keys, err := d.constraintColumns(ctx, schema, name, "PRIMARY KEY")
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return keys[0], nil
+}
+
+// ForeignKeys returns the relationships declared on a table. Parents are
+// qualified when the child is or when they live in another schema.
+func (d infoSchemaDialect) ForeignKeys(ctx context.Context, table string) ([]Relationship, error) {
+	if !d.constraints {
+		return nil, nil
+	}
+	schema, name := d.SplitTable(table)
+	fks, parentSchemas, err := d.foreignKeys(ctx, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	relationships := make([]Relationship, len(fks))
+	for i, fk := range fks {
+		parent := fk.ReferencesTable
+		if parentSchemas[i] != "" {
+			parent = parentSchemas[i] + "." + parent
+		}
+		relationships[i] = Relationship{ChildTable: table, ChildColumns: fk.Columns, ParentTable: parent, ParentColumns: fk.ReferencesColumns, Declared: true}
+	}
+	return relationships, nil
+}
+
+// foreignKeys pairs the referencing and referenced key columns through
+// REFERENTIAL_CONSTRAINTS. The parent schema is returned for each key when
+// the child is qualified or the parent lives in another schema.
+func (d infoSchemaDialect) foreignKeys(ctx context.Context, schema, name string) ([]ForeignKeyInfo, []string, error) {
+	b := &bindings{d: d}
+	query := fmt.Sprintf(`SELECT rc.CONSTRAINT_NAME, k.TABLE_SCHEMA, k.COLUMN_NAME, r.TABLE_SCHEMA, r.TABLE_NAME, r.COLUMN_NAME, rc.UPDATE_RULE, rc.DELETE_RULE
+		FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS AS rc
+		JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS k ON k.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND k.CONSTRAINT_NAME = rc.CONSTRAINT_NAME
+		JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS r ON r.CONSTRAINT_SCHEMA = rc.UNIQUE_CONSTRAINT_SCHEMA
+			AND r.CONSTRAINT_NAME = rc.UNIQUE_CONSTRAINT_NAME AND r.ORDINAL_POSITION = k.POSITION_IN_UNIQUE_CONSTRAINT
+		WHERE %s AND k.TABLE_NAME = %s ORDER BY rc.CONSTRAINT_NAME, k.ORDINAL_POSITION`,
+		d.schemaCondition("k.TABLE_SCHEMA", schema, b), b.add(name))
+	rows, err := d.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	fks := []ForeignKeyInfo{}
+	var parentSchemas []string
+	lastName := ""
+	for rows.Next() {
+		var constraint, childSchema, from, parentSchema, parent, to, onUpdate, onDelete string
+		if err := rows.Scan(&constraint, &childSchema, &from, &parentSchema, &parent, &to, &onUpdate, &onDelete); err != nil {
+			return nil, nil, err
+		}
+		if constraint != lastName || len(fks) == 0 {
+			fks = append(fks, ForeignKeyInfo{ReferencesTable: parent, OnUpdate: onUpdate, OnDelete: onDelete})
+			if schema == "" && parentSchema == childSchema {
+				parentSchema = ""
+			}
+			parentSchemas = append(parentSchemas, parentSchema)
+			lastName = constraint
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, from)
+		fk.ReferencesColumns = append(fk.ReferencesColumns, to)
+	}
+	return fks, parentSchemas, rows.Err()
+}
+
+// SplitTable treats the part before the first dot as the schema.
+func (d infoSchemaDialect) SplitTable(table string) (schema, name string) {
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		return schema, name
+	}
+	return "", table
+}
+
+func (d infoSchemaDialect) QuoteIdent(name string) string {
+	if d.quote != nil {
+		return d.quote(name)
+	}
+	return quoteIdentifier(name)
+}
+
+func (d infoSchemaDialect) ValidateReadOnly(query string) error {
+	return requireSelect(query)
+}
+
+func (d infoSchemaDialect) queryStrings(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	maxInstructionTables  = 50
+	maxInstructionColumns = 8
+	instructionsTimeout   = 5 * time.Second // For reading the schema at initialization
+	instructionsTTL       = 5 * time.Minute // Without a schema version to tell changes apart
+)
+
+// SchemaInstructions fills the instructions of each initialize result with
+// a short overview of the database generated from the live schema: the
+// tables the caller may read with their likely role and first columns, how
+// to explore further and the caller's limits. Clients pass the instructions
+// to the model, so every session starts with some grounding before its
+// first tool call.
+type SchemaInstructions struct {
+	ds       *DatabaseService
+	profiles *Profiles
+
+	mu    sync.Mutex
+	cache map[*Profile]cachedInstructions
+}
+
+type cachedInstructions struct {
+	version    int64 // PRAGMA schema_version of SQLite databases
+	generation int64 // Connector generation, which changes with the file
+	built      time.Time
+	text       string
+}
+
+// schemaInstructionsFromEnv reads SCHEMA_INSTRUCTIONS, which turns the
+// generated instructions on (default true). It returns nil when they are
+// off.
+func schemaInstructionsFromEnv(ds *DatabaseService, profiles *Profiles) (*SchemaInstructions, error) {
+	if v := os.Getenv("SCHEMA_INSTRUCTIONS"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SCHEMA_INSTRUCTIONS %q", v)
+		}
+		if !enabled {
+			return nil, nil
+		}
+	}
+	return &SchemaInstructions{ds: ds, profiles: profiles, cache: make(map[*Profile]cachedInstructions)}, nil
+}
+
+// initializeHook sets the instructions of an initialize result for the
+// caller's profile. They are rebuilt when the schema changes, or after
+// instructionsTTL for databases without a schema version.
+func (s *SchemaInstructions) initializeHook(ctx context.Context, id any, request *mcp.InitializeRequest, result *mcp.InitializeResult) {
+	profile := s.profiles.forContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, instructionsTimeout)
+	defer cancel()
+
+	var version int64
+	generation := s.ds.connector.generation.Load()
+	if s.ds.driverName == "sqlite" {
+		if err := s.ds.db.QueryRowContext(ctx, "PRAGMA schema_version").Scan(&version); err != nil {
+			version = -1
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cached, ok := s.cache[profile]
+	if ok && version >= 0 && cached.version == version && cached.generation == generation && (s.ds.driverName == "sqlite" || time.Since(cached.built) < instructionsTTL) {
+		result.Instructions = cached.text
+		return
+	}
+	text, err := s.build(ctx, profile)
+	if err != nil {
+		// Without the tables, the tips and limits still help
+		log.Printf("Error generating schema instructions: %v", err)
+		result.Instructions = text
+		return
+	}
+	s.cache[profile] = cachedInstructions{version: version, generation: generation, built: time.Now(), text: text}
+	result.Instructions = text
+}
+
+// build writes the instructions for a profile. On error, the text returned
+// leaves out the tables.
+func (s *SchemaInstructions) build(ctx context.Context, profile *Profile) (string, error) {
+	var sb strings.Builder
+	name := s.ds.driverName + " database"
+	if file := s.ds.databaseFile(); s.ds.driverName == "sqlite" && file != "" {
+		name = fmt.Sprintf("SQLite database %s", filepath.Base(file))
+	}
+	access := "read-only"
+	if s.ds.writeDB != nil && profile.Write {
+		access = "read-write"
+	}
+	fmt.Fprintf(&sb, "This server gives %s access to the %s.\n", access, name)
+
+	tables, err := s.tables(ctx, profile)
+	if err == nil {
+		sb.WriteString(tables)
+	}
+
+	sb.WriteString("\nTips:\n")
+	if profile.allows("describe_table") {
+		sb.WriteString("- Call describe_table for the columns, keys and sample values of a table before querying it.\n")
+	}
+	if profile.allows("schema_summary") {
+		sb.WriteString("- Call schema_summary for how the tables relate.\n")
+	}
+	sb.WriteString("- Add a LIMIT while exploring; select only the columns you need.\n")
+	if profile.allows("fetch_result") {
+		sb.WriteString("- Large results are stored and return a result_id: page through them with fetch_result instead of rerunning the query.\n")
+	}
+
+	limits := profile.apply(s.ds.limits)
+	var parts []string
+	if limits.Timeout > 0 {
+		parts = append(parts, fmt.Sprintf("queries time out after %s", limits.Timeout))
+	}
+	if limits.MaxRows > 0 {
+		parts = append(parts, fmt.Sprintf("read at most %d rows", limits.MaxRows))
+	}
+	if len(parts) > 0 {
+		fmt.Fprintf(&sb, "\nLimits: %s.\n", strings.Join(parts, " and "))
+	}
+	return strings.TrimRight(sb.String(), "\n"), err
+}
+
+// tables lists the first maxInstructionTables tables the profile may read,
+// each with its guessed role on SQLite and its first visible columns.
+func (s *SchemaInstructions) tables(ctx context.Context, profile *Profile) (string, error) {
+	names, err := s.ds.readableTables(ctx, profile)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "\nThe database has no tables you can read.\n", nil
+	}
+	var roles map[string]*tableProfile
+	if s.ds.driverName == "sqlite" {
+		// Roles are a guess; the list stays useful without them
+		if _, profiles, err := s.ds.tableProfiles(ctx); err == nil {
+			roles = profiles
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\nTables (%d):\n", len(names))
+	for i, name := range names {
+		if i == maxInstructionTables {
+			fmt.Fprintf(&sb, "- … and %d more; call list_tables for all of them.\n", len(names)-i)
+			break
+		}
+		sb.WriteString("- " + name)
+		if p, ok := roles[name]; ok {
+			sb.WriteString(": " + strings.TrimSuffix(p.role(), "."))
+		}
+		columns, err := s.ds.readableColumns(ctx, profile, name)
+		if err != nil {
+			return "", err
+		}
+		if len(columns) > maxInstructionColumns {
+			columns = append(columns[:maxInstructionColumns], "…")
+		}
+		if len(columns) > 0 {
+			fmt.Fprintf(&sb, " (%s)", strings.Join(columns, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
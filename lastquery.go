@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// LastQueries keeps the last read_query of each MCP session, so rerun_last
+// can run it again with another ORDER BY, LIMIT or page without the agent
+// resending the SQL.
+type LastQueries struct {
+	ds *DatabaseService
+
+	mu        sync.Mutex
+	bySession map[string]*lastQuery
+}
+
+// lastQuery is a session's last query and the refinements of its last rerun.
+type lastQuery struct {
+	Query       string
+	Format      string
+	ColumnTypes bool
+	OrderBy     []orderTerm
+	Limit       int
+	Offset      int
+	ran         time.Time
+}
+
+func NewLastQueries(ds *DatabaseService) *LastQueries {
+	return &LastQueries{ds: ds, bySession: make(map[string]*lastQuery)}
+}
+
+// middleware records successful read_query calls, and turns rerun_last
+// calls into the read_query of the refined SQL before the other
+// middlewares see them, so the query is audited, checked against the
+// caller's role and limited like any other.
+func (l *LastQueries) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id := sessionID(ctx)
+		args := request.GetArguments()
+		var last *lastQuery
+		switch request.Params.Name {
+		case "read_query":
+			if dryRun, _ := args["dry_run"].(bool); dryRun {
+				return next(ctx, request)
+			}
+			query, _ := args["query"].(string)
+			format, _ := args["format"].(string)
+			columnTypes, _ := args["column_types"].(bool)
+			last = &lastQuery{Query: query, Format: format, ColumnTypes: columnTypes}
+		case "rerun_last":
+			previous := l.get(id)
+			if previous == nil {
+				return mcp.NewToolResultError("This session has no previous read_query to rerun."), nil
+			}
+			var err error
+			if last, err = refine(*previous, args); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			request.Params.Arguments = map[string]any{
+				"query":        l.ds.refinedQuery(last),
+				"format":       last.Format,
+				"column_types": last.ColumnTypes,
+			}
+		default:
+			return next(ctx, request)
+		}
+
+		result, err := next(ctx, request)
+		if id != "" && err == nil && result != nil && !result.IsError {
+			l.set(id, last)
+		}
+		return result, err
+	}
+}
+
+// refine applies the arguments of rerun_last to the previous query. A new
+// limit starts from the first row again unless an offset is given, and
+// next_page moves past the rows of the last run.
+func refine(last lastQuery, args map[string]any) (*lastQuery, error) {
+	if orderBy, _ := args["order_by"].(string); orderBy != "" {
+		terms, err := parseOrderBy(orderBy)
+		if err != nil {
+			return nil, err
+		}
+		last.OrderBy = terms
+	}
+	if v, ok := args["limit"].(float64); ok {
+		if v < 1 {
+			return nil, fmt.Errorf("invalid 'limit' argument: must be at least 1")
+		}
+		last.Limit, last.Offset = int(min(v, 1<<20)), 0
+	}
+	if v, ok := args["offset"].(float64); ok {
+		if v < 0 {
+			return nil, fmt.Errorf("invalid 'offset' argument: must not be negative")
+		}
+		last.Offset = int(min(v, 1<<30))
+	}
+	if nextPage, _ := args["next_page"].(bool); nextPage {
+		if last.Limit == 0 {
+			return nil, fmt.Errorf("next_page needs a page size: pass 'limit' first")
+		}
+		last.Offset += last.Limit
+	}
+	if format, _ := args["format"].(string); format != "" {
+		last.Format = format
+	}
+	if columnTypes, ok := args["column_types"].(bool); ok {
+		last.ColumnTypes = columnTypes
+	}
+	return &last, nil
+}
+
+// orderTerm is one key of a rerun_last ORDER BY: a result column, by name
+// or 1-based position.
+type orderTerm struct {
+	Column   string
+	Position int
+	Desc     bool
+}
+
+// orderTermPattern matches one key of an order_by argument and the comma or
+// end that follows it.
+var orderTermPattern = regexp.MustCompile(`^\s*(?:"((?:[^"]|"")+)"|([A-Za-z_][A-Za-z0-9_]*)|([0-9]+))(?:\s+([A-Za-z]+))?\s*(?:,|$)`)
+
+// parseOrderBy parses an order_by argument: result column names, quoted
+// or not, or positions, each optionally followed by ASC or DESC. The
+// argument is spliced into SQL, so nothing else is accepted.
+func parseOrderBy(orderBy string) ([]orderTerm, error) {
+	invalid := fmt.Errorf("invalid 'order_by' argument %q: expected result column names or positions, each optionally followed by ASC or DESC, e.g. \"total DESC, 1\"", orderBy)
+	var terms []orderTerm
+	for rest := orderBy; strings.TrimSpace(rest) != ""; {
+		m := orderTermPattern.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, invalid
+		}
+		rest = rest[len(m[0]):]
+		var term orderTerm
+		switch {
+		case m[1] != "":
+			term.Column = strings.ReplaceAll(m[1], `""`, `"`)
+		case m[2] != "":
+			term.Column = m[2]
+		default:
+			n, err := strconv.Atoi(m[3])
+			if err != nil || n < 1 {
+				return nil, invalid
+			}
+			term.Position = n
+		}
+		switch strings.ToUpper(m[4]) {
+		case "", "ASC":
+		case "DESC":
+			term.Desc = true
+		default:
+			return nil, invalid
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 0 || strings.HasSuffix(strings.TrimSpace(orderBy), ",") {
+		return nil, invalid
+	}
+	return terms, nil
+}
+
+// orderByClause renders terms as an ORDER BY list in the syntax of the
+// database.
+func (ds *DatabaseService) orderByClause(terms []orderTerm) string {
+	keys := make([]string, len(terms))
+	for i, term := range terms {
+		keys[i] = strconv.Itoa(term.Position)
+		if term.Column != "" {
+			keys[i] = ds.dialect.QuoteIdent(term.Column)
+		}
+		if term.Desc {
+			keys[i] += " DESC"
+		}
+	}
+	return strings.Join(keys, ", ")
+}
+
+// refinedQuery wraps the last query in a SELECT applying its ORDER BY,
+// LIMIT and OFFSET, in the syntax of the database.
+func (ds *DatabaseService) refinedQuery(last *lastQuery) string {
+	if len(last.OrderBy) == 0 && last.Limit == 0 && last.Offset == 0 {
+		return last.Query
+	}
+	base := strings.TrimRight(strings.TrimSpace(last.Query), "; \t\r\n")
+	query := "SELECT * FROM (" + base + ") AS last_query"
+	orderBy := ds.orderByClause(last.OrderBy)
+	switch ds.dialect.(type) {
+	case mssqlDialect:
+		if orderBy == "" && (last.Limit > 0 || last.Offset > 0) {
+			// OFFSET … FETCH needs an ORDER BY
+			orderBy = "(SELECT NULL)"
+		}
+		if orderBy != "" {
+			query += " ORDER BY " + orderBy
+		}
+		if last.Limit > 0 || last.Offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d ROWS", last.Offset)
+		}
+		if last.Limit > 0 {
+			query += fmt.Sprintf(" FETCH NEXT %d ROWS ONLY", last.Limit)
+		}
+		return query
+	case trinoDialect:
+		if orderBy != "" {
+			query += " ORDER BY " + orderBy
+		}
+		if last.Offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", last.Offset)
+		}
+		if last.Limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", last.Limit)
+		}
+		return query
+	}
+	if orderBy != "" {
+		query += " ORDER BY " + orderBy
+	}
+	if last.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", last.Limit)
+	}
+	if last.Offset > 0 {
+		query += fmt.Sprintf(" OFFSET %d", last.Offset)
+	}
+	return query
+}
+
+func (l *LastQueries) get(session string) *lastQuery {
+	if session == "" {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bySession[session]
+}
+
+// set records the last query of a session, forgetting the least recently
+// active tenth of sessions once maxTrackedSessions are tracked.
+func (l *LastQueries) set(session string, last *lastQuery) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.bySession[session]; !ok && len(l.bySession) >= maxTrackedSessions {
+		sessions := make([]string, 0, len(l.bySession))
+		for id := range l.bySession {
+			sessions = append(sessions, id)
+		}
+		sort.Slice(sessions, func(i, j int) bool { return l.bySession[sessions[i]].ran.Before(l.bySession[sessions[j]].ran) })
+		for _, id := range sessions[:len(sessions)/10+1] {
+			delete(l.bySession, id)
+		}
+	}
+	last.ran = time.Now()
+	l.bySession[session] = last
+}
@@ -0,0 +1,417 @@
+//go:build !no_libsql
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The libsql driver talks to libSQL/Turso servers using the Hrana-over-HTTP
+// protocol (POST /v2/pipeline). Each database/sql connection maps to one
+// Hrana stream, identified by the baton returned with every response.
+//
+// DSNs follow the libsql-client convention: libsql://host?authToken=TOKEN
+// (libsql:// is served over https://, http:// may be used for local sqld).
+
+func init() {
+	sql.Register("libsql", &libsqlDriver{})
+	registerDriver("libsql", "Remote libSQL/Turso servers over Hrana HTTP", newSQLiteDialect)
+}
+
+type libsqlDriver struct{}
+
+// Open implements driver.Driver.
+func (d *libsqlDriver) Open(dsn string) (driver.Conn, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid libSQL DSN: %w", err)
+	}
+	token := u.Query().Get("authToken")
+	u.RawQuery = ""
+	switch u.Scheme {
+	case "libsql", "wss":
+		u.Scheme = "https"
+	case "ws":
+		u.Scheme = "http"
+	case "https", "http":
+	default:
+		return nil, fmt.Errorf("unsupported libSQL URL scheme %q", u.Scheme)
+	}
+
+	return &libsqlConn{
+		baseURL: strings.TrimRight(u.String(), "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// libsqlConn is a single Hrana stream.
+type libsqlConn struct {
+	baseURL string
+	token   string
+	client  *http.Client
+	baton   string
+	closed  bool
+}
+
+type hranaValue struct {
+	Type   string          `json:"type"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	Base64 string          `json:"base64,omitempty"`
+}
+
+type hranaNamedArg struct {
+	Name  string     `json:"name"`
+	Value hranaValue `json:"value"`
+}
+
+type hranaStmt struct {
+	SQL       string          `json:"sql"`
+	Args      []hranaValue    `json:"args,omitempty"`
+	NamedArgs []hranaNamedArg `json:"named_args,omitempty"`
+	WantRows  bool            `json:"want_rows"`
+}
+
+type hranaRequest struct {
+	Type string     `json:"type"`
+	Stmt *hranaStmt `json:"stmt,omitempty"`
+}
+
+type hranaPipelineRequest struct {
+	Baton    string         `json:"baton,omitempty"`
+	Requests []hranaRequest `json:"requests"`
+}
+
+type hranaStmtResult struct {
+	Cols []struct {
+		Name     string `json:"name"`
+		DeclType string `json:"decltype"`
+	} `json:"cols"`
+	Rows             [][]hranaValue `json:"rows"`
+	AffectedRowCount int64          `json:"affected_row_count"`
+	LastInsertRowID  *string        `json:"last_insert_rowid"`
+}
+
+type hranaPipelineResponse struct {
+	Baton   *string `json:"baton"`
+	BaseURL *string `json:"base_url"`
+	Results []struct {
+		Type     string `json:"type"`
+		Response *struct {
+			Type   string           `json:"type"`
+			Result *hranaStmtResult `json:"result"`
+		} `json:"response"`
+		Error *struct {
+			Message string `json:"message"`
+			Code    string `json:"code"`
+		} `json:"error"`
+	} `json:"results"`
+}
+
+// pipeline sends requests on the connection's stream and returns the first result.
+func (c *libsqlConn) pipeline(ctx context.Context, requests ...hranaRequest) (*hranaStmtResult, error) {
+	if c.closed {
+		return nil, driver.ErrBadConn
+	}
+
+	body, err := json.Marshal(hranaPipelineRequest{Baton: c.baton, Requests: requests})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v2/pipeline", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if c.baton != "" {
+			// The stream is gone; let database/sql retry on a fresh connection
+			c.closed = true
+		}
+		return nil, fmt.Errorf("libSQL server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result hranaPipelineResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid libSQL response: %w", err)
+	}
+	if result.Baton != nil {
+		c.baton = *result.Baton
+	} else {
+		c.baton = ""
+	}
+	if result.BaseURL != nil && *result.BaseURL != "" {
+		c.baseURL = strings.TrimRight(*result.BaseURL, "/")
+	}
+
+	if len(result.Results) == 0 {
+		return nil, errors.New("empty libSQL response")
+	}
+	first := result.Results[0]
+	if first.Type == "error" && first.Error != nil {
+		return nil, fmt.Errorf("libSQL error: %s", first.Error.Message)
+	}
+	if first.Response == nil || first.Response.Result == nil {
+		return &hranaStmtResult{}, nil
+	}
+	return first.Response.Result, nil
+}
+
+func (c *libsqlConn) execute(ctx context.Context, query string, args []driver.NamedValue, wantRows bool) (*hranaStmtResult, error) {
+	stmt := &hranaStmt{SQL: query, WantRows: wantRows}
+	for _, arg := range args {
+		v, err := toHranaValue(arg.Value)
+		if err != nil {
+			return nil, err
+		}
+		if arg.Name != "" {
+			stmt.NamedArgs = append(stmt.NamedArgs, hranaNamedArg{Name: ":" + arg.Name, Value: v})
+		} else {
+			stmt.Args = append(stmt.Args, v)
+		}
+	}
+	return c.pipeline(ctx, hranaRequest{Type: "execute", Stmt: stmt})
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *libsqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, err := c.execute(ctx, query, args, true)
+	if err != nil {
+		return nil, err
+	}
+	return &libsqlRows{result: result}, nil
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *libsqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.execute(ctx, query, args, false)
+	if err != nil {
+		return nil, err
+	}
+	var lastID int64
+	if result.LastInsertRowID != nil {
+		lastID, _ = strconv.ParseInt(*result.LastInsertRowID, 10, 64)
+	}
+	return libsqlResult{lastInsertID: lastID, rowsAffected: result.AffectedRowCount}, nil
+}
+
+// Ping implements driver.Pinger. Opening a connection does not reach the
+// server, so a trivial statement is sent.
+func (c *libsqlConn) Ping(ctx context.Context) error {
+	_, err := c.execute(ctx, "SELECT 1", nil, true)
+	return err
+}
+
+// Prepare implements driver.Conn. Statements are sent as text on execution.
+func (c *libsqlConn) Prepare(query string) (driver.Stmt, error) {
+	return &libsqlStmt{conn: c, query: query}, nil
+}
+
+// Close implements driver.Conn and releases the server-side stream.
+func (c *libsqlConn) Close() error {
+	if c.closed {
+		return nil
+	}
+	var err error
+	if c.baton != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err = c.pipeline(ctx, hranaRequest{Type: "close"})
+	}
+	c.closed = true
+	return err
+}
+
+// Begin implements driver.Conn.
+func (c *libsqlConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx implements driver.ConnBeginTx. The stream keeps the transaction open between requests.
+func (c *libsqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	stmt := "BEGIN"
+	if opts.ReadOnly {
+		stmt = "BEGIN DEFERRED"
+	}
+	if _, err := c.ExecContext(ctx, stmt, nil); err != nil {
+		return nil, err
+	}
+	return &libsqlTx{conn: c}, nil
+}
+
+type libsqlTx struct {
+	conn *libsqlConn
+}
+
+func (t *libsqlTx) Commit() error {
+	_, err := t.conn.ExecContext(context.Background(), "COMMIT", nil)
+	return err
+}
+
+func (t *libsqlTx) Rollback() error {
+	_, err := t.conn.ExecContext(context.Background(), "ROLLBACK", nil)
+	return err
+}
+
+type libsqlStmt struct {
+	conn  *libsqlConn
+	query string
+}
+
+func (s *libsqlStmt) Close() error  { return nil }
+func (s *libsqlStmt) NumInput() int { return -1 }
+
+func (s *libsqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamed(args))
+}
+
+func (s *libsqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamed(args))
+}
+
+func (s *libsqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *libsqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func valuesToNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+type libsqlResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r libsqlResult) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r libsqlResult) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+// libsqlRows iterates over a fully received statement result.
+type libsqlRows struct {
+	result *hranaStmtResult
+	pos    int
+}
+
+func (r *libsqlRows) Columns() []string {
+	cols := make([]string, len(r.result.Cols))
+	for i, c := range r.result.Cols {
+		cols[i] = c.Name
+	}
+	return cols
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName.
+func (r *libsqlRows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(r.result.Cols[index].DeclType)
+}
+
+func (r *libsqlRows) Close() error { return nil }
+
+func (r *libsqlRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.result.Rows) {
+		return io.EOF
+	}
+	row := r.result.Rows[r.pos]
+	r.pos++
+	for i := range dest {
+		if i >= len(row) {
+			dest[i] = nil
+			continue
+		}
+		v, err := fromHranaValue(row[i])
+		if err != nil {
+			return err
+		}
+		dest[i] = v
+	}
+	return nil
+}
+
+func toHranaValue(v driver.Value) (hranaValue, error) {
+	switch x := v.(type) {
+	case nil:
+		return hranaValue{Type: "null"}, nil
+	case int64:
+		return hranaValue{Type: "integer", Value: json.RawMessage(strconv.Quote(strconv.FormatInt(x, 10)))}, nil
+	case bool:
+		b := "0"
+		if x {
+			b = "1"
+		}
+		return hranaValue{Type: "integer", Value: json.RawMessage(strconv.Quote(b))}, nil
+	case float64:
+		raw, err := json.Marshal(x)
+		return hranaValue{Type: "float", Value: raw}, err
+	case string:
+		raw, err := json.Marshal(x)
+		return hranaValue{Type: "text", Value: raw}, err
+	case []byte:
+		return hranaValue{Type: "blob", Base64: base64.StdEncoding.EncodeToString(x)}, nil
+	case time.Time:
+		raw, err := json.Marshal(x.Format(time.RFC3339Nano))
+		return hranaValue{Type: "text", Value: raw}, err
+	default:
+		return hranaValue{}, fmt.Errorf("unsupported argument type %T", v)
+	}
+}
+
+func fromHranaValue(v hranaValue) (driver.Value, error) {
+	switch v.Type {
+	case "null":
+		return nil, nil
+	case "integer":
+		var s string
+		if err := json.Unmarshal(v.Value, &s); err != nil {
+			return nil, fmt.Errorf("invalid integer value: %w", err)
+		}
+		return strconv.ParseInt(s, 10, 64)
+	case "float":
+		var f float64
+		if err := json.Unmarshal(v.Value, &f); err != nil {
+			return nil, fmt.Errorf("invalid float value: %w", err)
+		}
+		return f, nil
+	case "text":
+		var s string
+		if err := json.Unmarshal(v.Value, &s); err != nil {
+			return nil, fmt.Errorf("invalid text value: %w", err)
+		}
+		return s, nil
+	case "blob":
+		// Hrana may omit base64 padding
+		return base64.RawStdEncoding.DecodeString(strings.TrimRight(v.Base64, "="))
+	default:
+		return nil, fmt.Errorf("unsupported libSQL value type %q", v.Type)
+	}
+}
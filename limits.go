@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Default per-query budgets, overridable through the environment.
+const (
+	defaultQueryTimeout = 30 * time.Second
+	defaultQueryMaxRows = 100000
+)
+
+// QueryLimits bounds the resources a single tool call may consume. The heap
+// limit is the exception: SQLite's hard_heap_limit is global to the process,
+// and the driver offers no progress handler to count a statement's steps,
+// so there is no per-query memory or step budget. Runaway queries are bound
+// by the timeout.
+type QueryLimits struct {
+	Timeout   time.Duration // Wall-clock budget; SQLite is interrupted when it expires
+	MaxRows   int           // Maximum rows read from a result set (0 = unlimited)
+	HeapLimit int64         // SQLite hard heap limit in bytes (0 = unlimited), for the whole process
+}
+
+// loadQueryLimits reads QUERY_TIMEOUT, QUERY_MAX_ROWS and SQLITE_HEAP_LIMIT.
+// QUERY_MEMORY_LIMIT, its former name, is refused, since it read as a
+// per-query budget.
+func loadQueryLimits() (QueryLimits, error) {
+	limits := QueryLimits{Timeout: defaultQueryTimeout, MaxRows: defaultQueryMaxRows}
+
+	if v := os.Getenv("QUERY_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return limits, fmt.Errorf("invalid QUERY_TIMEOUT %q", v)
+		}
+		limits.Timeout = d
+	}
+	if v := os.Getenv("QUERY_MAX_ROWS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return limits, fmt.Errorf("invalid QUERY_MAX_ROWS %q", v)
+		}
+		limits.MaxRows = n
+	}
+	if os.Getenv("QUERY_MEMORY_LIMIT") != "" {
+		return limits, fmt.Errorf("QUERY_MEMORY_LIMIT is now SQLITE_HEAP_LIMIT, a limit for the whole process rather than each query")
+	}
+	if v := os.Getenv("SQLITE_HEAP_LIMIT"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return limits, fmt.Errorf("invalid SQLITE_HEAP_LIMIT %q", v)
+		}
+		limits.HeapLimit = n
+	}
+	return limits, nil
+}
+
+// connInit returns the statements that apply the heap limit. Running the
+// pragma on each connection sets the same process-wide limit again.
+func (l QueryLimits) connInit() []string {
+	if l.HeapLimit <= 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("PRAGMA hard_heap_limit = %d", l.HeapLimit)}
+}
+
+// timeoutMiddleware bounds every tool call by the configured timeout, capped
+// by the caller's profile. When the deadline passes the driver interrupts the
+// running SQLite statement.
+func (l QueryLimits) timeoutMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		l := l
+		if profile := profileFromContext(ctx); profile != nil {
+			l = profile.apply(l)
+		}
+		if l.Timeout <= 0 {
+			return next(ctx, request)
+		}
+		ctx, cancel := context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+		return next(ctx, request)
+	}
+}
+
+// errRowBudgetExceeded is returned when a result set has more rows than QueryLimits.MaxRows.
+var errRowBudgetExceeded = errors.New("row budget exceeded")
+
+// budgetRows is the number of rows a query may be cut to on the database
+// side: one extra row still trips the row budget.
+func (l QueryLimits) budgetRows() int {
+	return l.MaxRows + 1
+}
+
+// budgetError converts an error caused by an exhausted budget into a structured
+// tool error, or returns nil if err is unrelated to resource limits.
+func (l QueryLimits) budgetError(ctx context.Context, err error) *mcp.CallToolResult {
+	switch {
+	case errors.Is(err, errRowBudgetExceeded):
+		return budgetExceededResult("rows", strconv.Itoa(l.MaxRows), "Add a LIMIT clause or aggregate the data.")
+	case errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded):
+		return budgetExceededResult("timeout", l.Timeout.String(), "Narrow the query with WHERE conditions, use indexed columns, or add a LIMIT.")
+	case l.HeapLimit > 0 && strings.Contains(err.Error(), "out of memory"):
+		// The heap is shared, so the query may have failed for others' use of it
+		return budgetExceededResult("heap", strconv.FormatInt(l.HeapLimit, 10), "Avoid large sorts, DISTINCT or GROUP BY over big tables, or add a LIMIT; retry if other queries were running.")
+	}
+	return nil
+}
+
+// budgetExceededResult builds the error returned when a query exceeds one of its budgets.
+func budgetExceededResult(limit, value, hint string) *mcp.CallToolResult {
+	payload, _ := json.MarshalIndent(map[string]string{
+		"error":   "resource_budget_exceeded",
+		"limit":   limit,
+		"value":   value,
+		"message": fmt.Sprintf("Query exceeded resource budget: %s limit (%s) reached.", limit, value),
+		"hint":    hint,
+	}, "", "  ")
+	return mcp.NewToolResultError(string(payload))
+}
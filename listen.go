@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultSocketMode lets the owner and group of the server connect to a
+// Unix socket listener; the group is the access control for local agents.
+const defaultSocketMode = 0o660
+
+// listenFdsStart is the first file descriptor passed by systemd socket activation.
+const listenFdsStart = 3
+
+// listenerFromEnv opens the listener the HTTP server serves on. A socket
+// passed by systemd comes first; otherwise LISTEN takes
+// unix:///path/to.sock for a Unix domain socket or tcp://host:port, and
+// without it the server listens on TCP port PORT.
+func listenerFromEnv(port string) (net.Listener, error) {
+	if listener, err := activatedListener(); listener != nil || err != nil {
+		return listener, err
+	}
+	addr := os.Getenv("LISTEN")
+	switch {
+	case addr == "":
+		return net.Listen("tcp", ":"+port)
+	case strings.HasPrefix(addr, "unix://"):
+		return listenUnix(strings.TrimPrefix(addr, "unix://"))
+	case strings.HasPrefix(addr, "tcp://"):
+		return net.Listen("tcp", strings.TrimPrefix(addr, "tcp://"))
+	default:
+		return nil, fmt.Errorf("invalid LISTEN %q: want unix:///path/to.sock or tcp://host:port", addr)
+	}
+}
+
+// listenUnix listens on a Unix domain socket at path with the permissions of
+// SOCKET_MODE, replacing a socket left behind by a previous run.
+func listenUnix(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, fmt.Errorf("LISTEN needs a socket path, as in unix:///var/run/db-mcp.sock")
+	}
+	mode := os.FileMode(defaultSocketMode)
+	if v := os.Getenv("SOCKET_MODE"); v != "" {
+		m, err := strconv.ParseUint(v, 8, 32)
+		if err != nil || m > 0o777 {
+			return nil, fmt.Errorf("invalid SOCKET_MODE %q: want octal permissions such as 0660", v)
+		}
+		mode = os.FileMode(m)
+	}
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another server", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// activatedListener returns the socket systemd passed to this process under
+// socket activation (LISTEN_PID and LISTEN_FDS), or nil without one. systemd
+// keeps the socket open across restarts, so connections queue instead of
+// being refused while the server restarts.
+func activatedListener() (net.Listener, error) {
+	pid, fds := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+	if fds == "" || pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	// Child processes must not take the sockets for their own
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+	if n > 1 {
+		return nil, fmt.Errorf("systemd passed %d sockets; configure the socket unit with a single ListenStream", n)
+	}
+	file := os.NewFile(listenFdsStart, "systemd-socket")
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("socket passed by systemd is not a stream socket: %w", err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultLitestreamInterval is how often the replica is re-restored when LITESTREAM_SYNC_INTERVAL is not set.
+const defaultLitestreamInterval = time.Minute
+
+// LitestreamReplica keeps a local copy of a database restored from a Litestream
+// replica (e.g. s3://bucket/path). Each sync restores into a scratch file with
+// the litestream binary and atomically renames it over the served file.
+type LitestreamReplica struct {
+	Binary     string
+	ReplicaURL string
+	DBFile     string
+	Interval   time.Duration
+}
+
+// Restore downloads the latest replica state and replaces DBFile with it.
+func (l *LitestreamReplica) Restore(ctx context.Context) error {
+	tmp := l.DBFile + ".restore"
+	removeDatabaseFiles(tmp)
+
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, l.Binary, "restore", "-o", tmp, l.ReplicaURL)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		removeDatabaseFiles(tmp)
+		return fmt.Errorf("litestream restore failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	if err := os.Rename(tmp, l.DBFile); err != nil {
+		removeDatabaseFiles(tmp)
+		return fmt.Errorf("failed to replace %s: %w", l.DBFile, err)
+	}
+	// The restored file is fully checkpointed. Unlink the previous copy's WAL so new
+	// connections don't replay it; connections still on the old file keep their open handles.
+	os.Remove(l.DBFile + "-wal")
+	os.Remove(l.DBFile + "-shm")
+
+	log.Printf("Restored %s from Litestream replica %s in %s", l.DBFile, l.ReplicaURL, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// Run periodically restores the replica until ctx is cancelled, calling
+// onSwap after every successful restore so open connections can be recycled.
+func (l *LitestreamReplica) Run(ctx context.Context, onSwap func()) {
+	ticker := time.NewTicker(l.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Restore(ctx); err != nil {
+				log.Printf("Error syncing Litestream replica: %v", err)
+				continue
+			}
+			onSwap()
+		}
+	}
+}
+
+// removeDatabaseFiles deletes a SQLite file together with its WAL and shared-memory files.
+func removeDatabaseFiles(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(path + suffix)
+	}
+}
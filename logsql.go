@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// How string literals of SQL appear in logs and the audit log.
+const (
+	literalsKeep  = "keep"  // As written
+	literalsStrip = "strip" // Replaced by '?'
+	literalsHash  = "hash"  // Replaced by a hash, so equal values can still be matched
+)
+
+// logLiterals is the LOG_SQL_LITERALS mode.
+var logLiterals = literalsKeep
+
+// loadLogLiteralsFromEnv reads LOG_SQL_LITERALS: keep (default), strip or hash.
+func loadLogLiteralsFromEnv() error {
+	switch v := os.Getenv("LOG_SQL_LITERALS"); v {
+	case "":
+	case literalsKeep, literalsStrip, literalsHash:
+		logLiterals = v
+	default:
+		return fmt.Errorf("invalid LOG_SQL_LITERALS %q (want %s, %s or %s)", v, literalsKeep, literalsStrip, literalsHash)
+	}
+	return nil
+}
+
+// logSQL returns query as it may be logged: with its string literals
+// stripped or hashed per LOG_SQL_LITERALS, and everything else, including
+// quoted identifiers and comments, left in place so the shape of the query
+// stays readable.
+func logSQL(query string) string {
+	if logLiterals == literalsKeep {
+		return query
+	}
+	return scrubSQL(query, logLiterals)
+}
+
+// scrubSQL strips or hashes the string literals of query, per mode.
+func scrubSQL(query, mode string) string {
+	var b strings.Builder
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 4
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 4
+		case c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			j := i + 1
+			for j < len(query) && query[j] != closing {
+				j++
+			}
+			j = min(j+1, len(query))
+			b.WriteString(query[i:j])
+			i = j
+		case c == '\'':
+			// '' inside a literal is an escaped quote
+			j := i + 1
+			for j < len(query) {
+				if query[j] == '\'' {
+					if j+1 < len(query) && query[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			literal := query[i+1 : min(j, len(query))]
+			if mode == literalsHash {
+				sum := sha256.Sum256([]byte(strings.ReplaceAll(literal, "''", "'")))
+				b.WriteString("'#" + hex.EncodeToString(sum[:6]) + "'")
+			} else {
+				b.WriteString("'?'")
+			}
+			i = min(j+1, len(query))
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
@@ -6,51 +6,121 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
-	_ "modernc.org/sqlite" // SQLite driver
 )
 
 // dbKey is a context key for the database connection.
 type dbKey struct{}
 
+// maxResultSize limits inline tool output to ~10KB; larger results are spilled to the result store.
+const maxResultSize = 10000
+
 // DatabaseService holds the database connection.
 type DatabaseService struct {
-	db *sql.DB
+	db         *sql.DB
+	mu         sync.Mutex
+	dbFile     string // Guarded by mu, since use_database replaces it
+	connector  *initConnector
+	driverName string
+	dialect    Dialect   // Schema introspection and quoting for the backend
+	health     *dbHealth // Reconnects after the database is lost
+
+	migrationTables []string // Tables inspected by migration_status
+	migrationsDir   string   // Optional directory of migration files used to detect pending migrations
+
+	applicationNames map[int64]string // Friendly database names keyed by PRAGMA application_id
+
+	mountFile string // Scratch database holding MOUNT_FILES tables, attached as "mounts"
+
+	writeDB *sql.DB // Read-write pool of the mutation tools, nil unless WRITE_MODE is set
+
+	temp *TempTables // Connections pinned to sessions with temporary tables, nil unless TEMP_TABLES is set
+
+	limits          QueryLimits      // Per-query resource budgets
+	deniedFunctions functionDenylist // SQL functions queries may not call
+	results         *ResultStore     // Spilled results too large to return inline
+	exports         *ExportStore     // Files written by export_query, downloadable over HTTP
+
+	schemaFile   string             // Expected schema (SQL or JSON) checked by validate_schema
+	summaryCache schemaSummaryCache // Last schema_summary output
 }
 
-// NewDatabaseService creates a new DatabaseService and connects to the SQLite DB.
-func NewDatabaseService(dbFile string) (*DatabaseService, error) {
-	if dbFile == "" {
-		return nil, fmt.Errorf("DB_FILE environment variable not set")
+// NewDatabaseService creates a new DatabaseService and connects to the database
+// using the given driver ("sqlite" for a local file, "libsql" for a remote libSQL server).
+// The connInit statements are executed on every new pooled connection. An
+// unreachable database is retried with backoff for up to opts.Timeout, then
+// connected in the background if opts.Lazy is set.
+func NewDatabaseService(driverName, dsn string, opts connectOptions, connInit ...string) (*DatabaseService, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("DB_FILE or LIBSQL_URL environment variable not set")
+	}
+	name := redactDSN(dsn)
+	if err := checkDriver(driverName); err != nil {
+		return nil, err
 	}
 
-	db, err := sql.Open("sqlite", dbFile)
+	probe, err := sql.Open(driverName, dsn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database %s: %w", dbFile, err)
+		return nil, fmt.Errorf("failed to open database %s: %w", name, err)
 	}
+	// Only the driver is needed; connections are opened through initConnector
+	connector := &initConnector{driver: probe.Driver(), dsn: dsn, init: connInit, keys: opts.Keys}
+	db := sql.OpenDB(connector)
+	probe.Close()
 
 	// Check the connection
-	err = db.Ping()
-	if err != nil {
+	health := newDBHealth(db, connector)
+	err = connectWithRetry(db, name, opts.Timeout)
+	switch {
+	case err == nil:
+		log.Printf("Successfully connected to database: %s", name)
+	case opts.Lazy:
+		log.Printf("Database %s not available yet (%v); starting anyway and connecting in the background", name, err)
+		health.markDown(err)
+	default:
 		db.Close()
-		return nil, fmt.Errorf("failed to connect to database %s: %w", dbFile, err)
+		return nil, fmt.Errorf("failed to connect to database %s: %w", name, err)
 	}
 
-	log.Printf("Successfully connected to database: %s", dbFile)
-	return &DatabaseService{db: db}, nil
+	return &DatabaseService{
+		db: db, dbFile: name, connector: connector, health: health,
+		driverName: driverName, dialect: drivers[driverName].dialect(db, dsn),
+	}, nil
+}
+
+// databaseFile returns the name of the database being served.
+func (ds *DatabaseService) databaseFile() string {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.dbFile
 }
 
 // Close closes the database connection.
 func (ds *DatabaseService) Close() error {
+	var err error
 	if ds.db != nil {
 		log.Println("Closing database connection...")
-		return ds.db.Close()
+		err = ds.db.Close()
 	}
-	return nil
+	if ds.writeDB != nil {
+		ds.writeDB.Close()
+	}
+	if ds.mountFile != "" {
+		os.Remove(ds.mountFile)
+	}
+	return err
 }
 
 // readQueryHandler is the handler function for the 'read_query' tool.
@@ -62,57 +132,225 @@ func (ds *DatabaseService) readQueryHandler(ctx context.Context, request mcp.Cal
 	}
 
 	// --- Read-Only Validation ---
-	trimmedQuery := strings.TrimSpace(strings.ToUpper(query))
-	if !strings.HasPrefix(trimmedQuery, "SELECT") {
-		return mcp.NewToolResultError("Only SELECT queries are allowed for read-only access."), nil
+	if err := ds.validateReadOnly(query); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	format, err := parseFormat(args["format"])
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// --- Cost Estimate ---
+	dryRun, _ := args["dry_run"].(bool)
+	if estimator, ok := ds.dialect.(costEstimator); ok {
+		scanned, err := estimator.EstimateBytes(ctx, query)
+		if err != nil {
+			log.Printf("Error estimating query cost: %v, Query: %s", err, logSQL(query))
+			if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+				return result, nil
+			}
+			return mcp.NewToolResultErrorFromErr("Error estimating query cost", err), nil
+		}
+		if dryRun {
+			resultJSON, _ := json.MarshalIndent(map[string]int64{"estimated_bytes_scanned": scanned}, "", "  ")
+			return mcp.NewToolResultText(string(resultJSON)), nil
+		}
+		if limit := estimator.MaxBytesScanned(); limit > 0 && scanned > limit {
+			return budgetExceededResult("bytes_scanned", strconv.FormatInt(limit, 10),
+				fmt.Sprintf("The query would scan %d bytes. Select fewer columns or filter on partitioning or clustering columns.", scanned)), nil
+		}
+	} else if dryRun {
+		return mcp.NewToolResultError("dry_run is not supported by this database."), nil
 	}
-	// More robust validation could be added here if needed (e.g., disallowing PRAGMA, ATTACH etc.)
 
 	// --- Execute Query ---
-	rows, err := ds.db.QueryContext(ctx, query)
+	if limiter, ok := ds.dialect.(rowLimiter); ok && ds.limitsFor(ctx).MaxRows > 0 {
+		query = limiter.LimitQuery(query, ds.limitsFor(ctx).budgetRows())
+	}
+	db, release := ds.sessionDB(ctx)
+	defer release()
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
-		log.Printf("Error executing query: %v, Query: %s", err, query)
+		log.Printf("Error executing query: %v, Query: %s", err, logSQL(query))
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
 		return mcp.NewToolResultErrorFromErr("Error executing query", err), nil
 	}
 	defer rows.Close()
 
 	// --- Process Results ---
-	return processRows(rows) // Use helper function
+	columnTypes, _ := args["column_types"].(bool)
+	return ds.processRows(ctx, rows, resultOptions{ColumnTypes: columnTypes, Format: format}) // Use helper function
 }
 
-// listTablesHandler lists all user tables in the database.
-func (ds *DatabaseService) listTablesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	query := "SELECT name FROM sqlite_schema WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name;"
-	rows, err := ds.db.QueryContext(ctx, query)
-	if err != nil {
-		log.Printf("Error listing tables: %v", err)
-		return mcp.NewToolResultErrorFromErr("Error listing tables", err), nil
+// sourceRelation returns the FROM clause operand for tools that accept either a
+// 'table' argument, resolved against the live schema, or a base SELECT in a
+// 'query' argument.
+func (ds *DatabaseService) sourceRelation(ctx context.Context, args map[string]interface{}) (string, error) {
+	table, _ := args["table"].(string)
+	query, _ := args["query"].(string)
+	switch {
+	case table != "" && query != "":
+		return "", fmt.Errorf("pass either 'table' or 'query', not both")
+	case table != "":
+		resolved, err := ds.resolveTable(ctx, table)
+		if err != nil {
+			return "", err
+		}
+		return ds.quoteTable(resolved), nil
+	case query != "":
+		if err := ds.validateReadOnly(query); err != nil {
+			return "", err
+		}
+		// The newline keeps a trailing line comment from swallowing the parenthesis
+		return "(" + strings.TrimRight(strings.TrimSpace(query), "; \t\n") + "\n) AS src", nil
+	default:
+		return "", fmt.Errorf("missing 'table' or 'query' argument")
 	}
-	defer rows.Close()
+}
 
-	var tables []string
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			log.Printf("Error scanning table name: %v", err)
-			return mcp.NewToolResultErrorFromErr("Error reading table name", err), nil
+// filterClause returns a WHERE clause for the optional 'where' argument. The
+// condition runs against the read-only connection, and the tables and
+// columns it reads are checked against the caller's role like a query's,
+// masked columns aside (see access.go and rowfilters.go). Here it is refused unless it is a single expression that
+// stays inside its parentheses (see checkCondition), and if it calls a
+// denied function.
+func (ds *DatabaseService) filterClause(args map[string]interface{}) (string, error) {
+	where, _ := args["where"].(string)
+	if where = strings.TrimSpace(where); where == "" {
+		return "", nil
+	}
+	if err := checkCondition(where); err != nil {
+		return "", err
+	}
+	if err := ds.deniedFunctions.check(where); err != nil {
+		return "", err
+	}
+	return " WHERE (" + where + "\n)", nil
+}
+
+// Pagination limits of list_tables.
+const (
+	defaultListTablesLimit = 500
+	maxListTablesLimit     = 5000
+)
+
+// tableNameMatcher turns a list_tables pattern into a name predicate: a glob
+// when it contains *, ? or [, a LIKE pattern when it contains %, and a
+// case-insensitive substring match otherwise.
+func tableNameMatcher(pattern string) func(string) bool {
+	switch {
+	case pattern == "":
+		return func(string) bool { return true }
+	case strings.ContainsAny(pattern, "*?["):
+		return func(name string) bool {
+			ok, _ := path.Match(pattern, name)
+			return ok
 		}
-		tables = append(tables, name)
+	case strings.Contains(pattern, "%"):
+		// Like SQL LIKE: % is any run of characters, _ any one character, ASCII case ignored
+		var re strings.Builder
+		re.WriteString("(?is)^")
+		for _, r := range pattern {
+			switch r {
+			case '%':
+				re.WriteString(".*")
+			case '_':
+				re.WriteString(".")
+			default:
+				re.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		re.WriteString("$")
+		like := regexp.MustCompile(re.String())
+		return like.MatchString
+	default:
+		lower := strings.ToLower(pattern)
+		return func(name string) bool { return strings.Contains(strings.ToLower(name), lower) }
 	}
+}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating table list: %v", err)
-		return mcp.NewToolResultErrorFromErr("Error iterating through table list", err), nil
+// listTablesHandler lists the user tables (and optionally views) in the
+// database, filtered by an optional name pattern and paginated.
+func (ds *DatabaseService) listTablesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	includeViews, _ := args["include_views"].(bool)
+	limit := defaultListTablesLimit
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = min(int(v), maxListTablesLimit)
+	}
+	offset := 0
+	if v, ok := args["offset"].(float64); ok && v > 0 {
+		offset = int(v)
+	}
+	schemas := []string{""} // The default schema
+	if ds.mountFile != "" {
+		schemas = append(schemas, mountSchema)
+	}
+	if schema, err := ds.schemaArg(ctx, args); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	} else if schema != "" {
+		schemas = []string{schema}
+	}
+
+	pattern, _ := args["pattern"].(string)
+	match := tableNameMatcher(pattern)
+
+	// Tables outside main (such as mounted files) are listed schema-qualified after the database tables,
+	// leaving out those the caller's role may not read
+	profile := profileFromContext(ctx)
+	var names []string
+	for _, schema := range schemas {
+		schemaTables, err := ds.dialect.ListTables(ctx, schema, includeViews)
+		if err != nil {
+			log.Printf("Error listing tables: %v", err)
+			return mcp.NewToolResultErrorFromErr("Error listing tables", err), nil
+		}
+		for _, name := range schemaTables {
+			if schema != "" {
+				name = schema + "." + name
+			}
+			if match(name) && (profile == nil || profile.readsTable(ds.accessName(name))) {
+				names = append(names, name)
+			}
+		}
+	}
+	total := len(names)
+	tables := names[min(offset, total):min(offset+limit, total)]
+	if tables == nil {
+		tables = []string{}
 	}
 
-	// Format result as JSON array string
-	resultJSON, err := json.MarshalIndent(tables, "", "  ")
+	// Format result as JSON array string, of names or of names with row counts
+	var listing interface{} = tables
+	if includeRowCounts, _ := args["include_row_counts"].(bool); includeRowCounts {
+		counts, err := ds.tableRowCounts(ctx, tables)
+		if err != nil {
+			log.Printf("Error counting table rows: %v", err)
+			if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+				return result, nil
+			}
+			return mcp.NewToolResultErrorFromErr("Error counting table rows", err), nil
+		}
+		listing = counts
+	}
+	resultJSON, err := json.MarshalIndent(listing, "", "  ")
 	if err != nil {
 		log.Printf("Error marshalling table list to JSON: %v", err)
 		return mcp.NewToolResultErrorFromErr("Error formatting table list", err), nil
 	}
 
-	return mcp.NewToolResultText(string(resultJSON)), nil
+	text := string(resultJSON)
+	meta := map[string]any{"total": total}
+	if next := offset + len(tables); next < total {
+		text += fmt.Sprintf("\n... %d of %d tables shown; call again with offset=%d for more", len(tables), total, next)
+		meta["next_offset"] = next
+	}
+	result := mcp.NewToolResultText(text)
+	result.Meta = mcp.NewMetaFromMap(meta)
+	return result, nil
 }
 
 // describeTableHandler provides schema information for a specific table.
@@ -122,34 +360,102 @@ func (ds *DatabaseService) describeTableHandler(ctx context.Context, request mcp
 	if !ok || tableName == "" {
 		return mcp.NewToolResultError("Missing or invalid 'table_name' argument."), nil
 	}
-
-	// Basic validation to prevent SQL injection in PRAGMA
-	// A stricter validation (e.g., checking against list_tables result) is recommended for production
-	if strings.ContainsAny(tableName, "';--") {
-		return mcp.NewToolResultError("Invalid characters in table name."), nil
+	if schema, err := ds.schemaArg(ctx, args); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	} else if schema != "" {
+		tableName = schema + "." + tableName
 	}
 
-	// Use PRAGMA table_info with properly quoted table name to handle spaces and special characters
-	// Quote the table name with double quotes to handle spaces and other special characters
-	query := fmt.Sprintf("PRAGMA table_info(\"%s\");", strings.ReplaceAll(tableName, "\"", "\"\""))
+	// Resolve the name against the live schema, suggesting near matches when it is unknown
+	resolved, err := ds.resolveTable(ctx, tableName)
+	if err != nil {
+		return tableErrorResult(tableName, err), nil
+	}
+	tableName = resolved
 
-	rows, err := ds.db.QueryContext(ctx, query)
+	description, err := ds.dialect.DescribeTable(ctx, tableName)
 	if err != nil {
 		log.Printf("Error describing table %s: %v", tableName, err)
-		// Check if the error is because the table doesn't exist
-		// Note: The specific error message might vary depending on the driver/SQLite version
-		if strings.Contains(err.Error(), "no such table") || strings.Contains(err.Error(), "unable to use function") {
-			return mcp.NewToolResultError(fmt.Sprintf("Table '%s' not found or PRAGMA query failed.", tableName)), nil
-		}
 		return mcp.NewToolResultErrorFromErr(fmt.Sprintf("Error describing table '%s'", tableName), err), nil
 	}
-	defer rows.Close()
+	if len(description.Columns) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("Table '%s' not found.", tableName)), nil
+	}
+
+	// Leave out the columns hidden from the caller's role and mask the samples of masked ones
+	profile := profileFromContext(ctx)
+	tables := []string{ds.accessName(tableName)}
+	if profile != nil && profile.Classification != nil {
+		visible := description.Columns[:0]
+		for _, column := range description.Columns {
+			name, _ := column["name"].(string)
+			if profile.Classification.columnAction(tables[0], strings.ToLower(name)) != actionHide {
+				visible = append(visible, column)
+			}
+		}
+		description.Columns = visible
+	}
+
+	// Add a few example values to each column description
+	k := 0
+	if v, ok := args["sample_values"].(float64); ok && v > 0 && scopeFromContext(ctx) != scopeSchema {
+		k = min(int(v), maxSampleValues)
+	}
+	for _, column := range description.Columns {
+		if k == 0 {
+			break
+		}
+		name, _ := column["name"].(string)
+		colType, _ := column["type"].(string)
+		samples, err := ds.sampleColumnValues(ctx, tableName, name, colType, k)
+		if err != nil {
+			log.Printf("Error sampling %s.%s: %v", tableName, name, err)
+			if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+				return result, nil
+			}
+			return mcp.NewToolResultErrorFromErr(fmt.Sprintf("Error sampling column '%s'", name), err), nil
+		}
+		if profile != nil {
+			if method := profile.maskFor(tables, name); method != "" {
+				for i, v := range samples {
+					samples[i] = maskValue(method, v)
+				}
+			}
+		}
+		if pii := piiFromContext(ctx); pii != nil {
+			for i, v := range samples {
+				samples[i] = pii.redact(v)
+			}
+		}
+		column["sample_values"] = samples
+	}
+
+	resultJSON, err := json.MarshalIndent(description, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling table description to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting table description", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// resultOptions controls how processRows presents a result.
+type resultOptions struct {
+	ColumnTypes bool   // Prepend the declared type of each column as a separate content block
+	Format      string // formatJSON (default), formatJSONL or formatHTML
+}
 
-	return processRows(rows) // Use helper function to format PRAGMA results
+// ColumnInfo describes a result column in the column_types metadata.
+type ColumnInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
 }
 
 // processRows is a helper function to process sql.Rows into a CallToolResult.
-func processRows(rows *sql.Rows) (*mcp.CallToolResult, error) {
+// Exhausted query budgets are reported as structured errors, and results larger
+// than maxResultSize are spilled to the result store with an inline preview.
+func (ds *DatabaseService) processRows(ctx context.Context, rows *sql.Rows, opts resultOptions) (*mcp.CallToolResult, error) {
+	limits := ds.limitsFor(ctx)
+
 	columns, err := rows.Columns()
 	if err != nil {
 		log.Printf("Error getting columns: %v", err)
@@ -161,12 +467,20 @@ func processRows(rows *sql.Rows) (*mcp.CallToolResult, error) {
 		return mcp.NewToolResultErrorFromErr("Error getting result column types", err), nil
 	}
 
-	results := []map[string]interface{}{}
+	// Rows are encoded individually as they are read, so a spilled result
+	// can be paged later and a large result never exists as a slice of maps
+	masks := masksFor(ctx, columns)
+	encoding := rowEncoding{Format: opts.Format, Columns: columns}
+	buffer := newRowBuffer(max(ds.results.capacity(), maxResultSize))
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
 	for rows.Next() {
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
+		if limits.MaxRows > 0 && buffer.rows >= limits.MaxRows {
+			log.Printf("Query exceeded row budget of %d", limits.MaxRows)
+			return limits.budgetError(ctx, errRowBudgetExceeded), nil
 		}
 
 		if err := rows.Scan(valuePtrs...); err != nil {
@@ -174,90 +488,438 @@ func processRows(rows *sql.Rows) (*mcp.CallToolResult, error) {
 			return mcp.NewToolResultErrorFromErr("Error reading result row", err), nil
 		}
 
-		rowMap := make(map[string]interface{})
+		rowMap := make(map[string]interface{}, len(columns))
 		for i, colName := range columns {
-			// Handle potential NULL values and different data types gracefully
-			val := values[i]
-			if val == nil {
-				rowMap[colName] = nil
-				continue
-			}
-
-			// Try to retain original type if possible, fallback to string representation
-			switch v := val.(type) {
-			case []byte:
-				colType := columnTypes[i].DatabaseTypeName()
-				if strings.Contains(strings.ToUpper(colType), "BLOB") {
-					rowMap[colName] = fmt.Sprintf("BLOB data (length %d)", len(v)) // Avoid sending large blobs directly
-				} else {
-					rowMap[colName] = string(v) // Assume text if not explicitly BLOB
-				}
-			case int64, float64, bool, string:
-				rowMap[colName] = v
-			// Handle specific types returned by PRAGMA table_info if needed
-			// (e.g., 'pk' which might be int64 0 or 1)
-			default:
-				// Convert integer types specifically if needed by the client
-				if iType, ok := val.(int); ok {
-					rowMap[colName] = int64(iType)
-				} else if iType32, ok := val.(int32); ok {
-					rowMap[colName] = int64(iType32)
-				} else {
-					rowMap[colName] = fmt.Sprintf("%v", v) // Fallback representation
-				}
-			}
+			rowMap[colName] = masks.apply(i, normalizeValue(values[i], columnTypes[i].DatabaseTypeName()))
+		}
+		part, err := encoding.encodeRow(rowMap)
+		if err != nil {
+			log.Printf("Error marshalling results to JSON: %v", err)
+			return mcp.NewToolResultErrorFromErr("Error formatting results", err), nil
 		}
-		results = append(results, rowMap)
+		buffer.add(part)
+		addRowProgress(ctx)
 	}
+	addRowsReturned(ctx, buffer.rows)
 
 	if err := rows.Err(); err != nil {
 		log.Printf("Error iterating rows: %v", err)
+		if result := limits.budgetError(ctx, err); result != nil {
+			return result, nil
+		}
 		return mcp.NewToolResultErrorFromErr("Error iterating through results", err), nil
 	}
 
-	// --- Format Output ---
-	resultJSON, err := json.MarshalIndent(results, "", "  ")
-	if err != nil {
-		log.Printf("Error marshalling results to JSON: %v", err)
-		return mcp.NewToolResultErrorFromErr("Error formatting results", err), nil
+	result := ds.formatRows(ctx, buffer, encoding)
+	if opts.ColumnTypes {
+		info := make([]ColumnInfo, len(columns))
+		for i, colName := range columns {
+			info[i] = ColumnInfo{Name: colName, Type: columnTypes[i].DatabaseTypeName()}
+		}
+		metaJSON, err := json.MarshalIndent(map[string]interface{}{"columns": info}, "", "  ")
+		if err != nil {
+			log.Printf("Error marshalling column types to JSON: %v", err)
+			return mcp.NewToolResultErrorFromErr("Error formatting column types", err), nil
+		}
+		result.Content = append([]mcp.Content{mcp.NewTextContent(string(metaJSON))}, result.Content...)
+	}
+	return result, nil
+}
+
+// formatRows renders the encoded rows, spilling results larger than
+// maxResultSize to the result store. The client is told when it only gets
+// part of the rows.
+func (ds *DatabaseService) formatRows(ctx context.Context, buffer *rowBuffer, encoding rowEncoding) *mcp.CallToolResult {
+	parts, rendered := buffer.parts, buffer.bytes
+	if !buffer.overflow {
+		resultStr := encoding.render(parts)
+		rendered = len(resultStr)
+		if len(resultStr) <= maxResultSize {
+			return mcp.NewToolResultText(resultStr)
+		}
+
+		// Keep the full result server-side and return a preview with its resource handle
+		if stored := ds.results.Put(parts, encoding); stored != nil {
+			preview, size := 0, 0
+			for preview < len(parts) && size+len(parts[preview])+4 <= maxResultSize {
+				size += len(parts[preview]) + 4
+				preview++
+			}
+			notifyClient(ctx, mcp.LoggingLevelNotice, "result_spilled", fmt.Sprintf(
+				"The result of %d rows (%d bytes) is too large to return inline; %d rows are shown and the rest is stored as %s.", len(parts), len(resultStr), preview, resultURI(stored.ID)))
+			result := mcp.NewToolResultText(encoding.render(parts[:preview]) + fmt.Sprintf(
+				"\n... (showing %d of %d rows) Full result (%d bytes) stored as %s. Read it with resources/read, or page through it with fetch_result(result_id=%q, offset=%d).",
+				preview, len(parts), len(resultStr), resultURI(stored.ID), stored.ID, preview))
+			result.Meta = truncatedMeta(fmt.Sprintf("Page through the rest with fetch_result(result_id=%q, offset=%d).", stored.ID, preview))
+			result.Meta.AdditionalFields["result_id"] = stored.ID
+			return result
+		}
 	}
 
 	// Limit the size of the output to avoid overly large responses
-	const maxResultSize = 10000 // Limit to ~10KB, adjust as needed
-	resultStr := string(resultJSON)
-	if len(resultStr) > maxResultSize {
-		resultStr = resultStr[:maxResultSize] + "\n... (results truncated)"
+	notifyClient(ctx, mcp.LoggingLevelWarning, "result_truncated", fmt.Sprintf(
+		"The result of %d rows (%d bytes) was truncated to %d bytes and could not be stored; narrow the query or add a LIMIT.", buffer.rows, rendered, maxResultSize))
+	result := mcp.NewToolResultText(encoding.truncate(encoding.render(parts)))
+	result.Meta = truncatedMeta("Narrow the query or add a LIMIT to get every row.")
+	return result
+}
+
+// normalizeValue converts a scanned column value into a JSON-friendly value.
+func normalizeValue(val interface{}, colType string) interface{} {
+	// Handle potential NULL values and different data types gracefully
+	if val == nil {
+		return nil
+	}
+	// Decimals are returned as strings to keep their exact digits
+	if scale, ok := decimalScale(colType); ok {
+		return formatDecimal(val, scale)
 	}
 
-	return mcp.NewToolResultText(resultStr), nil
+	// Try to retain original type if possible, fallback to string representation
+	switch v := val.(type) {
+	case []byte:
+		if strings.Contains(strings.ToUpper(colType), "BLOB") {
+			return fmt.Sprintf("BLOB data (length %d)", len(v)) // Avoid sending large blobs directly
+		}
+		return string(v) // Assume text if not explicitly BLOB
+	case int64, float64, bool, string:
+		return v
+	// Handle specific types returned by PRAGMA table_info if needed
+	// (e.g., 'pk' which might be int64 0 or 1)
+	default:
+		// Convert integer types specifically if needed by the client
+		if iType, ok := val.(int); ok {
+			return int64(iType)
+		} else if iType32, ok := val.(int32); ok {
+			return int64(iType32)
+		}
+		return fmt.Sprintf("%v", v) // Fallback representation
+	}
 }
 
 func main() {
 	port := os.Getenv("PORT")
-	if port == "" {
+	if port == "" && os.Getenv("LISTEN") == "" {
 		port = "8080"
 		log.Printf("PORT environment variable not set, using default %s", port)
 	}
 	dbFile := os.Getenv("DB_FILE")
 
+	// Settings with credentials may come from files or secret managers
+	secret := func(name string) string {
+		value, err := secretEnv(name)
+		if err != nil {
+			log.Fatalf("Invalid %s: %v", name, err)
+		}
+		return value
+	}
+
+	if err := loadLogLiteralsFromEnv(); err != nil {
+		log.Fatalf("Invalid log settings: %v", err)
+	}
+
+	// An explicit driver takes precedence over a remote libSQL/Turso server,
+	// which takes precedence over a local file
+	driverName, dsn := "sqlite", readOnlyDSN(dbFile)
+	if name := os.Getenv("DB_DRIVER"); name != "" {
+		driverName, dsn = name, secret("DB_DSN")
+	} else if dbDSN := secret("DB_DSN"); dbDSN != "" {
+		driverName, dsn = driverForDSN(dbDSN), dbDSN
+		if driverName == "" {
+			log.Fatalf("DB_DSN without a URL scheme needs DB_DRIVER")
+		}
+	} else if libsqlURL := secret("LIBSQL_URL"); libsqlURL != "" {
+		driverName = "libsql"
+		var err error
+		dsn, err = libsqlDSN(libsqlURL, secret("LIBSQL_AUTH_TOKEN"))
+		if err != nil {
+			log.Fatalf("Invalid LIBSQL_URL: %v", err)
+		}
+	}
+	if driverName == "sqlite" {
+		// A SQLite DSN is opened read-only like DB_FILE: query_only alone is
+		// a connection setting, not a property of the file
+		dsn = sqliteReadOnlyDSN(dsn)
+	}
+
+	// With roots discovery and no database configured, the server starts on
+	// an empty in-memory database and use_database opens a discovered file
+	clientRequests := NewClientRequests()
+	rootsDiscovery, err := rootsDiscoveryFromEnv(clientRequests)
+	if err != nil {
+		log.Fatalf("Invalid roots discovery settings: %v", err)
+	}
+	if dsn == "" && driverName == "sqlite" && rootsDiscovery != nil {
+		dsn = ":memory:"
+		rootsDiscovery.selectable = true
+	}
+
+	// Encrypted files are opened with SQLCipher, trying previous keys during a rotation
+	keys, err := databaseKeys(secret("DB_KEY"), secret("DB_KEY_PREVIOUS"))
+	if err != nil {
+		log.Fatalf("Invalid database key settings: %v", err)
+	}
+	if len(keys) > 0 && (driverName != "sqlite" || dbFile == "") {
+		log.Fatalf("DB_KEY requires a local DB_FILE database")
+	}
+	if len(keys) > 0 && !sqlcipherEnabled {
+		log.Fatalf("DB_KEY requires a build with SQLCipher support (go build -tags sqlcipher)")
+	}
+
+	// Restore the local copy from a Litestream replica before opening it
+	var replica *LitestreamReplica
+	if replicaURL := secret("LITESTREAM_REPLICA"); replicaURL != "" {
+		if driverName != "sqlite" || dbFile == "" {
+			log.Fatalf("LITESTREAM_REPLICA requires DB_FILE as the local restore path")
+		}
+		replica = &LitestreamReplica{
+			Binary:     os.Getenv("LITESTREAM_BIN"),
+			ReplicaURL: replicaURL,
+			DBFile:     dbFile,
+			Interval:   defaultLitestreamInterval,
+		}
+		if replica.Binary == "" {
+			replica.Binary = "litestream"
+		}
+		if v := os.Getenv("LITESTREAM_SYNC_INTERVAL"); v != "" {
+			interval, err := time.ParseDuration(v)
+			if err != nil || interval <= 0 {
+				log.Fatalf("Invalid LITESTREAM_SYNC_INTERVAL %q", v)
+			}
+			replica.Interval = interval
+		}
+		if err := replica.Restore(context.Background()); err != nil {
+			log.Fatalf("Failed to restore Litestream replica: %v", err)
+		}
+	}
+
+	// Load CSV/JSONL mounts into a scratch database attached to every connection
+	var connInit []string
+	mounts, err := parseFileMounts(os.Getenv("MOUNT_FILES"))
+	if err != nil {
+		log.Fatalf("Invalid MOUNT_FILES: %v", err)
+	}
+	var mountFile string
+	if len(mounts) > 0 {
+		if driverName != "sqlite" {
+			log.Fatalf("MOUNT_FILES requires a local DB_FILE database")
+		}
+		mountFile, err = buildMountDatabase(mounts)
+		if err != nil {
+			log.Fatalf("Failed to mount files: %v", err)
+		}
+		connInit = append(connInit, attachMountStatement(mountFile, len(keys) > 0))
+	}
+
+	limits, err := loadQueryLimits()
+	if err != nil {
+		log.Fatalf("Invalid query limits: %v", err)
+	}
+	requestLimits, err := loadRequestLimits()
+	if err != nil {
+		log.Fatalf("Invalid request limits: %v", err)
+	}
+	switch driverName {
+	case "sqlite":
+		connInit = append(connInit, limits.connInit()...)
+		connInit = append(connInit, readOnlyConnInit)
+	case "snowflake":
+		opts := snowflakeOptions{
+			Warehouse: os.Getenv("SNOWFLAKE_WAREHOUSE"),
+			Role:      os.Getenv("SNOWFLAKE_ROLE"),
+			Database:  os.Getenv("SNOWFLAKE_DATABASE"),
+		}
+		if limits.MaxRows > 0 {
+			opts.MaxRows = limits.budgetRows()
+		}
+		if dsn, err = snowflakeDSN(dsn, opts); err != nil {
+			log.Fatalf("Invalid DB_DSN: %v", err)
+		}
+	}
+
 	// Initialize Database Service
-	dbService, err := NewDatabaseService(dbFile)
+	connectOpts, err := connectOptionsFromEnv()
 	if err != nil {
+		log.Fatalf("Invalid connection settings: %v", err)
+	}
+	connectOpts.Keys = keys
+	if v := os.Getenv("DB_REKEY"); v == "true" || v == "1" {
+		if len(keys) < 2 || replica != nil {
+			log.Fatalf("DB_REKEY requires DB_KEY_PREVIOUS and a DB_FILE without LITESTREAM_REPLICA")
+		}
+		if err := rekeyDatabase(context.Background(), dbFile, keys); err != nil {
+			log.Fatalf("Failed to re-encrypt %s: %v", dbFile, err)
+		}
+	}
+	dbService, err := NewDatabaseService(driverName, dsn, connectOpts, connInit...)
+	if err != nil {
+		if mountFile != "" {
+			os.Remove(mountFile)
+		}
 		log.Fatalf("Failed to initialize database service: %v", err)
 	}
+	dbService.mountFile = mountFile
+	dbService.limits = limits
+	dbService.deniedFunctions = loadDeniedFunctions()
+	if v := os.Getenv("WRITE_MODE"); v != "" {
+		writeMode, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("Invalid WRITE_MODE %q", v)
+		}
+		if writeMode {
+			if driverName != "sqlite" || dbFile == "" || replica != nil {
+				log.Fatalf("WRITE_MODE requires a local DB_FILE database without LITESTREAM_REPLICA")
+			}
+			if dbService.writeDB, err = openWriteDB(dbFile, limits.connInit(), connectOpts.Keys); err != nil {
+				log.Fatalf("Failed to open %s for writing: %v", dbFile, err)
+			}
+		}
+	}
+	if dbService.temp, err = tempTablesFromEnv(dbService.db); err != nil {
+		log.Fatalf("Invalid temporary table settings: %v", err)
+	}
+	if dbService.temp != nil && (driverName != "sqlite" || rootsDiscovery != nil && rootsDiscovery.selectable) {
+		log.Fatalf("TEMP_TABLES requires a local DB_FILE database")
+	}
+	dbService.results, err = NewResultStoreFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid result store settings: %v", err)
+	}
+	dbService.exports, err = NewExportStoreFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid export settings: %v", err)
+	}
 	defer dbService.Close()
 
+	if replica != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// Connections opened on the previous copy are dropped once they are returned to the pool
+		go replica.Run(ctx, dbService.connector.invalidate)
+	}
+
+	exportCtx, cancelExports := context.WithCancel(context.Background())
+	defer cancelExports()
+	go dbService.exports.Run(exportCtx)
+	if dbService.temp != nil {
+		go dbService.temp.Run(exportCtx)
+	}
+
+	dbService.migrationTables = parseMigrationTables(os.Getenv("MIGRATION_TABLES"))
+	dbService.migrationsDir = os.Getenv("MIGRATIONS_DIR")
+	dbService.schemaFile = os.Getenv("SCHEMA_FILE")
+	dbService.applicationNames, err = parseApplicationNames(os.Getenv("APPLICATION_NAMES"))
+	if err != nil {
+		log.Fatalf("Invalid APPLICATION_NAMES: %v", err)
+	}
+
+	limiter, err := newQueryLimiterFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid concurrency settings: %v", err)
+	}
+
+	identity := NewIdentity(os.Getenv("IDENTITY_HEADER"), os.Getenv("ADMIN_USERS"))
+	profiles, err := NewProfiles(os.Getenv("PROFILE"), os.Getenv("PROFILE_USERS"), secret("ANONYMIZATION_SALT"))
+	if err != nil {
+		log.Fatalf("Invalid profile settings: %v", err)
+	}
+	piiPolicy, err := loadPIIPolicy()
+	if err != nil {
+		log.Fatalf("Invalid PII redaction settings: %v", err)
+	}
+	tokens, err := parseTokens(secret("AUTH_TOKENS"))
+	if err != nil {
+		log.Fatalf("Invalid token settings: %v", err)
+	}
+	for _, token := range tokens {
+		profiles.AdminTokens = profiles.AdminTokens || token.Scope == scopeAdmin
+	}
+	audit, err := auditLogFromEnv(secret("AUDIT_SINK_TOKEN"))
+	if err != nil {
+		log.Fatalf("Invalid audit settings: %v", err)
+	}
+	defer audit.close()
+	clientLog, err := clientLogFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid client log settings: %v", err)
+	}
+	reporter, err := errorReporterFromEnv(secret("SENTRY_DSN"), secret("ERROR_WEBHOOK_TOKEN"))
+	if err != nil {
+		log.Fatalf("Invalid error reporting settings: %v", err)
+	}
+	defer reporter.close()
+	stats := NewSessionStats()
+	usage := NewUsageAnalytics()
+	lastQueries := NewLastQueries(dbService)
+	confirmer, err := confirmerFromEnv(dbService, clientRequests)
+	if err != nil {
+		log.Fatalf("Invalid confirmation settings: %v", err)
+	}
+	if rootsDiscovery != nil {
+		rootsDiscovery.ds = dbService
+	}
+	tableResources, err := tableResourcesFromEnv(dbService, profiles)
+	if err != nil {
+		log.Fatalf("Invalid table resource settings: %v", err)
+	}
+	schemaInstructions, err := schemaInstructionsFromEnv(dbService, profiles)
+	if err != nil {
+		log.Fatalf("Invalid schema instructions settings: %v", err)
+	}
+	hooks := &server.Hooks{}
+	if tableResources != nil {
+		hooks.AddAfterListResources(tableResources.listHook)
+	}
+	if schemaInstructions != nil {
+		hooks.AddAfterInitialize(schemaInstructions.initializeHook)
+	}
+	registry := NewQueryRegistry()
+	health := dbService.health
+
 	// Create MCP Server
 	mcpServer := server.NewMCPServer(
 		"sqlite-readonly-mcp-server",
 		"1.0.0",
-		server.WithToolCapabilities(true), // Enable tools
-		server.WithLogging(),              // Enable basic logging via MCP
-		server.WithRecovery(),             // Add panic recovery middleware
+		server.WithToolCapabilities(true),            // Enable tools
+		server.WithResourceCapabilities(false, true), // Expose spilled results as resources
+		server.WithLogging(),                         // Enable basic logging via MCP
+		server.WithHooks(hooks),                      // Page tables into resources/list and describe the schema at initialization
+		server.WithToolHandlerMiddleware(reporter.recoveryMiddleware), // Recover and report panics and internal errors
+		server.WithToolFilter(profiles.toolFilter),                    // List only the tools of the caller's profile
+		server.WithToolHandlerMiddleware(lastQueries.middleware),      // Remember each session's last query and expand rerun_last
+		server.WithToolHandlerMiddleware(structuredOutputMiddleware),  // Derive structured results for tools with an output schema
+		server.WithToolHandlerMiddleware(audit.toolLogMiddleware),     // Log and audit each call with its request ID
+		server.WithToolHandlerMiddleware(stats.middleware),            // Track per-session usage counters
+		server.WithToolHandlerMiddleware(usage.middleware),            // Track tables read, failure patterns and result sizes
+		server.WithToolHandlerMiddleware(clientLog.middleware),        // Report slow and refused calls to the client
+		server.WithToolHandlerMiddleware(errorCodesMiddleware),        // Give every error a code and a hint
+		server.WithToolHandlerMiddleware(scopeMiddleware),             // Refuse tools outside the caller's token scope
+		server.WithToolHandlerMiddleware(profiles.middleware),         // Refuse tools outside the caller's profile
+		server.WithToolHandlerMiddleware(requestLimits.middleware),    // Refuse queries longer than MAX_QUERY_LENGTH
+		server.WithToolHandlerMiddleware(confirmer.middleware),        // Ask the user before writes and large scans
+		server.WithToolHandlerMiddleware(health.middleware),           // Fail fast and reconnect while the database is down
+		server.WithToolHandlerMiddleware(limiter.middleware),          // Queue calls beyond MAX_CONCURRENT_QUERIES
+		server.WithToolHandlerMiddleware(registry.middleware),         // Register executing calls for running_queries
+		server.WithToolHandlerMiddleware(limits.timeoutMiddleware),    // Apply QUERY_TIMEOUT once a slot is acquired
+		server.WithToolHandlerMiddleware(progressMiddleware),          // Report rows read and bytes exported to clients that ask
+		server.WithToolHandlerMiddleware(piiPolicy.middleware),        // Redact personal data from results and log redactions
+		server.WithToolHandlerMiddleware(dbService.accessMiddleware),  // Refuse tables outside the caller's role
 	)
 
+	clientLog.server = mcpServer
+	clientRequests.server = mcpServer
+
 	// --- Define Tools ---
 
+	// Tools no profile in use may call are not registered
+	var toolNames []string
+	addTool := func(tool mcp.Tool, handler server.ToolHandlerFunc) {
+		if profiles.registers(tool.Name) {
+			mcpServer.AddTool(withOutputSchema(tool), handler)
+			toolNames = append(toolNames, tool.Name)
+		}
+	}
+
 	// 1. read_query tool
 	readQueryTool := mcp.NewTool(
 		"read_query",
@@ -266,36 +928,940 @@ func main() {
 			mcp.Required(),
 			mcp.Description("The SELECT SQL query to execute"),
 		),
+		mcp.WithString("format",
+			mcp.Description("Output format: json (default, one array), jsonl (one JSON object per line) or html (a sortable table)"),
+			mcp.Enum("json", "jsonl", "html"),
+		),
+		mcp.WithBoolean("column_types",
+			mcp.Description("Also return the declared type of each result column"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Only estimate the bytes the query would scan, without running it (BigQuery)"),
+		),
 	)
-	mcpServer.AddTool(readQueryTool, dbService.readQueryHandler)
+	addTool(readQueryTool, dbService.readQueryHandler)
 
 	// 2. list_tables tool
 	listTablesTool := mcp.NewTool(
 		"list_tables",
-		mcp.WithDescription("List all user tables in the SQLite database"),
+		mcp.WithDescription("List the user tables in the SQLite database, optionally filtered by name and paginated"),
+		mcp.WithString("pattern",
+			mcp.Description("Only list names matching this pattern: a glob (orders_*), a LIKE pattern (%log%), or plain text matched as a case-insensitive substring"),
+		),
+		mcp.WithBoolean("include_views",
+			mcp.Description("Also list views"),
+		),
+		mcp.WithBoolean("include_row_counts",
+			mcp.Description("Return objects with the row count of each table: exact when counted within a short time budget, otherwise estimated from ANALYZE statistics (exact=false) or null"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of names to return (default 500, at most 5000)"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of names to skip, for fetching the next page"),
+		),
+		mcp.WithString("schema",
+			mcp.Description("Only list tables of this schema, e.g. catalog.schema on Trino or a dataset on BigQuery (see list_schemas)"),
+		),
 	)
-	mcpServer.AddTool(listTablesTool, dbService.listTablesHandler)
+	addTool(listTablesTool, dbService.listTablesHandler)
 
 	// 3. describe_table tool
 	describeTableTool := mcp.NewTool(
 		"describe_table",
-		mcp.WithDescription("Get the schema information (columns, types) for a specific table"),
+		mcp.WithDescription("Get the complete definition of a table: columns and types, primary key, foreign keys, indexes, unique and check constraints, and WITHOUT ROWID/STRICT flags"),
 		mcp.WithString("table_name",
 			mcp.Required(),
 			mcp.Description("Name of the table to describe"),
 		),
+		mcp.WithNumber("sample_values",
+			mcp.Description("Include up to this many distinct example values per column (at most 10)"),
+		),
+		mcp.WithString("schema",
+			mcp.Description("Schema of the table when table_name is not qualified (see list_schemas)"),
+		),
 	)
-	mcpServer.AddTool(describeTableTool, dbService.describeTableHandler)
+	addTool(describeTableTool, dbService.describeTableHandler)
 
-	listenAddr := fmt.Sprintf(":%s", port)
-	server := server.NewStreamableHTTPServer(mcpServer)
+	// 4. migration_status tool
+	migrationStatusTool := mcp.NewTool(
+		"migration_status",
+		mcp.WithDescription("Summarize schema migration state (current version, dirty flag, pending migrations) from golang-migrate, goose or Rails migration tables"),
+	)
+	addTool(migrationStatusTool, dbService.migrationStatusHandler)
+
+	// 5. database_info tool
+	databaseInfoTool := mcp.NewTool(
+		"database_info",
+		mcp.WithDescription("Get general information about the database: file, size, SQLite version, user_version and application_id"),
+	)
+	addTool(databaseInfoTool, dbService.databaseInfoHandler)
 
-	log.Printf("Starting MCP HTTP server on %s", listenAddr)
-	log.Printf("Database file: %s", dbFile)
-	log.Printf("Read-only access enabled.")
-	log.Printf("Available tools: read_query, list_tables, describe_table")
+	// 6. session_stats tool
+	sessionStatsTool := mcp.NewTool(
+		"session_stats",
+		mcp.WithDescription("Get usage counters (queries, rows and bytes returned, errors, time spent) for the current session and server-wide totals"),
+	)
+	addTool(sessionStatsTool, stats.sessionStatsHandler)
 
-	if err := server.Start(listenAddr); err != nil {
-		log.Fatalf("SSE Server error: %v", err)
+	// 7. running_queries tool
+	runningQueriesTool := mcp.NewTool(
+		"running_queries",
+		mcp.WithDescription("List currently executing queries and tool calls with their session, SQL and elapsed time (other sessions' calls for administrators only)"),
+	)
+	addTool(runningQueriesTool, registry.runningQueriesHandler(identity))
+
+	// 8. kill_query tool
+	killQueryTool := mcp.NewTool(
+		"kill_query",
+		mcp.WithDescription("Cancel an in-flight query by its running_queries ID (administrators only)"),
+		mcp.WithNumber("id",
+			mcp.Required(),
+			mcp.Description("ID of the query as reported by running_queries"),
+		),
+	)
+	addTool(killQueryTool, registry.killQueryHandler(identity))
+
+	// 9. fetch_result tool and db://results/{id} resources
+	fetchResultTool := mcp.NewTool(
+		"fetch_result",
+		mcp.WithDescription("Page through a large query result that was stored server-side (db://results/{id})"),
+		mcp.WithString("result_id",
+			mcp.Required(),
+			mcp.Description("ID or db://results/ URI of the stored result"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Index of the first row to return (default 0)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of rows to return (default 100)"),
+		),
+	)
+	addTool(fetchResultTool, dbService.results.fetchResultHandler)
+	mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(resultURIPrefix+"{id}", "Stored query result",
+			mcp.WithTemplateDescription("Full JSON result of a query that was too large to return inline"),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		dbService.results.resultResourceHandler,
+	)
+	if tableResources != nil {
+		mcpServer.AddResourceTemplate(
+			mcp.NewResourceTemplate(tableURIPrefix+"{name}", "Table",
+				mcp.WithTemplateDescription("Columns, types and keys of a table, as returned by describe_table"),
+				mcp.WithTemplateMIMEType("application/json"),
+			),
+			tableResources.tableResourceHandler,
+		)
+	}
+
+	// 10. export_query tool
+	exportQueryTool := mcp.NewTool(
+		"export_query",
+		mcp.WithDescription("Run a SELECT query and save the full result as a CSV, JSON, Parquet or Excel file that can be downloaded from /results/{id}"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The SELECT SQL query to export"),
+		),
+		mcp.WithArray("queries",
+			mcp.Description("Additional SELECT queries exported to further sheets of the same workbook (xlsx only)"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithString("format",
+			mcp.Description("File format: csv (default), json, parquet or xlsx"),
+			mcp.Enum("csv", "json", "parquet", "xlsx"),
+		),
+	)
+	addTool(exportQueryTool, dbService.exportQueryHandler)
+
+	// 11. pivot_query tool
+	pivotQueryTool := mcp.NewTool(
+		"pivot_query",
+		mcp.WithDescription("Build and run a crosstab: one row per value of the row column and one result column per distinct value of the column column, each holding the aggregate"),
+		mcp.WithString("table",
+			mcp.Description("Table to pivot (or use query)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Base SELECT query to pivot instead of a table"),
+		),
+		mcp.WithString("row",
+			mcp.Required(),
+			mcp.Description("Column whose values become the result rows"),
+		),
+		mcp.WithString("column",
+			mcp.Required(),
+			mcp.Description("Column whose distinct values become the result columns (at most 100)"),
+		),
+		mcp.WithString("aggregate",
+			mcp.Description("Aggregate applied to each cell: count (default), sum, avg, min or max"),
+			mcp.Enum("count", "sum", "avg", "min", "max"),
+		),
+		mcp.WithString("value",
+			mcp.Description("Column aggregated in each cell; required except for count"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: json (default), jsonl or html"),
+			mcp.Enum("json", "jsonl", "html"),
+		),
+	)
+	addTool(pivotQueryTool, dbService.pivotQueryHandler)
+
+	// 12. summarize tool
+	summarizeTool := mcp.NewTool(
+		"summarize",
+		mcp.WithDescription("Group a table or query by columns and compute aggregates, without writing SQL"),
+		mcp.WithString("table",
+			mcp.Description("Table to summarize (or use query)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Base SELECT query to summarize instead of a table"),
+		),
+		mcp.WithArray("group_by",
+			mcp.Description("Columns to group by; omit for a single summary row"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithArray("aggregates",
+			mcp.Description("Aggregates such as count(*), sum(amount), avg(price), min(date), max(date) or count_distinct(customer_id); default count(*)"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: json (default), jsonl or html"),
+			mcp.Enum("json", "jsonl", "html"),
+		),
+	)
+	addTool(summarizeTool, dbService.summarizeHandler)
+
+	// 13. histogram tool
+	histogramTool := mcp.NewTool(
+		"histogram",
+		mcp.WithDescription("Bucket a numeric or date column into bins and return the bin boundaries and row counts"),
+		mcp.WithString("table",
+			mcp.Description("Table containing the column (or use query)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Base SELECT query to read the column from instead of a table"),
+		),
+		mcp.WithString("column",
+			mcp.Required(),
+			mcp.Description("Numeric or date column to bucket"),
+		),
+		mcp.WithNumber("bins",
+			mcp.Description("Number of bins (default 10, at most 100)"),
+		),
+		mcp.WithString("method",
+			mcp.Description("equal_width (default) bins of the same size, or quantile bins holding the same number of rows"),
+			mcp.Enum("equal_width", "quantile"),
+		),
+	)
+	addTool(histogramTool, dbService.histogramHandler)
+
+	// 14. column_stats tool
+	columnStatsTool := mcp.NewTool(
+		"column_stats",
+		mcp.WithDescription("Compute descriptive statistics (count, nulls, mean, stddev, quartiles) and pairwise correlations for numeric columns; large sources are randomly sampled"),
+		mcp.WithString("table",
+			mcp.Description("Table containing the columns (or use query)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Base SELECT query to read the columns from instead of a table"),
+		),
+		mcp.WithArray("columns",
+			mcp.Required(),
+			mcp.Description("Numeric columns to describe"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithNumber("sample_size",
+			mcp.Description("Maximum rows kept; larger sources are randomly sampled in one pass (default 100000)"),
+		),
+	)
+	addTool(columnStatsTool, dbService.columnStatsHandler)
+
+	// 15. resample tool
+	resampleTool := mcp.NewTool(
+		"resample",
+		mcp.WithDescription("Aggregate a value over time buckets (minute, hour, day, week, month, year) of a timestamp column and return the ordered series. ISO-8601 text and Unix seconds or milliseconds are recognised"),
+		mcp.WithString("table",
+			mcp.Description("Table containing the series (or use query)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Base SELECT query to read the series from instead of a table"),
+		),
+		mcp.WithString("time_column",
+			mcp.Required(),
+			mcp.Description("Timestamp column"),
+		),
+		mcp.WithString("bucket",
+			mcp.Description("Bucket size: minute, hour, day (default), week (starting Monday), month or year"),
+			mcp.Enum("minute", "hour", "day", "week", "month", "year"),
+		),
+		mcp.WithString("aggregate",
+			mcp.Description("Aggregate per bucket: count (default), sum, avg, min or max"),
+			mcp.Enum("count", "sum", "avg", "min", "max"),
+		),
+		mcp.WithString("value",
+			mcp.Description("Column aggregated per bucket; required except for count"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: json (default), jsonl or html"),
+			mcp.Enum("json", "jsonl", "html"),
+		),
+	)
+	addTool(resampleTool, dbService.resampleHandler)
+
+	// 16. top_values tool
+	topValuesTool := mcp.NewTool(
+		"top_values",
+		mcp.WithDescription("Return the most frequent values of a column with their counts and percentages, plus the number of distinct values and the count of all other rows"),
+		mcp.WithString("table",
+			mcp.Description("Table containing the column (or use query)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Base SELECT query to read the column from instead of a table"),
+		),
+		mcp.WithString("column",
+			mcp.Required(),
+			mcp.Description("Column to count values of"),
+		),
+		mcp.WithString("where",
+			mcp.Description("Optional SQL condition restricting the rows counted, e.g. status = 'active'"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Number of values to return (default 10, at most 1000)"),
+		),
+	)
+	addTool(topValuesTool, dbService.topValuesHandler)
+
+	// 17. find_duplicates tool
+	findDuplicatesTool := mcp.NewTool(
+		"find_duplicates",
+		mcp.WithDescription("Find groups of rows sharing the same values in the key columns, largest first, with counts and example rows"),
+		mcp.WithString("table",
+			mcp.Description("Table to check (or use query)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Base SELECT query to check instead of a table"),
+		),
+		mcp.WithArray("key_columns",
+			mcp.Required(),
+			mcp.Description("Columns that should uniquely identify a row"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of duplicate groups returned (default 20, at most 200)"),
+		),
+		mcp.WithNumber("examples",
+			mcp.Description("Example rows returned per group (default 3, at most 10)"),
+		),
+	)
+	addTool(findDuplicatesTool, dbService.findDuplicatesHandler)
+
+	// 18. find_orphans tool
+	findOrphansTool := mcp.NewTool(
+		"find_orphans",
+		mcp.WithDescription("Report child rows whose referenced parent row is missing, for a given relationship or for every foreign key declared on the child table"),
+		mcp.WithString("child_table",
+			mcp.Required(),
+			mcp.Description("Table holding the references"),
+		),
+		mcp.WithString("parent_table",
+			mcp.Description("Referenced table; omit to check the child table's declared foreign keys"),
+		),
+		mcp.WithArray("child_columns",
+			mcp.Description("Referencing columns of the child table"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithArray("parent_columns",
+			mcp.Description("Referenced columns of the parent table (default its primary key)"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+	)
+	addTool(findOrphansTool, dbService.findOrphansHandler)
+
+	// 19. validate_schema tool
+	validateSchemaTool := mcp.NewTool(
+		"validate_schema",
+		mcp.WithDescription("Compare the live schema with an expected schema and report missing or extra tables, columns and indexes and column type drift"),
+		mcp.WithString("schema",
+			mcp.Description("Expected schema as SQL DDL or JSON ({\"table\": {\"columns\": {\"name\": \"TYPE\"}, \"indexes\": [\"idx\"]}}); defaults to SCHEMA_FILE"),
+		),
+	)
+	addTool(validateSchemaTool, dbService.validateSchemaHandler)
+
+	// 20. schema_summary tool
+	schemaSummaryTool := mcp.NewTool(
+		"schema_summary",
+		mcp.WithDescription("Get a short prose overview of the schema: what each table likely represents, its keys, time columns and how tables reference each other. Use it first in a new session"),
+		mcp.WithBoolean("refresh",
+			mcp.Description("Rebuild the summary even if the schema has not changed since it was cached"),
+		),
+	)
+	addTool(schemaSummaryTool, dbService.schemaSummaryHandler)
+
+	// 21. get_table_ddl tool
+	getTableDDLTool := mcp.NewTool(
+		"get_table_ddl",
+		mcp.WithDescription("Get the exact CREATE TABLE (or CREATE VIEW) statement of a table together with its CREATE INDEX and CREATE TRIGGER statements"),
+		mcp.WithString("table_name",
+			mcp.Required(),
+			mcp.Description("Name of the table or view"),
+		),
+	)
+	addTool(getTableDDLTool, dbService.getTableDDLHandler)
+
+	// 22. list_schemas tool
+	listSchemasTool := mcp.NewTool(
+		"list_schemas",
+		mcp.WithDescription("List the schemas of the connection (main and attached databases such as mounts) with their files and table counts"),
+	)
+	addTool(listSchemasTool, dbService.listSchemasHandler)
+
+	// 23. health tool
+	healthTool := mcp.NewTool(
+		"health",
+		mcp.WithDescription("Report whether the database is reachable, with the last connection error while it is not"),
+	)
+	addTool(healthTool, dbService.healthHandler)
+
+	if dbService.writeDB != nil {
+		// 24. insert_row tool
+		insertRowTool := mcp.NewTool(
+			"insert_row",
+			mcp.WithDescription("Insert one row into a table. Values are bound as parameters; the executed statement is returned"),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithString("table",
+				mcp.Required(),
+				mcp.Description("Table to insert into"),
+			),
+			mcp.WithObject("values",
+				mcp.Required(),
+				mcp.Description("Column names mapped to the values of the new row"),
+			),
+		)
+		addTool(insertRowTool, dbService.insertRowHandler)
+
+		// 25. update_row tool
+		updateRowTool := mcp.NewTool(
+			"update_row",
+			mcp.WithDescription("Update the rows of a table whose columns equal the 'where' values. Fails without changes if more than max_rows rows match"),
+			mcp.WithString("table",
+				mcp.Required(),
+				mcp.Description("Table to update"),
+			),
+			mcp.WithObject("values",
+				mcp.Required(),
+				mcp.Description("Column names mapped to their new values"),
+			),
+			mcp.WithObject("where",
+				mcp.Required(),
+				mcp.Description("Column names mapped to the values identifying the rows, ideally the primary key; null matches NULL"),
+			),
+			mcp.WithNumber("max_rows",
+				mcp.Description("Maximum number of rows the update may change (default 1)"),
+			),
+		)
+		addTool(updateRowTool, dbService.updateRowHandler)
+
+		// 26. delete_row tool
+		deleteRowTool := mcp.NewTool(
+			"delete_row",
+			mcp.WithDescription("Delete the rows of a table whose columns equal the 'where' values. Fails without changes if more than max_rows rows match"),
+			mcp.WithString("table",
+				mcp.Required(),
+				mcp.Description("Table to delete from"),
+			),
+			mcp.WithObject("where",
+				mcp.Required(),
+				mcp.Description("Column names mapped to the values identifying the rows, ideally the primary key; null matches NULL"),
+			),
+			mcp.WithNumber("max_rows",
+				mcp.Description("Maximum number of rows the delete may remove (default 1)"),
+			),
+		)
+		addTool(deleteRowTool, dbService.deleteRowHandler)
+
+		// 27. create_table tool
+		createTableTool := mcp.NewTool(
+			"create_table",
+			mcp.WithDescription("Create a table from structured column definitions. Returns the generated DDL without running it unless confirm is true"),
+			mcp.WithString("table",
+				mcp.Required(),
+				mcp.Description("Name of the new table (letters, digits and underscores)"),
+			),
+			mcp.WithArray("columns",
+				mcp.Required(),
+				mcp.Description("Column definitions: objects with name, and optionally type, primary_key, not_null, unique, default and references ({table, column})"),
+				mcp.Items(map[string]interface{}{"type": "object"}),
+			),
+			mcp.WithBoolean("if_not_exists",
+				mcp.Description("Do nothing if the table already exists"),
+			),
+			mcp.WithBoolean("strict",
+				mcp.Description("Create a STRICT table, which enforces column types"),
+			),
+			mcp.WithBoolean("confirm",
+				mcp.Description("Execute the statement; without it the DDL is only returned for review"),
+			),
+		)
+		addTool(createTableTool, dbService.createTableHandler)
+
+		// 28. create_index tool
+		createIndexTool := mcp.NewTool(
+			"create_index",
+			mcp.WithDescription("Create an index on columns of a table. Returns the generated DDL without running it unless confirm is true"),
+			mcp.WithString("table",
+				mcp.Required(),
+				mcp.Description("Table to index"),
+			),
+			mcp.WithArray("columns",
+				mcp.Required(),
+				mcp.Description("Indexed columns, in order"),
+				mcp.Items(map[string]interface{}{"type": "string"}),
+			),
+			mcp.WithString("name",
+				mcp.Description("Index name (default idx_<table>_<columns>)"),
+			),
+			mcp.WithBoolean("unique",
+				mcp.Description("Create a UNIQUE index"),
+			),
+			mcp.WithBoolean("if_not_exists",
+				mcp.Description("Do nothing if the index already exists"),
+			),
+			mcp.WithBoolean("confirm",
+				mcp.Description("Execute the statement; without it the DDL is only returned for review"),
+			),
+		)
+		addTool(createIndexTool, dbService.createIndexHandler)
+
+		// 29. drop_table tool
+		dropTableTool := mcp.NewTool(
+			"drop_table",
+			mcp.WithDescription("Drop a table with its data and indexes. Returns the generated DDL without running it unless confirm is true"),
+			mcp.WithString("table",
+				mcp.Required(),
+				mcp.Description("Table to drop"),
+			),
+			mcp.WithBoolean("confirm",
+				mcp.Description("Execute the statement; without it the DDL is only returned for review"),
+			),
+		)
+		addTool(dropTableTool, dbService.dropTableHandler)
+
+		// 30. apply_migration tool
+		applyMigrationTool := mcp.NewTool(
+			"apply_migration",
+			mcp.WithDescription("Run a SQL migration script in one transaction and report the rows changed and schema changes of each statement. With dry_run the transaction is rolled back; a failing statement always rolls back everything"),
+			mcp.WithString("script",
+				mcp.Required(),
+				mcp.Description("Semicolon separated SQL statements; transaction control, VACUUM and ATTACH are not allowed"),
+			),
+			mcp.WithBoolean("dry_run",
+				mcp.Required(),
+				mcp.Description("true to roll back after running the script, false to commit it"),
+			),
+		)
+		addTool(applyMigrationTool, dbService.applyMigrationHandler)
+	}
+
+	// 31. batch_read tool
+	batchReadTool := mcp.NewTool(
+		"batch_read",
+		mcp.WithDescription("Run several read-only queries (e.g. a count, a sample and a schema lookup) in one call. They run in one read transaction, so all see the same snapshot; a failing query reports its error without affecting the others"),
+		mcp.WithArray("queries",
+			mcp.Required(),
+			mcp.Description("SELECT statements to run, in order (at most 20)"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum rows returned per query (default 100); longer results are marked truncated"),
+		),
+	)
+	addTool(batchReadTool, dbService.batchReadHandler)
+
+	// 32. analyze_query tool
+	analyzeQueryTool := mcp.NewTool(
+		"analyze_query",
+		mcp.WithDescription("Diagnose the performance of a SELECT on SQLite: returns its query plan, whether an index was used, full table scans and temporary sorts, then runs it and reports rows and actual duration (rows are not returned)"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The SELECT SQL query to analyze"),
+		),
+	)
+	addTool(analyzeQueryTool, dbService.analyzeQueryHandler)
+
+	// 33. benchmark_query tool
+	benchmarkQueryTool := mcp.NewTool(
+		"benchmark_query",
+		mcp.WithDescription("Run a SELECT several times and report min, median, p95 and max latency, to compare query variants while optimizing. All runs share the QUERY_TIMEOUT of the call"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The SELECT SQL query to benchmark"),
+		),
+		mcp.WithNumber("runs",
+			mcp.Description("Number of timed runs (default 10, max 100)"),
+		),
+		mcp.WithBoolean("cold",
+			mcp.Description("Open a new connection for every run so SQLite starts with an empty page cache; by default runs are warm, after an untimed warm-up run"),
+		),
+	)
+	addTool(benchmarkQueryTool, dbService.benchmarkQueryHandler)
+
+	if dbService.writeDB != nil {
+		// 34. optimize_database tool
+		optimizeDatabaseTool := mcp.NewTool(
+			"optimize_database",
+			mcp.WithDescription("Refresh the statistics the SQLite query planner uses (ANALYZE or PRAGMA optimize) and return the updated sqlite_stat1 rows. Fresh statistics help the planner choose indexes for analytical queries"),
+			mcp.WithString("mode",
+				mcp.Description("analyze (default) gathers statistics for every table or the given one; optimize only re-analyzes tables whose statistics are stale"),
+				mcp.Enum("analyze", "optimize"),
+			),
+			mcp.WithString("table",
+				mcp.Description("Table to analyze (mode analyze only); all tables by default"),
+			),
+		)
+		addTool(optimizeDatabaseTool, dbService.optimizeDatabaseHandler)
+
+		// 35. vacuum_database tool
+		vacuumDatabaseTool := mcp.NewTool(
+			"vacuum_database",
+			mcp.WithDescription("Rebuild the database file with VACUUM to reclaim free pages and defragment it. Reports the size before and after and the duration; sends progress notifications while it runs. Blocks other writers and needs free disk space of up to twice the database size"),
+		)
+		addTool(vacuumDatabaseTool, dbService.vacuumDatabaseHandler)
+
+		// 36. checkpoint_wal tool
+		checkpointWALTool := mcp.NewTool(
+			"checkpoint_wal",
+			mcp.WithDescription("Copy the write-ahead log into the database file with PRAGMA wal_checkpoint and report the frames checkpointed and the duration"),
+			mcp.WithString("mode",
+				mcp.Description("Checkpoint mode: TRUNCATE (default) also empties the WAL file; PASSIVE does not wait for readers or writers"),
+				mcp.Enum("PASSIVE", "FULL", "RESTART", "TRUNCATE"),
+			),
+		)
+		addTool(checkpointWALTool, dbService.checkpointWALHandler)
 	}
+
+	// 37. storage_report tool
+	storageReportTool := mcp.NewTool(
+		"storage_report",
+		mcp.WithDescription("Report the disk space used by each table and index of a SQLite database, largest first, with unused space, fragmentation and the free pages VACUUM would reclaim. Reads every page, so it takes a while on large databases"),
+		mcp.WithString("schema",
+			mcp.Description("Schema to report on: main (default) or an attached database such as mounts"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of tables and indexes listed (default 50)"),
+		),
+	)
+	addTool(storageReportTool, dbService.storageReportHandler)
+
+	// 38. sql_capabilities tool
+	sqlCapabilitiesTool := mcp.NewTool(
+		"sql_capabilities",
+		mcp.WithDescription("List the SQL functions, collations, virtual table modules and compile options of the SQLite build, with flags for commonly assumed features such as REGEXP (not built in) and JSON. Check here before using a function in a query"),
+	)
+	addTool(sqlCapabilitiesTool, dbService.sqlCapabilitiesHandler)
+
+	// 39. validate_query tool
+	validateQueryTool := mcp.NewTool(
+		"validate_query",
+		mcp.WithDescription("Check a SELECT without running it: reports whether it compiles, the error if not, its placeholder count and, for SQLite, its result columns with declared types. Cheaper than read_query for pre-flighting generated SQL"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("SQL query to validate"),
+		),
+	)
+	addTool(validateQueryTool, dbService.validateQueryHandler)
+
+	// 40. format_sql tool
+	formatSQLTool := mcp.NewTool(
+		"format_sql",
+		mcp.WithDescription("Pretty-print SQL: one clause per line, SELECT items and WHERE conditions on their own lines, subqueries indented. Nothing is run, so any statement is accepted"),
+		mcp.WithString("sql",
+			mcp.Required(),
+			mcp.Description("SQL to format; several statements may be separated by semicolons"),
+		),
+		mcp.WithBoolean("uppercase",
+			mcp.Description("Upper-case SQL keywords (default false keeps their case)"),
+		),
+	)
+	addTool(formatSQLTool, dbService.formatSQLHandler)
+
+	// 41. schema_vocabulary tool
+	schemaVocabularyTool := mcp.NewTool(
+		"schema_vocabulary",
+		mcp.WithDescription("List every table and view with its columns in one call, as \"name TYPE\" entries marked PK or \"-> table.column\" for foreign keys. A compact data dictionary for writing SQL against the real identifiers"),
+	)
+	addTool(schemaVocabularyTool, dbService.schemaVocabularyHandler)
+
+	// 42. compare_queries tool
+	compareQueriesTool := mcp.NewTool(
+		"compare_queries",
+		mcp.WithDescription("Run two SELECT queries on the same snapshot and report whether they return the same rows, with counts and a sample of the rows only one of them returns. Use it to check that a rewritten query is equivalent"),
+		mcp.WithString("first",
+			mcp.Required(),
+			mcp.Description("The original query"),
+		),
+		mcp.WithString("second",
+			mcp.Required(),
+			mcp.Description("The query to compare with it"),
+		),
+		mcp.WithBoolean("ordered",
+			mcp.Description("Also require the rows to come in the same order (default false compares them as multisets)"),
+		),
+		mcp.WithNumber("sample",
+			mcp.Description("Maximum differing rows shown from each side (default 10)"),
+		),
+	)
+	addTool(compareQueriesTool, dbService.compareQueriesHandler)
+
+	// 43. count_rows tool
+	countRowsTool := mcp.NewTool(
+		"count_rows",
+		mcp.WithDescription("Count the rows of a table, or estimate them from ANALYZE statistics or the largest rowid when an exact COUNT(*) would be too slow. The result says whether the count is exact and how it was obtained"),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Table to count"),
+		),
+		mcp.WithString("mode",
+			mcp.Description("auto (default) counts exactly for a few seconds, then estimates; exact always counts; approximate only estimates"),
+			mcp.Enum("auto", "exact", "approximate"),
+		),
+	)
+	addTool(countRowsTool, dbService.countRowsHandler)
+
+	// 44. paginate tool
+	paginateTool := mcp.NewTool(
+		"paginate",
+		mcp.WithDescription("Page through a table or base SELECT in key order with keyset pagination: each call returns one page and a next_cursor to pass back for the following page, so deep pages cost no more than the first. The key columns must be non-NULL and together unique"),
+		mcp.WithString("table",
+			mcp.Description("Table to page through (or use query)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Base SELECT query to page through instead of a table; it must return the key columns"),
+		),
+		mcp.WithArray("keys",
+			mcp.Required(),
+			mcp.Description("Columns that order the rows and identify each one, e.g. [\"id\"] or [\"created_at\", \"id\"]"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithNumber("page_size",
+			mcp.Description("Rows per page (default 100)"),
+		),
+		mcp.WithBoolean("descending",
+			mcp.Description("Page in descending key order"),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("next_cursor of the previous page; omit for the first page"),
+		),
+	)
+	addTool(paginateTool, dbService.paginateHandler)
+
+	// 45. audit_log tool
+	auditLogTool := mcp.NewTool(
+		"audit_log",
+		mcp.WithDescription("Review recent tool calls, newest first, with their caller, session, SQL, tables read and outcome (administrators only)"),
+		mcp.WithString("session_id",
+			mcp.Description("Only calls of this MCP session"),
+		),
+		mcp.WithString("principal",
+			mcp.Description("Only calls by this caller"),
+		),
+		mcp.WithString("tool",
+			mcp.Description("Only calls of this tool"),
+		),
+		mcp.WithString("table",
+			mcp.Description("Only calls that read this table"),
+		),
+		mcp.WithString("since",
+			mcp.Description("Only calls started at or after this RFC 3339 time"),
+		),
+		mcp.WithString("until",
+			mcp.Description("Only calls started at or before this RFC 3339 time"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of calls to return (default 100, at most 1000)"),
+		),
+	)
+	addTool(auditLogTool, audit.auditLogHandler(identity))
+
+	// 46. server_stats tool
+	serverStatsTool := mcp.NewTool(
+		"server_stats",
+		mcp.WithDescription("Get per-tool metrics since the server started: calls, errors by class (validation, denied, timeout, budget, unavailable, db_error, internal) and the average, median, 95th percentile and maximum of duration, result bytes and rows"),
+	)
+	addTool(serverStatsTool, stats.serverStatsHandler)
+
+	// 47. usage_report tool
+	usageReportTool := mcp.NewTool(
+		"usage_report",
+		mcp.WithDescription("Summarize usage since the server started: the most read tables with their callers and columns, the most frequent failing query patterns, and the query patterns returning the most data (administrators only)"),
+		mcp.WithNumber("top",
+			mcp.Description("Entries per section (default 10, at most 100)"),
+		),
+	)
+	addTool(usageReportTool, usage.usageReportHandler(identity))
+
+	// 48. rerun_last tool
+	rerunLastTool := mcp.NewTool(
+		"rerun_last",
+		mcp.WithDescription("Run this session's last read_query again, optionally with another ORDER BY, a LIMIT or the next page, without resending the SQL. Refinements carry over to the next rerun_last, so next_page can be repeated"),
+		mcp.WithString("order_by",
+			mcp.Description("Result columns to order by, by name or position, each optionally followed by ASC or DESC, e.g. \"total DESC, 1\""),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Rows to return; starts again from the first row unless offset is given"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Rows to skip"),
+		),
+		mcp.WithBoolean("next_page",
+			mcp.Description("Skip past the rows of the last run, keeping its limit"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format, as for read_query (default: that of the last query)"),
+			mcp.Enum("json", "jsonl", "html"),
+		),
+		mcp.WithBoolean("column_types",
+			mcp.Description("Also return the declared type of each result column (default: as for the last query)"),
+		),
+	)
+	addTool(rerunLastTool, dbService.readQueryHandler)
+
+	if dbService.temp != nil {
+		// 49. create_temp_table tool
+		createTempTableTool := mcp.NewTool(
+			"create_temp_table",
+			mcp.WithDescription("Materialize the result of a SELECT as a temporary table, or define it as a temporary view, to build on in later read_query calls of this session. Temporary objects are only visible to this session and are dropped when it ends or stays idle"),
+			mcp.WithString("name",
+				mcp.Required(),
+				mcp.Description("Name of the temporary table or view"),
+			),
+			mcp.WithString("query",
+				mcp.Required(),
+				mcp.Description("The SELECT whose result the table holds or the view shows"),
+			),
+			mcp.WithBoolean("view",
+				mcp.Description("Create a view, evaluated on each read, instead of copying the rows (default false)"),
+			),
+		)
+		addTool(createTempTableTool, dbService.createTempTableHandler)
+
+		// 50. drop_temp_table tool
+		dropTempTableTool := mcp.NewTool(
+			"drop_temp_table",
+			mcp.WithDescription("Drop a temporary table or view created by this session with create_temp_table"),
+			mcp.WithString("name",
+				mcp.Required(),
+				mcp.Description("Name of the temporary table or view"),
+			),
+		)
+		addTool(dropTempTableTool, dbService.dropTempTableHandler)
+	}
+
+	if rootsDiscovery != nil {
+		// 51. list_databases tool
+		listDatabasesTool := mcp.NewTool(
+			"list_databases",
+			mcp.WithDescription("List the SQLite database files in the filesystem roots the client exposes, with their size and modification time, marking the one this server is connected to"),
+		)
+		addTool(listDatabasesTool, rootsDiscovery.listDatabasesHandler)
+
+		if rootsDiscovery.selectable {
+			// 52. use_database tool
+			useDatabaseTool := mcp.NewTool(
+				"use_database",
+				mcp.WithDescription("Switch the server to one of the database files list_databases lists. The switch applies to every session of the server, not only this one"),
+				mcp.WithString("path",
+					mcp.Required(),
+					mcp.Description("Path of the database file, as listed by list_databases"),
+				),
+			)
+			addTool(useDatabaseTool, rootsDiscovery.useDatabaseHandler)
+		}
+	}
+
+	// Tell clients when other processes change the database
+	pollInterval, err := changePollIntervalFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid change notification settings: %v", err)
+	}
+	if driverName == "sqlite" && pollInterval > 0 {
+		watcher := &changeWatcher{db: dbService.db, connector: dbService.connector, server: mcpServer, interval: pollInterval}
+		watchCtx, cancelWatch := context.WithCancel(context.Background())
+		defer cancelWatch()
+		go watcher.Run(watchCtx)
+	}
+
+	debugAddr, err := debugAddrFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid debug settings: %v", err)
+	}
+	if debugAddr != "" {
+		startDebugServer(debugAddr, dbService.db)
+	}
+
+	server := server.NewStreamableHTTPServer(mcpServer,
+		server.WithHTTPContextFunc(identity.contextFunc), // Resolve the caller from the proxy identity header
+	)
+
+	mux := http.NewServeMux()
+	completer := &Completer{ds: dbService, profiles: profiles, identity: identity}
+	mux.Handle("/mcp", tokens.require(clientLog.setLevelHandler(completer.handler(clientRequests.handler(dbService.temp.handler(server))))))
+	mux.HandleFunc("GET /healthz", livenessHandler)
+	mux.HandleFunc("GET /readyz", dbService.readinessHandler)
+	mux.Handle("GET /metrics", tokens.require(http.HandlerFunc(stats.metricsHandler)))
+	mux.Handle("GET /results/{id}", tokens.require(dbService.exports.downloadHandler(identity)))
+	basePath, err := basePathFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid base path: %v", err)
+	}
+	if basePath != "" && dbService.exports.baseURL == "" {
+		dbService.exports.baseURL = basePath // Relative download links still need the prefix
+	}
+	var handler http.Handler = requestLimits.bodyHandler(mux) // Refuse bodies over MAX_REQUEST_BYTES
+	handler = withBasePath(basePath, handler)
+	if v := os.Getenv("HTTP_COMPRESSION"); v == "false" || v == "0" {
+		log.Printf("HTTP response compression disabled.")
+	} else {
+		handler = compressHandler(handler) // gzip/deflate negotiated via Accept-Encoding
+	}
+	cors, err := corsConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid CORS settings: %v", err)
+	}
+	if cors != nil {
+		handler = cors.handler(handler) // Let browser clients on other origins connect
+	}
+	grace, err := shutdownGraceFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid shutdown settings: %v", err)
+	}
+	drain := newDrainer(grace)
+	handler = drain.handler(handler)
+	handler = requestLogHandler(handler) // Correlation IDs and access log
+	httpServer := &http.Server{Handler: handler, BaseContext: drain.baseContext}
+	listener, err := listenerFromEnv(port)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	log.Printf("Starting MCP HTTP server on %s %s, endpoint %s/mcp", listener.Addr().Network(), listener.Addr(), basePath)
+	log.Printf("Database: %s", dbService.databaseFile())
+	if dbService.writeDB != nil {
+		log.Printf("Write mode enabled for the row mutation tools.")
+	} else {
+		log.Printf("Read-only access enabled.")
+	}
+	log.Printf("Profile: %s (%d per-user overrides)", profiles.Default.Name, len(profiles.Users))
+	log.Printf("Available tools: %s", strings.Join(toolNames, ", "))
+
+	stopCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("SSE Server error: %v", err)
+		}
+	}()
+	<-stopCtx.Done()
+	stop() // A second signal kills the process
+	drain.shutdown(httpServer, mcpServer, health)
+	// The deferred calls stop the background jobs and close the database
 }
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// The maintenance tools run on the write pool, since ANALYZE, VACUUM and
+// checkpoints write to the database file. The server reports itself not
+// ready on /readyz while they run.
+
+// TableStat is one row of sqlite_stat1.
+type TableStat struct {
+	Table string `json:"table"`
+	Index string `json:"index,omitempty"`
+	Rows  int64  `json:"rows"` // Approximate rows in the table or index
+	Stat  string `json:"stat"`
+}
+
+// OptimizeResult is the payload returned by optimize_database.
+type OptimizeResult struct {
+	Statement  string      `json:"statement"`
+	DurationMs float64     `json:"duration_ms"`
+	Stats      []TableStat `json:"stats"`
+}
+
+// optimizeDatabaseHandler refreshes the statistics the query planner uses,
+// with ANALYZE or PRAGMA optimize, and returns the resulting sqlite_stat1.
+func (ds *DatabaseService) optimizeDatabaseHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	mode, _ := args["mode"].(string)
+	table, _ := args["table"].(string)
+
+	var stmt string
+	switch mode {
+	case "", "analyze":
+		stmt = "ANALYZE"
+		if table != "" {
+			resolved, err := ds.resolveTable(ctx, table)
+			if err != nil {
+				return tableErrorResult(table, err), nil
+			}
+			table = resolved
+			stmt += " " + ds.quoteTable(resolved)
+		}
+	case "optimize":
+		if table != "" {
+			return mcp.NewToolResultError("PRAGMA optimize covers the whole database; 'table' only applies to mode analyze."), nil
+		}
+		// 0x10002 analyzes every table that needs it, not only those the
+		// connection has queried
+		stmt = "PRAGMA optimize(0x10002)"
+	default:
+		return mcp.NewToolResultError("Invalid 'mode' argument: use analyze or optimize."), nil
+	}
+
+	defer ds.health.beginMaintenance(stmt)()
+	start := time.Now()
+	if _, err := ds.writeDB.ExecContext(ctx, stmt); err != nil {
+		log.Printf("Error running %s: %v", stmt, err)
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error updating statistics", err), nil
+	}
+	result := OptimizeResult{Statement: stmt, DurationMs: millisSince(start)}
+	log.Printf("Ran %s in %.0fms", stmt, result.DurationMs)
+
+	var err error
+	if result.Stats, err = readTableStats(ctx, ds.writeDB, table); err != nil {
+		log.Printf("Error reading sqlite_stat1: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error reading statistics", err), nil
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling statistics to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting statistics", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// readTableStats returns the rows of sqlite_stat1, for one table when table
+// is set. ANALYZE leaves no sqlite_stat1 when the database has no indexes.
+func readTableStats(ctx context.Context, db *sql.DB, table string) ([]TableStat, error) {
+	stats := []TableStat{}
+	var exists int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_schema WHERE name = 'sqlite_stat1'").Scan(&exists); err != nil || exists == 0 {
+		return stats, err
+	}
+	query, args := "SELECT tbl, idx, stat FROM sqlite_stat1", []interface{}{}
+	if table != "" {
+		query, args = query+" WHERE tbl = ?", append(args, table)
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY tbl, idx", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s TableStat
+		var index sql.NullString
+		if err := rows.Scan(&s.Table, &index, &s.Stat); err != nil {
+			return nil, err
+		}
+		s.Index = index.String
+		if fields := strings.Fields(s.Stat); len(fields) > 0 {
+			s.Rows, _ = strconv.ParseInt(fields[0], 10, 64)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// execWithProgress executes stmt on the write pool, reporting the elapsed
+// time as progress while it runs.
+func (ds *DatabaseService) execWithProgress(ctx context.Context, request mcp.CallToolRequest, stmt string) (float64, error) {
+	done := make(chan struct{})
+	defer close(done)
+	start := time.Now()
+	go func() {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				reportProgress(ctx, request, 1, 3, fmt.Sprintf("%s running for %s", stmt, time.Since(start).Round(time.Second)))
+			}
+		}
+	}()
+	_, err := ds.writeDB.ExecContext(ctx, stmt)
+	return millisSince(start), err
+}
+
+// DatabaseSize describes how the pages of the database file are used.
+type DatabaseSize struct {
+	Bytes     int64 `json:"bytes"`
+	Pages     int64 `json:"pages"`
+	FreePages int64 `json:"free_pages"`
+	PageSize  int64 `json:"page_size"`
+}
+
+// databaseSize reads the page usage of the file of a schema.
+func databaseSize(ctx context.Context, db *sql.DB, schema string) (DatabaseSize, error) {
+	var size DatabaseSize
+	err := db.QueryRowContext(ctx, `SELECT
+		(SELECT page_count FROM pragma_page_count(?1)),
+		(SELECT freelist_count FROM pragma_freelist_count(?1)),
+		(SELECT page_size FROM pragma_page_size(?1))`, schema).Scan(&size.Pages, &size.FreePages, &size.PageSize)
+	size.Bytes = size.Pages * size.PageSize
+	return size, err
+}
+
+// VacuumResult is the payload returned by vacuum_database.
+type VacuumResult struct {
+	DurationMs float64      `json:"duration_ms"`
+	Before     DatabaseSize `json:"before"`
+	After      DatabaseSize `json:"after"`
+	BytesFreed int64        `json:"bytes_freed"`
+}
+
+// vacuumDatabaseHandler rebuilds the database file to reclaim free pages and
+// defragment tables and indexes.
+func (ds *DatabaseService) vacuumDatabaseHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	defer ds.health.beginMaintenance("VACUUM")()
+	var result VacuumResult
+	var err error
+	if result.Before, err = databaseSize(ctx, ds.writeDB, "main"); err != nil {
+		log.Printf("Error reading database size: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error reading database size", err), nil
+	}
+	reportProgress(ctx, request, 0, 3, fmt.Sprintf("Vacuuming %d pages (%d free)", result.Before.Pages, result.Before.FreePages))
+	if result.DurationMs, err = ds.execWithProgress(ctx, request, "VACUUM"); err != nil {
+		log.Printf("Error running VACUUM: %v", err)
+		if res := ds.limitsFor(ctx).budgetError(ctx, err); res != nil {
+			return res, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error running VACUUM", err), nil
+	}
+	reportProgress(ctx, request, 2, 3, "VACUUM finished")
+	if result.After, err = databaseSize(ctx, ds.writeDB, "main"); err != nil {
+		log.Printf("Error reading database size: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error reading database size", err), nil
+	}
+	result.BytesFreed = result.Before.Bytes - result.After.Bytes
+	log.Printf("VACUUM freed %d bytes in %.0fms", result.BytesFreed, result.DurationMs)
+	reportProgress(ctx, request, 3, 3, "Done")
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling vacuum result to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting vacuum result", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// CheckpointResult is the payload returned by checkpoint_wal.
+type CheckpointResult struct {
+	Mode         string  `json:"mode"`
+	DurationMs   float64 `json:"duration_ms"`
+	Busy         bool    `json:"busy"`         // The checkpoint could not complete because of readers or writers
+	WALFrames    int64   `json:"wal_frames"`   // Frames in the WAL before the checkpoint, -1 if not in WAL mode
+	Checkpointed int64   `json:"checkpointed"` // Frames copied into the database file
+}
+
+// checkpointWALHandler copies the write-ahead log into the database file and,
+// in TRUNCATE mode, truncates the log.
+func (ds *DatabaseService) checkpointWALHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	mode, _ := request.GetArguments()["mode"].(string)
+	if mode == "" {
+		mode = "TRUNCATE"
+	}
+	switch mode = strings.ToUpper(mode); mode {
+	case "PASSIVE", "FULL", "RESTART", "TRUNCATE":
+	default:
+		return mcp.NewToolResultError("Invalid 'mode' argument: use PASSIVE, FULL, RESTART or TRUNCATE."), nil
+	}
+
+	defer ds.health.beginMaintenance("wal_checkpoint")()
+	result := CheckpointResult{Mode: mode}
+	start := time.Now()
+	var busy int
+	err := ds.writeDB.QueryRowContext(ctx, fmt.Sprintf("PRAGMA wal_checkpoint(%s)", mode)).Scan(&busy, &result.WALFrames, &result.Checkpointed)
+	result.DurationMs = millisSince(start)
+	if err != nil {
+		log.Printf("Error checkpointing WAL: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error checkpointing WAL", err), nil
+	}
+	result.Busy = busy != 0
+	log.Printf("WAL checkpoint (%s): %d of %d frames in %.0fms", mode, result.Checkpointed, result.WALFrames, result.DurationMs)
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling checkpoint result to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting checkpoint result", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Error classes of failed tool calls.
+const (
+	errorValidation  = "validation"  // Bad arguments or SQL refused before running
+	errorDenied      = "denied"      // Outside the caller's profile, scope or role
+	errorTimeout     = "timeout"     // QUERY_TIMEOUT reached or cancelled
+	errorBudget      = "budget"      // Row, memory or cost budget exceeded
+	errorUnavailable = "unavailable" // Database down or server busy
+	errorDatabase    = "db_error"    // The database failed the query
+	errorInternal    = "internal"    // The handler failed outright
+)
+
+// Histogram bucket upper bounds.
+var (
+	durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60} // Seconds
+	bytesBuckets    = []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+	rowsBuckets     = []float64{0, 1, 10, 100, 1000, 10000, 100000}
+)
+
+// histogram counts observations in fixed buckets, as Prometheus does.
+type histogram struct {
+	bounds []float64
+	counts []int64 // Per bucket, not cumulative; the last one is +Inf
+	sum    float64
+	count  int64
+	max    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]int64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.bounds, v)]++
+	h.sum += v
+	h.count++
+	h.max = max(h.max, v)
+}
+
+// quantile returns the upper bound of the bucket holding quantile q, or the
+// largest observation when it falls beyond the last bucket.
+func (h *histogram) quantile(q float64) float64 {
+	rank := int64(q*float64(h.count) + 0.5)
+	var seen int64
+	for i, n := range h.counts {
+		if seen += n; seen >= rank && n > 0 {
+			if i < len(h.bounds) {
+				return min(h.bounds[i], h.max)
+			}
+			break
+		}
+	}
+	return h.max
+}
+
+// summary reports the histogram for server_stats, scaled by unit.
+func (h *histogram) summary(unit float64) map[string]float64 {
+	if h.count == 0 {
+		return map[string]float64{}
+	}
+	return map[string]float64{
+		"avg": h.sum / float64(h.count) * unit,
+		"p50": h.quantile(0.5) * unit,
+		"p95": h.quantile(0.95) * unit,
+		"max": h.max * unit,
+	}
+}
+
+// toolMetrics are the metrics of one tool.
+type toolMetrics struct {
+	calls    int64
+	errors   map[string]int64 // By error class
+	duration *histogram
+	bytes    *histogram
+	rows     *histogram
+}
+
+func newToolMetrics() *toolMetrics {
+	return &toolMetrics{
+		errors:   make(map[string]int64),
+		duration: newHistogram(durationBuckets),
+		bytes:    newHistogram(bytesBuckets),
+		rows:     newHistogram(rowsBuckets),
+	}
+}
+
+// errorClass classifies a failed tool call by its error code, or returns
+// "" for a successful one.
+func errorClass(result *mcp.CallToolResult, err error) string {
+	if err != nil {
+		return errorInternal
+	}
+	if result == nil || !result.IsError {
+		return ""
+	}
+	code, _ := errorCode(result)
+	if class := errorCodeInfo[code].class; class != "" {
+		return class
+	}
+	return errorValidation
+}
+
+// recordTool adds a call to the metrics of its tool. The caller holds s.mu.
+func (s *SessionStats) recordTool(tool, class string, rows, bytes int64, seconds float64) {
+	m, ok := s.tools[tool]
+	if !ok {
+		m = newToolMetrics()
+		s.tools[tool] = m
+	}
+	m.calls++
+	if class != "" {
+		m.errors[class]++
+	}
+	m.duration.observe(seconds)
+	m.bytes.observe(float64(bytes))
+	m.rows.observe(float64(rows))
+}
+
+// toolNames returns the tools with metrics in order. The caller holds s.mu.
+func (s *SessionStats) toolNames() []string {
+	names := make([]string, 0, len(s.tools))
+	for name := range s.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serverStatsHandler reports, per tool, the calls, errors by class and the
+// distribution of durations, result sizes and rows.
+func (s *SessionStats) serverStatsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.mu.Lock()
+	tools := make(map[string]interface{}, len(s.tools))
+	for _, name := range s.toolNames() {
+		m := s.tools[name]
+		errors := make(map[string]int64, len(m.errors))
+		for class, n := range m.errors {
+			errors[class] = n
+		}
+		tools[name] = map[string]interface{}{
+			"calls":        m.calls,
+			"errors":       errors,
+			"duration_ms":  m.duration.summary(1000),
+			"result_bytes": m.bytes.summary(1),
+			"rows":         m.rows.summary(1),
+		}
+	}
+	s.mu.Unlock()
+
+	resultJSON, err := json.MarshalIndent(map[string]interface{}{"tools": tools}, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling server stats to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting server stats", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// metricsHandler serves the tool metrics in the Prometheus text format.
+func (s *SessionStats) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	s.mu.Lock()
+	names := s.toolNames()
+	b.WriteString("# HELP dbmcp_tool_calls_total Tool calls.\n# TYPE dbmcp_tool_calls_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "dbmcp_tool_calls_total{tool=%q} %d\n", name, s.tools[name].calls)
+	}
+	b.WriteString("# HELP dbmcp_tool_errors_total Failed tool calls by error class.\n# TYPE dbmcp_tool_errors_total counter\n")
+	for _, name := range names {
+		m := s.tools[name]
+		classes := make([]string, 0, len(m.errors))
+		for class := range m.errors {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			fmt.Fprintf(&b, "dbmcp_tool_errors_total{tool=%q,class=%q} %d\n", name, class, m.errors[class])
+		}
+	}
+	for _, metric := range []struct {
+		name, help string
+		get        func(*toolMetrics) *histogram
+	}{
+		{"dbmcp_tool_duration_seconds", "Tool call duration.", func(m *toolMetrics) *histogram { return m.duration }},
+		{"dbmcp_tool_result_bytes", "Size of tool results.", func(m *toolMetrics) *histogram { return m.bytes }},
+		{"dbmcp_tool_rows", "Rows returned by tool calls.", func(m *toolMetrics) *histogram { return m.rows }},
+	} {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", metric.name, metric.help, metric.name)
+		for _, name := range names {
+			h := metric.get(s.tools[name])
+			var cumulative int64
+			for i, bound := range h.bounds {
+				cumulative += h.counts[i]
+				fmt.Fprintf(&b, "%s_bucket{tool=%q,le=%q} %d\n", metric.name, name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket{tool=%q,le=\"+Inf\"} %d\n", metric.name, name, h.count)
+			fmt.Fprintf(&b, "%s_sum{tool=%q} %s\n", metric.name, name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+			fmt.Fprintf(&b, "%s_count{tool=%q} %d\n", metric.name, name, h.count)
+		}
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MigrationStep reports the effect of one statement of a migration script.
+type MigrationStep struct {
+	Statement     string  `json:"statement"`
+	RowsChanged   int64   `json:"rows_changed"` // Including rows changed by triggers and foreign key actions
+	SchemaChanged bool    `json:"schema_changed"`
+	DurationMs    float64 `json:"duration_ms"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// MigrationResult is the payload returned by apply_migration.
+type MigrationResult struct {
+	DryRun    bool            `json:"dry_run"`
+	Committed bool            `json:"committed"`
+	Steps     []MigrationStep `json:"statements"`
+	Message   string          `json:"message"`
+}
+
+// migrationForbidden are statements a migration script may not contain: the
+// tool owns the transaction, and attached databases would escape it.
+var migrationForbidden = map[string]bool{
+	"BEGIN": true, "COMMIT": true, "END": true, "ROLLBACK": true,
+	"VACUUM": true, "ATTACH": true, "DETACH": true,
+}
+
+// sqlStatement is one statement of a script with its leading keyword.
+type sqlStatement struct {
+	Text    string
+	Keyword string // First word, upper-cased
+}
+
+// splitSQLStatements splits a SQLite script into statements at semicolons
+// outside string literals, quoted identifiers, comments and the BEGIN ... END
+// body of CREATE TRIGGER. Statements holding only comments are dropped.
+func splitSQLStatements(script string) []sqlStatement {
+	var statements []sqlStatement
+	start, depth, content := 0, 0, false
+	var words []string // Leading words of the current statement, upper-cased
+	var word strings.Builder
+	endWord := func() {
+		if word.Len() == 0 {
+			return
+		}
+		w := strings.ToUpper(word.String())
+		word.Reset()
+		if len(words) < 3 {
+			words = append(words, w)
+		}
+		if isTriggerDefinition(words) {
+			switch w {
+			case "BEGIN", "CASE":
+				depth++
+			case "END":
+				depth--
+			}
+		}
+	}
+	finish := func(end int) {
+		endWord()
+		if content {
+			stmt := sqlStatement{Text: strings.TrimSpace(script[start:end])}
+			if len(words) > 0 {
+				stmt.Keyword = words[0]
+			}
+			statements = append(statements, stmt)
+		}
+		start, depth, content, words = end+1, 0, false, nil
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			endWord()
+			content = true
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			for i++; i < len(script) && script[i] != closing; i++ {
+			}
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			endWord()
+			if end := strings.IndexByte(script[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(script)
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			endWord()
+			if end := strings.Index(script[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(script)
+			}
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9':
+			word.WriteByte(c)
+			content = true
+		case c == ';':
+			if endWord(); depth <= 0 {
+				finish(i)
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			endWord()
+		default:
+			endWord()
+			content = true
+		}
+	}
+	finish(len(script))
+	return statements
+}
+
+// isTriggerDefinition reports whether the leading words start CREATE [TEMP] TRIGGER.
+func isTriggerDefinition(words []string) bool {
+	if len(words) < 2 || words[0] != "CREATE" {
+		return false
+	}
+	return words[1] == "TRIGGER" || len(words) > 2 && (words[1] == "TEMP" || words[1] == "TEMPORARY") && words[2] == "TRIGGER"
+}
+
+// applyMigrationHandler runs a migration script in one transaction on the
+// write pool, committing it unless dry_run is set or a statement fails.
+func (ds *DatabaseService) applyMigrationHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	script, _ := args["script"].(string)
+	statements := splitSQLStatements(script)
+	if len(statements) == 0 {
+		return mcp.NewToolResultError("Missing or empty 'script' argument."), nil
+	}
+	dryRun, ok := args["dry_run"].(bool)
+	if !ok {
+		return mcp.NewToolResultError("Missing 'dry_run' argument: pass true to roll back after running the script, false to commit it."), nil
+	}
+	for _, stmt := range statements {
+		if migrationForbidden[stmt.Keyword] {
+			return mcp.NewToolResultError(fmt.Sprintf("%s statements are not allowed in a migration; the script runs in a transaction managed by the tool.", stmt.Keyword)), nil
+		}
+	}
+
+	if !dryRun {
+		defer ds.health.beginMaintenance("apply_migration")()
+	}
+	conn, err := ds.writeDB.Conn(ctx)
+	if err != nil {
+		log.Printf("Error opening write connection: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error opening write connection", err), nil
+	}
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Error starting migration transaction: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error starting migration transaction", err), nil
+	}
+	defer tx.Rollback()
+
+	// total_changes() and schema_version are per connection, so the pinned
+	// connection sees exactly the effect of each statement
+	counters := func() (changes, schemaVersion int64, err error) {
+		err = tx.QueryRowContext(ctx, "SELECT total_changes(), (SELECT schema_version FROM pragma_schema_version)").Scan(&changes, &schemaVersion)
+		return changes, schemaVersion, err
+	}
+	result := MigrationResult{DryRun: dryRun, Steps: []MigrationStep{}}
+	changes, schemaVersion, err := counters()
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("Error reading change counters", err), nil
+	}
+	for i, stmt := range statements {
+		step := MigrationStep{Statement: stmt.Text}
+		start := time.Now()
+		_, err := tx.ExecContext(ctx, stmt.Text)
+		step.DurationMs = millisSince(start)
+		if err != nil {
+			log.Printf("Migration statement %d failed: %v, Statement: %s", i+1, err, logSQL(stmt.Text))
+			step.Error = err.Error()
+			result.Steps = append(result.Steps, step)
+			result.Message = fmt.Sprintf("Statement %d of %d failed; the migration was rolled back and nothing was changed.", i+1, len(statements))
+			return migrationResult(result, true)
+		}
+		newChanges, newSchemaVersion, err := counters()
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Error reading change counters", err), nil
+		}
+		step.RowsChanged, step.SchemaChanged = newChanges-changes, newSchemaVersion != schemaVersion
+		changes, schemaVersion = newChanges, newSchemaVersion
+		result.Steps = append(result.Steps, step)
+	}
+
+	if dryRun {
+		result.Message = fmt.Sprintf("Dry run: all %d statements succeeded and were rolled back.", len(statements))
+		return migrationResult(result, false)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing migration: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error committing migration", err), nil
+	}
+	log.Printf("Applied migration of %d statements", len(statements))
+	result.Committed = true
+	result.Message = fmt.Sprintf("All %d statements succeeded and were committed.", len(statements))
+	return migrationResult(result, false)
+}
+
+func migrationResult(result MigrationResult, failed bool) (*mcp.CallToolResult, error) {
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling migration result to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting migration result", err), nil
+	}
+	if failed {
+		return mcp.NewToolResultError(string(resultJSON)), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultMigrationTables are the bookkeeping tables checked when MIGRATION_TABLES is not set.
+// golang-migrate and Rails both use schema_migrations; they are told apart by their columns.
+var defaultMigrationTables = []string{"schema_migrations", "goose_db_version"}
+
+// migrationFilePattern matches the leading version number of a migration file name,
+// e.g. 000001_init.up.sql (golang-migrate), 20240101120000_init.sql (goose) or
+// 20240101120000_create_users.rb (Rails).
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_.+\.(sql|rb|go)$`)
+
+// MigrationStatus describes the state recorded in a single migration table.
+type MigrationStatus struct {
+	Table          string  `json:"table"`
+	Tool           string  `json:"tool"`
+	CurrentVersion *int64  `json:"current_version"`
+	Dirty          bool    `json:"dirty"`
+	AppliedCount   int     `json:"applied_count"`
+	Pending        []int64 `json:"pending,omitempty"`
+}
+
+// parseMigrationTables splits a comma separated MIGRATION_TABLES value.
+func parseMigrationTables(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return defaultMigrationTables
+	}
+	var tables []string
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// migrationStatusHandler reports the current version and dirty/pending state of the configured migration tables.
+func (ds *DatabaseService) migrationStatusHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var fileVersions []int64
+	if ds.migrationsDir != "" {
+		var err error
+		fileVersions, err = readMigrationVersions(ds.migrationsDir)
+		if err != nil {
+			log.Printf("Error reading migrations directory %s: %v", ds.migrationsDir, err)
+			return mcp.NewToolResultErrorFromErr("Error reading migrations directory", err), nil
+		}
+	}
+
+	statuses := []MigrationStatus{}
+	for _, table := range ds.migrationTables {
+		columns, err := ds.tableColumns(ctx, table)
+		if err != nil {
+			log.Printf("Error inspecting migration table %s: %v", table, err)
+			return mcp.NewToolResultErrorFromErr(fmt.Sprintf("Error inspecting migration table '%s'", table), err), nil
+		}
+		if len(columns) == 0 {
+			continue // Table does not exist
+		}
+
+		status, applied, err := ds.readMigrationTable(ctx, table, columns)
+		if err != nil {
+			log.Printf("Error reading migration table %s: %v", table, err)
+			return mcp.NewToolResultErrorFromErr(fmt.Sprintf("Error reading migration table '%s'", table), err), nil
+		}
+		status.Pending = pendingMigrations(fileVersions, applied, status.Tool)
+		statuses = append(statuses, status)
+	}
+
+	if len(statuses) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No migration tables found (looked for: %s).", strings.Join(ds.migrationTables, ", "))), nil
+	}
+
+	resultJSON, err := json.MarshalIndent(statuses, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling migration status to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting migration status", err), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// tableColumns returns the lower-cased column names of a table, or nil if it does not exist.
+func (ds *DatabaseService) tableColumns(ctx context.Context, table string) (map[string]bool, error) {
+	rows, err := ds.db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns map[string]bool
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if columns == nil {
+			columns = make(map[string]bool)
+		}
+		columns[strings.ToLower(name)] = true
+	}
+	return columns, rows.Err()
+}
+
+// readMigrationTable detects which tool owns the table from its columns and reads the applied versions.
+func (ds *DatabaseService) readMigrationTable(ctx context.Context, table string, columns map[string]bool) (MigrationStatus, map[int64]bool, error) {
+	status := MigrationStatus{Table: table}
+	quoted := `"` + strings.ReplaceAll(table, `"`, `""`) + `"`
+
+	var query string
+	switch {
+	case columns["version"] && columns["dirty"]:
+		// golang-migrate keeps a single row with the current version.
+		status.Tool = "golang-migrate"
+		query = fmt.Sprintf("SELECT version, dirty FROM %s", quoted)
+	case columns["version_id"] && columns["is_applied"]:
+		// goose appends a row per up/down step; the latest row per version wins.
+		status.Tool = "goose"
+		query = fmt.Sprintf("SELECT version_id, is_applied FROM %s ORDER BY id", quoted)
+	case columns["version"]:
+		// Rails stores one row per applied migration.
+		status.Tool = "rails"
+		query = fmt.Sprintf("SELECT version, 1 FROM %s", quoted)
+	default:
+		return status, nil, fmt.Errorf("unrecognized migration table layout")
+	}
+
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		return status, nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version sql.NullString
+		var flag sql.NullBool
+		if err := rows.Scan(&version, &flag); err != nil {
+			return status, nil, err
+		}
+		v, err := strconv.ParseInt(strings.TrimSpace(version.String), 10, 64)
+		if err != nil {
+			return status, nil, fmt.Errorf("invalid migration version %q: %w", version.String, err)
+		}
+		if status.Tool == "golang-migrate" {
+			status.Dirty = flag.Bool
+			applied[v] = true
+			continue
+		}
+		if flag.Bool {
+			applied[v] = true
+		} else {
+			delete(applied, v)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return status, nil, err
+	}
+
+	for v := range applied {
+		if v == 0 && status.Tool == "goose" {
+			continue // goose seeds version 0 on creation
+		}
+		status.AppliedCount++
+		if status.CurrentVersion == nil || v > *status.CurrentVersion {
+			current := v
+			status.CurrentVersion = &current
+		}
+	}
+	return status, applied, nil
+}
+
+// pendingMigrations returns the versions found on disk that have not been applied.
+// golang-migrate only records the latest version, so everything above it is pending.
+func pendingMigrations(fileVersions []int64, applied map[int64]bool, tool string) []int64 {
+	var current int64 = -1
+	for v := range applied {
+		if v > current {
+			current = v
+		}
+	}
+
+	var pending []int64
+	for _, v := range fileVersions {
+		if tool == "golang-migrate" {
+			if v > current {
+				pending = append(pending, v)
+			}
+		} else if !applied[v] {
+			pending = append(pending, v)
+		}
+	}
+	return pending
+}
+
+// readMigrationVersions collects the distinct migration versions from the file names in dir.
+func readMigrationVersions(dir string) ([]int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool)
+	var versions []int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(filepath.Base(entry.Name()))
+		if match == nil || strings.HasSuffix(entry.Name(), ".down.sql") {
+			continue
+		}
+		v, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || seen[v] {
+			continue
+		}
+		seen[v] = true
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions, nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mountSchema is the name under which the mounted file tables are attached to every connection.
+const mountSchema = "mounts"
+
+// FileMount describes a CSV or JSONL file exposed as a table.
+type FileMount struct {
+	Table string
+	Path  string
+}
+
+// parseFileMounts parses MOUNT_FILES, a comma separated list of table=path pairs,
+// e.g. "regions=/data/regions.csv,events=/data/events.jsonl".
+func parseFileMounts(value string) ([]FileMount, error) {
+	var mounts []FileMount
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		table, path, ok := strings.Cut(pair, "=")
+		table, path = strings.TrimSpace(table), strings.TrimSpace(path)
+		if !ok || table == "" || path == "" {
+			return nil, fmt.Errorf("invalid file mount %q, expected table=path", pair)
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv", ".jsonl", ".ndjson":
+		default:
+			return nil, fmt.Errorf("unsupported file type for mount %q (expected .csv, .jsonl or .ndjson)", path)
+		}
+		mounts = append(mounts, FileMount{Table: table, Path: path})
+	}
+	return mounts, nil
+}
+
+// buildMountDatabase loads the mounted files into a scratch SQLite database and
+// returns its path. The caller attaches it to each connection and removes it on shutdown.
+func buildMountDatabase(mounts []FileMount) (string, error) {
+	f, err := os.CreateTemp("", "db-mcp-mounts-*.db")
+	if err != nil {
+		return "", fmt.Errorf("failed to create mount database: %w", err)
+	}
+	path := f.Name()
+	f.Close()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to open mount database: %w", err)
+	}
+	defer db.Close()
+
+	for _, m := range mounts {
+		columns, rows, err := readMountFile(m.Path)
+		if err != nil {
+			os.Remove(path)
+			return "", fmt.Errorf("failed to read %s: %w", m.Path, err)
+		}
+		if err := loadMountTable(db, m.Table, columns, rows); err != nil {
+			os.Remove(path)
+			return "", fmt.Errorf("failed to load %s into table %s: %w", m.Path, m.Table, err)
+		}
+		log.Printf("Mounted %s as table %s.%s (%d rows)", m.Path, mountSchema, m.Table, len(rows))
+	}
+	return path, nil
+}
+
+// attachMountStatement returns the statement that attaches the mount database to a connection.
+func attachMountStatement(path string, encrypted bool) string {
+	stmt := fmt.Sprintf("ATTACH DATABASE '%s' AS %s", strings.ReplaceAll(path, "'", "''"), mountSchema)
+	if encrypted {
+		stmt += " KEY ''" // The mounts are plain files; SQLCipher would apply the main key otherwise
+	}
+	return stmt
+}
+
+// readMountFile reads a CSV (header row required) or JSONL file into column names and row values.
+func readMountFile(path string) ([]string, [][]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readCSV(f)
+	}
+	return readJSONL(f)
+}
+
+func readCSV(r io.Reader) ([]string, [][]interface{}, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	var rows [][]interface{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		row := make([]interface{}, len(header))
+		for i := range header {
+			if i < len(record) && record[i] != "" {
+				row[i] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return header, rows, nil
+}
+
+func readJSONL(r io.Reader) ([]string, [][]interface{}, error) {
+	var columns []string
+	index := make(map[string]int)
+	var objects []map[string]interface{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			if _, ok := index[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys) // Map order is random; keep the column order stable
+		for _, key := range keys {
+			index[key] = len(columns)
+			columns = append(columns, key)
+		}
+		objects = append(objects, obj)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([][]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		row := make([]interface{}, len(columns))
+		for key, val := range obj {
+			switch v := val.(type) {
+			case nil, string, bool:
+				row[index[key]] = v
+			case json.Number:
+				row[index[key]] = v.String()
+			default:
+				// Nested arrays/objects are stored as JSON text so json_extract() works on them
+				encoded, _ := json.Marshal(v)
+				row[index[key]] = string(encoded)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
+}
+
+// inferColumnType picks INTEGER or REAL when every non-NULL value parses as such, TEXT otherwise.
+func inferColumnType(rows [][]interface{}, col int) string {
+	colType := "INTEGER"
+	seen := false
+	for _, row := range rows {
+		s, ok := row[col].(string)
+		if !ok {
+			if row[col] != nil {
+				return "TEXT"
+			}
+			continue
+		}
+		seen = true
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			continue
+		}
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			colType = "REAL"
+			continue
+		}
+		return "TEXT"
+	}
+	if !seen {
+		return "TEXT"
+	}
+	return colType
+}
+
+// loadMountTable creates the table and inserts all rows in one transaction.
+func loadMountTable(db *sql.DB, table string, columns []string, rows [][]interface{}) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns found")
+	}
+
+	quote := func(name string) string { return `"` + strings.ReplaceAll(name, `"`, `""`) + `"` }
+	defs := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, col := range columns {
+		defs[i] = quote(col) + " " + inferColumnType(rows, i)
+		placeholders[i] = "?"
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", quote(table), strings.Join(defs, ", "))); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (%s)", quote(table), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
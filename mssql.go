@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// mssqlDialect describes SQL Server databases: INFORMATION_SCHEMA for tables,
+// columns and constraints, sys.indexes for indexes.
+type mssqlDialect struct {
+	infoSchemaDialect
+}
+
+func newMSSQLDialect(db *sql.DB, dsn string) Dialect {
+	return mssqlDialect{infoSchemaDialect{
+		db:            db,
+		param:         func(n int) string { return fmt.Sprintf("@p%d", n) },
+		quote:         func(name string) string { return "[" + strings.ReplaceAll(name, "]", "]]") + "]" },
+		currentSchema: "SCHEMA_NAME()",
+		systemSchemas: []string{"sys", "INFORMATION_SCHEMA", "guest"},
+		constraints:   true,
+	}}
+}
+
+// DescribeTable adds the indexes from sys.indexes to the INFORMATION_SCHEMA description.
+func (d mssqlDialect) DescribeTable(ctx context.Context, table string) (*TableDescription, error) {
+	desc, err := d.infoSchemaDialect.DescribeTable(ctx, table)
+	if err != nil || len(desc.Columns) == 0 {
+		return desc, err
+	}
+	if desc.Indexes, err = d.ListIndexes(ctx, table); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+func (d mssqlDialect) ListIndexes(ctx context.Context, table string) ([]IndexInfo, error) {
+	schema, name := d.SplitTable(table)
+	object := d.QuoteIdent(name)
+	if schema != "" {
+		object = d.QuoteIdent(schema) + "." + object
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT i.name, i.is_unique, i.is_primary_key, i.is_unique_constraint, i.has_filter, c.name
+		FROM sys.indexes AS i
+		JOIN sys.index_columns AS ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id AND ic.is_included_column = 0
+		JOIN sys.columns AS c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+		WHERE i.object_id = OBJECT_ID(@p1) AND i.name IS NOT NULL
+		ORDER BY i.name, ic.key_ordinal`, object)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := []IndexInfo{}
+	for rows.Next() {
+		var idx IndexInfo
+		var primary, uniqueConstraint bool
+		var column string
+		if err := rows.Scan(&idx.Name, &idx.Unique, &primary, &uniqueConstraint, &idx.Partial, &column); err != nil {
+			return nil, err
+		}
+		if len(indexes) == 0 || indexes[len(indexes)-1].Name != idx.Name {
+			switch {
+			case primary:
+				idx.Origin = "primary_key"
+			case uniqueConstraint:
+				idx.Origin = "unique"
+			default:
+				idx.Origin = "index"
+			}
+			indexes = append(indexes, idx)
+		}
+		last := &indexes[len(indexes)-1]
+		last.Columns = append(last.Columns, column)
+	}
+	return indexes, rows.Err()
+}
+
+// mssqlSelectInto matches SELECT ... INTO, which creates a table.
+var mssqlSelectInto = regexp.MustCompile(`(?i)\bINTO\s`)
+
+// ValidateReadOnly accepts SELECT statements that do not write their result
+// into a table. WITH is refused because T-SQL allows it before DML.
+func (d mssqlDialect) ValidateReadOnly(query string) error {
+	if err := requireSelect(query); err != nil {
+		return err
+	}
+	if mssqlSelectInto.MatchString(query) {
+		return fmt.Errorf("SELECT ... INTO is not allowed for read-only access")
+	}
+	return nil
+}
+
+// mssqlSelectPrefix matches the start of a SELECT up to where TOP goes.
+var mssqlSelectPrefix = regexp.MustCompile(`(?is)^\s*SELECT(\s+(DISTINCT|ALL))?\s+`)
+
+// mssqlHasLimit matches queries that already limit their rows.
+var mssqlHasLimit = regexp.MustCompile(`(?is)^\s*SELECT(\s+(DISTINCT|ALL))?\s+TOP\b|\bOFFSET\s+\S+\s+ROWS?\b`)
+
+// LimitQuery caps a SELECT at n rows with TOP, since SQL Server has no LIMIT.
+// Queries that already use TOP or OFFSET ... FETCH are left alone.
+func (d mssqlDialect) LimitQuery(query string, n int) string {
+	loc := mssqlSelectPrefix.FindStringIndex(query)
+	if loc == nil || mssqlHasLimit.MatchString(query) {
+		return query
+	}
+	return query[:loc[1]] + fmt.Sprintf("TOP (%d) ", n) + query[loc[1]:]
+}
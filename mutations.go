@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// In write mode (WRITE_MODE=true) the row mutation tools change a local SQLite
+// database through a second, read-write connection pool. Every other tool
+// keeps using the read-only pool. Statements are built server-side from
+// validated table and column names, and values are always bound as
+// parameters.
+
+// writeConnInit is run on every connection of the write pool.
+var writeConnInit = []string{"PRAGMA foreign_keys = ON", "PRAGMA busy_timeout = 5000"}
+
+// defaultMutationMaxRows is how many rows update_row and delete_row may
+// change unless max_rows says otherwise.
+const defaultMutationMaxRows = 1
+
+// writeDSN turns a database file path into a SQLite URI that opens it read-write.
+func writeDSN(path string) string {
+	return "file:" + sqliteURIEscaper.Replace(path) + "?mode=rw"
+}
+
+// openWriteDB opens the read-write pool used by the mutation tools. Writers
+// are serialized on a single connection.
+func openWriteDB(path string, connInit, keys []string) (*sql.DB, error) {
+	probe, err := sql.Open("sqlite", writeDSN(path))
+	if err != nil {
+		return nil, err
+	}
+	connector := &initConnector{driver: probe.Driver(), dsn: writeDSN(path), init: append(append([]string{}, connInit...), writeConnInit...), keys: keys}
+	probe.Close()
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(1)
+	if err := pingDatabase(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// MutationResult reports the statement a mutation tool executed.
+type MutationResult struct {
+	Statement    string `json:"statement"`
+	RowsAffected int64  `json:"rows_affected"`
+	LastInsertID *int64 `json:"last_insert_id,omitempty"`
+}
+
+// mutationTable resolves the table argument and returns it quoted, with the
+// set of its column names.
+func (ds *DatabaseService) mutationTable(ctx context.Context, args map[string]interface{}) (string, map[string]bool, *mcp.CallToolResult) {
+	table, _ := args["table"].(string)
+	if table == "" {
+		return "", nil, mcp.NewToolResultError("Missing or invalid 'table' argument.")
+	}
+	resolved, err := ds.resolveTable(ctx, table)
+	if err != nil {
+		return "", nil, tableErrorResult(table, err)
+	}
+	if schema, _ := ds.dialect.SplitTable(resolved); schema == mountSchema {
+		return "", nil, mcp.NewToolResultError(fmt.Sprintf("Table '%s' is a mounted file and cannot be modified.", resolved))
+	}
+	desc, err := ds.dialect.DescribeTable(ctx, resolved)
+	if err != nil {
+		log.Printf("Error describing table %s: %v", resolved, err)
+		return "", nil, mcp.NewToolResultErrorFromErr("Error describing table", err)
+	}
+	columns := make(map[string]bool, len(desc.Columns))
+	for _, col := range desc.Columns {
+		if name, ok := col["name"].(string); ok {
+			columns[name] = true
+		}
+	}
+	return ds.quoteTable(resolved), columns, nil
+}
+
+// columnValues validates a column/value map argument against the table's
+// columns and returns the columns in a stable order with their values.
+func columnValues(args map[string]interface{}, name string, columns map[string]bool) ([]string, []interface{}, error) {
+	m, ok := args[name].(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return nil, nil, fmt.Errorf("missing or empty '%s' argument; pass an object mapping column names to values", name)
+	}
+	names := make([]string, 0, len(m))
+	for column := range m {
+		if !columns[column] {
+			return nil, nil, fmt.Errorf("unknown column '%s' in '%s'", column, name)
+		}
+		names = append(names, column)
+	}
+	sort.Strings(names)
+	values := make([]interface{}, len(names))
+	for i, column := range names {
+		values[i] = bindValue(m[column])
+	}
+	return names, values, nil
+}
+
+// bindValue converts a JSON argument value into a SQLite parameter. Whole
+// numbers bind as integers; objects and arrays are stored as JSON text.
+func bindValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return v
+}
+
+// whereEquals builds an AND of column equality conditions; nil values match NULL.
+func whereEquals(names []string, values []interface{}) (string, []interface{}) {
+	conditions := make([]string, len(names))
+	var args []interface{}
+	for i, column := range names {
+		if values[i] == nil {
+			conditions[i] = quoteIdentifier(column) + " IS NULL"
+			continue
+		}
+		conditions[i] = quoteIdentifier(column) + " = ?"
+		args = append(args, values[i])
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// insertRowHandler inserts one row from a column/value map.
+func (ds *DatabaseService) insertRowHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	table, columns, errResult := ds.mutationTable(ctx, args)
+	if errResult != nil {
+		return errResult, nil
+	}
+	names, values, err := columnValues(args, "values", columns)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	quoted := make([]string, len(names))
+	for i, column := range names {
+		quoted[i] = quoteIdentifier(column)
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(quoted, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", "))
+	return ds.execMutation(ctx, stmt, values, 1, true)
+}
+
+// updateRowHandler sets columns on the rows matching a mandatory where map.
+func (ds *DatabaseService) updateRowHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	table, columns, errResult := ds.mutationTable(ctx, args)
+	if errResult != nil {
+		return errResult, nil
+	}
+	names, values, err := columnValues(args, "values", columns)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	whereNames, whereValues, err := columnValues(args, "where", columns)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	assignments := make([]string, len(names))
+	for i, column := range names {
+		assignments[i] = quoteIdentifier(column) + " = ?"
+	}
+	where, whereArgs := whereEquals(whereNames, whereValues)
+	stmt := fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(assignments, ", "), where)
+	return ds.execMutation(ctx, stmt, append(values, whereArgs...), mutationMaxRows(args), false)
+}
+
+// deleteRowHandler deletes the rows matching a mandatory where map.
+func (ds *DatabaseService) deleteRowHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	table, columns, errResult := ds.mutationTable(ctx, args)
+	if errResult != nil {
+		return errResult, nil
+	}
+	whereNames, whereValues, err := columnValues(args, "where", columns)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	where, whereArgs := whereEquals(whereNames, whereValues)
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE %s", table, where)
+	return ds.execMutation(ctx, stmt, whereArgs, mutationMaxRows(args), false)
+}
+
+// mutationMaxRows reads the optional max_rows argument.
+func mutationMaxRows(args map[string]interface{}) int64 {
+	if v, ok := args["max_rows"].(float64); ok && v >= 1 {
+		return int64(v)
+	}
+	return defaultMutationMaxRows
+}
+
+// execMutation runs stmt in a transaction on the write pool, rolling back if
+// it changes more than maxRows rows.
+func (ds *DatabaseService) execMutation(ctx context.Context, stmt string, args []interface{}, maxRows int64, insert bool) (*mcp.CallToolResult, error) {
+	tx, err := ds.writeDB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Error starting write transaction: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error starting write transaction", err), nil
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		log.Printf("Error executing mutation: %v, Statement: %s", err, logSQL(stmt))
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error executing statement", err), nil
+	}
+	result := MutationResult{Statement: stmt}
+	if result.RowsAffected, err = res.RowsAffected(); err != nil {
+		return mcp.NewToolResultErrorFromErr("Error reading affected rows", err), nil
+	}
+	if result.RowsAffected > maxRows {
+		log.Printf("Rolled back mutation changing %d rows (max %d): %s", result.RowsAffected, maxRows, logSQL(stmt))
+		payload, _ := json.MarshalIndent(map[string]string{
+			"error":   "too_many_rows",
+			"message": fmt.Sprintf("The statement would change %d rows, more than max_rows (%d); nothing was changed.", result.RowsAffected, maxRows),
+			"hint":    "Narrow the 'where' conditions, e.g. to the primary key, or raise max_rows if every matching row should change.",
+		}, "", "  ")
+		return mcp.NewToolResultError(string(payload)), nil
+	}
+	if insert {
+		if id, err := res.LastInsertId(); err == nil {
+			result.LastInsertID = &id
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing mutation: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error committing changes", err), nil
+	}
+	log.Printf("Changed %d rows: %s", result.RowsAffected, logSQL(stmt))
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling mutation result to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting result", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// orphanExamples is the number of example orphaned rows returned per relationship.
+const orphanExamples = 5
+
+// Relationship is a child → parent reference between tables.
+type Relationship struct {
+	ChildTable    string   `json:"child_table"`
+	ChildColumns  []string `json:"child_columns"`
+	ParentTable   string   `json:"parent_table"`
+	ParentColumns []string `json:"parent_columns"`
+	Declared      bool     `json:"declared"` // Taken from a FOREIGN KEY constraint
+}
+
+// OrphanReport lists the child rows of a relationship whose parent is missing.
+type OrphanReport struct {
+	Relationship
+	OrphanCount int64                    `json:"orphan_count"`
+	Examples    []map[string]interface{} `json:"examples"`
+}
+
+// findOrphans counts the child rows with a non-NULL reference that matches no parent row.
+func (ds *DatabaseService) findOrphans(ctx context.Context, rel Relationship) (OrphanReport, error) {
+	report := OrphanReport{Relationship: rel, Examples: []map[string]interface{}{}}
+
+	var notNull, joins []string
+	for i, child := range rel.ChildColumns {
+		notNull = append(notNull, "c."+quoteIdentifier(child)+" IS NOT NULL")
+		joins = append(joins, "p."+quoteIdentifier(rel.ParentColumns[i])+" = c."+quoteIdentifier(child))
+	}
+	where := fmt.Sprintf("%s AND NOT EXISTS (SELECT 1 FROM %s AS p WHERE %s)",
+		strings.Join(notNull, " AND "), ds.quoteTable(rel.ParentTable), strings.Join(joins, " AND "))
+	child := ds.quoteTable(rel.ChildTable)
+
+	if err := ds.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s AS c WHERE %s", child, where)).Scan(&report.OrphanCount); err != nil {
+		return report, err
+	}
+	if report.OrphanCount == 0 {
+		return report, nil
+	}
+
+	rows, err := ds.db.QueryContext(ctx, fmt.Sprintf("SELECT c.* FROM %s AS c WHERE %s LIMIT %d", child, where, orphanExamples))
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+	report.Examples, err = scanRowMaps(rows)
+	maskRowMaps(ctx, report.Examples)
+	return report, err
+}
+
+// findOrphansHandler reports child rows whose parent row is missing, either for
+// an explicit relationship or for every foreign key declared on the child table.
+func (ds *DatabaseService) findOrphansHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	childTable, _ := args["child_table"].(string)
+	if childTable == "" {
+		return mcp.NewToolResultError("Missing or invalid 'child_table' argument."), nil
+	}
+	parentTable, _ := args["parent_table"].(string)
+	for _, table := range []*string{&childTable, &parentTable} {
+		if *table == "" {
+			continue
+		}
+		resolved, err := ds.resolveTable(ctx, *table)
+		if err != nil {
+			return tableErrorResult(*table, err), nil
+		}
+		*table = resolved
+	}
+	childColumns := request.GetStringSlice("child_columns", nil)
+	parentColumns := request.GetStringSlice("parent_columns", nil)
+
+	var relationships []Relationship
+	if parentTable == "" {
+		if len(childColumns) > 0 || len(parentColumns) > 0 {
+			return mcp.NewToolResultError("'child_columns' and 'parent_columns' need a 'parent_table'."), nil
+		}
+		var err error
+		relationships, err = ds.dialect.ForeignKeys(ctx, childTable)
+		if err != nil {
+			log.Printf("Error reading foreign keys of %s: %v", childTable, err)
+			return mcp.NewToolResultErrorFromErr(fmt.Sprintf("Error reading foreign keys of '%s'", childTable), err), nil
+		}
+		if len(relationships) == 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("Table '%s' declares no foreign keys; pass parent_table, child_columns and parent_columns.", childTable)), nil
+		}
+	} else {
+		if len(childColumns) == 0 || slices.Contains(childColumns, "") {
+			return mcp.NewToolResultError("Missing or invalid 'child_columns' argument."), nil
+		}
+		if slices.Contains(parentColumns, "") {
+			return mcp.NewToolResultError("Invalid 'parent_columns' argument."), nil
+		}
+		var err error
+		if childColumns, err = ds.resolveColumns(ctx, ds.quoteTable(childTable), childColumns...); err != nil {
+			return columnErrorResult(err), nil
+		}
+		if parentColumns, err = ds.resolveColumns(ctx, ds.quoteTable(parentTable), parentColumns...); err != nil {
+			return columnErrorResult(err), nil
+		}
+		if len(parentColumns) == 0 {
+			pk, err := ds.dialect.PrimaryKey(ctx, parentTable)
+			if err != nil {
+				log.Printf("Error reading primary key of %s: %v", parentTable, err)
+				return mcp.NewToolResultErrorFromErr(fmt.Sprintf("Error reading primary key of '%s'", parentTable), err), nil
+			}
+			parentColumns = pk
+		}
+		if len(parentColumns) != len(childColumns) {
+			return mcp.NewToolResultError("'child_columns' and 'parent_columns' must have the same number of columns."), nil
+		}
+		relationships = []Relationship{{ChildTable: childTable, ChildColumns: childColumns, ParentTable: parentTable, ParentColumns: parentColumns}}
+	}
+
+	reports := make([]OrphanReport, 0, len(relationships))
+	for _, rel := range relationships {
+		if len(rel.ParentColumns) != len(rel.ChildColumns) {
+			continue // Parent without a usable key
+		}
+		report, err := ds.findOrphans(ctx, rel)
+		if err != nil {
+			log.Printf("Error checking %s → %s: %v", rel.ChildTable, rel.ParentTable, err)
+			if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+				return result, nil
+			}
+			return mcp.NewToolResultErrorFromErr(fmt.Sprintf("Error checking references from '%s' to '%s'", rel.ChildTable, rel.ParentTable), err), nil
+		}
+		addRowsReturned(ctx, len(report.Examples))
+		reports = append(reports, report)
+	}
+
+	resultJSON, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling orphan report to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting orphan report", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// The tools below declare an output schema, and their results carry
+// structured content derived from the final text result, after masking and
+// redaction, so typed clients need not parse the text.
+
+// outputSchemas are the JSON Schemas of the structured results of tools.
+var outputSchemas = map[string]map[string]any{
+	"read_query": rowsOutputSchema,
+	"rerun_last": rowsOutputSchema,
+	"list_tables": {
+		"type": "object",
+		"properties": map[string]any{
+			"tables": map[string]any{
+				"type":        "array",
+				"description": "Table names, or with include_row_counts objects with the name, rows and whether the count is exact",
+				"items": map[string]any{"oneOf": []any{
+					map[string]any{"type": "string"},
+					map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"name":  map[string]any{"type": "string"},
+							"rows":  map[string]any{"type": []string{"integer", "null"}},
+							"exact": map[string]any{"type": "boolean"},
+						},
+						"required": []string{"name", "rows", "exact"},
+					},
+				}},
+			},
+			"total":       map[string]any{"type": "integer", "description": "Tables matching the pattern, on every page"},
+			"next_offset": map[string]any{"type": "integer", "description": "Offset of the next page; absent on the last page"},
+		},
+		"required": []string{"tables", "total"},
+	},
+	"describe_table": {
+		"type": "object",
+		"properties": map[string]any{
+			"table": map[string]any{"type": "string"},
+			"columns": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"name":          map[string]any{"type": "string"},
+						"type":          map[string]any{"type": "string"},
+						"sample_values": map[string]any{"type": "array"},
+					},
+					"required": []string{"name", "type"},
+				},
+			},
+			"primary_key":        map[string]any{"type": []string{"array", "null"}, "items": map[string]any{"type": "string"}},
+			"foreign_keys":       map[string]any{"type": []string{"array", "null"}, "items": map[string]any{"type": "object"}},
+			"indexes":            map[string]any{"type": []string{"array", "null"}, "items": map[string]any{"type": "object"}},
+			"unique_constraints": map[string]any{"type": []string{"array", "null"}},
+			"check_constraints":  map[string]any{"type": []string{"array", "null"}, "items": map[string]any{"type": "string"}},
+			"without_rowid":      map[string]any{"type": "boolean"},
+			"strict":             map[string]any{"type": "boolean"},
+			"engine":             map[string]any{"type": "string"},
+		},
+		"required": []string{"table", "columns"},
+	},
+}
+
+// rowsOutputSchema describes the structured result of a query.
+var rowsOutputSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"rows": map[string]any{
+			"type":        "array",
+			"description": "Result rows, as objects keyed by column name",
+			"items":       map[string]any{"type": "object"},
+		},
+		"row_count": map[string]any{"type": "integer", "description": "Rows included"},
+		"columns": map[string]any{
+			"type":        "array",
+			"description": "Declared column types, with column_types",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name": map[string]any{"type": "string"},
+					"type": map[string]any{"type": "string"},
+				},
+				"required": []string{"name", "type"},
+			},
+		},
+		"truncated": map[string]any{"type": "boolean", "description": "Only part of the result's rows are included"},
+		"result_id": map[string]any{"type": "string", "description": "Stored full result, to page through with fetch_result"},
+	},
+	"required": []string{"rows", "row_count"},
+}
+
+// withOutputSchema declares the output schema of a tool that has one.
+func withOutputSchema(tool mcp.Tool) mcp.Tool {
+	if schema := outputSchemas[tool.Name]; schema != nil {
+		tool.RawOutputSchema, _ = json.Marshal(schema)
+	}
+	return tool
+}
+
+// structuredOutputMiddleware derives the structured result of calls to the
+// tools with an output schema. It runs outside the middlewares that mask and
+// redact results, so both forms of the result hold the same values.
+func structuredOutputMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err != nil || result == nil || result.IsError {
+			return result, err
+		}
+		switch request.Params.Name {
+		case "read_query", "rerun_last":
+			result.StructuredContent = structuredRows(result)
+		case "list_tables":
+			result.StructuredContent = structuredTables(result)
+		case "describe_table":
+			var description map[string]any
+			if json.Unmarshal([]byte(resultText(result)), &description) == nil {
+				result.StructuredContent = description
+			}
+		}
+		return result, err
+	}
+}
+
+// structuredRows parses the rows of a JSON or JSON Lines query result. A
+// result spilled to the result store keeps the rows of its preview; HTML
+// results and results truncated mid-row have no structured form.
+func structuredRows(result *mcp.CallToolResult) any {
+	if len(result.Content) == 0 {
+		return nil
+	}
+	content := map[string]any{}
+	if len(result.Content) > 1 {
+		// With column_types, the declared types come first
+		var columns struct {
+			Columns []ColumnInfo `json:"columns"`
+		}
+		if json.Unmarshal([]byte(resultText(result)), &columns) != nil {
+			return nil
+		}
+		content["columns"] = columns.Columns
+	}
+	text, _ := result.Content[len(result.Content)-1].(mcp.TextContent)
+
+	// The rows come first, before any note about a spilled result
+	rows := []any{}
+	decoder := json.NewDecoder(strings.NewReader(text.Text))
+	decoder.UseNumber() // Keep 64-bit integers exact
+	if strings.HasPrefix(text.Text, "[") {
+		if decoder.Decode(&rows) != nil {
+			return nil
+		}
+	} else {
+		for decoder.More() {
+			var row map[string]any
+			if decoder.Decode(&row) != nil {
+				break
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) == 0 && strings.TrimSpace(text.Text) != "" {
+			return nil
+		}
+	}
+	content["rows"] = rows
+	content["row_count"] = len(rows)
+	meta := metaFields(result)
+	if code, _ := meta["code"].(string); code == codeResultTruncated {
+		content["truncated"] = true
+	}
+	if id, ok := meta["result_id"].(string); ok {
+		content["result_id"] = id
+	}
+	return content
+}
+
+// structuredTables parses a list_tables result, with the paging details the
+// handler puts in its metadata.
+func structuredTables(result *mcp.CallToolResult) any {
+	var tables []any
+	if json.NewDecoder(strings.NewReader(resultText(result))).Decode(&tables) != nil {
+		return nil
+	}
+	meta := metaFields(result)
+	content := map[string]any{"tables": tables, "total": meta["total"]}
+	if next, ok := meta["next_offset"]; ok {
+		content["next_offset"] = next
+	}
+	return content
+}
+
+// metaFields returns the metadata handlers put in a result.
+func metaFields(result *mcp.CallToolResult) map[string]any {
+	if result.Meta == nil {
+		return nil
+	}
+	return result.Meta.AdditionalFields
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultPageSize is the number of rows paginate returns per page by default.
+const defaultPageSize = 100
+
+// pageCursor is the decoded form of a paginate continuation token: the key
+// values of the last row returned and a fingerprint of the source and keys
+// it belongs to.
+type pageCursor struct {
+	Source string        `json:"s"`
+	After  []interface{} `json:"a"`
+}
+
+// Page is the payload returned by paginate.
+type Page struct {
+	Columns    []string                 `json:"columns"`
+	Rows       []map[string]interface{} `json:"rows"`
+	NextCursor string                   `json:"next_cursor,omitempty"` // Pass as cursor for the next page; absent on the last page
+}
+
+// paginateHandler returns one page of a table or base SELECT ordered by key
+// columns, seeking past the last row of the previous page instead of using
+// OFFSET, so every page costs the same however deep it is.
+func (ds *DatabaseService) paginateHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	source, err := ds.sourceRelation(ctx, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	keys := request.GetStringSlice("keys", nil)
+	if len(keys) == 0 {
+		return mcp.NewToolResultError("Missing or invalid 'keys' argument."), nil
+	}
+	descending, _ := args["descending"].(bool)
+	pageSize := defaultPageSize
+	if v, ok := args["page_size"].(float64); ok && v >= 1 {
+		pageSize = int(min(v, 1<<20))
+	}
+	limits := ds.limitsFor(ctx)
+	if limits.MaxRows > 0 && pageSize > limits.MaxRows {
+		pageSize = limits.MaxRows
+	}
+
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = quoteIdentifier(key)
+	}
+	fingerprint := sha256.Sum256([]byte(source + "\x00" + strings.Join(quoted, ",") + fmt.Sprint(descending)))
+	sourceID := hex.EncodeToString(fingerprint[:8])
+
+	var where string
+	if token, _ := args["cursor"].(string); token != "" {
+		cursor, err := decodePageCursor(token)
+		if err != nil || cursor.Source != sourceID || len(cursor.After) != len(keys) {
+			return mcp.NewToolResultError("Invalid 'cursor' argument: pass the next_cursor of a previous page of the same source and keys."), nil
+		}
+		if where, err = seekPredicate(quoted, cursor.After, descending); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	order := make([]string, len(quoted))
+	for i, key := range quoted {
+		order[i] = key
+		if descending {
+			order[i] += " DESC"
+		}
+	}
+	query := "SELECT * FROM " + source + where + " ORDER BY " + strings.Join(order, ", ")
+	// One extra row tells whether there is a next page
+	if limiter, ok := ds.dialect.(rowLimiter); ok {
+		query = limiter.LimitQuery(query, pageSize+1)
+	} else {
+		query += fmt.Sprintf(" LIMIT %d", pageSize+1)
+	}
+
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("Error executing page query: %v, Query: %s", err, logSQL(query))
+		if result := limits.budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error executing query", err), nil
+	}
+	defer rows.Close()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("Error reading columns", err), nil
+	}
+	page := Page{Columns: make([]string, len(columnTypes)), Rows: []map[string]interface{}{}}
+	for i, ct := range columnTypes {
+		page.Columns[i] = ct.Name()
+	}
+	keyIndexes := make([]int, len(keys))
+	for i, key := range keys {
+		keyIndexes[i] = -1
+		for j, column := range page.Columns {
+			if strings.EqualFold(column, key) {
+				keyIndexes[i] = j
+				break
+			}
+		}
+		if keyIndexes[i] < 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("Key column %q is not a column of the result.", key)), nil
+		}
+	}
+
+	masks := masksFor(ctx, page.Columns)
+	values := make([]interface{}, len(columnTypes))
+	valuePtrs := make([]interface{}, len(columnTypes))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	var last []interface{}
+	more := false
+	for rows.Next() {
+		if len(page.Rows) == pageSize {
+			more = true
+			break
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return mcp.NewToolResultErrorFromErr("Error reading result row", err), nil
+		}
+		row := make(map[string]interface{}, len(columnTypes))
+		for i, column := range page.Columns {
+			row[column] = normalizeValue(values[i], columnTypes[i].DatabaseTypeName())
+		}
+		// The cursor keeps the real key values, so keys are masked after
+		last = make([]interface{}, len(keys))
+		for i, j := range keyIndexes {
+			last[i] = row[page.Columns[j]]
+		}
+		for i, column := range page.Columns {
+			row[column] = masks.apply(i, row[column])
+		}
+		page.Rows = append(page.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating page rows: %v", err)
+		if result := limits.budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error iterating through results", err), nil
+	}
+	addRowsReturned(ctx, len(page.Rows))
+	if more {
+		if page.NextCursor, err = encodePageCursor(pageCursor{Source: sourceID, After: last}); err != nil {
+			return mcp.NewToolResultErrorFromErr("Error encoding cursor", err), nil
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling page to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting page", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// seekPredicate returns a WHERE clause selecting the rows after the key
+// values in key order, written out as (a > x) OR (a = x AND b > y) ... since
+// not every database compares row values.
+func seekPredicate(keys []string, after []interface{}, descending bool) (string, error) {
+	op := " > "
+	if descending {
+		op = " < "
+	}
+	literals := make([]string, len(after))
+	for i, v := range after {
+		if v == nil {
+			return "", fmt.Errorf("key column %s is NULL in the last row; keyset pagination needs non-NULL keys", keys[i])
+		}
+		var err error
+		if n, ok := v.(json.Number); ok {
+			literals[i] = n.String()
+		} else if literals[i], err = sqlLiteral(v); err != nil {
+			return "", fmt.Errorf("key column %s has a value that cannot be compared", keys[i])
+		}
+	}
+	terms := make([]string, len(keys))
+	for i := range keys {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, keys[j]+" = "+literals[j])
+		}
+		parts = append(parts, keys[i]+op+literals[i])
+		terms[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return " WHERE " + strings.Join(terms, " OR "), nil
+}
+
+func encodePageCursor(cursor pageCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data), err
+}
+
+// decodePageCursor reads a continuation token, keeping numbers exact.
+func decodePageCursor(token string) (pageCursor, error) {
+	var cursor pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return cursor, decoder.Decode(&cursor)
+}
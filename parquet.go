@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Parquet enum values used by writeParquet, as numbered in the format's
+// Thrift definitions.
+const (
+	parquetBoolean   = 0 // Type
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+	parquetOptional  = 1 // FieldRepetitionType
+	parquetUTF8      = 0 // ConvertedType
+	parquetPlain     = 0 // Encoding
+	parquetRLE       = 3
+	parquetDataPage  = 0 // PageType
+)
+
+// Thrift compact protocol type codes.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// writeParquet writes a result as an uncompressed Parquet file with a single
+// row group holding one PLAIN-encoded page per column. values holds the
+// values of each column in row order. Every column is optional; its type is
+// INT64, DOUBLE or BOOLEAN when all its values are, and UTF-8 text otherwise,
+// since SQLite columns may mix types.
+func writeParquet(w io.Writer, columns []string, values [][]interface{}, rows int) error {
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+
+	var footer thriftWriter
+	footer.begin()
+	footer.i32(1, 1) // version
+	footer.list(2, thriftStruct, len(columns)+1)
+	footer.begin()
+	footer.str(4, "schema")
+	footer.i32(5, int32(len(columns)))
+	footer.end()
+	types := make([]int32, len(columns))
+	names := parquetNames(columns)
+	for i, name := range names {
+		types[i] = parquetType(values[i])
+		footer.begin()
+		footer.i32(1, types[i])
+		footer.i32(3, parquetOptional)
+		footer.str(4, name)
+		if types[i] == parquetByteArray {
+			footer.i32(6, parquetUTF8)
+		}
+		footer.end()
+	}
+	footer.i64(3, int64(rows))
+
+	footer.list(4, thriftStruct, min(rows, 1))
+	if rows > 0 {
+		footer.begin()
+		footer.list(1, thriftStruct, len(columns))
+		start := file.Len()
+		for i, name := range names {
+			offset := int64(file.Len())
+			page := parquetPage(values[i], types[i])
+			var header thriftWriter
+			header.begin()
+			header.i32(1, parquetDataPage)
+			header.i32(2, int32(len(page)))
+			header.i32(3, int32(len(page)))
+			header.field(5, thriftStruct)
+			header.begin()
+			header.i32(1, int32(rows))
+			header.i32(2, parquetPlain)
+			header.i32(3, parquetRLE)
+			header.i32(4, parquetRLE)
+			header.end()
+			header.end()
+			file.Write(header.buf.Bytes())
+			file.Write(page)
+			size := int64(file.Len()) - offset
+
+			footer.begin()
+			footer.i64(2, offset)
+			footer.field(3, thriftStruct)
+			footer.begin()
+			footer.i32(1, types[i])
+			footer.list(2, thriftI32, 2)
+			footer.varint(zigzag(parquetPlain))
+			footer.varint(zigzag(parquetRLE))
+			footer.list(3, thriftBinary, 1)
+			footer.varint(uint64(len(name)))
+			footer.buf.WriteString(name)
+			footer.i32(4, 0) // Uncompressed
+			footer.i64(5, int64(rows))
+			footer.i64(6, size)
+			footer.i64(7, size)
+			footer.i64(9, offset)
+			footer.end()
+			footer.end()
+		}
+		footer.i64(2, int64(file.Len()-start))
+		footer.i64(3, int64(rows))
+		footer.end()
+	}
+	footer.str(6, "db-mcp")
+	footer.end()
+
+	file.Write(footer.buf.Bytes())
+	file.Write(binary.LittleEndian.AppendUint32(nil, uint32(footer.buf.Len())))
+	file.WriteString("PAR1")
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// parquetNames makes column names unique, as Parquet schemas require, by
+// numbering repeated names.
+func parquetNames(columns []string) []string {
+	names := make([]string, len(columns))
+	seen := make(map[string]bool)
+	for i, name := range columns {
+		for n := 2; seen[name]; n++ {
+			name = fmt.Sprintf("%s_%d", columns[i], n)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// parquetType picks the physical type of a column from its values.
+func parquetType(values []interface{}) int32 {
+	ints, floats, bools, others := 0, 0, 0, 0
+	for _, v := range values {
+		switch v.(type) {
+		case nil:
+		case int64:
+			ints++
+		case float64:
+			floats++
+		case bool:
+			bools++
+		default:
+			others++
+		}
+	}
+	switch {
+	case others > 0 || bools > 0 && ints+floats > 0:
+		return parquetByteArray
+	case bools > 0:
+		return parquetBoolean
+	case floats > 0:
+		return parquetDouble
+	case ints > 0:
+		return parquetInt64
+	}
+	return parquetByteArray
+}
+
+// parquetPage encodes the definition levels and values of a column for a
+// data page: the levels as one bit-packed run of the RLE hybrid encoding,
+// prefixed by its length, then the non-null values in PLAIN encoding.
+func parquetPage(values []interface{}, typ int32) []byte {
+	levels := make([]byte, (len(values)+7)/8)
+	var bits []bool
+	var data []byte
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		levels[i/8] |= 1 << (i % 8)
+		switch typ {
+		case parquetInt64:
+			data = binary.LittleEndian.AppendUint64(data, uint64(v.(int64)))
+		case parquetDouble:
+			f, ok := v.(float64)
+			if !ok {
+				f = float64(v.(int64))
+			}
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(f))
+		case parquetBoolean:
+			bits = append(bits, v.(bool))
+		default:
+			s, ok := v.(string)
+			if !ok {
+				s = fmt.Sprint(v)
+			}
+			data = binary.LittleEndian.AppendUint32(data, uint32(len(s)))
+			data = append(data, s...)
+		}
+	}
+	if typ == parquetBoolean {
+		data = make([]byte, (len(bits)+7)/8)
+		for i, b := range bits {
+			if b {
+				data[i/8] |= 1 << (i % 8)
+			}
+		}
+	}
+
+	run := binary.AppendUvarint(nil, uint64(len(levels))<<1|1)
+	run = append(run, levels...)
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(run)))
+	page = append(page, run...)
+	return append(page, data...)
+}
+
+// thriftWriter encodes structs in the Thrift compact protocol, which Parquet
+// uses for page headers and the file footer.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // Last field ID written in each open struct
+}
+
+// begin opens a struct: the top-level one, a list element or the value of a
+// field written with field.
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+// end closes the innermost struct.
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+// field writes a field header, as a delta from the previous field ID when
+// it is small.
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) str(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+// list writes the header of a list field; its n elements follow.
+func (t *thriftWriter) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.varint(uint64(n))
+	}
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+// zigzag maps signed integers to unsigned ones with small magnitudes first.
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
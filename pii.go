@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// piiDetector finds one kind of personal data in text.
+type piiDetector struct {
+	kind    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool // Rejects pattern matches that are not the kind; nil accepts all
+}
+
+// piiDetectors are the kinds of personal data PII_REDACTION can act on.
+var piiDetectors = []piiDetector{
+	{kind: "email", pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)},
+	// Card numbers before phones, which would match them too
+	{kind: "card", pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), valid: luhnValid},
+	{kind: "national_id", pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b|\b[A-CEGHJ-PR-TW-Z]{2}\d{6}[A-D]\b`)},
+	{kind: "phone", pattern: regexp.MustCompile(`\+?\(?\d[\d ().-]{7,}\d`), valid: phoneValid},
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum of card numbers.
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// isoDate matches the start of an ISO 8601 date, which with a time can look
+// like a phone number.
+var isoDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+
+// phoneValid accepts 10 to 15 digits written with a leading + or separators,
+// so plain numbers such as IDs and Unix timestamps are left alone.
+func phoneValid(s string) bool {
+	if isoDate.MatchString(s) {
+		return false
+	}
+	digits := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits++
+		}
+	}
+	return digits >= 10 && digits <= 15 && (s[0] == '+' || digits < len(s))
+}
+
+// PIIPolicy is the action taken on each kind of personal data found in
+// result values: redact or hash.
+type PIIPolicy map[string]string
+
+// loadPIIPolicy reads PII_REDACTION, comma separated kind=action pairs such
+// as email=hash,card=redact, where kind is email, phone, card, national_id
+// or all. It returns nil when redaction is off.
+func loadPIIPolicy() (PIIPolicy, error) {
+	v := os.Getenv("PII_REDACTION")
+	if v == "" {
+		return nil, nil
+	}
+	policy := make(PIIPolicy)
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		kind, action, ok := strings.Cut(entry, "=")
+		kind, action = strings.TrimSpace(kind), strings.TrimSpace(action)
+		if !ok || action != maskRedact && action != maskHash {
+			return nil, fmt.Errorf("invalid PII_REDACTION entry %q, expected kind=redact or kind=hash", entry)
+		}
+		known := kind == "all"
+		for _, d := range piiDetectors {
+			if d.kind == kind || kind == "all" {
+				policy[d.kind] = action
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown PII kind %q in PII_REDACTION (want email, phone, card, national_id or all)", kind)
+		}
+	}
+	return policy, nil
+}
+
+// piiRedactor redacts personal data from the results of one tool call and
+// counts what it redacted, for the audit log.
+type piiRedactor struct {
+	policy PIIPolicy
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// piiKey is a context key for the redactor of the tool call.
+type piiKey struct{}
+
+// piiFromContext returns the redactor of the tool call, or nil when
+// redaction is off.
+func piiFromContext(ctx context.Context) *piiRedactor {
+	r, _ := ctx.Value(piiKey{}).(*piiRedactor)
+	return r
+}
+
+// redact replaces the personal data found in a string value according to
+// the policy. Other values are returned as is.
+func (r *piiRedactor) redact(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok || len(s) < 6 {
+		return v
+	}
+	for _, d := range piiDetectors {
+		action := r.policy[d.kind]
+		if action == "" {
+			continue
+		}
+		s = d.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if d.valid != nil && !d.valid(match) {
+				return match
+			}
+			r.mu.Lock()
+			r.counts[d.kind]++
+			r.mu.Unlock()
+			if action == maskHash {
+				sum := sha256.Sum256([]byte(match))
+				return "[" + d.kind + ":" + hex.EncodeToString(sum[:6]) + "]"
+			}
+			return "[" + d.kind + "]"
+		})
+	}
+	return s
+}
+
+// middleware gives each tool call a redactor and logs what it redacted
+// with the request ID and caller, as the audit trail of redactions.
+func (p PIIPolicy) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if len(p) == 0 {
+			return next(ctx, request)
+		}
+		r := &piiRedactor{policy: p, counts: make(map[string]int)}
+		result, err := next(context.WithValue(ctx, piiKey{}, r), request)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if len(r.counts) > 0 {
+			kinds := make([]string, 0, len(r.counts))
+			for kind, n := range r.counts {
+				kinds = append(kinds, fmt.Sprintf("%d %s", n, kind))
+			}
+			sort.Strings(kinds)
+			caller := principalFromContext(ctx)
+			if caller == "" {
+				caller = "-"
+			}
+			log.Printf("[%s] redacted %s from %s result for %s", requestIDFromContext(ctx), strings.Join(kinds, ", "), request.Params.Name, caller)
+		}
+		return result, err
+	}
+}
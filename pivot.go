@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxPivotColumns bounds the number of distinct column dimension values turned
+// into result columns.
+const maxPivotColumns = 100
+
+// pivotAggregates maps the aggregate argument to its SQL function.
+var pivotAggregates = map[string]string{
+	"count": "COUNT",
+	"sum":   "SUM",
+	"avg":   "AVG",
+	"min":   "MIN",
+	"max":   "MAX",
+}
+
+// pivotQueryHandler builds and runs a crosstab: one row per value of the row
+// dimension and one column per value of the column dimension.
+func (ds *DatabaseService) pivotQueryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	source, err := ds.sourceRelation(ctx, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	rowDim, _ := args["row"].(string)
+	colDim, _ := args["column"].(string)
+	if rowDim == "" || colDim == "" {
+		return mcp.NewToolResultError("Missing or invalid 'row' or 'column' argument."), nil
+	}
+	aggregate, _ := args["aggregate"].(string)
+	if aggregate == "" {
+		aggregate = "count"
+	}
+	fn, ok := pivotAggregates[strings.ToLower(aggregate)]
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Unsupported aggregate '%s' (expected count, sum, avg, min or max).", aggregate)), nil
+	}
+	value, _ := args["value"].(string)
+	if value == "" && fn != "COUNT" {
+		return mcp.NewToolResultError(fmt.Sprintf("The '%s' aggregate needs a 'value' column.", aggregate)), nil
+	}
+	format, err := parseFormat(args["format"])
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	columns, err := ds.resolveColumns(ctx, source, rowDim, colDim, value)
+	if err != nil {
+		return columnErrorResult(err), nil
+	}
+	rowDim, colDim, value = columns[0], columns[1], columns[2]
+	// Column values become result column names, which masks cannot reach
+	if result := maskedColumnResult(ctx, rowDim, colDim, value); result != nil {
+		return result, nil
+	}
+
+	// The distinct column values become the result columns
+	distinct := fmt.Sprintf("SELECT DISTINCT %s FROM %s ORDER BY 1 LIMIT %d", quoteIdentifier(colDim), source, maxPivotColumns+1)
+	rows, err := ds.db.QueryContext(ctx, distinct)
+	if err != nil {
+		log.Printf("Error reading pivot columns: %v, Query: %s", err, logSQL(distinct))
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error reading pivot column values", err), nil
+	}
+	var colValues []interface{}
+	for rows.Next() {
+		var v interface{}
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return mcp.NewToolResultErrorFromErr("Error reading pivot column values", err), nil
+		}
+		colValues = append(colValues, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return mcp.NewToolResultErrorFromErr("Error reading pivot column values", err), nil
+	}
+	if len(colValues) > maxPivotColumns {
+		return mcp.NewToolResultError(fmt.Sprintf("Column '%s' has more than %d distinct values; filter the source or pick a coarser column.", colDim, maxPivotColumns)), nil
+	}
+
+	measure := "1"
+	if value != "" {
+		measure = quoteIdentifier(value)
+	}
+	selects := []string{quoteIdentifier(rowDim)}
+	params := make([]interface{}, 0, len(colValues))
+	for _, v := range colValues {
+		name := "(null)"
+		if v != nil {
+			name = fmt.Sprint(normalizeValue(v, ""))
+		}
+		selects = append(selects, fmt.Sprintf("%s(CASE WHEN %s IS ? THEN %s END) AS %s", fn, quoteIdentifier(colDim), measure, quoteIdentifier(name)))
+		params = append(params, v)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s GROUP BY 1 ORDER BY 1", strings.Join(selects, ", "), source)
+
+	rows, err = ds.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		log.Printf("Error executing pivot query: %v, Query: %s", err, logSQL(query))
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error executing pivot query", err), nil
+	}
+	defer rows.Close()
+
+	return ds.processRows(ctx, rows, resultOptions{Format: format})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Profile is a named bundle of the tools a caller may use and the limits
+// their queries run under.
+type Profile struct {
+	Name    string
+	Tools   map[string]bool   // Tools the profile may call; nil allows every tool
+	Admin   bool              // May manage other users' queries and use admin-only tools
+	Write   bool              // May use the tools that modify the database in write mode
+	Timeout time.Duration     // Caps QUERY_TIMEOUT (0 = no cap)
+	MaxRows int               // Caps QUERY_MAX_ROWS (0 = no cap)
+	Tables  []string          // Table patterns the profile may read; nil allows every table
+	Masks   map[string]string // Masking method by table.column or column
+
+	RowFilters map[string]string // Predicate by table, added to every query reading it
+
+	Classification *classifiedAccess // Actions of CLASSIFICATION_FILE; nil allows everything
+}
+
+// defaultProfile is used when PROFILE is not set.
+const defaultProfile = "analyst"
+
+// profiles are the built-in capability profiles:
+//
+//   - readonly: schema browsing and small ad-hoc queries, capped at 10s and 1000 rows;
+//   - analyst: every read-only tool, including exports and aggregations, under the configured limits;
+//   - admin: every tool, including killing other users' queries and, in write mode, changing data.
+var profiles = map[string]*Profile{
+	"readonly": {
+		Name: "readonly",
+		Tools: map[string]bool{
+			"read_query": true, "list_tables": true, "describe_table": true, "list_schemas": true,
+			"get_table_ddl": true, "schema_summary": true, "database_info": true, "migration_status": true,
+			"validate_schema": true, "session_stats": true, "running_queries": true, "kill_query": true,
+			"fetch_result": true, "health": true, "batch_read": true,
+			"sql_capabilities": true, "validate_query": true, "format_sql": true, "schema_vocabulary": true,
+			"compare_queries": true, "count_rows": true, "paginate": true, "rerun_last": true,
+		},
+		Timeout: 10 * time.Second,
+		MaxRows: 1000,
+	},
+	"analyst": {Name: "analyst"},
+	"admin":   {Name: "admin", Admin: true, Write: true},
+}
+
+// writeTools modify the database and are only allowed for profiles with Write.
+var writeTools = map[string]bool{"insert_row": true, "update_row": true, "delete_row": true}
+
+// adminTools change the schema and are only allowed for profiles with Admin.
+var adminTools = map[string]bool{
+	"create_table": true, "create_index": true, "drop_table": true, "apply_migration": true,
+	"optimize_database": true, "vacuum_database": true, "checkpoint_wal": true,
+}
+
+// allows reports whether the profile may call tool.
+func (p *Profile) allows(tool string) bool {
+	if writeTools[tool] && !p.Write || adminTools[tool] && !p.Admin {
+		return false
+	}
+	return p.Tools == nil || p.Tools[tool]
+}
+
+// apply caps limits by the profile's own limits.
+func (p *Profile) apply(limits QueryLimits) QueryLimits {
+	if p.Timeout > 0 && (limits.Timeout <= 0 || limits.Timeout > p.Timeout) {
+		limits.Timeout = p.Timeout
+	}
+	if p.MaxRows > 0 && (limits.MaxRows <= 0 || limits.MaxRows > p.MaxRows) {
+		limits.MaxRows = p.MaxRows
+	}
+	return limits
+}
+
+// profileKey is a context key for the caller's profile.
+type profileKey struct{}
+
+// profileFromContext returns the profile of the tool call, or nil outside one.
+func profileFromContext(ctx context.Context) *Profile {
+	profile, _ := ctx.Value(profileKey{}).(*Profile)
+	return profile
+}
+
+// Profiles selects the profile of each caller: PROFILE for the deployment,
+// overridden per principal by PROFILE_USERS.
+type Profiles struct {
+	Default     *Profile
+	Users       map[string]*Profile // Keyed by lowercased principal
+	AdminTokens bool                // Admin-scoped tokens exist, which use the admin profile
+}
+
+// NewProfiles parses the deployment profile name and a comma separated list
+// of principal=profile assignments, which override those of ROLES_FILE.
+// salt keys the anonymizing masks.
+func NewProfiles(name, userList, salt string) (*Profiles, error) {
+	users, err := loadRolesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := loadClassificationFromEnv(); err != nil {
+		return nil, err
+	}
+	if err := loadAnonymizationFromEnv(salt); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = defaultProfile
+	}
+	p := &Profiles{Default: profiles[name], Users: make(map[string]*Profile)}
+	for principal, profile := range users {
+		p.Users[principal] = profile
+	}
+	if p.Default == nil {
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(profileNames(), ", "))
+	}
+	for _, entry := range strings.Split(userList, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		principal, profileName, ok := strings.Cut(entry, "=")
+		principal = strings.ToLower(strings.TrimSpace(principal))
+		profile := profiles[strings.TrimSpace(profileName)]
+		if !ok || principal == "" || profile == nil {
+			return nil, fmt.Errorf("invalid PROFILE_USERS entry %q, expected principal=profile with profile one of %s", entry, strings.Join(profileNames(), ", "))
+		}
+		p.Users[principal] = profile
+	}
+	return p, nil
+}
+
+func profileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// forContext returns the profile of the caller. Admin-scoped tokens always
+// use the admin profile.
+func (p *Profiles) forContext(ctx context.Context) *Profile {
+	if scopeFromContext(ctx) == scopeAdmin {
+		return profiles["admin"]
+	}
+	if profile, ok := p.Users[principalFromContext(ctx)]; ok {
+		return profile
+	}
+	return p.Default
+}
+
+// registers reports whether tool is registered at all, which is the case if
+// any caller's profile may use it.
+func (p *Profiles) registers(tool string) bool {
+	if p.AdminTokens || p.Default.allows(tool) {
+		return true
+	}
+	for _, profile := range p.Users {
+		if profile.allows(tool) {
+			return true
+		}
+	}
+	return false
+}
+
+// toolFilter hides the tools the caller's profile or token scope may not use
+// from tools/list.
+func (p *Profiles) toolFilter(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	profile := p.forContext(ctx)
+	scope := scopeFromContext(ctx)
+	allowed := tools[:0:0]
+	for _, tool := range tools {
+		if profile.allows(tool.Name) && scopeAllows(scope, tool.Name) {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
+}
+
+// middleware refuses tools outside the caller's profile and makes the profile
+// available to handlers through the context.
+func (p *Profiles) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		profile := p.forContext(ctx)
+		if !profile.allows(request.Params.Name) {
+			log.Printf("Rejected %s call: not allowed for profile %s", request.Params.Name, profile.Name)
+			payload, _ := json.MarshalIndent(map[string]string{
+				"error":   "tool_not_allowed",
+				"message": fmt.Sprintf("The %s tool is not available with the %s profile.", request.Params.Name, profile.Name),
+				"hint":    "Use the tools returned by tools/list, or ask an administrator for a different profile.",
+			}, "", "  ")
+			return mcp.NewToolResultError(string(payload)), nil
+		}
+		return next(context.WithValue(ctx, profileKey{}, profile), request)
+	}
+}
+
+// limitsFor returns the query limits of the caller's profile.
+func (ds *DatabaseService) limitsFor(ctx context.Context) QueryLimits {
+	if profile := profileFromContext(ctx); profile != nil {
+		return profile.apply(ds.limits)
+	}
+	return ds.limits
+}
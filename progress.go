@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	progressInterval    = 5 * time.Second // How often long maintenance operations report progress
+	rowProgressInterval = time.Second     // The least time between two row counts of a call
+)
+
+// reportProgress sends a notifications/progress message when the client
+// asked for progress with a progress token. A total of 0 leaves the total
+// out, for operations whose size is not known in advance.
+func reportProgress(ctx context.Context, request mcp.CallToolRequest, progress, total float64, message string) {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return
+	}
+	params := map[string]any{
+		"progressToken": request.Params.Meta.ProgressToken,
+		"progress":      progress,
+		"message":       message,
+	}
+	if total > 0 {
+		params["total"] = total
+	}
+	srv.SendNotificationToClient(ctx, "notifications/progress", params)
+}
+
+type progressKey struct{}
+
+// callProgress counts the rows a call has read and the bytes it has
+// exported, for clients that asked for progress.
+type callProgress struct {
+	request mcp.CallToolRequest
+	verb    string // "read" or "exported"
+
+	mu          sync.Mutex
+	rows, bytes int64
+	last        time.Time
+}
+
+// progressMiddleware lets the row loops of calls made with a progress token
+// report how far they got, so clients can show rows read or bytes exported
+// instead of a spinner while a large SELECT or export_query runs.
+func progressMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+			return next(ctx, request)
+		}
+		verb := "read"
+		if request.Params.Name == "export_query" {
+			verb = "exported"
+		}
+		p := &callProgress{request: request, verb: verb, last: time.Now()}
+		return next(context.WithValue(ctx, progressKey{}, p), request)
+	}
+}
+
+// addRowProgress counts a row read by the call, reporting the count at most
+// once every rowProgressInterval.
+func addRowProgress(ctx context.Context) {
+	p, ok := ctx.Value(progressKey{}).(*callProgress)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	p.rows++
+	if time.Since(p.last) < rowProgressInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.last = time.Now()
+	rows, bytes := p.rows, p.bytes
+	p.mu.Unlock()
+
+	message := fmt.Sprintf("%d rows %s", rows, p.verb)
+	if bytes > 0 {
+		message = fmt.Sprintf("%d rows, %d bytes %s", rows, bytes, p.verb)
+	}
+	reportProgress(ctx, p.request, float64(rows), 0, message)
+}
+
+// progressWriter counts the bytes written to w as exported by the call.
+func progressWriter(ctx context.Context, w io.Writer) io.Writer {
+	p, ok := ctx.Value(progressKey{}).(*callProgress)
+	if !ok {
+		return w
+	}
+	return &countingWriter{w: w, progress: p}
+}
+
+type countingWriter struct {
+	w        io.Writer
+	progress *callProgress
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.progress.mu.Lock()
+	c.progress.bytes += int64(n)
+	c.progress.mu.Unlock()
+	return n, err
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+)
+
+// Read-only access is enforced by SQLite itself rather than relying only on the
+// SELECT prefix check in read_query:
+//
+//   - the database file is opened with the URI parameter mode=ro, so the pager
+//     refuses to write;
+//   - every pooled connection runs PRAGMA query_only, which makes the VDBE reject
+//     any statement that would modify a database (INSERT/UPDATE/DELETE, DDL,
+//     writable PRAGMAs, VACUUM) with "attempt to write a readonly database".
+//
+// Neither stops a second statement: the driver runs every statement of the
+// text it is given, and ATTACH reads any file the process can open while
+// PRAGMA query_only = OFF lifts the second guard. validateReadOnly therefore
+// accepts a single statement only, and refuses ATTACH, DETACH and PRAGMA.
+
+// readOnlyConnInit is run on every new connection to a local SQLite database.
+const readOnlyConnInit = "PRAGMA query_only = ON"
+
+// sqliteURIEscaper escapes the characters that are significant in SQLite URI filenames.
+var sqliteURIEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+// readOnlyDSN turns a database file path into a SQLite URI that opens it read-only.
+func readOnlyDSN(path string) string {
+	if path == "" {
+		return ""
+	}
+	return "file:" + sqliteURIEscaper.Replace(path) + "?mode=ro"
+}
+
+// sqliteReadOnlyDSN makes a SQLite DSN given with DB_DSN open its database
+// read-only, as DB_FILE does: a plain path becomes a read-only URI, and a
+// file: URI gets mode=ro in place of any other mode. Driver parameters after
+// the path are kept, and in-memory databases are left as they are.
+func sqliteReadOnlyDSN(dsn string) string {
+	path, query, _ := strings.Cut(dsn, "?")
+	if path == "" || path == ":memory:" || path == "file::memory:" {
+		return dsn
+	}
+	if !strings.HasPrefix(path, "file:") {
+		path = "file:" + sqliteURIEscaper.Replace(path)
+	}
+	var params []string
+	for _, param := range strings.Split(query, "&") {
+		key, value, _ := strings.Cut(param, "=")
+		if key == "mode" && value == "memory" {
+			return dsn
+		}
+		if param != "" && key != "mode" {
+			params = append(params, param)
+		}
+	}
+	return path + "?" + strings.Join(append(params, "mode=ro"), "&")
+}
+
+// sqliteURIPath returns the file path of a file: URI built by readOnlyDSN.
+func sqliteURIPath(dsn string) string {
+	path, _, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	return strings.NewReplacer("%3f", "?", "%23", "#", "%25", "%").Replace(path)
+}
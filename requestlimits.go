@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Default request size limits, overridable through the environment.
+const (
+	defaultMaxRequestBytes = 1 << 20 // 1 MiB
+	defaultMaxQueryLength  = 100000
+)
+
+// RequestLimits bounds the size of what clients send, so a multi-megabyte
+// "query" is refused before it is parsed or prepared.
+type RequestLimits struct {
+	MaxBodyBytes   int64 // Maximum HTTP request body (0 = unlimited)
+	MaxQueryLength int   // Maximum length of a string argument such as a query (0 = unlimited)
+}
+
+// loadRequestLimits reads MAX_REQUEST_BYTES and MAX_QUERY_LENGTH.
+func loadRequestLimits() (RequestLimits, error) {
+	limits := RequestLimits{MaxBodyBytes: defaultMaxRequestBytes, MaxQueryLength: defaultMaxQueryLength}
+	if v := os.Getenv("MAX_REQUEST_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return limits, fmt.Errorf("invalid MAX_REQUEST_BYTES %q", v)
+		}
+		limits.MaxBodyBytes = n
+	}
+	if v := os.Getenv("MAX_QUERY_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return limits, fmt.Errorf("invalid MAX_QUERY_LENGTH %q", v)
+		}
+		limits.MaxQueryLength = n
+	}
+	return limits, nil
+}
+
+// bodyHandler answers 413 Request Entity Too Large to requests declaring a
+// body over the limit and stops reading other bodies at the limit.
+func (l RequestLimits) bodyHandler(next http.Handler) http.Handler {
+	if l.MaxBodyBytes <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > l.MaxBodyBytes {
+			http.Error(w, fmt.Sprintf("Request body of %d bytes exceeds the limit of %d bytes", r.ContentLength, l.MaxBodyBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, l.MaxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// middleware refuses tool calls with a string argument, or a string in a list
+// argument such as batch_read's queries, longer than MaxQueryLength.
+func (l RequestLimits) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if l.MaxQueryLength <= 0 {
+			return next(ctx, request)
+		}
+		for name, value := range request.GetArguments() {
+			values, ok := value.([]interface{})
+			if !ok {
+				values = []interface{}{value}
+			}
+			for _, v := range values {
+				if s, ok := v.(string); ok && len(s) > l.MaxQueryLength {
+					payload, _ := json.MarshalIndent(map[string]string{
+						"error":   "argument_too_long",
+						"message": fmt.Sprintf("The '%s' argument is %d bytes long; the limit is %d bytes.", name, len(s), l.MaxQueryLength),
+						"hint":    "Shorten the query, for example by moving literal value lists into a table or splitting the work into several queries.",
+					}, "", "  ")
+					return mcp.NewToolResultError(string(payload)), nil
+				}
+			}
+		}
+		return next(ctx, request)
+	}
+}
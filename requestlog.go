@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// requestIDHeader carries the correlation ID of a request. An ID sent by the
+// client or a proxy is kept, so its logs and ours can be joined.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDKey is a context key for the correlation ID of the HTTP request.
+type requestIDKey struct{}
+
+// requestIDFromContext returns the correlation ID of the current request, or
+// "-" outside of one.
+func requestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return "-"
+}
+
+// validRequestID accepts short printable IDs, so a header cannot forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// statusWriter records the status code of a response. It keeps Flush, which
+// the streamable HTTP transport needs for event streams.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// requestLogHandler assigns every request a correlation ID, returns it in
+// the X-Request-ID response header and logs the request when it completes.
+// Tool calls made by the request log the same ID.
+func requestLogHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(requestIDHeader, id)
+		sw := &statusWriter{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		log.Printf("[%s] %s %s %d %s", id, r.Method, r.URL.Path, sw.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// toolLogMiddleware logs every tool call with the correlation ID of its
+// request, the caller and the outcome, and records it in the audit log with
+// the tables it read.
+func (a *AuditLog) toolLogMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id := requestIDFromContext(ctx)
+		principal := principalFromContext(ctx)
+		caller := principal
+		if caller == "" {
+			caller = "-"
+		}
+		call := &auditCall{}
+		if a.enabled() {
+			// Finding the tables costs an analysis of each query
+			ctx = context.WithValue(ctx, auditCallKey{}, call)
+		}
+		start := time.Now()
+		result, err := next(ctx, request)
+		outcome := "ok"
+		switch {
+		case err != nil:
+			outcome = "error: " + err.Error()
+		case result != nil && result.IsError:
+			outcome = "error"
+			if len(result.Content) > 0 {
+				if text, ok := result.Content[0].(mcp.TextContent); ok {
+					// One line, at most 200 bytes
+					message := strings.Join(strings.Fields(text.Text), " ")
+					if len(message) > 200 {
+						message = message[:200] + "…"
+					}
+					outcome += ": " + message
+				}
+			}
+		}
+		elapsed := time.Since(start)
+		log.Printf("[%s] tool %s by %s (session %s) in %s: %s", id, request.Params.Name, caller, sessionID(ctx), elapsed.Round(time.Millisecond), outcome)
+		entry := AuditEntry{
+			Time:       start.UTC(),
+			RequestID:  id,
+			SessionID:  sessionID(ctx),
+			Principal:  principal,
+			Tool:       request.Params.Name,
+			Tables:     call.tables,
+			DurationMs: elapsed.Milliseconds(),
+			Outcome:    outcome,
+		}
+		if query, ok := request.GetArguments()["query"].(string); ok {
+			entry.SQL = logSQL(query)
+		}
+		a.record(entry)
+		return result, err
+	}
+}
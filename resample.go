@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// resampleBuckets maps a bucket size to the SQLite expression computing the
+// start of the bucket. %s receives the date function arguments of the
+// timestamp. Weeks start on Monday.
+var resampleBuckets = map[string]string{
+	"minute": "strftime('%%Y-%%m-%%d %%H:%%M:00', %s)",
+	"hour":   "strftime('%%Y-%%m-%%d %%H:00:00', %s)",
+	"day":    "date(%s)",
+	"week":   "date(%s, 'weekday 0', '-6 days')",
+	"month":  "strftime('%%Y-%%m-01', %s)",
+	"year":   "strftime('%%Y-01-01', %s)",
+}
+
+// timestampArgs returns the date function arguments that interpret column as a
+// timestamp. Numeric columns are read as Unix time in seconds, or milliseconds
+// when their values are too large to be seconds; text is parsed as ISO-8601.
+func (ds *DatabaseService) timestampArgs(ctx context.Context, source, column string) (string, error) {
+	col := quoteIdentifier(column)
+	var maxNumeric interface{}
+	query := fmt.Sprintf("SELECT MAX(%s) FROM %s WHERE typeof(%s) IN ('integer', 'real')", col, source, col)
+	if err := ds.db.QueryRowContext(ctx, query).Scan(&maxNumeric); err != nil {
+		return "", err
+	}
+	switch {
+	case maxNumeric == nil:
+		return col, nil
+	case toFloat(maxNumeric) > 1e11: // Later than year 5138 in seconds
+		return col + " / 1000, 'unixepoch'", nil
+	default:
+		return col + ", 'unixepoch'", nil
+	}
+}
+
+// resampleHandler aggregates a value over time buckets and returns the series
+// ordered by bucket.
+func (ds *DatabaseService) resampleHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	source, err := ds.sourceRelation(ctx, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	timeColumn, _ := args["time_column"].(string)
+	if timeColumn == "" {
+		return mcp.NewToolResultError("Missing or invalid 'time_column' argument."), nil
+	}
+	bucket, _ := args["bucket"].(string)
+	if bucket == "" {
+		bucket = "day"
+	}
+	bucketExpr, ok := resampleBuckets[strings.ToLower(bucket)]
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Unsupported bucket '%s' (expected minute, hour, day, week, month or year).", bucket)), nil
+	}
+	aggregate, _ := args["aggregate"].(string)
+	if aggregate == "" {
+		aggregate = "count"
+	}
+	fn, ok := pivotAggregates[strings.ToLower(aggregate)]
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Unsupported aggregate '%s' (expected count, sum, avg, min or max).", aggregate)), nil
+	}
+	value, _ := args["value"].(string)
+	if value == "" && fn != "COUNT" {
+		return mcp.NewToolResultError(fmt.Sprintf("The '%s' aggregate needs a 'value' column.", aggregate)), nil
+	}
+	format, err := parseFormat(args["format"])
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	columns, err := ds.resolveColumns(ctx, source, timeColumn, value)
+	if err != nil {
+		return columnErrorResult(err), nil
+	}
+	timeColumn, value = columns[0], columns[1]
+	if result := maskedColumnResult(ctx, timeColumn, value); result != nil {
+		return result, nil
+	}
+
+	timeArgs, err := ds.timestampArgs(ctx, source, timeColumn)
+	if err != nil {
+		log.Printf("Error inspecting time column %s: %v", timeColumn, err)
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr(fmt.Sprintf("Error inspecting time column '%s'", timeColumn), err), nil
+	}
+	measure := "*"
+	if value != "" {
+		measure = quoteIdentifier(value)
+	}
+	expr := fmt.Sprintf(bucketExpr, timeArgs)
+	query := fmt.Sprintf("SELECT %s AS bucket, %s(%s) AS value FROM %s WHERE %s IS NOT NULL GROUP BY 1 ORDER BY 1", expr, fn, measure, source, expr)
+
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("Error executing resample query: %v, Query: %s", err, logSQL(query))
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error executing resample query", err), nil
+	}
+	defer rows.Close()
+
+	return ds.processRows(ctx, rows, resultOptions{Format: format})
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Defaults for spilled result retention.
+const (
+	defaultResultTTL      = 30 * time.Minute
+	defaultResultMaxBytes = 64 << 20
+	resultURIPrefix       = "db://results/"
+)
+
+// StoredResult is a complete query result kept server-side after it was too
+// large to return inline. Rows are kept individually encoded so any page of
+// them can be reassembled cheaply.
+type StoredResult struct {
+	ID       string
+	Rows     []string
+	Encoding rowEncoding // How Rows are encoded and rendered
+	Bytes    int
+	Created  time.Time
+}
+
+// ResultStore holds spilled results in memory, bounded by age and total size.
+type ResultStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	maxBytes int
+	bytes    int
+	results  map[string]*StoredResult
+	order    []string // IDs from oldest to newest
+}
+
+// NewResultStoreFromEnv creates a store configured by RESULT_TTL and RESULT_STORE_MAX_BYTES.
+func NewResultStoreFromEnv() (*ResultStore, error) {
+	store := &ResultStore{
+		ttl:      defaultResultTTL,
+		maxBytes: defaultResultMaxBytes,
+		results:  make(map[string]*StoredResult),
+	}
+	if v := os.Getenv("RESULT_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid RESULT_TTL %q", v)
+		}
+		store.ttl = d
+	}
+	if v := os.Getenv("RESULT_STORE_MAX_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid RESULT_STORE_MAX_BYTES %q", v)
+		}
+		store.maxBytes = n
+	}
+	return store, nil
+}
+
+// Put stores rows with their encoding and returns the stored result, or nil if
+// the store is disabled or the result alone exceeds its capacity.
+func (s *ResultStore) Put(rows []string, encoding rowEncoding) *StoredResult {
+	size := 0
+	for _, row := range rows {
+		size += len(row)
+	}
+	if s == nil || size > s.maxBytes {
+		return nil
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil
+	}
+	result := &StoredResult{ID: hex.EncodeToString(idBytes), Rows: rows, Encoding: encoding, Bytes: size, Created: time.Now()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+	for s.bytes+size > s.maxBytes && len(s.order) > 0 {
+		s.removeLocked(s.order[0])
+	}
+	s.results[result.ID] = result
+	s.order = append(s.order, result.ID)
+	s.bytes += size
+	return result
+}
+
+// capacity returns the size of the largest result the store accepts.
+func (s *ResultStore) capacity() int {
+	if s == nil {
+		return 0
+	}
+	return s.maxBytes
+}
+
+// Get returns a stored result that has not expired yet.
+func (s *ResultStore) Get(id string) (*StoredResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+	result, ok := s.results[id]
+	return result, ok
+}
+
+func (s *ResultStore) expireLocked() {
+	cutoff := time.Now().Add(-s.ttl)
+	for len(s.order) > 0 && s.results[s.order[0]].Created.Before(cutoff) {
+		s.removeLocked(s.order[0])
+	}
+}
+
+func (s *ResultStore) removeLocked(id string) {
+	if result, ok := s.results[id]; ok {
+		s.bytes -= result.Bytes
+		delete(s.results, id)
+	}
+	for i, existing := range s.order {
+		if existing == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// rowBuffer collects the encoded rows of a result as they are read, up to
+// limit bytes: past it the result can neither be stored nor returned whole,
+// so the rows kept shrink to the ones its truncated output shows, and the
+// rest are only counted.
+type rowBuffer struct {
+	parts    []string
+	rows     int  // Rows added, kept or not
+	bytes    int  // Encoded size of the rows added
+	limit    int  // Bytes of rows kept
+	overflow bool // Rows were dropped
+}
+
+func newRowBuffer(limit int) *rowBuffer {
+	return &rowBuffer{parts: []string{}, limit: limit}
+}
+
+func (b *rowBuffer) add(part string) {
+	b.rows++
+	b.bytes += len(part)
+	if b.overflow {
+		return
+	}
+	if b.bytes <= b.limit {
+		b.parts = append(b.parts, part)
+		return
+	}
+	b.overflow = true
+	// Keep the rows that fill the truncated output, and the row past it
+	kept, size := 0, 0
+	for kept < len(b.parts) && size <= maxResultSize {
+		size += len(b.parts[kept])
+		kept++
+	}
+	if size <= maxResultSize {
+		b.parts = append(b.parts, part)
+		kept++
+	}
+	b.parts = append([]string(nil), b.parts[:kept]...)
+}
+
+// joinRows assembles indented row objects into a JSON array formatted like
+// json.MarshalIndent(rows, "", "  ").
+func joinRows(rows []string) string {
+	if len(rows) == 0 {
+		return "[]"
+	}
+	return "[\n  " + strings.Join(rows, ",\n  ") + "\n]"
+}
+
+// resultURI returns the resource URI of a stored result.
+func resultURI(id string) string {
+	return resultURIPrefix + id
+}
+
+// resultResourceHandler serves db://results/{id} resources.
+func (s *ResultStore) resultResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id := templateArgument(request, "id")
+	result, ok := s.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("result %q not found or expired", id)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: result.Encoding.mimeType(),
+			Text:     result.Encoding.render(result.Rows),
+		},
+	}, nil
+}
+
+// fetchResultHandler returns a page of rows from a stored result, for clients
+// that cannot read resources.
+func (s *ResultStore) fetchResultHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	id, ok := args["result_id"].(string)
+	if !ok || id == "" {
+		return mcp.NewToolResultError("Missing or invalid 'result_id' argument."), nil
+	}
+	id = strings.TrimPrefix(id, resultURIPrefix)
+
+	offset, limit := 0, 100
+	if v, ok := args["offset"].(float64); ok && v >= 0 {
+		offset = int(v)
+	}
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(v)
+	}
+
+	result, found := s.Get(id)
+	if !found {
+		return mcp.NewToolResultError(fmt.Sprintf("Result '%s' not found or expired.", id)), nil
+	}
+	if offset >= len(result.Rows) {
+		return mcp.NewToolResultError(fmt.Sprintf("Offset %d is past the end of the result (%d rows).", offset, len(result.Rows))), nil
+	}
+
+	end := offset + limit
+	if end > len(result.Rows) {
+		end = len(result.Rows)
+	}
+	// Keep pages within the inline size limit
+	size := 0
+	for i := offset; i < end; i++ {
+		size += len(result.Rows[i]) + 4
+		if size > maxResultSize && i > offset {
+			end = i
+			break
+		}
+	}
+
+	text := result.Encoding.render(result.Rows[offset:end])
+	if end < len(result.Rows) {
+		text += fmt.Sprintf("\n... rows %d-%d of %d; continue with offset=%d", offset, end-1, len(result.Rows), end)
+	}
+	return mcp.NewToolResultText(text), nil
+}
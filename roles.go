@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RolesConfig is the document read from ROLES_FILE: custom roles, used like
+// the built-in profiles, and the principals assigned to them.
+type RolesConfig struct {
+	Roles map[string]RoleConfig `json:"roles"`
+	Users map[string]string     `json:"users"` // Principal to role or profile name
+}
+
+// RoleConfig defines a role. Unset fields leave the matching limit open, as
+// in the analyst profile.
+type RoleConfig struct {
+	Tools   []string          `json:"tools"`       // Tools the role may call; empty allows every read-only tool
+	Tables  []string          `json:"tables"`      // Tables the role may read, as names or patterns such as sales_* or mounts.*
+	MaxRows int               `json:"max_rows"`    // Caps QUERY_MAX_ROWS
+	Timeout string            `json:"timeout"`     // Caps QUERY_TIMEOUT, e.g. 10s
+	Masks   map[string]string `json:"masks"`       // Masking method by table.column or column
+	Filters map[string]string `json:"row_filters"` // SQL predicate by table, limiting the rows the role sees
+	Admin   bool              `json:"admin"`
+	Write   bool              `json:"write"`
+}
+
+// Masking methods applied to the values of masked columns.
+const (
+	maskRedact  = "redact"  // Replace the value with ***
+	maskHash    = "hash"    // Replace the value with a hash, so equal values can still be matched
+	maskPartial = "partial" // Keep the last four characters
+)
+
+// loadRolesFromEnv reads ROLES_FILE and adds its roles to the available
+// profiles. It returns the principal assignments of the file, keyed by
+// lowercased principal.
+func loadRolesFromEnv() (map[string]*Profile, error) {
+	file := os.Getenv("ROLES_FILE")
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading ROLES_FILE: %w", err)
+	}
+	var config RolesConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parsing ROLES_FILE %s: %w", file, err)
+	}
+
+	names := make([]string, 0, len(config.Roles))
+	for name := range config.Roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := profiles[name]; ok {
+			return nil, fmt.Errorf("role %q in ROLES_FILE has the name of a built-in profile", name)
+		}
+		profile, err := config.Roles[name].profile(name)
+		if err != nil {
+			return nil, fmt.Errorf("role %q in ROLES_FILE: %w", name, err)
+		}
+		profiles[name] = profile
+	}
+
+	users := make(map[string]*Profile, len(config.Users))
+	for principal, name := range config.Users {
+		profile := profiles[name]
+		if profile == nil {
+			return nil, fmt.Errorf("user %q in ROLES_FILE has unknown role %q", principal, name)
+		}
+		users[strings.ToLower(strings.TrimSpace(principal))] = profile
+	}
+	return users, nil
+}
+
+// profile converts the role definition into a Profile.
+func (r RoleConfig) profile(name string) (*Profile, error) {
+	p := &Profile{Name: name, Admin: r.Admin, Write: r.Write, MaxRows: r.MaxRows}
+	if r.MaxRows < 0 {
+		return nil, fmt.Errorf("invalid max_rows %d", r.MaxRows)
+	}
+	if r.Timeout != "" {
+		d, err := time.ParseDuration(r.Timeout)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid timeout %q", r.Timeout)
+		}
+		p.Timeout = d
+	}
+	if len(r.Tools) > 0 {
+		p.Tools = toSet(r.Tools)
+	}
+	if r.Tables != nil {
+		p.Tables = make([]string, 0, len(r.Tables))
+		for _, pattern := range r.Tables {
+			pattern = strings.ToLower(strings.TrimSpace(pattern))
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				return nil, fmt.Errorf("invalid table pattern %q", pattern)
+			}
+			p.Tables = append(p.Tables, pattern)
+		}
+	}
+	if len(r.Masks) > 0 {
+		p.Masks = make(map[string]string, len(r.Masks))
+		for column, method := range r.Masks {
+			if !validMaskMethod(method) {
+				return nil, fmt.Errorf("unknown masking method %q for %s (want one of %s)", method, column, strings.Join(maskMethods, ", "))
+			}
+			p.Masks[strings.ToLower(strings.TrimSpace(column))] = method
+		}
+	}
+	if len(r.Filters) > 0 {
+		p.RowFilters = make(map[string]string, len(r.Filters))
+		for table, predicate := range r.Filters {
+			table = strings.ToLower(strings.TrimSpace(table))
+			if table == "" || strings.Contains(table, ".") {
+				return nil, fmt.Errorf("invalid row filter table %q (row filters apply to tables in main)", table)
+			}
+			if strings.TrimSpace(predicate) == "" {
+				return nil, fmt.Errorf("empty row filter for %s", table)
+			}
+			p.RowFilters[table] = predicate
+		}
+	}
+	return p, nil
+}
+
+// readsTable reports whether the profile may read table, given as a
+// lowercased name qualified by its schema unless it is in main.
+func (p *Profile) readsTable(table string) bool {
+	// The queries behind the session's temporary objects were checked when
+	// they were created
+	if table == "sqlite_schema" || strings.HasPrefix(table, "temp.") {
+		return true
+	}
+	if p.Classification != nil && p.Classification.tableAction(table) == actionHide {
+		return false
+	}
+	if p.Tables == nil {
+		return true
+	}
+	for _, pattern := range p.Tables {
+		if ok, _ := path.Match(pattern, table); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// restrictsReads reports whether the profile may not read some tables,
+// columns or rows, which must then be checked for every query.
+func (p *Profile) restrictsReads() bool {
+	if p.Tables != nil || len(p.RowFilters) > 0 {
+		return true
+	}
+	if c := p.Classification; c != nil {
+		if c.unlisted == actionHide {
+			return true
+		}
+		for _, actions := range []map[string]string{c.tables, c.columns} {
+			for _, action := range actions {
+				if action == actionHide {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// masksColumns reports whether the profile masks some column values.
+func (p *Profile) masksColumns() bool {
+	return len(p.Masks) > 0 || p.Classification != nil
+}
+
+// maskFor returns the masking method of column when it comes from one of
+// tables, or "" if it is not masked. With tables nil, when the tables a
+// query reads are unknown, a mask on any table applies. Columns the
+// classification policy masks are redacted.
+func (p *Profile) maskFor(tables []string, column string) string {
+	column = strings.ToLower(column)
+	if method, ok := p.Masks[column]; ok {
+		return method
+	}
+	if tables == nil {
+		for key, method := range p.Masks {
+			if _, name, ok := cutLast(key, "."); ok && name == column {
+				return method
+			}
+		}
+		if p.Classification != nil && p.Classification.masksAny(column) {
+			return maskRedact
+		}
+		return ""
+	}
+	for _, table := range tables {
+		if method, ok := p.Masks[table+"."+column]; ok {
+			return method
+		}
+	}
+	if p.Classification != nil {
+		for _, table := range tables {
+			if p.Classification.columnAction(table, column) == actionMask {
+				return maskRedact
+			}
+		}
+	}
+	return ""
+}
+
+// readsMaskedColumn reports whether the queries of a call read a column the
+// profile masks. Results are masked by column name, which a query can
+// change with an alias or an expression, so such a result is masked whole.
+func (p *Profile) readsMaskedColumn(access *queryAccess) bool {
+	if !p.masksColumns() {
+		return false
+	}
+	for table, columns := range access.columns {
+		for column := range columns {
+			if p.maskFor([]string{table}, column) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// maskValue applies a masking method to a non-NULL value.
+func maskValue(method string, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	s := fmt.Sprint(v)
+	switch method {
+	case maskHash:
+		return "sha256:" + hex.EncodeToString(saltedSum(s)[:8])
+	case maskFakeEmail:
+		return fakeEmail(s)
+	case maskFakeName:
+		return fakeName(s)
+	case maskPartial:
+		if r := []rune(s); len(r) > 4 {
+			return strings.Repeat("*", len(r)-4) + string(r[len(r)-4:])
+		}
+		return "****"
+	default:
+		return "***"
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	defaultRootsPattern    = "*.db,*.sqlite,*.sqlite3"
+	rootsTimeout           = 10 * time.Second // For the client to list its roots
+	maxRootsDepth          = 4                // Directory levels scanned below each root
+	maxDiscoveredDatabases = 200
+)
+
+// sqliteMagic starts every SQLite database file.
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// RootsDiscovery finds the SQLite databases in the filesystem roots a
+// client exposes, so list_databases can offer them. Only files whose names
+// match the allow patterns and that start with the SQLite header are
+// listed; directories starting with a dot are skipped.
+//
+// The roots are paths the client claims, but they are walked on the
+// server's filesystem, which a remote client has no business browsing:
+// only the parts of them inside the operator's base directories are
+// scanned.
+//
+// A server started without a database can switch to a listed file with
+// use_database.
+type RootsDiscovery struct {
+	requests   *ClientRequests
+	ds         *DatabaseService
+	patterns   []string // Globs matched against file names
+	baseDirs   []string // Directories roots are confined to, without symlinks
+	selectable bool     // No database was configured; use_database picks one
+}
+
+// rootsDiscoveryFromEnv reads DISCOVER_ROOTS, which turns the discovery on
+// (default false), ROOTS_BASE_DIRS, the comma separated directories client
+// roots are confined to (required with discovery), and ROOTS_DB_PATTERN, the
+// comma separated globs of the file names listed (default
+// *.db,*.sqlite,*.sqlite3). It returns nil when discovery is off.
+func rootsDiscoveryFromEnv(requests *ClientRequests) (*RootsDiscovery, error) {
+	v := os.Getenv("DISCOVER_ROOTS")
+	if v == "" {
+		return nil, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DISCOVER_ROOTS %q", v)
+	}
+	if !enabled {
+		return nil, nil
+	}
+	pattern := os.Getenv("ROOTS_DB_PATTERN")
+	if pattern == "" {
+		pattern = defaultRootsPattern
+	}
+	d := &RootsDiscovery{requests: requests}
+	for _, p := range strings.Split(pattern, ",") {
+		p = strings.TrimSpace(p)
+		if _, err := filepath.Match(p, ""); err != nil || p == "" {
+			return nil, fmt.Errorf("invalid ROOTS_DB_PATTERN %q", pattern)
+		}
+		d.patterns = append(d.patterns, p)
+	}
+	baseDirs := os.Getenv("ROOTS_BASE_DIRS")
+	if strings.TrimSpace(baseDirs) == "" {
+		return nil, fmt.Errorf("DISCOVER_ROOTS requires ROOTS_BASE_DIRS")
+	}
+	for _, dir := range strings.Split(baseDirs, ",") {
+		resolved, err := resolveDir(strings.TrimSpace(dir))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ROOTS_BASE_DIRS entry %q: %v", dir, err)
+		}
+		d.baseDirs = append(d.baseDirs, resolved)
+	}
+	return d, nil
+}
+
+// serving returns the resolved path of the database being served, or ""
+// when it is not a local file.
+func (d *RootsDiscovery) serving() string {
+	file := d.ds.databaseFile()
+	if d.ds.driverName != "sqlite" || file == "" || file == ":memory:" {
+		return ""
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(file); err == nil {
+		file = resolved
+	}
+	return file
+}
+
+// DiscoveredDatabase is a database file found in a client root.
+type DiscoveredDatabase struct {
+	Path     string    `json:"path"`
+	Root     string    `json:"root"`
+	Bytes    int64     `json:"bytes"`
+	Modified time.Time `json:"modified"`
+	Serving  bool      `json:"serving,omitempty"` // The database this server is connected to
+}
+
+// DatabaseList is the payload returned by list_databases.
+type DatabaseList struct {
+	Roots     []string             `json:"roots"`
+	Databases []DiscoveredDatabase `json:"databases"`
+	Truncated bool                 `json:"truncated,omitempty"` // More files matched than are listed
+	Note      string               `json:"note,omitempty"`
+}
+
+// roots asks the client of the call for its roots and returns the local
+// directories among them.
+func (d *RootsDiscovery) roots(ctx context.Context) ([]string, error) {
+	answer, err := d.requests.request(ctx, "roots/list", map[string]any{},
+		"roots_requested", "Listing the client's roots to find databases.", rootsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Roots []struct {
+			URI string `json:"uri"`
+		} `json:"roots"`
+	}
+	if err := json.Unmarshal(answer, &result); err != nil {
+		return nil, fmt.Errorf("invalid roots/list result: %v", err)
+	}
+	var dirs []string
+	for _, root := range result.Roots {
+		u, err := url.Parse(root.URI)
+		if err != nil || u.Scheme != "file" || u.Path == "" {
+			continue
+		}
+		dirs = append(dirs, filepath.Clean(filepath.FromSlash(u.Path)))
+	}
+	return dirs, nil
+}
+
+// resolveDir returns the absolute path of an existing directory with its
+// symlinks resolved, so that containment can be checked on the path.
+func resolveDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("not a directory")
+	}
+	return resolved, nil
+}
+
+// within reports whether path is dir or below it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// confine returns the directories to scan for the client's roots: each
+// root inside a base directory, and each base directory inside a root.
+// Roots that do not exist on the server are dropped. partial reports
+// whether any root was not scanned in full.
+func (d *RootsDiscovery) confine(roots []string) (dirs []string, partial bool) {
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		resolved, err := resolveDir(root)
+		if err != nil {
+			partial = true
+			continue
+		}
+		inside := false
+		for _, base := range d.baseDirs {
+			dir := ""
+			switch {
+			case within(resolved, base):
+				dir, inside = resolved, true
+			case within(base, resolved):
+				dir = base
+			}
+			if dir != "" && !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+		partial = partial || !inside
+	}
+	return dirs, partial
+}
+
+// matches reports whether a file name matches an allow pattern.
+func (d *RootsDiscovery) matches(name string) bool {
+	for _, p := range d.patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// scan returns the SQLite databases below root, and whether it stopped at
+// limit files. serving is the path of the database being served.
+func (d *RootsDiscovery) scan(ctx context.Context, root, serving string, limit int) ([]DiscoveredDatabase, bool) {
+	var found []DiscoveredDatabase
+	truncated := false
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || ctx.Err() != nil {
+			if entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			if path != root && (strings.HasPrefix(entry.Name(), ".") || strings.Count(rel, string(filepath.Separator)) >= maxRootsDepth) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !d.matches(entry.Name()) || !isSQLiteFile(path) {
+			return nil
+		}
+		if len(found) == limit {
+			truncated = true
+			return fs.SkipAll
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		found = append(found, DiscoveredDatabase{
+			Path:     path,
+			Root:     root,
+			Bytes:    info.Size(),
+			Modified: info.ModTime().UTC(),
+			Serving:  path == serving,
+		})
+		return nil
+	})
+	return found, truncated
+}
+
+// isSQLiteFile reports whether a file starts with the SQLite header.
+func isSQLiteFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, len(sqliteMagic))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, sqliteMagic)
+}
+
+// listDatabasesHandler lists the SQLite databases in the roots the client
+// exposes, marking the one this server serves.
+func (d *RootsDiscovery) listDatabasesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	list := DatabaseList{Roots: []string{}, Databases: []DiscoveredDatabase{}}
+	serving := d.serving()
+	if !d.requests.supports(sessionID(ctx), "roots") {
+		list.Note = "The client does not expose filesystem roots."
+	} else if roots, err := d.roots(ctx); err != nil {
+		log.Printf("[%s] Error listing client roots: %v", requestIDFromContext(ctx), err)
+		list.Note = fmt.Sprintf("The client's roots could not be listed: %v.", err)
+	} else {
+		var partial bool
+		roots, partial = d.confine(roots)
+		list.Roots = append(list.Roots, roots...)
+		if partial {
+			list.Note = "Only the parts of the client's roots inside the server's ROOTS_BASE_DIRS were scanned."
+		}
+		seen := make(map[string]bool)
+		for _, root := range roots {
+			found, truncated := d.scan(ctx, root, serving, maxDiscoveredDatabases-len(list.Databases))
+			for _, db := range found {
+				// Nested roots would list their files twice
+				if !seen[db.Path] {
+					seen[db.Path] = true
+					list.Databases = append(list.Databases, db)
+				}
+			}
+			if truncated {
+				list.Truncated = true
+				break
+			}
+		}
+		sort.Slice(list.Databases, func(i, j int) bool { return list.Databases[i].Path < list.Databases[j].Path })
+	}
+	if serving != "" && !list.hasServing() {
+		if info, err := os.Stat(serving); err == nil {
+			list.Databases = append([]DiscoveredDatabase{{Path: serving, Bytes: info.Size(), Modified: info.ModTime().UTC(), Serving: true}}, list.Databases...)
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling database list to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting database list", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+func (l DatabaseList) hasServing() bool {
+	for _, db := range l.Databases {
+		if db.Serving {
+			return true
+		}
+	}
+	return false
+}
+
+// useDatabaseHandler switches the server to a database file that
+// list_databases could list. Every session then reads the new file.
+func (d *RootsDiscovery) useDatabaseHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	path, _ := request.GetArguments()["path"].(string)
+	if path == "" {
+		return mcp.NewToolResultError("Missing 'path' argument."), nil
+	}
+	resolved, err := d.allowed(ctx, path)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := d.ds.openFile(ctx, resolved); err != nil {
+		log.Printf("[%s] Error opening database %s: %v", requestIDFromContext(ctx), resolved, err)
+		return mcp.NewToolResultErrorFromErr("Error opening database", err), nil
+	}
+	log.Printf("[%s] Switched to database %s", requestIDFromContext(ctx), resolved)
+	return mcp.NewToolResultText(fmt.Sprintf("Now serving %s.", resolved)), nil
+}
+
+// allowed resolves path and checks that it is a file list_databases could
+// list: a SQLite file matching the allow patterns, in a directory the scan
+// reaches below one of the client's roots confined to the base directories.
+func (d *RootsDiscovery) allowed(ctx context.Context, path string) (string, error) {
+	resolved, err := filepath.Abs(path)
+	if err == nil {
+		resolved, err = filepath.EvalSymlinks(resolved)
+	}
+	if err != nil {
+		return "", fmt.Errorf("database file %s not found", path)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil || !info.Mode().IsRegular() || !d.matches(filepath.Base(resolved)) || !isSQLiteFile(resolved) {
+		return "", fmt.Errorf("%s is not a SQLite database file matching ROOTS_DB_PATTERN", path)
+	}
+	if !d.requests.supports(sessionID(ctx), "roots") {
+		return "", fmt.Errorf("the client does not expose filesystem roots")
+	}
+	roots, err := d.roots(ctx)
+	if err != nil {
+		return "", fmt.Errorf("the client's roots could not be listed: %v", err)
+	}
+	dirs, _ := d.confine(roots)
+	for _, dir := range dirs {
+		if reachable(dir, resolved) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not in the client's roots inside the server's ROOTS_BASE_DIRS", path)
+}
+
+// reachable reports whether scan would visit path below root: at most
+// maxRootsDepth directories down, none of them starting with a dot.
+func reachable(root, path string) bool {
+	if !within(path, root) || path == root {
+		return false
+	}
+	rel, _ := filepath.Rel(root, filepath.Dir(path))
+	if rel == "." {
+		return true
+	}
+	dirs := strings.Split(rel, string(filepath.Separator))
+	for _, dir := range dirs {
+		if strings.HasPrefix(dir, ".") {
+			return false
+		}
+	}
+	return len(dirs) <= maxRootsDepth
+}
+
+// openFile switches the service to the SQLite file at path. Pooled
+// connections to the previous file are dropped as they are returned; the
+// previous file is kept when the new one cannot be read.
+func (ds *DatabaseService) openFile(ctx context.Context, path string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	previous := ds.connector.currentDSN()
+	ds.connector.reopen(readOnlyDSN(path))
+	var tables int
+	if err := ds.db.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_schema").Scan(&tables); err != nil {
+		ds.connector.reopen(previous)
+		return err
+	}
+	ds.dbFile = path
+	return nil
+}
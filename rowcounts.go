@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// rowCountBudget bounds the time list_tables spends counting rows exactly;
+// tables not reached in time fall back to the sqlite_stat1 estimate.
+const rowCountBudget = 2 * time.Second
+
+// TableRowCount is a table name with its number of rows.
+type TableRowCount struct {
+	Name  string `json:"name"`
+	Rows  *int64 `json:"rows"`  // NULL when neither counted nor estimated
+	Exact bool   `json:"exact"` // False for estimates taken from ANALYZE statistics
+}
+
+// tableRowCounts counts the rows of each table until the budget runs out and
+// estimates the rest from sqlite_stat1 when the database has been analyzed.
+func (ds *DatabaseService) tableRowCounts(ctx context.Context, tables []string) ([]TableRowCount, error) {
+	estimates := ds.rowEstimates(ctx)
+	countCtx, cancel := context.WithTimeout(ctx, rowCountBudget)
+	defer cancel()
+
+	counts := make([]TableRowCount, len(tables))
+	for i, table := range tables {
+		counts[i].Name = table
+		if countCtx.Err() == nil {
+			var n int64
+			err := ds.db.QueryRowContext(countCtx, "SELECT COUNT(*) FROM "+ds.quoteTable(table)).Scan(&n)
+			if err == nil {
+				counts[i].Rows, counts[i].Exact = &n, true
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if countCtx.Err() == nil {
+				return nil, err
+			}
+		}
+		if n, ok := estimates[table]; ok {
+			counts[i].Rows = &n
+		}
+	}
+	return counts, nil
+}
+
+// rowEstimates reads the row counts recorded by ANALYZE for the main schema.
+// The first number of every sqlite_stat1 entry is the table's row count.
+func (ds *DatabaseService) rowEstimates(ctx context.Context) map[string]int64 {
+	estimates := make(map[string]int64)
+	rows, err := ds.db.QueryContext(ctx, "SELECT tbl, stat FROM sqlite_stat1")
+	if err != nil {
+		return estimates // Not analyzed
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, stat string
+		if rows.Scan(&table, &stat) != nil {
+			continue
+		}
+		field, _, _ := strings.Cut(stat, " ")
+		if n, err := strconv.ParseInt(field, 10, 64); err == nil && n > estimates[table] {
+			estimates[table] = n
+		}
+	}
+	return estimates
+}
+
+// RowCount is the payload returned by count_rows.
+type RowCount struct {
+	Table  string `json:"table"`
+	Rows   int64  `json:"rows"`
+	Exact  bool   `json:"exact"`
+	Method string `json:"method"` // count, sqlite_stat1 or max_rowid
+	Note   string `json:"note,omitempty"`
+}
+
+// countRowsHandler counts the rows of a table exactly or estimates them. In
+// auto mode the exact count gets rowCountBudget before falling back to an
+// estimate, so huge tables still return an order of magnitude.
+func (ds *DatabaseService) countRowsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	table, _ := args["table"].(string)
+	if table == "" {
+		return mcp.NewToolResultError("Missing or invalid 'table' argument."), nil
+	}
+	mode, _ := args["mode"].(string)
+	switch mode {
+	case "":
+		mode = "auto"
+	case "auto", "exact", "approximate":
+	default:
+		return mcp.NewToolResultError("Invalid 'mode' argument: use auto, exact or approximate."), nil
+	}
+	resolved, err := ds.resolveTable(ctx, table)
+	if err != nil {
+		return tableErrorResult(table, err), nil
+	}
+
+	count := RowCount{Table: resolved}
+	if mode != "approximate" {
+		countCtx := ctx
+		if mode == "auto" {
+			var cancel context.CancelFunc
+			countCtx, cancel = context.WithTimeout(ctx, rowCountBudget)
+			defer cancel()
+		}
+		err := ds.db.QueryRowContext(countCtx, "SELECT COUNT(*) FROM "+ds.quoteTable(resolved)).Scan(&count.Rows)
+		switch {
+		case err == nil:
+			count.Exact, count.Method = true, "count"
+			return rowCountResult(count)
+		case mode == "auto" && ctx.Err() == nil && countCtx.Err() != nil:
+			count.addNote(fmt.Sprintf("COUNT(*) took longer than %s", rowCountBudget))
+		default:
+			log.Printf("Error counting rows of %s: %v", resolved, err)
+			if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+				return result, nil
+			}
+			return mcp.NewToolResultErrorFromErr("Error counting rows", err), nil
+		}
+	}
+
+	if !ds.estimateRows(ctx, &count) {
+		return mcp.NewToolResultError(fmt.Sprintf("No row estimate for %s: it has no ANALYZE statistics and no rowid. Run optimize_database or use mode exact.", resolved)), nil
+	}
+	return rowCountResult(count)
+}
+
+// estimateRows fills in the sqlite_stat1 row count of a main schema table
+// or, failing that, its largest rowid, which counts deleted rows too.
+func (ds *DatabaseService) estimateRows(ctx context.Context, count *RowCount) bool {
+	if _, ok := ds.dialect.(sqliteDialect); !ok {
+		return false
+	}
+	if schema, name := ds.dialect.SplitTable(count.Table); schema == "" {
+		if n, ok := ds.rowEstimates(ctx)[name]; ok {
+			count.Rows, count.Method = n, "sqlite_stat1"
+			count.addNote("as of the last ANALYZE")
+			return true
+		}
+	}
+	var maxRowID sql.NullInt64
+	if err := ds.db.QueryRowContext(ctx, "SELECT MAX(rowid) FROM "+ds.quoteTable(count.Table)).Scan(&maxRowID); err != nil {
+		return false // WITHOUT ROWID tables and views
+	}
+	// An empty table has no largest rowid
+	count.Rows, count.Exact, count.Method = maxRowID.Int64, !maxRowID.Valid, "max_rowid"
+	if maxRowID.Valid {
+		count.addNote("upper bound, since deleted rows keep their rowids")
+	}
+	return true
+}
+
+func (c *RowCount) addNote(note string) {
+	if c.Note != "" {
+		c.Note += "; "
+	}
+	c.Note += note
+}
+
+func rowCountResult(count RowCount) (*mcp.CallToolResult, error) {
+	resultJSON, err := json.MarshalIndent(count, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling row count to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting row count", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// sentQueriesKey is a context key for the 'queries' argument of a call as
+// the caller sent it, before row filters were added to its statements.
+type sentQueriesKey struct{}
+
+// sentQuery returns statement i of the 'queries' argument as the caller sent
+// it, so that results echo it without the row filters, or query.
+func sentQuery(ctx context.Context, i int, query string) string {
+	if sent, _ := ctx.Value(sentQueriesKey{}).([]interface{}); i < len(sent) {
+		if s, ok := sent[i].(string); ok {
+			return s
+		}
+	}
+	return query
+}
+
+// filterRows applies the row filters of the caller's role to a tool call,
+// returning why the call is refused or "". Queries reading a filtered table
+// are rewritten to read it through a common table expression of the same
+// name that adds the filter; tools taking a filtered table as an argument
+// build their own SQL and are refused, except the schema tools, which get
+// no sample values, and so are 'where' conditions reading one.
+func (ds *DatabaseService) filterRows(ctx context.Context, profile *Profile, request mcp.CallToolRequest, access *queryAccess) string {
+	args := request.GetArguments()
+	for _, table := range access.named {
+		if _, ok := profile.RowFilters[table]; !ok {
+			continue
+		}
+		if !schemaTools[request.Params.Name] {
+			return fmt.Sprintf("the rows of table %s are filtered for the %s role; query it with read_query", table, profile.Name)
+		}
+		delete(args, "sample_values")
+	}
+
+	var filtered []string
+	for _, table := range access.tables {
+		if _, ok := profile.RowFilters[table]; ok {
+			filtered = append(filtered, table)
+		}
+	}
+	if len(filtered) == 0 {
+		return ""
+	}
+	sort.Strings(filtered)
+
+	// A 'where' condition is only a fragment of the tool's SQL, which cannot
+	// be wrapped, so it may not read a filtered table at all
+	if where, _ := args["where"].(string); strings.TrimSpace(where) != "" {
+		query, err := ds.conditionQuery(ctx, args, where)
+		if err != nil {
+			return err.Error()
+		}
+		condition := &queryAccess{tables: []string{}, columns: make(map[string]map[string]bool)}
+		if err := ds.sqliteQueryAccess(ctx, query, condition); err != nil {
+			return err.Error()
+		}
+		for _, table := range condition.tables {
+			if _, ok := profile.RowFilters[table]; ok {
+				return fmt.Sprintf("the 'where' condition may not read table %s, whose rows are filtered for the %s role", table, profile.Name)
+			}
+		}
+	}
+	views, err := ds.sqliteViews(ctx)
+	if err != nil {
+		return err.Error()
+	}
+	rewrite := func(query string) (string, string) {
+		if reason := checkFilteredReferences(query, profile.RowFilters, views); reason != "" {
+			return "", reason
+		}
+		return withRowFilters(query, filtered, profile.RowFilters), ""
+	}
+	for _, name := range []string{"query", "first", "second"} {
+		if query, _ := args[name].(string); query != "" {
+			rewritten, reason := rewrite(query)
+			if reason != "" {
+				return reason
+			}
+			args[name] = rewritten
+		}
+	}
+	if queries, ok := args["queries"].([]interface{}); ok {
+		for i, v := range queries {
+			if query, _ := v.(string); query != "" {
+				rewritten, reason := rewrite(query)
+				if reason != "" {
+					return reason
+				}
+				queries[i] = rewritten
+			}
+		}
+	}
+	return ""
+}
+
+// checkFilteredReferences refuses the references that would read a
+// filtered table around its common table expression: names qualified by a
+// schema, which do not resolve to common table expressions, and views,
+// which are resolved in the schema.
+func checkFilteredReferences(query string, filters map[string]string, views map[string]bool) string {
+	tokens := tokenizeSQL(query)
+	prev := ""
+	for _, tok := range tokens {
+		if tok.Kind == 'c' {
+			continue
+		}
+		name := strings.ToLower(tok.Text)
+		if tok.Kind == 'q' && name[0] != '\'' && len(name) >= 2 {
+			name = name[1 : len(name)-1]
+		} else if tok.Kind != 'w' {
+			prev = tok.Text
+			continue
+		}
+		if _, ok := filters[name]; ok && prev == "." {
+			return fmt.Sprintf("table %s must be named without a schema, so its row filter applies", name)
+		}
+		if views[name] {
+			return fmt.Sprintf("view %s cannot be read with row filters in place; query the tables directly", name)
+		}
+		prev = tok.Text
+	}
+	return ""
+}
+
+// withClausePattern matches the WITH keyword starting a statement, after
+// any comments, and the RECURSIVE keyword following it.
+var withClausePattern = regexp.MustCompile(`(?is)^(?:\s+|--[^\n]*(?:\n|$)|/\*.*?\*/)*WITH\b(\s+RECURSIVE\b)?`)
+
+// withRowFilters wraps query so that each of tables is read through a
+// common table expression of the same name holding the rows its filter
+// selects. A statement can only have one WITH clause, so the filters are
+// put first in the query's own when it has one, where its common table
+// expressions read them too. The result still starts with SELECT.
+func withRowFilters(query string, tables []string, filters map[string]string) string {
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\n")
+	var b strings.Builder
+	b.WriteString("SELECT * FROM (WITH ")
+	m := withClausePattern.FindStringSubmatchIndex(query)
+	if m != nil && m[2] >= 0 {
+		b.WriteString("RECURSIVE ")
+	}
+	for i, table := range tables {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s AS (SELECT * FROM main.%s WHERE (%s\n))", quoteIdentifier(table), quoteIdentifier(table), filters[table])
+	}
+	if m != nil {
+		b.WriteString(",")
+		query = query[m[1]:]
+	}
+	b.WriteString("\n")
+	b.WriteString(query)
+	b.WriteString("\n)")
+	return b.String()
+}
+
+// sqliteViews returns the lowercased names of the views of the database.
+func (ds *DatabaseService) sqliteViews(ctx context.Context) (map[string]bool, error) {
+	rows, err := ds.db.QueryContext(ctx, "SELECT lower(name) FROM sqlite_schema WHERE type = 'view'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	views := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		views[name] = true
+	}
+	return views, rows.Err()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RunningQuery describes a tool call that is currently executing.
+type RunningQuery struct {
+	ID        int64                  `json:"id"`
+	SessionID string                 `json:"session_id,omitempty"`
+	Tool      string                 `json:"tool"`
+	SQL       string                 `json:"sql,omitempty"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Started   time.Time              `json:"started"`
+	ElapsedMs int64                  `json:"elapsed_ms"`
+
+	cancel context.CancelFunc
+	killed bool
+}
+
+// QueryRegistry keeps track of in-flight tool calls.
+type QueryRegistry struct {
+	mu      sync.Mutex
+	nextID  int64
+	running map[int64]*RunningQuery
+}
+
+// NewQueryRegistry creates an empty registry.
+func NewQueryRegistry() *QueryRegistry {
+	return &QueryRegistry{running: make(map[int64]*RunningQuery)}
+}
+
+// middleware registers each tool call for the duration of its execution.
+// Calls get a cancellable context so kill_query can interrupt them.
+func (r *QueryRegistry) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		args := request.GetArguments()
+		entry := &RunningQuery{
+			SessionID: sessionID(ctx),
+			Tool:      request.Params.Name,
+			Started:   time.Now(),
+			cancel:    cancel,
+		}
+		if query, ok := args["query"].(string); ok {
+			entry.SQL = query
+		} else if len(args) > 0 {
+			entry.Arguments = args
+		}
+
+		r.mu.Lock()
+		r.nextID++
+		entry.ID = r.nextID
+		r.running[entry.ID] = entry
+		r.mu.Unlock()
+
+		result, err := next(ctx, request)
+
+		r.mu.Lock()
+		delete(r.running, entry.ID)
+		killed := entry.killed
+		r.mu.Unlock()
+
+		if killed {
+			return mcp.NewToolResultError(fmt.Sprintf("Query %d was cancelled by an administrator.", entry.ID)), nil
+		}
+		return result, err
+	}
+}
+
+// kill cancels the call with the given ID, interrupting its SQLite statement.
+func (r *QueryRegistry) kill(id int64) (RunningQuery, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.running[id]
+	if !ok {
+		return RunningQuery{}, false
+	}
+	entry.killed = true
+	entry.cancel()
+	return *entry, true
+}
+
+// snapshot returns the running calls ordered from oldest to newest, all of
+// them or only those of one session. Their SQL and string arguments are
+// redacted per LOG_SQL_LITERALS, as in the logs.
+func (r *QueryRegistry) snapshot(all bool, session string) []RunningQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	queries := make([]RunningQuery, 0, len(r.running))
+	for _, q := range r.running {
+		if !all && q.SessionID != session {
+			continue
+		}
+		entry := *q
+		entry.ElapsedMs = now.Sub(q.Started).Milliseconds()
+		entry.SQL = logSQL(q.SQL)
+		if len(q.Arguments) > 0 {
+			entry.Arguments = make(map[string]interface{}, len(q.Arguments))
+			for name, value := range q.Arguments {
+				if s, ok := value.(string); ok {
+					value = logSQL(s)
+				}
+				entry.Arguments[name] = value
+			}
+		}
+		queries = append(queries, entry)
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i].ID < queries[j].ID })
+	return queries
+}
+
+// runningQueriesHandler lists the tool calls currently executing on the
+// server. Administrators see every session's calls, other callers only
+// those of their own session.
+func (r *QueryRegistry) runningQueriesHandler(identity *Identity) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		resultJSON, err := json.MarshalIndent(r.snapshot(identity.isAdmin(ctx), sessionID(ctx)), "", "  ")
+		if err != nil {
+			log.Printf("Error marshalling running queries to JSON: %v", err)
+			return mcp.NewToolResultErrorFromErr("Error formatting running queries", err), nil
+		}
+
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	}
+}
+
+// killQueryHandler cancels an in-flight query by ID. Only administrators may call it.
+func (r *QueryRegistry) killQueryHandler(identity *Identity) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !identity.isAdmin(ctx) {
+			return mcp.NewToolResultError("kill_query requires an administrator (see ADMIN_USERS)."), nil
+		}
+
+		args := request.GetArguments()
+		id, ok := args["id"].(float64)
+		if !ok || id <= 0 || id != float64(int64(id)) {
+			return mcp.NewToolResultError("Missing or invalid 'id' argument."), nil
+		}
+
+		entry, ok := r.kill(int64(id))
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("No running query with id %d.", int64(id))), nil
+		}
+		log.Printf("Query %d (%s, session %s) cancelled by %s", entry.ID, entry.Tool, entry.SessionID, principalFromContext(ctx))
+
+		return mcp.NewToolResultText(fmt.Sprintf("Cancelled query %d (%s) after %s.", entry.ID, entry.Tool, time.Since(entry.Started).Round(time.Millisecond))), nil
+	}
+}
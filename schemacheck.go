@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// unsafeSchemaSQL matches statements that could touch files when an expected
+// SQL schema is loaded into the scratch database.
+var unsafeSchemaSQL = regexp.MustCompile(`(?i)\b(ATTACH|VACUUM|load_extension)\b`)
+
+// TableSchema is the expected or live shape of a table.
+type TableSchema struct {
+	Columns map[string]string `json:"columns"`           // Column name → declared type
+	Indexes []string          `json:"indexes,omitempty"` // Named indexes on the table
+}
+
+// SchemaViolation is a difference between the expected and the live schema.
+type SchemaViolation struct {
+	Kind     string `json:"kind"` // missing_table, missing_column, type_drift, missing_index, extra_table, extra_column, extra_index
+	Table    string `json:"table"`
+	Column   string `json:"column,omitempty"`
+	Index    string `json:"index,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// schemaSnapshot reads the tables, column types and named indexes of db.
+func schemaSnapshot(ctx context.Context, db *sql.DB) (map[string]*TableSchema, error) {
+	rows, err := db.QueryContext(ctx, `SELECT m.name, p.name, p.type FROM sqlite_schema AS m JOIN pragma_table_info(m.name) AS p
+		WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%' ORDER BY m.name, p.cid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schema := make(map[string]*TableSchema)
+	for rows.Next() {
+		var table, column, colType string
+		if err := rows.Scan(&table, &column, &colType); err != nil {
+			return nil, err
+		}
+		if schema[table] == nil {
+			schema[table] = &TableSchema{Columns: make(map[string]string)}
+		}
+		schema[table].Columns[column] = colType
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Automatic indexes backing UNIQUE constraints have generated names and are skipped
+	idxRows, err := db.QueryContext(ctx, "SELECT name, tbl_name FROM sqlite_schema WHERE type = 'index' AND name NOT LIKE 'sqlite_autoindex_%' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer idxRows.Close()
+	for idxRows.Next() {
+		var name, table string
+		if err := idxRows.Scan(&name, &table); err != nil {
+			return nil, err
+		}
+		if schema[table] != nil {
+			schema[table].Indexes = append(schema[table].Indexes, name)
+		}
+	}
+	return schema, idxRows.Err()
+}
+
+// expectedSchema parses an expected schema given as JSON (table → columns and
+// indexes) or as SQL DDL, which is loaded into an in-memory database.
+func expectedSchema(ctx context.Context, text string) (map[string]*TableSchema, error) {
+	if strings.HasPrefix(strings.TrimSpace(text), "{") {
+		var schema map[string]*TableSchema
+		if err := json.Unmarshal([]byte(text), &schema); err != nil {
+			return nil, fmt.Errorf("invalid JSON schema: %w", err)
+		}
+		for table, t := range schema {
+			if t == nil || t.Columns == nil {
+				schema[table] = &TableSchema{Columns: map[string]string{}}
+			}
+		}
+		return schema, nil
+	}
+
+	if unsafeSchemaSQL.MatchString(text) {
+		return nil, fmt.Errorf("expected schema SQL may only create tables, indexes, views and triggers")
+	}
+	scratch, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
+	scratch.SetMaxOpenConns(1) // Every connection would get its own empty database
+	if _, err := scratch.ExecContext(ctx, text); err != nil {
+		return nil, fmt.Errorf("invalid schema SQL: %w", err)
+	}
+	return schemaSnapshot(ctx, scratch)
+}
+
+// normalizeType makes declared types comparable: upper case, single spaces.
+func normalizeType(t string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(t)), " ")
+}
+
+// compareSchemas lists the differences between the expected and live schemas.
+func compareSchemas(expected, live map[string]*TableSchema) []SchemaViolation {
+	violations := []SchemaViolation{}
+	for _, table := range sortedKeys(expected) {
+		want, have := expected[table], live[table]
+		if have == nil {
+			violations = append(violations, SchemaViolation{Kind: "missing_table", Table: table})
+			continue
+		}
+		for _, column := range sortedKeys(want.Columns) {
+			actual, ok := have.Columns[column]
+			switch {
+			case !ok:
+				violations = append(violations, SchemaViolation{Kind: "missing_column", Table: table, Column: column, Expected: want.Columns[column]})
+			case normalizeType(actual) != normalizeType(want.Columns[column]):
+				violations = append(violations, SchemaViolation{Kind: "type_drift", Table: table, Column: column, Expected: want.Columns[column], Actual: actual})
+			}
+		}
+		for _, column := range sortedKeys(have.Columns) {
+			if _, ok := want.Columns[column]; !ok {
+				violations = append(violations, SchemaViolation{Kind: "extra_column", Table: table, Column: column, Actual: have.Columns[column]})
+			}
+		}
+		for _, index := range want.Indexes {
+			if !containsString(have.Indexes, index) {
+				violations = append(violations, SchemaViolation{Kind: "missing_index", Table: table, Index: index})
+			}
+		}
+		for _, index := range have.Indexes {
+			if !containsString(want.Indexes, index) {
+				violations = append(violations, SchemaViolation{Kind: "extra_index", Table: table, Index: index})
+			}
+		}
+	}
+	for _, table := range sortedKeys(live) {
+		if _, ok := expected[table]; !ok {
+			violations = append(violations, SchemaViolation{Kind: "extra_table", Table: table})
+		}
+	}
+	return violations
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSchemaHandler compares the live schema with the expected schema from
+// the 'schema' argument or the SCHEMA_FILE setting.
+func (ds *DatabaseService) validateSchemaHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	text, _ := args["schema"].(string)
+	if text == "" {
+		if ds.schemaFile == "" {
+			return mcp.NewToolResultError("No expected schema: pass 'schema' or set SCHEMA_FILE."), nil
+		}
+		data, err := os.ReadFile(ds.schemaFile)
+		if err != nil {
+			log.Printf("Error reading schema file %s: %v", ds.schemaFile, err)
+			return mcp.NewToolResultErrorFromErr("Error reading SCHEMA_FILE", err), nil
+		}
+		text = string(data)
+	}
+
+	expected, err := expectedSchema(ctx, text)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	live, err := schemaSnapshot(ctx, ds.db)
+	if err != nil {
+		log.Printf("Error reading live schema: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error reading database schema", err), nil
+	}
+
+	violations := compareSchemas(expected, live)
+	resultJSON, err := json.MarshalIndent(map[string]interface{}{
+		"valid":      len(violations) == 0,
+		"violations": violations,
+	}, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling schema violations to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting schema violations", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
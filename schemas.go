@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// SchemaInfo is a database schema, for SQLite main or an attached database.
+type SchemaInfo struct {
+	Name   string `json:"name"`
+	File   string `json:"file,omitempty"` // SQLite database file
+	Tables int    `json:"tables"`
+}
+
+// schemaArg returns the optional 'schema' argument after checking that the
+// schema exists.
+func (ds *DatabaseService) schemaArg(ctx context.Context, args map[string]interface{}) (string, error) {
+	schema, _ := args["schema"].(string)
+	if schema == "" {
+		return "", nil
+	}
+	schemas, err := ds.dialect.ListSchemas(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range schemas {
+		if s.Name == schema {
+			return schema, nil
+		}
+	}
+	return "", fmt.Errorf("unknown schema '%s'; use list_schemas to see the available schemas", schema)
+}
+
+// listSchemasHandler lists the schemas of the database with their number of
+// tables (for SQLite the main database and attached schemas with their files).
+func (ds *DatabaseService) listSchemasHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	schemas, err := ds.dialect.ListSchemas(ctx)
+	if err != nil {
+		log.Printf("Error listing schemas: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error listing schemas", err), nil
+	}
+	resultJSON, err := json.MarshalIndent(schemas, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling schema list to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting schema list", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
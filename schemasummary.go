@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// temporalColumnPattern matches column names and types that usually hold timestamps.
+var temporalColumnPattern = regexp.MustCompile(`(?i)(date|time|_at$|_on$)`)
+
+// schemaSummaryCache keeps the last generated summary until the schema changes.
+type schemaSummaryCache struct {
+	mu         sync.Mutex
+	version    int64 // PRAGMA schema_version the summary was built from
+	generation int64 // Connector generation, which changes with the file
+	text       string
+}
+
+// tableProfile collects what the summary says about one table.
+type tableProfile struct {
+	name         string
+	columns      []string
+	primaryKey   []string
+	references   []Relationship // Outgoing references, declared or inferred
+	referencedBy []string       // "table.column" of incoming references
+	temporal     []string
+}
+
+// schemaSummaryHandler describes the schema in a few lines of prose: what each
+// table probably represents, its keys and how tables relate.
+func (ds *DatabaseService) schemaSummaryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	refresh, _ := args["refresh"].(bool)
+
+	var version int64
+	generation := ds.connector.generation.Load()
+	if err := ds.db.QueryRowContext(ctx, "PRAGMA schema_version").Scan(&version); err != nil {
+		log.Printf("Error reading schema version: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error reading schema version", err), nil
+	}
+	ds.summaryCache.mu.Lock()
+	defer ds.summaryCache.mu.Unlock()
+	if !refresh && ds.summaryCache.text != "" && ds.summaryCache.version == version && ds.summaryCache.generation == generation {
+		return mcp.NewToolResultText(ds.summaryCache.text), nil
+	}
+
+	text, err := ds.summarizeSchema(ctx)
+	if err != nil {
+		log.Printf("Error summarizing schema: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error summarizing schema", err), nil
+	}
+	ds.summaryCache.version, ds.summaryCache.generation, ds.summaryCache.text = version, generation, text
+	return mcp.NewToolResultText(text), nil
+}
+
+func (ds *DatabaseService) summarizeSchema(ctx context.Context) (string, error) {
+	names, profiles, err := ds.tableProfiles(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "The database has no tables.", nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The database has %d tables.\n", len(names))
+	for _, name := range names {
+		p := profiles[name]
+		fmt.Fprintf(&sb, "\n- %s (%d columns): %s", name, len(p.columns), p.role())
+		if len(p.primaryKey) > 0 {
+			fmt.Fprintf(&sb, " Primary key: %s.", strings.Join(p.primaryKey, ", "))
+		}
+		for _, ref := range p.references {
+			how := "references"
+			if !ref.Declared {
+				how = "probably references"
+			}
+			fmt.Fprintf(&sb, " %s %s %s.", strings.Join(ref.ChildColumns, ", "), how, ref.ParentTable)
+		}
+		if len(p.referencedBy) > 0 {
+			fmt.Fprintf(&sb, " Referenced by %s.", strings.Join(p.referencedBy, "; "))
+		}
+		if len(p.temporal) > 0 {
+			fmt.Fprintf(&sb, " Time columns: %s.", strings.Join(p.temporal, ", "))
+		}
+	}
+	return sb.String(), nil
+}
+
+// tableProfiles profiles the tables of the main schema for the summary:
+// their columns, keys and declared or guessed references.
+func (ds *DatabaseService) tableProfiles(ctx context.Context) ([]string, map[string]*tableProfile, error) {
+	schema, err := schemaSnapshot(ctx, ds.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := sortedKeys(schema)
+
+	profiles := make(map[string]*tableProfile, len(names))
+	for _, name := range names {
+		p := &tableProfile{name: name}
+		rows, err := ds.db.QueryContext(ctx, "SELECT name, type FROM pragma_table_info(?) ORDER BY cid", name)
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			var column, colType string
+			if err := rows.Scan(&column, &colType); err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			p.columns = append(p.columns, column)
+			if temporalColumnPattern.MatchString(column) || temporalColumnPattern.MatchString(colType) {
+				p.temporal = append(p.temporal, column)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+		if p.primaryKey, err = ds.dialect.PrimaryKey(ctx, name); err != nil {
+			return nil, nil, err
+		}
+		if p.references, err = ds.dialect.ForeignKeys(ctx, name); err != nil {
+			return nil, nil, err
+		}
+		profiles[name] = p
+	}
+
+	// Undeclared references are guessed from <table>_id column names
+	for _, p := range profiles {
+		declared := make(map[string]bool)
+		for _, ref := range p.references {
+			for _, column := range ref.ChildColumns {
+				declared[column] = true
+			}
+		}
+		for _, column := range p.columns {
+			base, ok := strings.CutSuffix(strings.ToLower(column), "_id")
+			if !ok || declared[column] {
+				continue
+			}
+			if parent := matchTableName(base, profiles); parent != "" && parent != p.name {
+				p.references = append(p.references, Relationship{ChildTable: p.name, ChildColumns: []string{column}, ParentTable: parent})
+			}
+		}
+	}
+	for _, name := range names {
+		for _, ref := range profiles[name].references {
+			if parent, ok := profiles[ref.ParentTable]; ok {
+				parent.referencedBy = append(parent.referencedBy, name+"."+strings.Join(ref.ChildColumns, ","))
+			}
+		}
+	}
+	return names, profiles, nil
+}
+
+// role guesses what a table represents from its shape and relationships.
+func (p *tableProfile) role() string {
+	refColumns := 0
+	for _, ref := range p.references {
+		refColumns += len(ref.ChildColumns)
+	}
+	switch {
+	case len(p.references) >= 2 && len(p.columns) <= refColumns+2:
+		parents := make([]string, len(p.references))
+		for i, ref := range p.references {
+			parents[i] = ref.ParentTable
+		}
+		return fmt.Sprintf("Likely a link table joining %s (many-to-many).", strings.Join(parents, " and "))
+	case len(p.references) > 0 && len(p.temporal) > 0:
+		return "Likely records events or transactions over time."
+	case len(p.referencedBy) > 0 && len(p.references) == 0 && len(p.columns) <= 3:
+		return "Likely a lookup table of codes or categories."
+	case len(p.referencedBy) > 0:
+		return "Likely a core entity referenced by other tables."
+	case len(p.references) > 0:
+		return "Likely a detail table belonging to its parent."
+	default:
+		return "Standalone table."
+	}
+}
+
+// matchTableName finds the table a <name>_id column points at, trying the
+// common plural forms of name.
+func matchTableName(name string, profiles map[string]*tableProfile) string {
+	candidates := []string{name, name + "s", name + "es"}
+	if stem, ok := strings.CutSuffix(name, "y"); ok {
+		candidates = append(candidates, stem+"ies")
+	}
+	for _, candidate := range candidates {
+		for table := range profiles {
+			if strings.EqualFold(table, candidate) {
+				return table
+			}
+		}
+	}
+	return ""
+}
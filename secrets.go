@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Settings that carry credentials (DB_DSN, LIBSQL_AUTH_TOKEN, ...) can be
+// kept out of the environment:
+//
+//	DB_DSN_FILE=/run/secrets/dsn                 # read from a file
+//	DB_DSN=vault:secret/data/db-mcp#dsn          # a Vault KV secret field
+//	DB_DSN=aws-sm:prod/db-mcp#dsn                # an AWS Secrets Manager secret (JSON field)
+//
+// The #field suffix selects one field of the secret. Without it an AWS secret
+// is used whole and a Vault secret must have a single field.
+
+// secretTimeout bounds the lookup of a single secret in a secret manager.
+const secretTimeout = 30 * time.Second
+
+// secretEnv returns the value of an environment variable that may hold a
+// secret, reading it from NAME_FILE or resolving a secret manager reference.
+func secretEnv(name string) (string, error) {
+	value := os.Getenv(name)
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		if value != "" {
+			return "", fmt.Errorf("both %s and %s_FILE are set", name, name)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading %s_FILE: %w", name, err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	switch {
+	case strings.HasPrefix(value, "vault:"):
+		ref, field, _ := strings.Cut(strings.TrimPrefix(value, "vault:"), "#")
+		return vaultSecret(ctx, ref, field)
+	case strings.HasPrefix(value, "aws-sm:"):
+		ref, field, _ := strings.Cut(strings.TrimPrefix(value, "aws-sm:"), "#")
+		secret, err := awsSecret(ctx, ref)
+		if err != nil {
+			return "", err
+		}
+		return secretField(secret, field)
+	}
+	return value, nil
+}
+
+// secretField extracts a field of a JSON object secret, or returns the whole
+// secret when field is empty.
+func secretField(secret, field string) (string, error) {
+	if field == "" {
+		return secret, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, cannot select field %q", field)
+	}
+	value, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("secret has no field %q", field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// vaultSecret reads a field of a KV secret from HashiCorp Vault at VAULT_ADDR,
+// authenticating with VAULT_TOKEN (or VAULT_TOKEN_FILE). Both KV version 1
+// (secret/db) and version 2 (secret/data/db) paths are understood.
+func vaultSecret(ctx context.Context, path, field string) (string, error) {
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", fmt.Errorf("vault: reference needs VAULT_ADDR")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if tokenFile := os.Getenv("VAULT_TOKEN_FILE"); tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("reading VAULT_TOKEN_FILE: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" {
+		return "", fmt.Errorf("vault: reference needs VAULT_TOKEN or VAULT_TOKEN_FILE")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("reading Vault secret %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("reading Vault secret %s: %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var secret struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("invalid Vault response for %s: %w", path, err)
+	}
+	data := secret.Data
+	if nested, ok := data["data"]; ok && data["metadata"] != nil {
+		// KV version 2 wraps the secret with its metadata
+		data = nil
+		if err := json.Unmarshal(nested, &data); err != nil {
+			return "", fmt.Errorf("invalid Vault KV v2 secret %s: %w", path, err)
+		}
+	}
+	if field == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("Vault secret %s has %d fields; select one with #field", path, len(data))
+		}
+		for f := range data {
+			field = f
+		}
+	}
+	raw, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("Vault secret %s has no field %q", path, field)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return strings.TrimSpace(string(raw)), nil
+	}
+	return value, nil
+}
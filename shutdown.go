@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// defaultShutdownGrace is how long shutdown waits for active tool calls and
+// downloads by default.
+const defaultShutdownGrace = 30 * time.Second
+
+// streamCloseDelay gives the shutdown notice time to reach the event streams
+// of clients before they are closed.
+const streamCloseDelay = 500 * time.Millisecond
+
+// shutdownGraceFromEnv reads SHUTDOWN_GRACE.
+func shutdownGraceFromEnv() (time.Duration, error) {
+	v := os.Getenv("SHUTDOWN_GRACE")
+	if v == "" {
+		return defaultShutdownGrace, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid SHUTDOWN_GRACE %q", v)
+	}
+	return d, nil
+}
+
+// drainer shuts the HTTP server down in stages: new sessions are refused and
+// event streams closed at once, active requests get a grace period, and only
+// then are the queries still running cancelled.
+type drainer struct {
+	grace time.Duration
+
+	draining      context.Context // Done once shutdown starts
+	startDraining context.CancelFunc
+	requests      context.Context // Base of every request context, done when the grace period ends
+	cancelAll     context.CancelFunc
+}
+
+func newDrainer(grace time.Duration) *drainer {
+	d := &drainer{grace: grace}
+	d.draining, d.startDraining = context.WithCancel(context.Background())
+	d.requests, d.cancelAll = context.WithCancel(context.Background())
+	return d
+}
+
+// baseContext is the http.Server BaseContext, so tool calls can outlive the
+// start of shutdown but not the grace period.
+func (d *drainer) baseContext(net.Listener) context.Context {
+	return d.requests
+}
+
+// handler refuses requests opening a new MCP session once shutdown has
+// started, and ends event streams then instead of at the end of the grace
+// period, since they never complete on their own.
+func (d *drainer) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only initialize requests come without a session ID
+		if d.draining.Err() != nil && r.Method == http.MethodPost && r.Header.Get("Mcp-Session-Id") == "" {
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			stop := context.AfterFunc(d.draining, cancel)
+			defer stop()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// shutdown drains srv: it marks the server not ready, tells connected
+// clients the server is going away, waits up to the grace period for active
+// requests, then cancels what is left and closes the remaining connections.
+func (d *drainer) shutdown(srv *http.Server, mcpServer *server.MCPServer, health *dbHealth) {
+	log.Printf("Shutting down; waiting up to %s for active requests", d.grace)
+	health.beginShutdown()
+	mcpServer.SendNotificationToAllClients("notifications/message", map[string]any{
+		"level":  mcp.LoggingLevelWarning,
+		"logger": "server",
+		"data": map[string]any{
+			"event":   "shutdown",
+			"message": "The server is shutting down; reconnect to start a new session.",
+		},
+	})
+	time.Sleep(min(streamCloseDelay, d.grace))
+	d.startDraining()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.grace)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	d.cancelAll()
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("Grace period over; cancelling the requests still running")
+		// Let cancelled tool calls return their errors before the connections close
+		time.Sleep(streamCloseDelay)
+		srv.Close()
+	} else if err != nil {
+		log.Printf("HTTP server shutdown error: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// snowflakeDialect describes Snowflake databases. Tables and columns come from
+// INFORMATION_SCHEMA; Snowflake has no KEY_COLUMN_USAGE view, so keys come
+// from SHOW PRIMARY KEYS and SHOW IMPORTED KEYS.
+type snowflakeDialect struct {
+	infoSchemaDialect
+}
+
+func newSnowflakeDialect(db *sql.DB, dsn string) Dialect {
+	return snowflakeDialect{infoSchemaDialect{
+		db:            db,
+		currentSchema: "CURRENT_SCHEMA()",
+		systemSchemas: []string{"INFORMATION_SCHEMA"},
+	}}
+}
+
+// snowflakeOptions are the session settings applied to a Snowflake DSN.
+type snowflakeOptions struct {
+	Warehouse string
+	Role      string
+	Database  string
+	MaxRows   int // Rows returned per result set; 0 for no limit
+}
+
+// snowflakeDSN applies opts to a gosnowflake DSN
+// (user:password@account/database/schema?warehouse=WH&role=ROLE). MaxRows
+// becomes the ROWS_PER_RESULTSET session parameter, so Snowflake stops
+// producing rows the row budget would discard anyway.
+func snowflakeDSN(dsn string, opts snowflakeOptions) (string, error) {
+	base, rawQuery, _ := strings.Cut(dsn, "?")
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", fmt.Errorf("invalid Snowflake DSN parameters: %w", err)
+	}
+	if opts.Database != "" {
+		at := strings.LastIndexByte(base, '@')
+		host, path, _ := strings.Cut(base[at+1:], "/")
+		_, schema, _ := strings.Cut(path, "/")
+		base = base[:at+1] + host + "/" + opts.Database
+		if schema != "" {
+			base += "/" + schema
+		}
+	}
+	if opts.Warehouse != "" {
+		params.Set("warehouse", opts.Warehouse)
+	}
+	if opts.Role != "" {
+		params.Set("role", opts.Role)
+	}
+	if opts.MaxRows > 0 {
+		params.Set("ROWS_PER_RESULTSET", strconv.Itoa(opts.MaxRows))
+	}
+	// One statement per query, whatever the DSN asked for
+	params.Set("MULTI_STATEMENT_COUNT", "1")
+	return base + "?" + params.Encode(), nil
+}
+
+// DescribeTable adds the keys from SHOW PRIMARY KEYS and SHOW IMPORTED KEYS
+// to the INFORMATION_SCHEMA description.
+func (d snowflakeDialect) DescribeTable(ctx context.Context, table string) (*TableDescription, error) {
+	desc, err := d.infoSchemaDialect.DescribeTable(ctx, table)
+	if err != nil || len(desc.Columns) == 0 {
+		return desc, err
+	}
+	if desc.PrimaryKey, err = d.PrimaryKey(ctx, table); err != nil {
+		return nil, err
+	}
+	for i, key := range desc.PrimaryKey {
+		for _, column := range desc.Columns {
+			if column["name"] == key {
+				column["pk"] = i + 1
+			}
+		}
+	}
+	if desc.ForeignKeys, err = d.foreignKeys(ctx, table); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+// PrimaryKey returns the columns of the table's primary key. Snowflake does
+// not enforce it, but declares it for tools like this one.
+func (d snowflakeDialect) PrimaryKey(ctx context.Context, table string) ([]string, error) {
+	rows, err := d.show(ctx, "SHOW PRIMARY KEYS IN TABLE "+d.quoteTable(table))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(rows))
+	for _, row := range rows {
+		seq, err := strconv.Atoi(row["key_sequence"])
+		if err != nil || seq < 1 || seq > len(keys) {
+			return nil, fmt.Errorf("unexpected key_sequence %q in SHOW PRIMARY KEYS", row["key_sequence"])
+		}
+		keys[seq-1] = row["column_name"]
+	}
+	return keys, nil
+}
+
+// ForeignKeys returns the relationships declared on a table. Parents are
+// qualified when they live in another schema.
+func (d snowflakeDialect) ForeignKeys(ctx context.Context, table string) ([]Relationship, error) {
+	fks, err := d.foreignKeys(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	relationships := make([]Relationship, len(fks))
+	for i, fk := range fks {
+		relationships[i] = Relationship{ChildTable: table, ChildColumns: fk.Columns, ParentTable: fk.ReferencesTable, ParentColumns: fk.ReferencesColumns, Declared: true}
+	}
+	return relationships, nil
+}
+
+func (d snowflakeDialect) foreignKeys(ctx context.Context, table string) ([]ForeignKeyInfo, error) {
+	rows, err := d.show(ctx, "SHOW IMPORTED KEYS IN TABLE "+d.quoteTable(table))
+	if err != nil {
+		return nil, err
+	}
+	fks := []ForeignKeyInfo{}
+	lastName := ""
+	for _, row := range rows {
+		if row["fk_name"] != lastName || len(fks) == 0 {
+			parent := row["pk_table_name"]
+			if row["pk_schema_name"] != row["fk_schema_name"] {
+				parent = row["pk_schema_name"] + "." + parent
+			}
+			fks = append(fks, ForeignKeyInfo{ReferencesTable: parent, OnUpdate: row["update_rule"], OnDelete: row["delete_rule"]})
+			lastName = row["fk_name"]
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, row["fk_column_name"])
+		fk.ReferencesColumns = append(fk.ReferencesColumns, row["pk_column_name"])
+	}
+	return fks, nil
+}
+
+func (d snowflakeDialect) quoteTable(table string) string {
+	schema, name := d.SplitTable(table)
+	if schema == "" {
+		return d.QuoteIdent(name)
+	}
+	return d.QuoteIdent(schema) + "." + d.QuoteIdent(name)
+}
+
+// show runs a SHOW command and returns its rows keyed by column name.
+func (d snowflakeDialect) show(ctx context.Context, query string) ([]map[string]string, error) {
+	rows, err := d.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			row[strings.ToLower(column)] = values[i].String
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// ValidateReadOnly accepts the statements Snowflake can only read with.
+// snowflakeDSN pins MULTI_STATEMENT_COUNT to 1, so a query cannot carry a
+// second statement.
+func (d snowflakeDialect) ValidateReadOnly(query string) error {
+	fields := strings.Fields(strings.ToUpper(query))
+	if len(fields) > 0 {
+		switch fields[0] {
+		case "SELECT", "WITH", "SHOW", "DESCRIBE", "DESC":
+			return nil
+		}
+	}
+	return fmt.Errorf("only SELECT, WITH, SHOW and DESCRIBE statements are allowed for read-only access")
+}
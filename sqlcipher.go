@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// sqlcipherEnabled is set when the binary is built with the sqlcipher tag,
+// whose SQLite driver can open encrypted database files.
+var sqlcipherEnabled bool
+
+// errNoKeyUnlocks is returned when none of the configured keys opens the
+// database. The keys themselves never appear in errors or logs.
+var errNoKeyUnlocks = errors.New("none of DB_KEY and DB_KEY_PREVIOUS unlocks the database")
+
+// databaseKeys returns the SQLCipher keys to try, current first: DB_KEY,
+// then the comma or newline separated DB_KEY_PREVIOUS. Keep the old key in
+// DB_KEY_PREVIOUS while a file is being re-encrypted, and the server opens
+// it before and after the change. Keys are passphrases, or raw keys written
+// as x'…' with 64 hex digits.
+func databaseKeys(current, previous string) ([]string, error) {
+	if current == "" {
+		if previous != "" {
+			return nil, fmt.Errorf("DB_KEY_PREVIOUS is set without DB_KEY")
+		}
+		return nil, nil
+	}
+	keys := []string{current}
+	for _, key := range strings.FieldsFunc(previous, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if key = strings.TrimSpace(key); key != "" && key != current {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// keyStatement is the PRAGMA unlocking a connection with key.
+func keyStatement(pragma, key string) string {
+	return fmt.Sprintf("PRAGMA %s = '%s'", pragma, strings.ReplaceAll(key, "'", "''"))
+}
+
+// unlock opens a connection with the first key that can read the schema,
+// since a wrong key only fails on the first read. It returns the index of
+// the key used.
+func (c *initConnector) unlock(ctx context.Context) (driver.Conn, int, error) {
+	for i, key := range c.keys {
+		conn, err := c.driver.Open(c.currentDSN())
+		if err != nil {
+			return nil, 0, err
+		}
+		if execConn(ctx, conn, keyStatement("key", key)) == nil && execConn(ctx, conn, "SELECT count(*) FROM sqlite_schema") == nil {
+			if i > 0 && c.previousKey.CompareAndSwap(false, true) {
+				log.Printf("Database opened with a key from DB_KEY_PREVIOUS; re-encrypt it with DB_KEY (or set DB_REKEY=true) to complete the key rotation")
+			}
+			return conn, i, nil
+		}
+		conn.Close()
+	}
+	return nil, 0, errNoKeyUnlocks
+}
+
+// rekeyDatabase re-encrypts the database file at path with the current key
+// when it is still encrypted with a previous one, completing a key rotation.
+func rekeyDatabase(ctx context.Context, path string, keys []string) error {
+	probe, err := sql.Open("sqlite", writeDSN(path))
+	if err != nil {
+		return err
+	}
+	connector := &initConnector{driver: probe.Driver(), dsn: writeDSN(path), keys: keys}
+	probe.Close()
+	conn, used, err := connector.unlock(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if used == 0 {
+		return nil
+	}
+	if err := execConn(ctx, conn, keyStatement("rekey", keys[0])); err != nil {
+		return fmt.Errorf("re-encrypting the database: %w", err)
+	}
+	log.Printf("Re-encrypted the database with DB_KEY; the keys in DB_KEY_PREVIOUS are no longer needed")
+	return nil
+}
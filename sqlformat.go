@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// sqlKeywords are the words format_sql upper-cases when asked to.
+var sqlKeywords = toSet(strings.Fields(`ALL AND AS ASC BETWEEN BY CASE CAST COLLATE CROSS CURRENT DELETE
+	DESC DISTINCT DO ELSE END ESCAPE EXCEPT EXISTS FALSE FILTER FIRST FOLLOWING FROM FULL GLOB GROUP
+	HAVING IGNORE ILIKE IN INNER INSERT INTERSECT INTO IS ISNULL JOIN LAST LEFT LIKE LIMIT MATERIALIZED
+	NATURAL NOT NOTHING NOTNULL NULL NULLS OFFSET ON OR ORDER OUTER OVER PARTITION PRECEDING RANGE
+	RECURSIVE REGEXP RETURNING RIGHT ROW ROWS SELECT SET THEN TRUE UNBOUNDED UNION UPDATE USING VALUES
+	WHEN WHERE WINDOW WITH`))
+
+// sqlClauses start a new line when they appear outside parentheses or at
+// the top of a subquery.
+var sqlClauses = toSet(strings.Fields(`SELECT FROM WHERE GROUP ORDER HAVING LIMIT UNION INTERSECT EXCEPT
+	WITH VALUES SET RETURNING WINDOW INSERT UPDATE DELETE JOIN LEFT RIGHT FULL INNER CROSS NATURAL`))
+
+// sqlJoinModifiers may precede JOIN, which then stays on their line.
+var sqlJoinModifiers = toSet(strings.Fields("LEFT RIGHT FULL INNER CROSS NATURAL OUTER"))
+
+// sqlOperators are the punctuation tokens longer than one character.
+var sqlOperators = []string{"->>", "->", "::", "<=", ">=", "<>", "!=", "==", "||", "<<", ">>"}
+
+func toSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// sqlToken is a lexical token of a SQL statement.
+type sqlToken struct {
+	Text string
+	Kind byte // w word or number, q quoted, c comment, p punctuation
+}
+
+// tokenizeSQL splits SQL into words, quoted strings and identifiers,
+// comments and punctuation, dropping whitespace.
+func tokenizeSQL(sql string) []sqlToken {
+	var tokens []sqlToken
+	isWord := func(c byte) bool {
+		return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+	}
+	for i := 0; i < len(sql); {
+		c, start := sql[i], i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			// A doubled quote is an escaped quote inside the literal
+			for i++; i < len(sql); i++ {
+				if sql[i] == closing {
+					if i+1 < len(sql) && sql[i+1] == closing && c != '[' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i = min(i+1, len(sql))
+			tokens = append(tokens, sqlToken{sql[start:i], 'q'})
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			i += end
+			tokens = append(tokens, sqlToken{strings.TrimRight(sql[start:i], " \t\r"), 'c'})
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+			tokens = append(tokens, sqlToken{sql[start:i], 'c'})
+		case isWord(c) || (c == ':' || c == '@' || c == '?') && i+1 < len(sql) && isWord(sql[i+1]) && !strings.HasPrefix(sql[i:], "::"):
+			// Parameters such as :id, @id and ?1 are single words
+			for i++; i < len(sql) && isWord(sql[i]); i++ {
+			}
+			tokens = append(tokens, sqlToken{sql[start:i], 'w'})
+		default:
+			i++
+			for _, op := range sqlOperators {
+				if strings.HasPrefix(sql[start:], op) {
+					i = start + len(op)
+					break
+				}
+			}
+			tokens = append(tokens, sqlToken{sql[start:i], 'p'})
+		}
+	}
+	return tokens
+}
+
+// formatSQL lays out SQL one clause per line, with the SELECT list and the
+// AND/OR conditions of WHERE, HAVING and ON indented one item per line and
+// subqueries indented under their parenthesis. Keywords are upper-cased when
+// uppercase is set; everything else keeps its text.
+func formatSQL(sql string, uppercase bool) string {
+	tokens := tokenizeSQL(sql)
+	// A level is the top of a statement or a parenthesis
+	type level struct {
+		subquery bool
+		base     int    // Indentation of clauses
+		clause   string // Current clause, for subqueries
+	}
+	levels := []level{{subquery: true}}
+	var out []byte
+	lineStart, lineIndent, prev, prevKeyword := 0, 0, sqlToken{}, false
+	selectList, between := false, false // Expecting the first SELECT item; inside BETWEEN ... AND
+
+	// newline starts a line, or re-indents the current one if it is empty
+	newline := func(indent int) {
+		if prev.Text == "" {
+			out = out[:lineStart]
+		} else {
+			out = append(out, '\n')
+		}
+		lineStart = len(out)
+		out = append(out, strings.Repeat("  ", indent)...)
+		lineIndent, prev = indent, sqlToken{}
+	}
+	write := func(t sqlToken, text string) {
+		switch {
+		case prev.Text == "" || prev.Text == "(" || prev.Text == "." || prev.Text == "::":
+		case t.Text == "," || t.Text == ")" || t.Text == "." || t.Text == ";" || t.Text == "::":
+		case t.Text == "(" && prev.Kind == 'w' && (!prevKeyword || strings.EqualFold(prev.Text, "CAST")):
+			// Function call
+		default:
+			out = append(out, ' ')
+		}
+		out = append(out, text...)
+		prev = t
+	}
+	// nextWord returns the upper-cased word after token i, skipping comments
+	nextWord := func(i int) string {
+		for i++; i < len(tokens); i++ {
+			if tokens[i].Kind != 'c' {
+				return strings.ToUpper(tokens[i].Text)
+			}
+		}
+		return ""
+	}
+
+	for i, t := range tokens {
+		cur := &levels[len(levels)-1]
+		text, upper := t.Text, strings.ToUpper(t.Text)
+		// LEFT and RIGHT are also string functions
+		keyword := t.Kind == 'w' && sqlKeywords[upper] && prev.Text != "." &&
+			!(sqlJoinModifiers[upper] && nextWord(i) == "(")
+		if keyword && uppercase {
+			text = upper
+		}
+
+		if t.Kind == 'c' {
+			write(t, text)
+			if strings.HasPrefix(text, "--") {
+				newline(lineIndent)
+			}
+			continue
+		}
+		if selectList && !(keyword && (upper == "DISTINCT" || upper == "ALL")) {
+			selectList = false
+			if !(keyword && sqlClauses[upper]) {
+				newline(cur.base + 1)
+			}
+		}
+
+		switch {
+		case keyword && sqlClauses[upper] && cur.subquery:
+			// A join starts a line at its first word: LEFT OUTER JOIN
+			if !sqlJoinModifiers[strings.ToUpper(prev.Text)] || upper != "JOIN" && !sqlJoinModifiers[upper] {
+				newline(cur.base)
+			}
+			write(t, text)
+			cur.clause = upper
+			selectList = upper == "SELECT"
+		case keyword && upper == "ON" && cur.subquery:
+			write(t, text)
+			cur.clause = upper
+		case keyword && upper == "BETWEEN":
+			write(t, text)
+			between = true
+		case keyword && (upper == "AND" || upper == "OR") && cur.subquery && !between &&
+			(cur.clause == "WHERE" || cur.clause == "HAVING" || cur.clause == "ON"):
+			newline(cur.base + 1)
+			write(t, text)
+		case t.Text == "," && cur.subquery && cur.clause == "SELECT":
+			write(t, text)
+			newline(cur.base + 1)
+		case t.Text == "(":
+			write(t, text)
+			if next := nextWord(i); next == "SELECT" || next == "WITH" {
+				levels = append(levels, level{subquery: true, base: lineIndent + 1})
+				newline(lineIndent + 1)
+			} else {
+				levels = append(levels, level{base: cur.base, clause: cur.clause})
+			}
+		case t.Text == ")":
+			if len(levels) > 1 {
+				if cur.subquery {
+					newline(cur.base - 1)
+				}
+				levels = levels[:len(levels)-1]
+			}
+			write(t, text)
+		case t.Text == ";":
+			write(t, text)
+			levels = []level{{subquery: true}}
+			if i < len(tokens)-1 {
+				out = append(out, '\n')
+				newline(0)
+			}
+		default:
+			if keyword && upper == "AND" {
+				between = false
+			}
+			write(t, text)
+		}
+		prevKeyword = keyword
+	}
+
+	lines := strings.Split(string(out), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatSQLHandler pretty-prints a SQL statement. It does not need the
+// database and accepts any statement, since nothing is run.
+func (ds *DatabaseService) formatSQLHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	sql, _ := args["sql"].(string)
+	if strings.TrimSpace(sql) == "" {
+		return mcp.NewToolResultError("Missing or invalid 'sql' argument."), nil
+	}
+	uppercase, _ := args["uppercase"].(bool)
+	return mcp.NewToolResultText(formatSQL(sql, uppercase)), nil
+}
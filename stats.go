@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// maxTrackedSessions bounds the per-session counters kept in memory; the least
+// recently active sessions are forgotten first.
+const maxTrackedSessions = 1000
+
+// callRowsKey is a context key for the row counter of the current tool call.
+type callRowsKey struct{}
+
+// UsageCounters aggregates tool call activity.
+type UsageCounters struct {
+	Queries       int64     `json:"queries"`
+	RowsReturned  int64     `json:"rows_returned"`
+	BytesReturned int64     `json:"bytes_returned"`
+	Errors        int64     `json:"errors"`
+	TimeSpentMs   int64     `json:"time_spent_ms"`
+	LastActive    time.Time `json:"last_active,omitempty"`
+}
+
+func (u *UsageCounters) add(rows, bytes int64, failed bool, elapsed time.Duration) {
+	u.Queries++
+	u.RowsReturned += rows
+	u.BytesReturned += bytes
+	if failed {
+		u.Errors++
+	}
+	u.TimeSpentMs += elapsed.Milliseconds()
+	u.LastActive = time.Now()
+}
+
+// SessionStats tracks usage per MCP session, per tool and for the whole server.
+type SessionStats struct {
+	mu       sync.Mutex
+	started  time.Time
+	total    UsageCounters
+	sessions map[string]*UsageCounters
+	tools    map[string]*toolMetrics
+}
+
+// NewSessionStats creates an empty statistics registry.
+func NewSessionStats() *SessionStats {
+	return &SessionStats{
+		started:  time.Now(),
+		sessions: make(map[string]*UsageCounters),
+		tools:    make(map[string]*toolMetrics),
+	}
+}
+
+// sessionID returns the MCP session of the current request, or "" for sessionless calls.
+func sessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// addRowsReturned records rows produced by the current tool call.
+func addRowsReturned(ctx context.Context, n int) {
+	if counter, ok := ctx.Value(callRowsKey{}).(*atomic.Int64); ok {
+		counter.Add(int64(n))
+	}
+}
+
+// middleware records every tool call against its session, its tool and the
+// server totals.
+func (s *SessionStats) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		rows := &atomic.Int64{}
+		start := time.Now()
+		result, err := next(context.WithValue(ctx, callRowsKey{}, rows), request)
+
+		var bytes int64
+		if result != nil {
+			for _, content := range result.Content {
+				if text, ok := content.(mcp.TextContent); ok {
+					bytes += int64(len(text.Text))
+				}
+			}
+		}
+		s.record(sessionID(ctx), request.Params.Name, errorClass(result, err), rows.Load(), bytes, time.Since(start))
+		return result, err
+	}
+}
+
+func (s *SessionStats) record(session, tool, class string, rows, bytes int64, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failed := class != ""
+	s.total.add(rows, bytes, failed, elapsed)
+	s.recordTool(tool, class, rows, bytes, elapsed.Seconds())
+	if session == "" {
+		return
+	}
+	counters, ok := s.sessions[session]
+	if !ok {
+		if len(s.sessions) >= maxTrackedSessions {
+			s.evictOldest()
+		}
+		counters = &UsageCounters{}
+		s.sessions[session] = counters
+	}
+	counters.add(rows, bytes, failed, elapsed)
+}
+
+// evictOldest drops the least recently active tenth of the tracked sessions.
+func (s *SessionStats) evictOldest() {
+	ids := make([]string, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return s.sessions[ids[i]].LastActive.Before(s.sessions[ids[j]].LastActive)
+	})
+	for _, id := range ids[:len(ids)/10+1] {
+		delete(s.sessions, id)
+	}
+}
+
+// sessionStatsHandler returns the counters of the calling session and the server-wide totals.
+func (s *SessionStats) sessionStatsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id := sessionID(ctx)
+
+	s.mu.Lock()
+	report := map[string]interface{}{
+		"server": map[string]interface{}{
+			"uptime_seconds":  int64(time.Since(s.started).Seconds()),
+			"active_sessions": len(s.sessions),
+			"totals":          s.total,
+		},
+	}
+	if id != "" {
+		session := UsageCounters{}
+		if counters, ok := s.sessions[id]; ok {
+			session = *counters
+		}
+		report["session_id"] = id
+		report["session"] = session
+	}
+	s.mu.Unlock()
+
+	resultJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling session stats to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting session stats", err), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Limits of the number of objects listed by storage_report.
+const (
+	defaultStorageObjects = 50
+	maxStorageObjects     = 1000
+)
+
+// StorageObject is the space used by one table or index b-tree.
+type StorageObject struct {
+	Name              string  `json:"name"`
+	Type              string  `json:"type"`            // table, index, or internal for sqlite_ objects
+	Table             string  `json:"table,omitempty"` // Table an index belongs to
+	Bytes             int64   `json:"bytes"`
+	Pages             int64   `json:"pages"`
+	PayloadBytes      int64   `json:"payload_bytes"`
+	UnusedBytes       int64   `json:"unused_bytes"`
+	UnusedPercent     float64 `json:"unused_percent"`     // Allocated space not holding data
+	FragmentedPercent float64 `json:"fragmented_percent"` // Pages not stored right after the previous page of the b-tree
+	Cells             int64   `json:"cells"`
+}
+
+// StorageReport is the payload returned by storage_report.
+type StorageReport struct {
+	Schema      string          `json:"schema"`
+	Database    DatabaseSize    `json:"database"`
+	FreePercent float64         `json:"free_percent"` // Free pages VACUUM would reclaim
+	Objects     []StorageObject `json:"objects"`
+	Omitted     int             `json:"omitted,omitempty"` // Smaller objects left out by the limit
+}
+
+// storageReportHandler reports the space used by every table and index of a
+// schema, largest first, from the dbstat virtual table.
+func (ds *DatabaseService) storageReportHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if _, ok := ds.dialect.(sqliteDialect); !ok {
+		return mcp.NewToolResultError("storage_report is only supported for SQLite databases."), nil
+	}
+	args := request.GetArguments()
+	schema, _ := args["schema"].(string)
+	if schema == "" {
+		schema = "main"
+	}
+	limit := defaultStorageObjects
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(math.Min(v, maxStorageObjects))
+	}
+
+	report := StorageReport{Schema: schema, Objects: []StorageObject{}}
+	var err error
+	if report.Database, err = databaseSize(ctx, ds.db, schema); err != nil {
+		log.Printf("Error reading database size: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error reading database size", err), nil
+	}
+	if report.Database.Pages > 0 {
+		report.FreePercent = percent(report.Database.FreePages, report.Database.Pages)
+	}
+
+	// dbstat returns the pages of each b-tree in traversal order, so a page
+	// whose number does not follow the previous one is out of sequence
+	query := fmt.Sprintf(`SELECT d.name, COALESCE(s.type, 'internal'), COALESCE(s.tbl_name, ''),
+			COUNT(*), SUM(d.pgsize), SUM(d.payload), SUM(d.unused), SUM(d.ncell),
+			SUM(CASE WHEN d.prev IS NOT NULL AND d.pageno <> d.prev + 1 THEN 1 ELSE 0 END)
+		FROM (SELECT name, pageno, pgsize, payload, unused, ncell,
+				LAG(pageno) OVER (PARTITION BY name ORDER BY path) AS prev
+			FROM dbstat(?)) AS d
+		LEFT JOIN %s.sqlite_schema AS s ON s.name = d.name
+		GROUP BY d.name ORDER BY SUM(d.pgsize) DESC, d.name`, ds.dialect.QuoteIdent(schema))
+	rows, err := ds.db.QueryContext(ctx, query, schema)
+	if err != nil {
+		log.Printf("Error reading dbstat: %v", err)
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		if strings.Contains(err.Error(), "no such table") {
+			return mcp.NewToolResultError("This SQLite build has no dbstat virtual table (SQLITE_ENABLE_DBSTAT_VTAB)."), nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error reading storage statistics", err), nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var obj StorageObject
+		var outOfOrder int64
+		if err := rows.Scan(&obj.Name, &obj.Type, &obj.Table, &obj.Pages, &obj.Bytes, &obj.PayloadBytes, &obj.UnusedBytes, &obj.Cells, &outOfOrder); err != nil {
+			return mcp.NewToolResultErrorFromErr("Error reading storage statistics", err), nil
+		}
+		if len(report.Objects) >= limit {
+			report.Omitted++
+			continue
+		}
+		if obj.Type != "index" {
+			obj.Table = ""
+		}
+		obj.UnusedPercent = percent(obj.UnusedBytes, obj.Bytes)
+		obj.FragmentedPercent = percent(outOfOrder, obj.Pages)
+		report.Objects = append(report.Objects, obj)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating dbstat: %v", err)
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error reading storage statistics", err), nil
+	}
+
+	resultJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling storage report to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting storage report", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// percent returns part as a percentage of whole, rounded to one decimal.
+func percent(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*1000/float64(whole)) / 10
+}
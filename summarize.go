@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// aggregateSpecPattern parses aggregate specs such as "sum(amount)" or "count(*)".
+var aggregateSpecPattern = regexp.MustCompile(`(?i)^\s*(count|count_distinct|sum|avg|min|max)\s*(?:\(\s*(.*?)\s*\))?\s*$`)
+
+// summaryAggregate parses an aggregate spec into its SQL expression, result
+// column name and the column it aggregates, "" for count(*). The column must
+// be one of columns, the source's.
+func summaryAggregate(spec string, columns []string) (expr, alias, column string, err error) {
+	m := aggregateSpecPattern.FindStringSubmatch(spec)
+	if m == nil {
+		return "", "", "", fmt.Errorf("invalid aggregate '%s' (expected e.g. count(*), sum(column), avg(column), min(column), max(column) or count_distinct(column))", spec)
+	}
+	fn, column := strings.ToLower(m[1]), m[2]
+	if column == "" || column == "*" {
+		if fn != "count" {
+			return "", "", "", fmt.Errorf("aggregate '%s' needs a column", spec)
+		}
+		return "COUNT(*)", "count", "", nil
+	}
+	if column, err = resolveColumn(columns, column); err != nil {
+		return "", "", "", err
+	}
+
+	alias = fn + "_" + column
+	switch fn {
+	case "count_distinct":
+		return "COUNT(DISTINCT " + quoteIdentifier(column) + ")", alias, column, nil
+	default:
+		return strings.ToUpper(fn) + "(" + quoteIdentifier(column) + ")", alias, column, nil
+	}
+}
+
+// summarizeHandler runs a GROUP BY aggregation built from column names and
+// aggregate specs, so callers never write the SQL themselves.
+func (ds *DatabaseService) summarizeHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	source, err := ds.sourceRelation(ctx, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	groupBy := request.GetStringSlice("group_by", nil)
+	specs := request.GetStringSlice("aggregates", []string{"count(*)"})
+	if len(specs) == 0 {
+		specs = []string{"count(*)"}
+	}
+	format, err := parseFormat(args["format"])
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	columns, err := ds.sourceColumns(ctx, source)
+	if err != nil {
+		return columnErrorResult(err), nil
+	}
+
+	var selects, groups []string
+	for i, column := range groupBy {
+		if column == "" {
+			return mcp.NewToolResultError("Empty column name in 'group_by'."), nil
+		}
+		if column, err = resolveColumn(columns, column); err != nil {
+			return columnErrorResult(err), nil
+		}
+		selects = append(selects, quoteIdentifier(column))
+		groups = append(groups, fmt.Sprint(i+1))
+	}
+	for _, spec := range specs {
+		expr, alias, column, err := summaryAggregate(spec, columns)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		// The alias escapes masks matched by column name
+		if result := maskedColumnResult(ctx, column); result != nil {
+			return result, nil
+		}
+		selects = append(selects, expr+" AS "+quoteIdentifier(alias))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), source)
+	if len(groups) > 0 {
+		query += fmt.Sprintf(" GROUP BY %s ORDER BY %s", strings.Join(groups, ", "), strings.Join(groups, ", "))
+	}
+
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("Error executing summary query: %v, Query: %s", err, logSQL(query))
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error executing summary query", err), nil
+	}
+	defer rows.Close()
+
+	return ds.processRows(ctx, rows, resultOptions{Format: format})
+}
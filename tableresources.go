@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	tableURIPrefix          = "db://tables/"
+	defaultResourcePageSize = 100
+	maxResourcePageSize     = 1000
+)
+
+// TableResources exposes every table the caller may read as a
+// db://tables/{name} resource. resources/list pages through the table names
+// with a cursor, and a table's description is only generated when the
+// resource is read, so large schemas stay cheap to list.
+type TableResources struct {
+	ds       *DatabaseService
+	profiles *Profiles
+	pageSize int
+}
+
+// tableResourcesFromEnv reads TABLE_RESOURCES, which turns the table
+// resources on (default false), and RESOURCE_PAGE_SIZE, the tables per
+// resources/list page (default 100). It returns nil when they are off.
+func tableResourcesFromEnv(ds *DatabaseService, profiles *Profiles) (*TableResources, error) {
+	v := os.Getenv("TABLE_RESOURCES")
+	if v == "" {
+		return nil, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TABLE_RESOURCES %q", v)
+	}
+	if !enabled {
+		return nil, nil
+	}
+	t := &TableResources{ds: ds, profiles: profiles, pageSize: defaultResourcePageSize}
+	if v := os.Getenv("RESOURCE_PAGE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxResourcePageSize {
+			return nil, fmt.Errorf("invalid RESOURCE_PAGE_SIZE %q (want 1 to %d)", v, maxResourcePageSize)
+		}
+		t.pageSize = n
+	}
+	return t, nil
+}
+
+// tableURI returns the resource URI of a table.
+func tableURI(name string) string {
+	return tableURIPrefix + url.PathEscape(name)
+}
+
+// readableTables returns the tables the caller may read, database tables
+// and mounted files alike, sorted so cursors stay stable between pages.
+func (ds *DatabaseService) readableTables(ctx context.Context, profile *Profile) ([]string, error) {
+	schemas := []string{""}
+	if ds.mountFile != "" {
+		schemas = append(schemas, mountSchema)
+	}
+	var names []string
+	for _, schema := range schemas {
+		schemaTables, err := ds.dialect.ListTables(ctx, schema, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range schemaTables {
+			if schema != "" {
+				name = schema + "." + name
+			}
+			if profile.readsTable(ds.accessName(name)) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// listHook adds a page of tables to resources/list results. The cursor is
+// the base64 encoded name of the last table of the previous page, as the
+// server encodes its own cursors, so the server's listing of the other
+// resources accepts it.
+func (t *TableResources) listHook(ctx context.Context, id any, request *mcp.ListResourcesRequest, result *mcp.ListResourcesResult) {
+	profile := t.profiles.forContext(ctx)
+	if !profile.allows("describe_table") {
+		return
+	}
+	var after string
+	if request.Params.Cursor != "" {
+		decoded, err := base64.StdEncoding.DecodeString(string(request.Params.Cursor))
+		if err != nil {
+			return
+		}
+		after = string(decoded)
+	}
+	names, err := t.ds.readableTables(ctx, profile)
+	if err != nil {
+		log.Printf("Error listing table resources: %v", err)
+		return
+	}
+	start := sort.Search(len(names), func(i int) bool { return names[i] > after })
+	page := names[start:min(start+t.pageSize, len(names))]
+	for _, name := range page {
+		result.Resources = append(result.Resources, mcp.NewResource(tableURI(name), name,
+			mcp.WithResourceDescription("Columns, types and keys of table "+name),
+			mcp.WithMIMEType("application/json"),
+		))
+	}
+	if start+len(page) < len(names) {
+		result.NextCursor = mcp.Cursor(base64.StdEncoding.EncodeToString([]byte(page[len(page)-1])))
+	}
+}
+
+// tableResourceHandler describes a table when its resource is read, as
+// describe_table does without sample values. Tables outside the caller's
+// role are reported as not found.
+func (t *TableResources) tableResourceHandler(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	name := templateArgument(request, "name")
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	profile := t.profiles.forContext(ctx)
+	if name == "" || !profile.allows("describe_table") || !profile.readsTable(t.ds.accessName(name)) {
+		return nil, fmt.Errorf("table %q not found", name)
+	}
+	describe := mcp.CallToolRequest{}
+	describe.Params.Name = "describe_table"
+	describe.Params.Arguments = map[string]any{"table_name": name}
+	result, err := t.ds.describeTableHandler(context.WithValue(ctx, profileKey{}, profile), describe)
+	if err != nil {
+		return nil, err
+	}
+	if result.IsError {
+		return nil, fmt.Errorf("%s", resultText(result))
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "application/json",
+			Text:     resultText(result),
+		},
+	}, nil
+}
+
+// templateArgument returns a variable matched from a resource template,
+// which the server passes as a list of values.
+func templateArgument(request mcp.ReadResourceRequest, name string) string {
+	switch v := request.Params.Arguments[name].(type) {
+	case string:
+		return v
+	case []string:
+		if len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
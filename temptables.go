@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	defaultTempIdleTimeout = 30 * time.Minute
+	maxTempSessions        = 100 // Sessions that may hold a pinned connection at once
+)
+
+// TempTables lets MCP sessions materialize intermediate results as
+// temporary tables and views. SQLite keeps temporary objects per
+// connection, so the first one a session creates pins a connection of the
+// read pool to the session, and the session's read_query calls run on it.
+// The connection is discarded with its temporary objects, rather than
+// returned to the pool, when the client ends the session with DELETE, when
+// the session drops its last object or after it has been idle for the idle
+// timeout.
+type TempTables struct {
+	db          *sql.DB
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*tempSession
+}
+
+type tempSession struct {
+	mu       sync.Mutex // Serializes the session's statements on conn
+	conn     *sql.Conn  // nil once discarded
+	objects  map[string]string
+	lastUsed time.Time
+	ended    bool // Removed from the sessions; a new one replaces it
+}
+
+// tempTablesFromEnv reads TEMP_TABLES, which allows sessions to create
+// temporary tables and views (default false), and TEMP_TABLES_IDLE_TIMEOUT,
+// after which an idle session's objects are dropped (default 30m). It
+// returns nil when temporary tables are off.
+func tempTablesFromEnv(db *sql.DB) (*TempTables, error) {
+	v := os.Getenv("TEMP_TABLES")
+	if v == "" {
+		return nil, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TEMP_TABLES %q", v)
+	}
+	if !enabled {
+		return nil, nil
+	}
+	t := &TempTables{db: db, idleTimeout: defaultTempIdleTimeout, sessions: make(map[string]*tempSession)}
+	if v := os.Getenv("TEMP_TABLES_IDLE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid TEMP_TABLES_IDLE_TIMEOUT %q", v)
+		}
+		t.idleTimeout = d
+	}
+	return t, nil
+}
+
+// Run drops the objects of idle sessions until ctx is cancelled, then those
+// of every session.
+func (t *TempTables) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			t.mu.Lock()
+			ids := make([]string, 0, len(t.sessions))
+			for id := range t.sessions {
+				ids = append(ids, id)
+			}
+			t.mu.Unlock()
+			for _, id := range ids {
+				t.end(id)
+			}
+			return
+		case <-ticker.C:
+			t.expire()
+		}
+	}
+}
+
+func (t *TempTables) expire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, s := range t.sessions {
+		// Sessions running a statement are in use, however long it takes
+		if !s.mu.TryLock() {
+			continue
+		}
+		if time.Since(s.lastUsed) > t.idleTimeout {
+			log.Printf("Dropping %d temporary objects of idle session %s", len(s.objects), id)
+			s.discard()
+			s.ended = true
+			delete(t.sessions, id)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// handler ends the temporary objects of sessions the client terminates with
+// DELETE. It passes requests through when temporary tables are off.
+func (t *TempTables) handler(next http.Handler) http.Handler {
+	if t == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if r.Method == http.MethodDelete {
+			if id := r.Header.Get("Mcp-Session-Id"); id != "" {
+				t.end(id)
+			}
+		}
+	})
+}
+
+// end discards the pinned connection of a session, if it has one.
+func (t *TempTables) end(id string) {
+	t.mu.Lock()
+	s := t.sessions[id]
+	delete(t.sessions, id)
+	t.mu.Unlock()
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.discard()
+	s.ended = true
+}
+
+// discard closes the pinned connection. Failing it with ErrBadConn makes
+// database/sql close it instead of returning it to the pool, where another
+// session would see the temporary objects.
+func (s *tempSession) discard() {
+	if s.conn == nil {
+		return
+	}
+	s.conn.Raw(func(any) error { return driver.ErrBadConn })
+	s.conn.Close()
+	s.conn = nil
+}
+
+// acquire returns the session with id locked, pinning a connection to it
+// first if create is set. It returns nil for sessions without temporary
+// objects when create is not set.
+func (t *TempTables) acquire(ctx context.Context, id string, create bool) (*tempSession, error) {
+	for {
+		t.mu.Lock()
+		s, ok := t.sessions[id]
+		if !ok {
+			if !create {
+				t.mu.Unlock()
+				return nil, nil
+			}
+			if len(t.sessions) >= maxTempSessions {
+				t.mu.Unlock()
+				return nil, fmt.Errorf("%d sessions already hold temporary tables; drop unused ones or try again later", maxTempSessions)
+			}
+			s = &tempSession{objects: make(map[string]string)}
+			t.sessions[id] = s
+		}
+		t.mu.Unlock()
+
+		s.mu.Lock()
+		if s.ended {
+			// Ended while we waited for it
+			s.mu.Unlock()
+			continue
+		}
+		if s.conn == nil {
+			conn, err := t.db.Conn(ctx)
+			if err != nil {
+				t.forget(id, s)
+				s.mu.Unlock()
+				return nil, err
+			}
+			s.conn = conn
+		}
+		s.lastUsed = time.Now()
+		return s, nil
+	}
+}
+
+// release unlocks a session acquired to create or drop objects, discarding
+// its connection once it has none left.
+func (t *TempTables) release(id string, s *tempSession) {
+	if len(s.objects) == 0 {
+		s.discard()
+		t.forget(id, s)
+	}
+	s.mu.Unlock()
+}
+
+// forget removes a session from the sessions. The caller holds its lock.
+func (t *TempTables) forget(id string, s *tempSession) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessions[id] == s {
+		delete(t.sessions, id)
+	}
+	s.ended = true
+}
+
+// sessionDB returns where the caller's queries run: the connection pinned
+// to its session when the session has temporary objects, the read pool
+// otherwise. release must be called once the rows have been read.
+func (ds *DatabaseService) sessionDB(ctx context.Context) (queryContexter, func()) {
+	if ds.temp != nil {
+		if s, _ := ds.temp.acquire(ctx, sessionID(ctx), false); s != nil {
+			return s.conn, s.mu.Unlock
+		}
+	}
+	return ds.db, func() {}
+}
+
+// sessionConn returns a connection that sees the caller's temporary
+// objects, to compile its queries on: the one pinned to its session, or one
+// from the read pool. release must be called once done with it.
+func (ds *DatabaseService) sessionConn(ctx context.Context) (*sql.Conn, func(), error) {
+	if ds.temp != nil {
+		if s, _ := ds.temp.acquire(ctx, sessionID(ctx), false); s != nil {
+			return s.conn, s.mu.Unlock, nil
+		}
+	}
+	conn, err := ds.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, func() { conn.Close() }, nil
+}
+
+// execTemp runs a statement creating or dropping a temporary object. The
+// read pool's connections run with PRAGMA query_only, which SQLite also
+// applies to the temp schema, so it is lifted for the statement alone.
+// Nothing but that one statement may run meanwhile: the statement is
+// refused unless it is a single CREATE TEMP or DROP of the temp schema.
+func execTemp(ctx context.Context, s *tempSession, stmt string) error {
+	upper := strings.ToUpper(stmt)
+	if err := requireSingleStatement(stmt); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(upper, "CREATE TEMP ") && !strings.HasPrefix(upper, "DROP ") {
+		return fmt.Errorf("not a temporary object statement")
+	}
+	if _, err := s.conn.ExecContext(ctx, "PRAGMA query_only = OFF"); err != nil {
+		return err
+	}
+	_, err := s.conn.ExecContext(ctx, stmt)
+	if _, restoreErr := s.conn.ExecContext(context.WithoutCancel(ctx), readOnlyConnInit); restoreErr != nil {
+		// A connection that might accept writes is not kept
+		log.Printf("Error restoring query_only on a session connection: %v", restoreErr)
+		s.discard()
+		clear(s.objects)
+		if err == nil {
+			err = restoreErr
+		}
+	}
+	return err
+}
+
+// TempObject is the payload returned by create_temp_table and drop_temp_table.
+type TempObject struct {
+	Name    string   `json:"name"`
+	Kind    string   `json:"kind"`           // "table" or "view"
+	Rows    *int64   `json:"rows,omitempty"` // Rows materialized in a table
+	Objects []string `json:"session_objects"`
+}
+
+// sessionObjects lists the temporary objects of a session, sorted.
+func (s *tempSession) sessionObjects() []string {
+	names := make([]string, 0, len(s.objects))
+	for name := range s.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// createTempTableHandler materializes a query as a temporary table, or
+// defines it as a temporary view, visible only to the caller's session.
+func (ds *DatabaseService) createTempTableHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	name, _ := args["name"].(string)
+	query, _ := args["query"].(string)
+	if name == "" || query == "" {
+		return mcp.NewToolResultError("Missing 'name' or 'query' argument."), nil
+	}
+	if err := ds.validateReadOnly(query); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if all, _ := ctx.Value(maskAllKey{}).(bool); all {
+		// Reading the object later would return the stored values unmasked
+		profile := profileFromContext(ctx)
+		return accessDeniedResult(profile, fmt.Sprintf("the query reads columns masked for the %s role, which a temporary table would keep unmasked", profile.Name)), nil
+	}
+	id := sessionID(ctx)
+	if id == "" {
+		return mcp.NewToolResultError("Temporary tables belong to an MCP session; call the tool from a session with an Mcp-Session-Id."), nil
+	}
+	kind := "table"
+	if view, _ := args["view"].(bool); view {
+		kind = "view"
+	}
+
+	s, err := ds.temp.acquire(ctx, id, true)
+	if err != nil {
+		log.Printf("Error pinning a connection for temporary tables: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error creating temporary "+kind, err), nil
+	}
+	defer ds.temp.release(id, s)
+
+	limits := ds.limitsFor(ctx)
+	quoted := ds.dialect.QuoteIdent(name)
+	// The newline keeps a trailing line comment from swallowing what follows
+	source := strings.TrimRight(strings.TrimSpace(query), "; \t\n") + "\n"
+	stmt := fmt.Sprintf("CREATE TEMP VIEW %s AS %s", quoted, source)
+	if kind == "table" {
+		if limits.MaxRows > 0 {
+			source = fmt.Sprintf("SELECT * FROM (%s) LIMIT %d", source, limits.budgetRows())
+		}
+		stmt = fmt.Sprintf("CREATE TEMP TABLE %s AS %s", quoted, source)
+	}
+	if err := execTemp(ctx, s, stmt); err != nil {
+		log.Printf("Error creating temporary %s: %v, Query: %s", kind, err, logSQL(query))
+		if result := limits.budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error creating temporary "+kind, err), nil
+	}
+	s.objects[name] = kind
+
+	result := TempObject{Name: name, Kind: kind}
+	if kind == "table" {
+		var rows int64
+		if err := s.conn.QueryRowContext(ctx, "SELECT count(*) FROM temp."+quoted).Scan(&rows); err != nil {
+			log.Printf("Error counting rows of temporary table %s: %v", name, err)
+			return mcp.NewToolResultErrorFromErr("Error counting temporary table rows", err), nil
+		}
+		if limits.MaxRows > 0 && rows > int64(limits.MaxRows) {
+			if err := execTemp(ctx, s, "DROP TABLE temp."+quoted); err == nil {
+				delete(s.objects, name)
+			}
+			return limits.budgetError(ctx, errRowBudgetExceeded), nil
+		}
+		result.Rows = &rows
+	}
+	result.Objects = s.sessionObjects()
+	log.Printf("Created temporary %s %s for session %s", kind, name, id)
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling temporary table info to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting temporary table info", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// dropTempTableHandler drops a temporary table or view of the caller's
+// session, releasing the session's connection with its last object.
+func (ds *DatabaseService) dropTempTableHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, _ := request.GetArguments()["name"].(string)
+	if name == "" {
+		return mcp.NewToolResultError("Missing 'name' argument."), nil
+	}
+	id := sessionID(ctx)
+	s, err := ds.temp.acquire(ctx, id, false)
+	if err != nil || s == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("This session has no temporary table or view named %s.", name)), nil
+	}
+	defer ds.temp.release(id, s)
+	kind, ok := s.objects[name]
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("This session has no temporary table or view named %s.", name)), nil
+	}
+
+	stmt := fmt.Sprintf("DROP %s temp.%s", kind, ds.dialect.QuoteIdent(name))
+	if err := execTemp(ctx, s, stmt); err != nil {
+		log.Printf("Error dropping temporary %s %s: %v", kind, name, err)
+		return mcp.NewToolResultErrorFromErr("Error dropping temporary "+kind, err), nil
+	}
+	delete(s.objects, name)
+
+	resultJSON, err := json.MarshalIndent(TempObject{Name: name, Kind: kind, Objects: s.sessionObjects()}, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling temporary table info to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting temporary table info", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Token scopes, from narrowest to widest.
+const (
+	scopeSchema = "schema" // Schema browsing only, for planning agents
+	scopeRead   = "read"   // Every read-only tool, within the caller's profile
+	scopeAdmin  = "admin"  // Every tool, with the admin profile
+)
+
+// schemaTools are the tools a schema-scoped token may call. Besides the
+// versions migration_status reads from migration tables, only the sample
+// values of describe_table are table data, and describe_table leaves them
+// out for schema tokens.
+var schemaTools = map[string]bool{
+	"list_tables": true, "describe_table": true, "list_schemas": true, "get_table_ddl": true,
+	"schema_summary": true, "schema_vocabulary": true, "database_info": true, "migration_status": true,
+	"validate_schema": true, "sql_capabilities": true, "validate_query": true, "format_sql": true,
+	"health": true,
+}
+
+// scopeKey is a context key for the scope of the request's token.
+type scopeKey struct{}
+
+// scopeFromContext returns the scope of the token the request was made
+// with, or "" without token authentication.
+func scopeFromContext(ctx context.Context) string {
+	scope, _ := ctx.Value(scopeKey{}).(string)
+	return scope
+}
+
+// scopeAllows reports whether scope permits tool.
+func scopeAllows(scope, tool string) bool {
+	switch scope {
+	case scopeSchema:
+		return schemaTools[tool]
+	case scopeRead:
+		return !writeTools[tool] && !adminTools[tool]
+	}
+	return true
+}
+
+// apiToken is a bearer token with a name, which identifies its holder in
+// place of the proxy identity header, and a scope.
+type apiToken struct {
+	Name  string
+	Scope string
+	value []byte
+}
+
+// Tokens authenticates HTTP requests by bearer token.
+type Tokens []apiToken
+
+// parseTokens parses AUTH_TOKENS, a list of name=scope:token entries such
+// as planner=schema:6f1c… separated by commas or newlines.
+func parseTokens(list string) (Tokens, error) {
+	var tokens Tokens
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, rest, ok := strings.Cut(entry, "=")
+		scope, value, ok2 := strings.Cut(rest, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || !ok2 || name == "" || value == "" {
+			return nil, fmt.Errorf("invalid AUTH_TOKENS entry for %q, expected name=scope:token", name)
+		}
+		if scope != scopeSchema && scope != scopeRead && scope != scopeAdmin {
+			return nil, fmt.Errorf("invalid scope %q for token %s (want %s, %s or %s)", scope, name, scopeSchema, scopeRead, scopeAdmin)
+		}
+		if len(value) < 16 {
+			return nil, fmt.Errorf("token %s is shorter than 16 characters", name)
+		}
+		tokens = append(tokens, apiToken{Name: name, Scope: scope, value: []byte(value)})
+	}
+	return tokens, nil
+}
+
+// lookup returns the token matching value, comparing every token in
+// constant time.
+func (t Tokens) lookup(value string) *apiToken {
+	var found *apiToken
+	for i := range t {
+		if subtle.ConstantTimeCompare(t[i].value, []byte(value)) == 1 {
+			found = &t[i]
+		}
+	}
+	return found
+}
+
+// require answers 401 Unauthorized to requests without a valid bearer token
+// and stores the token's scope and name in the request context. Without
+// tokens configured every request passes.
+func (t Tokens) require(next http.Handler) http.Handler {
+	if len(t) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		token := t.lookup(strings.TrimSpace(value))
+		if !ok || token == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="db-mcp"`)
+			http.Error(w, "Missing or invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		ctx := context.WithValue(r.Context(), scopeKey{}, token.Scope)
+		ctx = context.WithValue(ctx, principalKey{}, token.Name)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// scopeMiddleware refuses tools outside the scope of the caller's token.
+// describe_table stays within the schema scope by leaving out sample values.
+func scopeMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		scope := scopeFromContext(ctx)
+		if !scopeAllows(scope, request.Params.Name) {
+			log.Printf("Rejected %s call: not allowed for %s token", request.Params.Name, scope)
+			payload, _ := json.MarshalIndent(map[string]string{
+				"error":   "tool_not_allowed",
+				"message": fmt.Sprintf("The %s tool is not available with a %s token.", request.Params.Name, scope),
+				"hint":    "Use the tools returned by tools/list, or a token with a wider scope.",
+			}, "", "  ")
+			return mcp.NewToolResultError(string(payload)), nil
+		}
+		return next(ctx, request)
+	}
+}
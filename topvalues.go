@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Limits of the number of values returned by top_values.
+const (
+	defaultTopValues = 10
+	maxTopValues     = 1000
+)
+
+// ValueCount is one value of a column with its frequency.
+type ValueCount struct {
+	Value   interface{} `json:"value"`
+	Count   int64       `json:"count"`
+	Percent float64     `json:"percent"`
+}
+
+// TopValues is the result of top_values. OtherCount covers every row whose
+// value is not listed, so the counts always add up to Rows.
+type TopValues struct {
+	Column         string       `json:"column"`
+	Rows           int64        `json:"rows"`
+	DistinctValues int64        `json:"distinct_values"`
+	Values         []ValueCount `json:"values"`
+	OtherCount     int64        `json:"other_count"`
+}
+
+// topValuesHandler returns the most frequent values of a column.
+func (ds *DatabaseService) topValuesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	source, err := ds.sourceRelation(ctx, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	where, err := ds.filterClause(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	column, _ := args["column"].(string)
+	if column == "" {
+		return mcp.NewToolResultError("Missing or invalid 'column' argument."), nil
+	}
+	resolved, err := ds.resolveColumns(ctx, source, column)
+	if err != nil {
+		return columnErrorResult(err), nil
+	}
+	column = resolved[0]
+	limit := defaultTopValues
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(math.Min(v, maxTopValues))
+	}
+
+	base := fmt.Sprintf("SELECT %s AS v FROM %s%s", quoteIdentifier(column), source, where)
+	result := TopValues{Column: column, Values: []ValueCount{}}
+
+	// NULL counts as a distinct value of its own, as it does in GROUP BY
+	var nulls int64
+	totals := fmt.Sprintf("SELECT COUNT(*), COUNT(DISTINCT v), COUNT(*) - COUNT(v) FROM (%s)", base)
+	if err := ds.db.QueryRowContext(ctx, totals).Scan(&result.Rows, &result.DistinctValues, &nulls); err != nil {
+		log.Printf("Error counting values: %v, Query: %s", err, logSQL(totals))
+		if res := ds.limitsFor(ctx).budgetError(ctx, err); res != nil {
+			return res, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error counting values", err), nil
+	}
+	if nulls > 0 {
+		result.DistinctValues++
+	}
+
+	query := fmt.Sprintf("SELECT v, COUNT(*) AS c FROM (%s) GROUP BY v ORDER BY c DESC, v LIMIT %d", base, limit)
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("Error executing top values query: %v, Query: %s", err, logSQL(query))
+		if res := ds.limitsFor(ctx).budgetError(ctx, err); res != nil {
+			return res, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error executing top values query", err), nil
+	}
+	defer rows.Close()
+
+	// Masked values are counted as they are, then masked: hash and fake values
+	// keep distinct values apart
+	masks := masksFor(ctx, []string{column})
+	listed := int64(0)
+	for rows.Next() {
+		var value interface{}
+		var count int64
+		if err := rows.Scan(&value, &count); err != nil {
+			return mcp.NewToolResultErrorFromErr("Error reading value counts", err), nil
+		}
+		listed += count
+		result.Values = append(result.Values, ValueCount{
+			Value:   masks.apply(0, normalizeValue(value, "")),
+			Count:   count,
+			Percent: math.Round(float64(count)*10000/float64(result.Rows)) / 100,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating value counts: %v", err)
+		if res := ds.limitsFor(ctx).budgetError(ctx, err); res != nil {
+			return res, nil
+		}
+		return mcp.NewToolResultErrorFromErr("Error iterating value counts", err), nil
+	}
+	result.OtherCount = result.Rows - listed
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling top values to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting top values", err), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// trinoDialect describes the catalogs of a Trino (or Presto) cluster. Schemas
+// are named catalog.schema, so list_tables can be scoped to any catalog the
+// cluster federates; unqualified names use the session catalog and schema
+// from the DSN. Trino connectors do not report keys or indexes.
+type trinoDialect struct {
+	db *sql.DB
+}
+
+func newTrinoDialect(db *sql.DB, dsn string) Dialect {
+	return trinoDialect{db: db}
+}
+
+// trinoInternalCatalogs are left out of list_schemas.
+var trinoInternalCatalogs = map[string]bool{"system": true}
+
+// infoSchema returns the information_schema of the catalog of schema and the
+// condition selecting schema in it. An empty schema is the session's.
+func (d trinoDialect) infoSchema(schema string) (view, condition string, args []interface{}) {
+	catalog, name, ok := strings.Cut(schema, ".")
+	switch {
+	case schema == "":
+		return "information_schema", "table_schema = current_schema", nil
+	case ok:
+		return d.QuoteIdent(catalog) + ".information_schema", "table_schema = ?", []interface{}{name}
+	default:
+		return "information_schema", "table_schema = ?", []interface{}{schema}
+	}
+}
+
+// ListSchemas lists catalog.schema for the schemas with tables in every
+// catalog. Catalogs whose connector fails to list its tables are left out,
+// so one unavailable source does not hide the others.
+func (d trinoDialect) ListSchemas(ctx context.Context) ([]SchemaInfo, error) {
+	catalogs, err := d.queryStrings(ctx, "SHOW CATALOGS")
+	if err != nil {
+		return nil, err
+	}
+	var schemas []SchemaInfo
+	for _, catalog := range catalogs {
+		if trinoInternalCatalogs[catalog] {
+			continue
+		}
+		rows, err := d.db.QueryContext(ctx, fmt.Sprintf(`SELECT table_schema, COUNT(*) FROM %s.information_schema.tables
+			WHERE table_type = 'BASE TABLE' AND table_schema <> 'information_schema'
+			GROUP BY table_schema ORDER BY table_schema`, d.QuoteIdent(catalog)))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		for rows.Next() {
+			var s SchemaInfo
+			if err := rows.Scan(&s.Name, &s.Tables); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			s.Name = catalog + "." + s.Name
+			schemas = append(schemas, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return schemas, nil
+}
+
+func (d trinoDialect) ListTables(ctx context.Context, schema string, includeViews bool) ([]string, error) {
+	view, condition, args := d.infoSchema(schema)
+	types := "table_type = 'BASE TABLE'"
+	if includeViews {
+		types = "table_type IN ('BASE TABLE', 'VIEW')"
+	}
+	return d.queryStrings(ctx, fmt.Sprintf("SELECT table_name FROM %s.tables WHERE %s AND %s ORDER BY table_name", view, condition, types), args...)
+}
+
+func (d trinoDialect) DescribeTable(ctx context.Context, table string) (*TableDescription, error) {
+	schema, name := d.SplitTable(table)
+	view, condition, args := d.infoSchema(schema)
+	rows, err := d.db.QueryContext(ctx, fmt.Sprintf(`SELECT column_name, data_type, is_nullable, column_default
+		FROM %s.columns WHERE %s AND table_name = ? ORDER BY ordinal_position`, view, condition), append(args, name)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	desc := &TableDescription{
+		Table:             table,
+		Columns:           []map[string]interface{}{},
+		ForeignKeys:       []ForeignKeyInfo{},
+		Indexes:           []IndexInfo{},
+		UniqueConstraints: [][]string{},
+		CheckConstraints:  []string{},
+	}
+	for rows.Next() {
+		var column, dataType, nullable string
+		var dflt sql.NullString
+		if err := rows.Scan(&column, &dataType, &nullable, &dflt); err != nil {
+			return nil, err
+		}
+		var dfltValue interface{}
+		if dflt.Valid {
+			dfltValue = dflt.String
+		}
+		notNull := 0
+		if nullable == "NO" {
+			notNull = 1
+		}
+		desc.Columns = append(desc.Columns, map[string]interface{}{
+			"cid": len(desc.Columns), "name": column, "type": dataType, "notnull": notNull, "dflt_value": dfltValue, "pk": 0,
+		})
+	}
+	return desc, rows.Err()
+}
+
+func (d trinoDialect) ListIndexes(ctx context.Context, table string) ([]IndexInfo, error) {
+	return []IndexInfo{}, nil
+}
+
+func (d trinoDialect) ForeignKeys(ctx context.Context, table string) ([]Relationship, error) {
+	return nil, nil
+}
+
+func (d trinoDialect) PrimaryKey(ctx context.Context, table string) ([]string, error) {
+	return nil, nil
+}
+
+// SplitTable treats everything before the last dot as the schema, which may
+// be catalog-qualified (catalog.schema.table).
+func (d trinoDialect) SplitTable(table string) (schema, name string) {
+	if i := strings.LastIndexByte(table, '.'); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+// QuoteIdent quotes each part of a dotted name, so catalog.schema schemas
+// quote as two identifiers.
+func (d trinoDialect) QuoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// ValidateReadOnly accepts the Trino statements that only read: queries
+// (SELECT, WITH, VALUES, TABLE), SHOW, DESCRIBE and EXPLAIN of one of them.
+// EXPLAIN ANALYZE runs its statement, so the explained statement is checked
+// too. Session statements (USE, SET SESSION) are refused since connections
+// are pooled.
+func (d trinoDialect) ValidateReadOnly(query string) error {
+	fields := strings.Fields(strings.ToUpper(query))
+	if len(fields) > 0 {
+		switch fields[0] {
+		case "SELECT", "WITH", "VALUES", "TABLE", "SHOW", "DESCRIBE":
+			return nil
+		case "EXPLAIN":
+			return d.ValidateReadOnly(trinoExplained(query))
+		}
+	}
+	return fmt.Errorf("only SELECT, WITH, VALUES, TABLE, SHOW, DESCRIBE and EXPLAIN statements are allowed for read-only access")
+}
+
+// trinoExplained returns the statement of EXPLAIN [(options)] [ANALYZE [VERBOSE]] statement.
+func trinoExplained(query string) string {
+	rest := strings.TrimSpace(query)
+	rest = strings.TrimSpace(rest[len("EXPLAIN"):])
+	if strings.HasPrefix(rest, "(") {
+		if end := strings.IndexByte(rest, ')'); end >= 0 {
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+	}
+	for _, keyword := range []string{"ANALYZE", "VERBOSE"} {
+		if fields := strings.Fields(rest); len(fields) > 0 && strings.EqualFold(fields[0], keyword) {
+			rest = strings.TrimSpace(rest[len(keyword):])
+		}
+	}
+	return rest
+}
+
+func (d trinoDialect) queryStrings(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	return infoSchemaDialect{db: d.db}.queryStrings(ctx, query, args...)
+}
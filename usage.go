@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	maxUsageEntries    = 1000 // Tables, failure patterns and result patterns each
+	defaultUsageTop    = 10   // Entries per section usage_report returns by default
+	maxUsageTop        = 100
+	maxPatternLength   = 500
+	bytesPerTokenGuess = 4 // Rough size of a token of JSON or text results
+)
+
+// UsageAnalytics tracks which tables and columns are read, by whom and how
+// often, which query patterns fail and which return the largest results,
+// so operators can see what agents need and curate views for them.
+type UsageAnalytics struct {
+	mu       sync.Mutex
+	started  time.Time
+	tables   map[string]*tableUsage
+	failures map[string]*failureUsage // By code, tool and pattern
+	results  map[string]*resultUsage  // By tool and pattern
+}
+
+type tableUsage struct {
+	calls      int64
+	principals map[string]int64
+	columns    map[string]int64
+	lastUsed   time.Time
+}
+
+type failureUsage struct {
+	code, tool, pattern, example string
+	count                        int64
+	lastSeen                     time.Time
+}
+
+type resultUsage struct {
+	tool, pattern   string
+	calls, rows     int64
+	bytes, maxBytes int64
+	lastSeen        time.Time
+}
+
+func NewUsageAnalytics() *UsageAnalytics {
+	return &UsageAnalytics{
+		started:  time.Now().UTC(),
+		tables:   make(map[string]*tableUsage),
+		failures: make(map[string]*failureUsage),
+		results:  make(map[string]*resultUsage),
+	}
+}
+
+// numberPattern matches numeric literals that are not part of identifiers.
+var numberPattern = regexp.MustCompile(`\b\d+(\.\d+)?\b`)
+
+// queryPattern reduces a query to its shape: string and numeric literals
+// replaced by ?, whitespace collapsed, so calls differing only in values
+// are counted together.
+func queryPattern(query string) string {
+	pattern := numberPattern.ReplaceAllString(scrubSQL(query, literalsStrip), "?")
+	pattern = strings.ReplaceAll(strings.Join(strings.Fields(pattern), " "), "'?'", "?")
+	if len(pattern) > maxPatternLength {
+		pattern = pattern[:maxPatternLength] + "…"
+	}
+	return pattern
+}
+
+// callPattern returns the pattern of the SQL a call runs, or "" for tools
+// that take none.
+func callPattern(args map[string]interface{}) string {
+	var patterns []string
+	for _, name := range queryArguments {
+		values, ok := args[name].([]interface{})
+		if !ok {
+			values = []interface{}{args[name]}
+		}
+		for _, v := range values {
+			if query, _ := v.(string); query != "" {
+				patterns = append(patterns, queryPattern(query))
+			}
+		}
+	}
+	return strings.Join(patterns, "; ")
+}
+
+// middleware records the tables and columns each call read, and its
+// failure or result size. It runs inside the audit log middleware, whose
+// auditCall collects the tables, and outside the error code middleware.
+func (u *UsageAnalytics) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		principal := principalFromContext(ctx)
+		if principal == "" {
+			principal = "anonymous"
+		}
+		var rows int64
+		if counter, ok := ctx.Value(callRowsKey{}).(*atomic.Int64); ok {
+			rows = counter.Load()
+		}
+		u.record(request, principal, auditCallFromContext(ctx), result, err, rows)
+		return result, err
+	}
+}
+
+func (u *UsageAnalytics) record(request mcp.CallToolRequest, principal string, call *auditCall, result *mcp.CallToolResult, err error, rows int64) {
+	tool := request.Params.Name
+	pattern := callPattern(request.GetArguments())
+	now := time.Now().UTC()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if call != nil {
+		for _, table := range call.tables {
+			t, ok := u.tables[table]
+			if !ok {
+				evictUsage(u.tables, func(t *tableUsage) time.Time { return t.lastUsed })
+				t = &tableUsage{principals: make(map[string]int64), columns: make(map[string]int64)}
+				u.tables[table] = t
+			}
+			t.calls++
+			t.principals[principal]++
+			for column := range call.columns[table] {
+				t.columns[column]++
+			}
+			t.lastUsed = now
+		}
+	}
+
+	if err != nil || result != nil && result.IsError {
+		code, example := codeInternal, ""
+		if err != nil {
+			example = err.Error()
+		} else {
+			code, _ = errorCode(result)
+			example = resultMessage(result)
+		}
+		key := code + "\x00" + tool + "\x00" + pattern
+		f, ok := u.failures[key]
+		if !ok {
+			evictUsage(u.failures, func(f *failureUsage) time.Time { return f.lastSeen })
+			f = &failureUsage{code: code, tool: tool, pattern: pattern}
+			u.failures[key] = f
+		}
+		f.count++
+		f.example = queryPattern(example)
+		f.lastSeen = now
+		return
+	}
+
+	var size int64
+	if result != nil {
+		for _, content := range result.Content {
+			if text, ok := content.(mcp.TextContent); ok {
+				size += int64(len(text.Text))
+			}
+		}
+	}
+	key := tool + "\x00" + pattern
+	r, ok := u.results[key]
+	if !ok {
+		evictUsage(u.results, func(r *resultUsage) time.Time { return r.lastSeen })
+		r = &resultUsage{tool: tool, pattern: pattern}
+		u.results[key] = r
+	}
+	r.calls++
+	r.rows += rows
+	r.bytes += size
+	r.maxBytes = max(r.maxBytes, size)
+	r.lastSeen = now
+}
+
+// evictUsage drops the least recently seen tenth of entries once the map
+// is full.
+func evictUsage[T any](entries map[string]T, seen func(T) time.Time) {
+	if len(entries) < maxUsageEntries {
+		return
+	}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return seen(entries[keys[i]]).Before(seen(entries[keys[j]])) })
+	for _, key := range keys[:len(keys)/10+1] {
+		delete(entries, key)
+	}
+}
+
+// topCounts returns the n largest counts of m, largest first.
+func topCounts(m map[string]int64, n int, label string) []map[string]interface{} {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]] != m[keys[j]] {
+			return m[keys[i]] > m[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	top := make([]map[string]interface{}, 0, min(n, len(keys)))
+	for _, key := range keys[:min(n, len(keys))] {
+		top = append(top, map[string]interface{}{label: key, "calls": m[key]})
+	}
+	return top
+}
+
+// usageReportHandler summarizes the hottest tables with their readers and
+// columns, the most frequent failure patterns and the patterns returning
+// the most data. Only administrators may call it, since it names callers.
+func (u *UsageAnalytics) usageReportHandler(identity *Identity) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !identity.isAdmin(ctx) {
+			return mcp.NewToolResultError("usage_report requires an administrator (see ADMIN_USERS)."), nil
+		}
+		top := defaultUsageTop
+		if v, ok := request.GetArguments()["top"].(float64); ok && v > 0 {
+			top = min(int(v), maxUsageTop)
+		}
+
+		u.mu.Lock()
+		tableNames := make([]string, 0, len(u.tables))
+		for name := range u.tables {
+			tableNames = append(tableNames, name)
+		}
+		sort.Slice(tableNames, func(i, j int) bool { return u.tables[tableNames[i]].calls > u.tables[tableNames[j]].calls })
+		hotTables := []map[string]interface{}{}
+		for _, name := range tableNames[:min(top, len(tableNames))] {
+			t := u.tables[name]
+			hotTables = append(hotTables, map[string]interface{}{
+				"table":      name,
+				"calls":      t.calls,
+				"principals": topCounts(t.principals, top, "principal"),
+				"columns":    topCounts(t.columns, top, "column"),
+				"last_used":  t.lastUsed,
+			})
+		}
+
+		failures := make([]*failureUsage, 0, len(u.failures))
+		for _, f := range u.failures {
+			failures = append(failures, f)
+		}
+		sort.Slice(failures, func(i, j int) bool { return failures[i].count > failures[j].count })
+		failedPatterns := []map[string]interface{}{}
+		for _, f := range failures[:min(top, len(failures))] {
+			failedPatterns = append(failedPatterns, map[string]interface{}{
+				"code":      f.code,
+				"tool":      f.tool,
+				"pattern":   f.pattern,
+				"count":     f.count,
+				"example":   f.example,
+				"last_seen": f.lastSeen,
+			})
+		}
+
+		results := make([]*resultUsage, 0, len(u.results))
+		for _, r := range u.results {
+			results = append(results, r)
+		}
+		sort.Slice(results, func(i, j int) bool { return results[i].bytes > results[j].bytes })
+		largeResults := []map[string]interface{}{}
+		for _, r := range results[:min(top, len(results))] {
+			largeResults = append(largeResults, map[string]interface{}{
+				"tool":             r.tool,
+				"pattern":          r.pattern,
+				"calls":            r.calls,
+				"avg_rows":         r.rows / r.calls,
+				"avg_bytes":        r.bytes / r.calls,
+				"max_bytes":        r.maxBytes,
+				"total_tokens_est": r.bytes / bytesPerTokenGuess,
+				"last_seen":        r.lastSeen,
+			})
+		}
+		report := map[string]interface{}{
+			"since":           u.started,
+			"hot_tables":      hotTables,
+			"failed_patterns": failedPatterns,
+			"largest_results": largeResults,
+		}
+		u.mu.Unlock()
+
+		resultJSON, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Printf("Error marshalling usage report to JSON: %v", err)
+			return mcp.NewToolResultErrorFromErr("Error formatting usage report", err), nil
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	}
+}
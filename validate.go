@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// QueryValidation is the payload returned by validate_query.
+type QueryValidation struct {
+	Valid          bool         `json:"valid"`
+	Error          string       `json:"error,omitempty"`
+	Parameters     *int         `json:"parameters,omitempty"`      // Placeholders to bind; absent when the driver cannot tell
+	ParameterNames []string     `json:"parameter_names,omitempty"` // Names of named placeholders such as :id
+	Columns        []ColumnInfo `json:"columns,omitempty"`         // Result columns, SQLite only
+}
+
+// validateQueryHandler compiles a query without running it and reports
+// whether it is valid, its placeholders and, for SQLite, its result columns.
+func (ds *DatabaseService) validateQueryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, _ := request.GetArguments()["query"].(string)
+	if query == "" {
+		return mcp.NewToolResultError("Missing or invalid 'query' argument."), nil
+	}
+
+	validation := QueryValidation{}
+	var err error
+	if err = ds.validateReadOnly(query); err == nil {
+		if _, ok := ds.dialect.(sqliteDialect); ok {
+			err = ds.validateSQLiteQuery(ctx, query, &validation)
+		} else {
+			err = ds.prepareQuery(ctx, query, &validation)
+		}
+	}
+	if err != nil {
+		if result := ds.limitsFor(ctx).budgetError(ctx, err); result != nil {
+			return result, nil
+		}
+		validation.Error = err.Error()
+	}
+	validation.Valid = err == nil
+
+	resultJSON, err := json.MarshalIndent(validation, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling query validation to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting query validation", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// prepareQuery prepares query on a connection, which reports syntax errors
+// and unknown tables for drivers that prepare statements on the server.
+func (ds *DatabaseService) prepareQuery(ctx context.Context, query string, validation *QueryValidation) error {
+	conn, err := ds.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(driverConn any) error {
+		var stmt driver.Stmt
+		var err error
+		if preparer, ok := driverConn.(driver.ConnPrepareContext); ok {
+			stmt, err = preparer.PrepareContext(ctx, query)
+		} else {
+			stmt, err = driverConn.(driver.Conn).Prepare(query)
+		}
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if n := stmt.NumInput(); n >= 0 {
+			validation.Parameters = &n
+		}
+		return nil
+	})
+}
+
+// validateSQLiteQuery compiles query with EXPLAIN, which finds its
+// placeholders without running it, then reads its result columns from a
+// wrapper that returns no rows. SQLite suffixes duplicate column names in
+// the wrapper, e.g. id:1.
+func (ds *DatabaseService) validateSQLiteQuery(ctx context.Context, query string, validation *QueryValidation) error {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	rows, err := ds.db.QueryContext(ctx, "EXPLAIN "+query)
+	if err != nil {
+		return err
+	}
+	// Each placeholder is loaded by a Variable opcode with its index in p1
+	// and, for named placeholders, its name in p4
+	names := make(map[int64]string)
+	var count int64
+	for rows.Next() {
+		var addr, p1, p2, p3, p5 int64
+		var opcode string
+		var p4, comment sql.NullString
+		if err := rows.Scan(&addr, &opcode, &p1, &p2, &p3, &p4, &p5, &comment); err != nil {
+			rows.Close()
+			return err
+		}
+		if opcode != "Variable" {
+			continue
+		}
+		count = max(count, p1)
+		if name := p4.String; name != "" && strings.ContainsRune(":@$", rune(name[0])) {
+			names[p1] = name
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	n := int(count)
+	validation.Parameters = &n
+	args := make([]interface{}, n)
+	for i := range args {
+		if name, ok := names[int64(i+1)]; ok {
+			validation.ParameterNames = append(validation.ParameterNames, name)
+			args[i] = sql.Named(name[1:], nil)
+		}
+	}
+
+	columnRows, err := ds.db.QueryContext(ctx, "SELECT * FROM ("+query+"\n) LIMIT 0", args...)
+	if err != nil {
+		return err
+	}
+	defer columnRows.Close()
+	columnTypes, err := columnRows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	validation.Columns = make([]ColumnInfo, len(columnTypes))
+	for i, ct := range columnTypes {
+		validation.Columns[i] = ColumnInfo{Name: ct.Name(), Type: ct.DatabaseTypeName()}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// SchemaVocabulary is the payload returned by schema_vocabulary. Each table
+// or view maps to its columns in order, written "name TYPE", with " PK" for
+// primary key columns and " -> table.column" for foreign keys. Tables outside
+// main are qualified with their schema.
+type SchemaVocabulary struct {
+	Tables map[string][]string `json:"tables"`
+	Views  map[string][]string `json:"views"`
+}
+
+// schemaVocabularyHandler returns every table, view and column of the
+// database in one compact listing, for grounding generated SQL.
+func (ds *DatabaseService) schemaVocabularyHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if _, ok := ds.dialect.(sqliteDialect); !ok {
+		return mcp.NewToolResultError("schema_vocabulary is only supported for SQLite databases."), nil
+	}
+	schemas, err := ds.dialect.ListSchemas(ctx)
+	if err != nil {
+		log.Printf("Error listing schemas: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error listing schemas", err), nil
+	}
+	vocabulary := SchemaVocabulary{Tables: make(map[string][]string), Views: make(map[string][]string)}
+	for _, schema := range schemas {
+		if err := ds.addSchemaVocabulary(ctx, schema.Name, &vocabulary); err != nil {
+			log.Printf("Error reading columns of schema %s: %v", schema.Name, err)
+			return mcp.NewToolResultErrorFromErr("Error reading columns", err), nil
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(vocabulary, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling vocabulary to JSON: %v", err)
+		return mcp.NewToolResultErrorFromErr("Error formatting vocabulary", err), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// addSchemaVocabulary adds the tables and views of one schema.
+func (ds *DatabaseService) addSchemaVocabulary(ctx context.Context, schema string, vocabulary *SchemaVocabulary) error {
+	quoted := ds.dialect.QuoteIdent(schema)
+	qualify := func(name string) string {
+		if schema == "main" {
+			return name
+		}
+		return schema + "." + name
+	}
+
+	references := make(map[[2]string]string) // Table and column to referenced column
+	rows, err := ds.db.QueryContext(ctx, fmt.Sprintf(`SELECT m.name, f."from", f."table", f."to"
+		FROM %s.sqlite_schema AS m JOIN pragma_foreign_key_list(m.name, ?) AS f
+		WHERE m.type = 'table'`, quoted), schema)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var table, from, parent string
+		var to sql.NullString
+		if err := rows.Scan(&table, &from, &parent, &to); err != nil {
+			rows.Close()
+			return err
+		}
+		// Without a column the reference is to the primary key of the parent
+		references[[2]string{table, from}] = qualify(parent)
+		if to.Valid {
+			references[[2]string{table, from}] += "." + to.String
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = ds.db.QueryContext(ctx, fmt.Sprintf(`SELECT m.type, m.name, p.name, p.type, p.pk
+		FROM %s.sqlite_schema AS m JOIN pragma_table_info(m.name, ?) AS p
+		WHERE m.type IN ('table', 'view') AND m.name NOT LIKE 'sqlite_%%'
+		ORDER BY m.name, p.cid`, quoted), schema)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kind, table, column, colType string
+		var pk int
+		if err := rows.Scan(&kind, &table, &column, &colType, &pk); err != nil {
+			return err
+		}
+		entry := column
+		if colType != "" {
+			entry += " " + colType
+		}
+		if pk > 0 {
+			entry += " PK"
+		}
+		if ref, ok := references[[2]string{table, column}]; ok {
+			entry += " -> " + ref
+		}
+		if kind == "view" {
+			vocabulary.Views[qualify(table)] = append(vocabulary.Views[qualify(table)], entry)
+		} else {
+			vocabulary.Tables[qualify(table)] = append(vocabulary.Tables[qualify(table)], entry)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Spreadsheet limits imposed by Excel.
+const (
+	xlsxMaxRows      = 1048576
+	xlsxMaxCellChars = 32767
+)
+
+// Static parts of a workbook package. Only the sheet list varies.
+const (
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs></styleSheet>`
+	xlsxSheetHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><sheetData>`
+	xlsxSheetTail = `</sheetData></worksheet>`
+)
+
+// writeWorkbook runs each query and writes its result to its own sheet of an
+// XLSX workbook. It returns the total number of rows written.
+func (ds *DatabaseService) writeWorkbook(ctx context.Context, w io.Writer, queries []string) (int, error) {
+	zw := zip.NewWriter(w)
+	names := make([]string, len(queries))
+	total := 0
+	for i, query := range queries {
+		names[i] = fmt.Sprintf("Query %d", i+1)
+		if len(queries) == 1 {
+			names[i] = "Result"
+		}
+		sheet, err := zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return total, err
+		}
+		n, err := ds.writeSheet(ctx, sheet, query)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("query %d: %w", i+1, err)
+		}
+	}
+
+	var contentTypes, workbook, workbookRels strings.Builder
+	contentTypes.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	workbookRels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, name := range names {
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, name, i+1, i+1)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(names)+1)
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	workbookRels.WriteString(`</Relationships>`)
+
+	for _, part := range []struct{ name, body string }{
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.String()},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		fw, err := zw.Create(part.name)
+		if err != nil {
+			return total, err
+		}
+		if _, err := io.WriteString(fw, part.body); err != nil {
+			return total, err
+		}
+	}
+	return total, zw.Close()
+}
+
+// writeSheet writes the result of query as worksheet XML with a bold header row.
+func (ds *DatabaseService) writeSheet(ctx context.Context, w io.Writer, query string) (int, error) {
+	rows, err := ds.db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xlsxSheetHead)
+	bw.WriteString(`<row r="1">`)
+	for i, col := range columns {
+		writeXLSXCell(bw, i, 1, col, true)
+	}
+	bw.WriteString(`</row>`)
+
+	masks := masksFor(ctx, columns)
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	maxRows := ds.limitsFor(ctx).MaxRows
+	count := 0
+	for rows.Next() {
+		if maxRows > 0 && count >= maxRows {
+			return count, errRowBudgetExceeded
+		}
+		if count+1 >= xlsxMaxRows {
+			return count, fmt.Errorf("result exceeds the %d rows an Excel sheet can hold", xlsxMaxRows-1)
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return count, err
+		}
+		count++
+		addRowProgress(ctx)
+
+		fmt.Fprintf(bw, `<row r="%d">`, count+1)
+		for i := range columns {
+			writeXLSXCell(bw, i, count+1, masks.apply(i, normalizeValue(values[i], columnTypes[i].DatabaseTypeName())), false)
+		}
+		bw.WriteString(`</row>`)
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	bw.WriteString(xlsxSheetTail)
+	return count, bw.Flush()
+}
+
+// writeXLSXCell writes one cell. Numbers and booleans keep their type; everything
+// else, including exact decimal strings, is written as inline text.
+func writeXLSXCell(w *bufio.Writer, col, row int, val interface{}, header bool) {
+	ref := xlsxColumnName(col) + strconv.Itoa(row)
+	style := ""
+	if header {
+		style = ` s="1"`
+	}
+
+	switch v := val.(type) {
+	case nil:
+		return
+	case int64:
+		fmt.Fprintf(w, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+		return
+	case float64:
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			fmt.Fprintf(w, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'g', -1, 64))
+			return
+		}
+	case bool:
+		b := 0
+		if v {
+			b = 1
+		}
+		fmt.Fprintf(w, `<c r="%s"%s t="b"><v>%d</v></c>`, ref, style, b)
+		return
+	}
+
+	text := fmt.Sprint(val)
+	if len(text) > xlsxMaxCellChars {
+		text = text[:xlsxMaxCellChars]
+	}
+	fmt.Fprintf(w, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+	xml.EscapeText(w, []byte(text))
+	w.WriteString(`</t></is></c>`)
+}
+
+// xlsxColumnName converts a zero-based column index to its letters (0 → A, 26 → AA).
+func xlsxColumnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}